@@ -0,0 +1,277 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/nf-go/nes"
+)
+
+func health(ctx context.Context, oper nes.ESOper, args []string) error {
+	fs := flag.NewFlagSet("health", flag.ExitOnError)
+	_ = fs.Parse(args)
+	api := oper.ESClient()
+	resp, err := api.Cluster.Health(api.Cluster.Health.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return errors.New(resp.String())
+	}
+	var m map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return err
+	}
+	return printJSON(m)
+}
+
+func search(ctx context.Context, oper nes.ESOper, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	index := fs.String("index", "", "the comma separated indices")
+	queryFile := fs.String("query", "", "the file of the request body, - for the stdin")
+	_ = fs.Parse(args)
+	if *index == "" || *queryFile == "" {
+		return errors.New("search: -index and -query are required")
+	}
+	query, err := readFile(*queryFile)
+	if err != nil {
+		return err
+	}
+	var result map[string]interface{}
+	if _, err := oper.Search(ctx, &result, string(query), strings.Split(*index, ",")); err != nil {
+		return err
+	}
+	return printJSON(result)
+}
+
+func bulk(ctx context.Context, oper nes.ESOper, args []string) error {
+	fs := flag.NewFlagSet("bulk", flag.ExitOnError)
+	index := fs.String("index", "", "the index the documents are imported into")
+	file := fs.String("file", "", "the NDJSON file of the documents, one document per line, - for the stdin")
+	idField := fs.String("id-field", "", "the field of the documents used as the ids, the ids are generated if empty")
+	workers := fs.Int("workers", 2, "the number of the workers of the bulk indexer")
+	_ = fs.Parse(args)
+	if *index == "" || *file == "" {
+		return errors.New("bulk: -index and -file are required")
+	}
+
+	f := os.Stdin
+	if *file != "-" {
+		var err error
+		if f, err = os.Open(*file); err != nil {
+			return err
+		}
+		defer f.Close()
+	}
+	bi, err := oper.NewBulkIndexer(nes.BulkIndexerConfig{Index: *index, NumWorkers: *workers})
+	if err != nil {
+		return err
+	}
+	var failed int64
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		doc := bytes.TrimSpace(scanner.Bytes())
+		if len(doc) == 0 {
+			continue
+		}
+		item := nes.BulkIndexerItem{
+			Action: "index",
+			Body:   bytes.NewReader(append([]byte(nil), doc...)),
+			OnFailure: func(ctx context.Context, item nes.BulkIndexerItem, res nes.BulkIndexerResponseItem, err error) {
+				atomic.AddInt64(&failed, 1)
+				if err == nil {
+					err = fmt.Errorf("%s: %s", res.Error.Type, res.Error.Reason)
+				}
+				fmt.Fprintf(os.Stderr, "nescli: fail to import the document %s: %s\n", item.DocumentID, err)
+			},
+		}
+		if *idField != "" {
+			if item.DocumentID, err = documentID(doc, *idField); err != nil {
+				return fmt.Errorf("bulk: the line %d: %w", line, err)
+			}
+		}
+		if err := bi.Add(ctx, item); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if err := bi.Close(ctx); err != nil {
+		return err
+	}
+	stats := bi.Stats()
+	fmt.Printf("indexed %d, failed %d\n", stats.NumIndexed, stats.NumFailed)
+	if failed > 0 {
+		return fmt.Errorf("bulk: %d documents failed", failed)
+	}
+	return nil
+}
+
+// documentID returns the id of the document by its field, which should be a string or a number, the numbers are kept
+// as they're written.
+func documentID(doc []byte, field string) (string, error) {
+	var m map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
+		return "", fmt.Errorf("not a JSON object: %w", err)
+	}
+	if m == nil {
+		return "", errors.New("not a JSON object: null")
+	}
+	switch id := m[field].(type) {
+	case string:
+		if id == "" {
+			return "", fmt.Errorf("the id field %s is empty", field)
+		}
+		return id, nil
+	case json.Number:
+		return id.String(), nil
+	case nil:
+		return "", fmt.Errorf("the id field %s is missing or null", field)
+	default:
+		return "", fmt.Errorf("the id field %s is not a string or a number", field)
+	}
+}
+
+func reindex(ctx context.Context, oper nes.ESOper, args []string) error {
+	fs := flag.NewFlagSet("reindex", flag.ExitOnError)
+	source := fs.String("source", "", "the source index")
+	dest := fs.String("dest", "", "the dest index, it should be created with the new mappings beforehand")
+	alias := fs.String("alias", "", "the alias swapped from the source to the dest after reindexing")
+	_ = fs.Parse(args)
+	if *source == "" || *dest == "" {
+		return errors.New("reindex: -source and -dest are required")
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"source": map[string]string{"index": *source},
+		"dest":   map[string]string{"index": *dest},
+	})
+	if err != nil {
+		return err
+	}
+	api := oper.ESClient()
+	resp, err := api.Reindex(bytes.NewReader(body), api.Reindex.WithContext(ctx), api.Reindex.WithWaitForCompletion(true))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return errors.New(resp.String())
+	}
+	result := &struct {
+		Total    int64         `json:"total"`
+		Created  int64         `json:"created"`
+		Updated  int64         `json:"updated"`
+		Failures []interface{} `json:"failures"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return err
+	}
+	fmt.Printf("reindexed %d, created %d, updated %d\n", result.Total, result.Created, result.Updated)
+	if len(result.Failures) > 0 {
+		return fmt.Errorf("reindex: %d failures, the alias is not swapped: %v", len(result.Failures), result.Failures)
+	}
+
+	if *alias == "" {
+		return nil
+	}
+	if err := oper.Refresh(ctx, []string{*dest}); err != nil {
+		return err
+	}
+	if err := oper.UpdateAliases(ctx, []*nes.AliasAction{
+		{Remove: &nes.AliasActionParams{Index: *source, Alias: *alias}},
+		{Add: &nes.AliasActionParams{Index: *dest, Alias: *alias}},
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("swapped the alias %s from %s to %s\n", *alias, *source, *dest)
+	return nil
+}
+
+func mappings(ctx context.Context, oper nes.ESOper, args []string) error {
+	fs := flag.NewFlagSet("mappings", flag.ExitOnError)
+	index := fs.String("index", "", "the comma separated indices")
+	_ = fs.Parse(args)
+	if *index == "" {
+		return errors.New("mappings: -index is required")
+	}
+	m, err := oper.GetMapping(ctx, strings.Split(*index, ","))
+	if err != nil {
+		return err
+	}
+	return printJSON(m)
+}
+
+func diffMapping(ctx context.Context, oper nes.ESOper, args []string) error {
+	fs := flag.NewFlagSet("diff-mapping", flag.ExitOnError)
+	index := fs.String("index", "", "the index, alias or pattern")
+	file := fs.String("mappings", "", "the file of the desired mappings, - for stdin")
+	_ = fs.Parse(args)
+	if *index == "" || *file == "" {
+		return errors.New("diff-mapping: -index and -mappings are required")
+	}
+	data, err := readFile(*file)
+	if err != nil {
+		return err
+	}
+	var desired map[string]interface{}
+	if err := json.Unmarshal(data, &desired); err != nil {
+		return err
+	}
+	diffs, err := oper.DiffMapping(ctx, *index, desired)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(diffs))
+	for name := range diffs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	drifted := 0
+	for _, name := range names {
+		fmt.Println(diffs[name])
+		if diffs[name].Drifted() {
+			drifted++
+		}
+	}
+	if drifted > 0 {
+		return fmt.Errorf("diff-mapping: the mappings of %d indices are drifted", drifted)
+	}
+	return nil
+}
+
+func readFile(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
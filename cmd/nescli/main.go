@@ -0,0 +1,128 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command nescli performs the common operations of the elasticsearch clusters with the nes package.
+//
+//	nescli [-addrs http://localhost:9200] [-username u] [-password p] <command> [flags]
+//
+// The commands are:
+//
+//	health    print the health of the cluster
+//	search    search the indices with the query in a file
+//	bulk      import the documents in a NDJSON file
+//	reindex   reindex the source into the dest and swap the alias to the dest
+//	mappings  dump the mappings of the indices
+//	diff-mapping  diff the mappings of the indices against the desired ones, fails if they are drifted
+//
+// The addrs, username and password default to the NES_ADDRS, NES_USERNAME and NES_PASSWORD environment variables.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"github.com/nf-go/nes"
+)
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, oper nes.ESOper, args []string) error
+}
+
+var commands = map[string]*command{
+	"health":   {usage: "health", run: health},
+	"search":   {usage: "search -index logs-* -query query.json", run: search},
+	"bulk":     {usage: "bulk -index logs -file docs.ndjson [-id-field id] [-workers 2]", run: bulk},
+	"reindex":  {usage: "reindex -source logs-v1 -dest logs-v2 [-alias logs]", run: reindex},
+	"mappings": {usage: "mappings -index logs-*", run: mappings},
+
+	"diff-mapping": {usage: "diff-mapping -index logs -mappings mappings.json", run: diffMapping},
+}
+
+func main() {
+	fs := flag.NewFlagSet("nescli", flag.ExitOnError)
+	addrs := fs.String("addrs", envOr("NES_ADDRS", "http://localhost:9200"), "the comma separated addresses of the cluster")
+	username := fs.String("username", os.Getenv("NES_USERNAME"), "the username of the basic auth")
+	password := fs.String("password", os.Getenv("NES_PASSWORD"), "the password of the basic auth")
+	fs.Usage = usage(fs)
+	_ = fs.Parse(os.Args[1:])
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "nescli: unknown command %s\n", fs.Arg(0))
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	client, err := nes.NewESClient(&nes.ESConfig{
+		Addrs:    strings.Split(*addrs, ","),
+		Username: *username,
+		Password: *password,
+	})
+	if err != nil {
+		fatal(err)
+	}
+	oper := nes.NewESOper(client)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := cmd.run(ctx, oper, fs.Args()[1:]); err != nil {
+		fatal(err)
+	}
+}
+
+func usage(fs *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintln(os.Stderr, "usage: nescli [flags] <command> [command flags]")
+		fmt.Fprintln(os.Stderr, "\nflags:")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr, "\ncommands:")
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+		}
+	}
+}
+
+func envOr(key string, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "nescli:", err)
+	os.Exit(1)
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
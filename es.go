@@ -15,8 +15,12 @@
 package nes
 
 import (
+	"context"
+	"time"
+
 	es "github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/elastic/go-elasticsearch/v8/esutil"
 	"github.com/nf-go/nfgo/nlog"
 )
 
@@ -65,29 +69,336 @@ type UpdateByQueryRequest = esapi.UpdateByQueryRequest
 // DeleteByQueryRequest -
 type DeleteByQueryRequest = esapi.DeleteByQueryRequest
 
+// IndicesCreateRequest -
+type IndicesCreateRequest = esapi.IndicesCreateRequest
+
+// IndicesForcemergeRequest -
+type IndicesForcemergeRequest = esapi.IndicesForcemergeRequest
+
+// IndicesRefreshRequest -
+type IndicesRefreshRequest = esapi.IndicesRefreshRequest
+
+// IndicesFlushRequest -
+type IndicesFlushRequest = esapi.IndicesFlushRequest
+
+// IndicesClearCacheRequest -
+type IndicesClearCacheRequest = esapi.IndicesClearCacheRequest
+
+// IndicesAnalyzeRequest -
+type IndicesAnalyzeRequest = esapi.IndicesAnalyzeRequest
+
+// SecurityCreateAPIKeyRequest -
+type SecurityCreateAPIKeyRequest = esapi.SecurityCreateAPIKeyRequest
+
+// SecurityInvalidateAPIKeyRequest -
+type SecurityInvalidateAPIKeyRequest = esapi.SecurityInvalidateAPIKeyRequest
+
+// SecurityPutRoleRequest -
+type SecurityPutRoleRequest = esapi.SecurityPutRoleRequest
+
+// SecurityGetRoleRequest -
+type SecurityGetRoleRequest = esapi.SecurityGetRoleRequest
+
+// SecurityPutRoleMappingRequest -
+type SecurityPutRoleMappingRequest = esapi.SecurityPutRoleMappingRequest
+
+// SecurityGetRoleMappingRequest -
+type SecurityGetRoleMappingRequest = esapi.SecurityGetRoleMappingRequest
+
+// SecurityPutUserRequest -
+type SecurityPutUserRequest = esapi.SecurityPutUserRequest
+
+// SecurityGetUserRequest -
+type SecurityGetUserRequest = esapi.SecurityGetUserRequest
+
+// SecurityDeleteUserRequest -
+type SecurityDeleteUserRequest = esapi.SecurityDeleteUserRequest
+
+// SecurityChangePasswordRequest -
+type SecurityChangePasswordRequest = esapi.SecurityChangePasswordRequest
+
+// IndicesDeleteRequest -
+type IndicesDeleteRequest = esapi.IndicesDeleteRequest
+
+// IndicesExistsRequest -
+type IndicesExistsRequest = esapi.IndicesExistsRequest
+
+// IndicesGetMappingRequest -
+type IndicesGetMappingRequest = esapi.IndicesGetMappingRequest
+
+// IndicesPutMappingRequest -
+type IndicesPutMappingRequest = esapi.IndicesPutMappingRequest
+
+// IndicesUpdateAliasesRequest -
+type IndicesUpdateAliasesRequest = esapi.IndicesUpdateAliasesRequest
+
+// IndicesPutIndexTemplateRequest -
+type IndicesPutIndexTemplateRequest = esapi.IndicesPutIndexTemplateRequest
+
+// IndicesGetIndexTemplateRequest -
+type IndicesGetIndexTemplateRequest = esapi.IndicesGetIndexTemplateRequest
+
+// ClusterPutComponentTemplateRequest -
+type ClusterPutComponentTemplateRequest = esapi.ClusterPutComponentTemplateRequest
+
+// ClusterGetComponentTemplateRequest -
+type ClusterGetComponentTemplateRequest = esapi.ClusterGetComponentTemplateRequest
+
+// ILMPutLifecycleRequest -
+type ILMPutLifecycleRequest = esapi.ILMPutLifecycleRequest
+
+// ILMGetLifecycleRequest -
+type ILMGetLifecycleRequest = esapi.ILMGetLifecycleRequest
+
+// IngestPutPipelineRequest -
+type IngestPutPipelineRequest = esapi.IngestPutPipelineRequest
+
+// IngestGetPipelineRequest -
+type IngestGetPipelineRequest = esapi.IngestGetPipelineRequest
+
+// RankEvalRequest -
+type RankEvalRequest = esapi.RankEvalRequest
+
+// TransformPutTransformRequest -
+type TransformPutTransformRequest = esapi.TransformPutTransformRequest
+
+// TransformStartTransformRequest -
+type TransformStartTransformRequest = esapi.TransformStartTransformRequest
+
+// TransformStopTransformRequest -
+type TransformStopTransformRequest = esapi.TransformStopTransformRequest
+
+// TransformDeleteTransformRequest -
+type TransformDeleteTransformRequest = esapi.TransformDeleteTransformRequest
+
+// TransformGetTransformStatsRequest -
+type TransformGetTransformStatsRequest = esapi.TransformGetTransformStatsRequest
+
+// TransformPreviewTransformRequest -
+type TransformPreviewTransformRequest = esapi.TransformPreviewTransformRequest
+
+// IndicesDownsampleRequest -
+type IndicesDownsampleRequest = esapi.IndicesDownsampleRequest
+
+// IndicesAddBlockRequest -
+type IndicesAddBlockRequest = esapi.IndicesAddBlockRequest
+
+// RollupPutJobRequest -
+type RollupPutJobRequest = esapi.RollupPutJobRequest
+
+// RollupStartJobRequest -
+type RollupStartJobRequest = esapi.RollupStartJobRequest
+
+// RollupStopJobRequest -
+type RollupStopJobRequest = esapi.RollupStopJobRequest
+
+// RollupDeleteJobRequest -
+type RollupDeleteJobRequest = esapi.RollupDeleteJobRequest
+
+// ExistsRequest -
+type ExistsRequest = esapi.ExistsRequest
+
+// ClearScrollRequest -
+type ClearScrollRequest = esapi.ClearScrollRequest
+
+// IndicesShrinkRequest -
+type IndicesShrinkRequest = esapi.IndicesShrinkRequest
+
+// IndicesSplitRequest -
+type IndicesSplitRequest = esapi.IndicesSplitRequest
+
+// IndicesCloneRequest -
+type IndicesCloneRequest = esapi.IndicesCloneRequest
+
+// ClusterGetSettingsRequest -
+type ClusterGetSettingsRequest = esapi.ClusterGetSettingsRequest
+
+// ClusterPutSettingsRequest -
+type ClusterPutSettingsRequest = esapi.ClusterPutSettingsRequest
+
+// NodesInfoRequest -
+type NodesInfoRequest = esapi.NodesInfoRequest
+
+// NodesStatsRequest -
+type NodesStatsRequest = esapi.NodesStatsRequest
+
+// NodesHotThreadsRequest -
+type NodesHotThreadsRequest = esapi.NodesHotThreadsRequest
+
+// ClusterAllocationExplainRequest -
+type ClusterAllocationExplainRequest = esapi.ClusterAllocationExplainRequest
+
+// EnrichPutPolicyRequest -
+type EnrichPutPolicyRequest = esapi.EnrichPutPolicyRequest
+
+// EnrichExecutePolicyRequest -
+type EnrichExecutePolicyRequest = esapi.EnrichExecutePolicyRequest
+
+// EnrichDeletePolicyRequest -
+type EnrichDeletePolicyRequest = esapi.EnrichDeletePolicyRequest
+
+// SlmPutLifecycleRequest -
+type SlmPutLifecycleRequest = esapi.SlmPutLifecycleRequest
+
+// SlmGetLifecycleRequest -
+type SlmGetLifecycleRequest = esapi.SlmGetLifecycleRequest
+
+// SlmExecuteLifecycleRequest -
+type SlmExecuteLifecycleRequest = esapi.SlmExecuteLifecycleRequest
+
+// SlmDeleteLifecycleRequest -
+type SlmDeleteLifecycleRequest = esapi.SlmDeleteLifecycleRequest
+
+// SlmGetStatsRequest -
+type SlmGetStatsRequest = esapi.SlmGetStatsRequest
+
+// CCRFollowRequest -
+type CCRFollowRequest = esapi.CCRFollowRequest
+
+// CCRPauseFollowRequest -
+type CCRPauseFollowRequest = esapi.CCRPauseFollowRequest
+
+// CCRResumeFollowRequest -
+type CCRResumeFollowRequest = esapi.CCRResumeFollowRequest
+
+// CCRUnfollowRequest -
+type CCRUnfollowRequest = esapi.CCRUnfollowRequest
+
+// CCRFollowInfoRequest -
+type CCRFollowInfoRequest = esapi.CCRFollowInfoRequest
+
+// CCRFollowStatsRequest -
+type CCRFollowStatsRequest = esapi.CCRFollowStatsRequest
+
+// CCRPutAutoFollowPatternRequest -
+type CCRPutAutoFollowPatternRequest = esapi.CCRPutAutoFollowPatternRequest
+
+// CCRGetAutoFollowPatternRequest -
+type CCRGetAutoFollowPatternRequest = esapi.CCRGetAutoFollowPatternRequest
+
+// CCRDeleteAutoFollowPatternRequest -
+type CCRDeleteAutoFollowPatternRequest = esapi.CCRDeleteAutoFollowPatternRequest
+
+// CCRPauseAutoFollowPatternRequest -
+type CCRPauseAutoFollowPatternRequest = esapi.CCRPauseAutoFollowPatternRequest
+
+// CCRResumeAutoFollowPatternRequest -
+type CCRResumeAutoFollowPatternRequest = esapi.CCRResumeAutoFollowPatternRequest
+
+// SnapshotCreateRequest -
+type SnapshotCreateRequest = esapi.SnapshotCreateRequest
+
+// SearchableSnapshotsMountRequest -
+type SearchableSnapshotsMountRequest = esapi.SearchableSnapshotsMountRequest
+
+// InfoRequest -
+type InfoRequest = esapi.InfoRequest
+
+// ILMExplainLifecycleRequest -
+type ILMExplainLifecycleRequest = esapi.ILMExplainLifecycleRequest
+
+// IndicesGetSettingsRequest -
+type IndicesGetSettingsRequest = esapi.IndicesGetSettingsRequest
+
+// IndicesFieldUsageStatsRequest -
+type IndicesFieldUsageStatsRequest = esapi.IndicesFieldUsageStatsRequest
+
+// GetSourceRequest -
+type GetSourceRequest = esapi.GetSourceRequest
+
+// ExistsSourceRequest -
+type ExistsSourceRequest = esapi.ExistsSourceRequest
+
 // Response -
 type Response = esapi.Response
 
+// BulkIndexer -
+type BulkIndexer = esutil.BulkIndexer
+
+// BulkIndexerConfig -
+type BulkIndexerConfig = esutil.BulkIndexerConfig
+
+// BulkIndexerItem -
+type BulkIndexerItem = esutil.BulkIndexerItem
+
+// BulkIndexerResponseItem -
+type BulkIndexerResponseItem = esutil.BulkIndexerResponseItem
+
+// BulkIndexerResponse -
+type BulkIndexerResponse = esutil.BulkIndexerResponse
+
 // ESConfig -
 type ESConfig struct {
 	Addrs    []string `yaml:"addrs"`
 	Username string   `yaml:"username"`
 	Password string   `yaml:"password"`
+	// CompatibilityHeaders sends the compatible-with=8 headers, so the cluster upgraded to the next major version
+	// keeps responding in the format of 8.x.
+	CompatibilityHeaders bool `yaml:"compatibility_headers"`
+	// Compat7 selects the compatibility mode of the 7.x clusters, see WithCompat7, DocType is the mapping type of the indices.
+	Compat7 bool   `yaml:"compat7"`
+	DocType string `yaml:"doc_type"`
+	// MaxRetries is the max number of the retries of a request, 3 by default, the retries are disabled if it's negative.
+	MaxRetries int `yaml:"max_retries"`
+	// RetryOnStatus are the statuses of the responses retried, 502, 503 and 504 by default.
+	RetryOnStatus []int `yaml:"retry_on_status"`
+	// RetryBackoff is the backoff of the first retry doubled by each retry, the retries are not delayed if it's 0.
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+	// Version is the version number of the Elasticsearch cluster like 8.13.1, which skips the detection of NewESClient.
+	Version string `yaml:"version"`
 }
 
-// NewESClient -
-func NewESClient(config *ESConfig) (*Client, error) {
+// NewESClient - the client detecting the product and the version of the cluster unless the Version is configured,
+// the detection is retried by the first operation needing the version if the cluster is unavailable then. The requests
+// of the features unsupported by the version detected fail with ErrUnsupportedByCluster without being sent.
+func NewESClient(config *ESConfig, opts ...ESClientOption) (*Client, error) {
 	c := es.Config{
 		Addresses: config.Addrs,
 		Username:  config.Username,
 		Password:  config.Password,
+
+		EnableCompatibilityMode: config.CompatibilityHeaders && !config.Compat7,
+
+		MaxRetries:    config.MaxRetries,
+		RetryOnStatus: config.RetryOnStatus,
+		RetryBackoff:  retryBackoff(config.RetryBackoff),
+	}
+	if config.MaxRetries < 0 {
+		// the transport makes no attempt at all with the negative max retries
+		c.MaxRetries, c.DisableRetry = 0, true
+	}
+	if config.Compat7 {
+		opts = append([]ESClientOption{WithCompat7(config.DocType)}, opts...)
+	}
+	t := newESTransport(opts...)
+	c.Transport = t
+	if t.nodeHealth != nil {
+		c.Selector = t.nodeHealth
+	}
+	client, err := es.NewClient(c)
+	if err != nil {
+		return nil, err
+	}
+	if config.Version != "" {
+		v, err := ParseClusterVersion(config.Version)
+		if err != nil {
+			return nil, err
+		}
+		t.version.v.Store(v)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), versionDetectionTimeout)
+		defer cancel()
+		if _, err := t.version.get(ctx, client); err != nil {
+			nlog.Logger(ctx).Warnf("nes: fail to detect the version of the cluster, it's detected on the first use: %s", err)
+		}
 	}
-	return es.NewClient(c)
+	clusterVersions.Store(client, t.version)
+	return client, nil
 }
 
 // MustNewESClient -
-func MustNewESClient(config *ESConfig) *Client {
-	api, err := NewESClient(config)
+func MustNewESClient(config *ESConfig, opts ...ESClientOption) *Client {
+	api, err := NewESClient(config, opts...)
 	if err != nil {
 		nlog.Fatal("fail to create esapi: ", err)
 	}
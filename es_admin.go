@@ -0,0 +1,502 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// IndexAdmin - the maintenance operations of the indices.
+type IndexAdmin interface {
+	// CreateIndex creates the index with the settings, the mappings and the aliases in the body.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/indices-create-index.html.
+	CreateIndex(ctx context.Context, index string, body *CreateIndexBody, opts ...func(*IndicesCreateRequest)) error
+	DeleteIndex(ctx context.Context, indexes []string, opts ...func(*IndicesDeleteRequest)) error
+	IndexExists(ctx context.Context, indexes []string, opts ...func(*IndicesExistsRequest)) (bool, error)
+	// EnsureIndex creates the index if it's missing, or applies the compatible mapping additions and the missing aliases
+	// if it's present. An *IncompatibleMappingError is returned if the mappings can't be changed in place.
+	EnsureIndex(ctx context.Context, spec *IndexSpec) error
+
+	// GetMapping returns the mappings of the indexes keyed by the concrete index names.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/indices-get-mapping.html.
+	GetMapping(ctx context.Context, indexes []string, opts ...func(*IndicesGetMappingRequest)) (map[string]map[string]interface{}, error)
+	// PutMapping adds the new fields to the mappings of the indexes or changes the updatable mapping parameters.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/indices-put-mapping.html.
+	PutMapping(ctx context.Context, indexes []string, mappings interface{}, opts ...func(*IndicesPutMappingRequest)) error
+	// DiffMapping returns the differences between the mappings of the indices of the index, alias or pattern and
+	// the desired mappings keyed by the index names, the incompatible changes are flagged.
+	DiffMapping(ctx context.Context, index string, desired map[string]interface{}) (map[string]*MappingDiff, error)
+	// UpdateAliases performs the alias actions atomically.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/indices-aliases.html.
+	UpdateAliases(ctx context.Context, actions []*AliasAction, opts ...func(*IndicesUpdateAliasesRequest)) error
+
+	// ForceMerge reduces the number of segments of the indexes.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/indices-forcemerge.html.
+	ForceMerge(ctx context.Context, indexes []string, opts ...func(*IndicesForcemergeRequest)) error
+
+	// Refresh makes the recent operations performed on the indexes available for search.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/indices-refresh.html.
+	Refresh(ctx context.Context, indexes []string, opts ...func(*IndicesRefreshRequest)) error
+
+	// Flush writes the data in the transaction log of the indexes to the index storage permanently.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/indices-flush.html.
+	Flush(ctx context.Context, indexes []string, opts ...func(*IndicesFlushRequest)) error
+
+	// ClearCache clears the caches of the indexes, all the caches are cleared if no cache option is given.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/indices-clearcache.html.
+	ClearCache(ctx context.Context, indexes []string, opts ...func(*IndicesClearCacheRequest)) error
+}
+
+// ESAdminOper - the maintenance operations of the indices and the cluster.
+type ESAdminOper interface {
+	ESClient() *Client
+	IndexAdmin
+
+	// PutIndexTemplate creates or updates the composable index template.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/indices-put-template.html.
+	PutIndexTemplate(ctx context.Context, name string, template interface{}, opts ...func(*IndicesPutIndexTemplateRequest)) error
+	// GetIndexTemplate returns the index template, the response error of 404 is returned if it's missing.
+	GetIndexTemplate(ctx context.Context, name string, opts ...func(*IndicesGetIndexTemplateRequest)) (map[string]interface{}, error)
+	// PutComponentTemplate creates or updates the component template.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/indices-component-template.html.
+	PutComponentTemplate(ctx context.Context, name string, template interface{}, opts ...func(*ClusterPutComponentTemplateRequest)) error
+	// GetComponentTemplate returns the component template, the response error of 404 is returned if it's missing.
+	GetComponentTemplate(ctx context.Context, name string, opts ...func(*ClusterGetComponentTemplateRequest)) (map[string]interface{}, error)
+	// PutILMPolicy creates or updates the lifecycle policy.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/ilm-put-lifecycle.html.
+	PutILMPolicy(ctx context.Context, name string, policy interface{}, opts ...func(*ILMPutLifecycleRequest)) error
+	// GetILMPolicy returns the lifecycle policy, the response error of 404 is returned if it's missing.
+	GetILMPolicy(ctx context.Context, name string, opts ...func(*ILMGetLifecycleRequest)) (map[string]interface{}, error)
+	// ExplainLifecycle returns the lifecycle states of the indices keyed by their names, the indexes can be patterns.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/ilm-explain-lifecycle.html.
+	ExplainLifecycle(ctx context.Context, indexes []string, opts ...func(*ILMExplainLifecycleRequest)) (map[string]*LifecycleExplain, error)
+	// PutPipeline creates or updates the ingest pipeline.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/put-pipeline-api.html.
+	PutPipeline(ctx context.Context, id string, pipeline interface{}, opts ...func(*IngestPutPipelineRequest)) error
+	// GetPipeline returns the ingest pipeline, the response error of 404 is returned if it's missing.
+	GetPipeline(ctx context.Context, id string, opts ...func(*IngestGetPipelineRequest)) (map[string]interface{}, error)
+
+	// PutTransform creates the transform, it's started by StartTransform.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/put-transform.html.
+	PutTransform(ctx context.Context, id string, transform *Transform, opts ...func(*TransformPutTransformRequest)) error
+	StartTransform(ctx context.Context, id string, opts ...func(*TransformStartTransformRequest)) error
+	StopTransform(ctx context.Context, id string, opts ...func(*TransformStopTransformRequest)) error
+	DeleteTransform(ctx context.Context, id string, opts ...func(*TransformDeleteTransformRequest)) error
+	// GetTransformStats returns the stats of the transforms matched by the id, which can be a wildcard expression or _all.
+	GetTransformStats(ctx context.Context, id string, opts ...func(*TransformGetTransformStatsRequest)) ([]*TransformStats, error)
+	// PreviewTransform returns the documents to be generated by the transform without creating it.
+	PreviewTransform(ctx context.Context, transform *Transform, opts ...func(*TransformPreviewTransformRequest)) (*TransformPreview, error)
+
+	// AddWriteBlock makes the indices read-only.
+	AddWriteBlock(ctx context.Context, indexes []string, opts ...func(*IndicesAddBlockRequest)) error
+	// Downsample aggregates the time series index into the target index by the fixed interval like 1h,
+	// the write block required by the downsampling is added to the source index first.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/indices-downsample-data-stream.html.
+	Downsample(ctx context.Context, sourceIndex string, targetIndex string, fixedInterval string, opts ...func(*IndicesDownsampleRequest)) error
+	// PutRollupJob creates the legacy rollup job, it's started by StartRollupJob.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/rollup-put-job.html.
+	PutRollupJob(ctx context.Context, id string, job *RollupJob, opts ...func(*RollupPutJobRequest)) error
+	StartRollupJob(ctx context.Context, id string, opts ...func(*RollupStartJobRequest)) error
+	StopRollupJob(ctx context.Context, id string, opts ...func(*RollupStopJobRequest)) error
+	DeleteRollupJob(ctx context.Context, id string, opts ...func(*RollupDeleteJobRequest)) error
+
+	// Shrink shrinks the source into the target with fewer primary shards, a copy of every shard of the source is
+	// relocated to a node and the source is made read-only first, the documents of the target are verified at last.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/indices-shrink-index.html.
+	Shrink(ctx context.Context, source string, target string, spec *ResizeSpec, opts ...func(*IndicesShrinkRequest)) error
+	// Split splits the source into the target with more primary shards, the source is made read-only first.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/indices-split-index.html.
+	Split(ctx context.Context, source string, target string, spec *ResizeSpec, opts ...func(*IndicesSplitRequest)) error
+	// Clone clones the source into the target, the source is made read-only first.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/indices-clone-index.html.
+	Clone(ctx context.Context, source string, target string, spec *ResizeSpec, opts ...func(*IndicesCloneRequest)) error
+
+	// GetClusterSettings returns the flat persistent and transient settings of the cluster, and the default ones
+	// if includeDefaults is true.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/cluster-get-settings.html.
+	GetClusterSettings(ctx context.Context, includeDefaults bool, opts ...func(*ClusterGetSettingsRequest)) (*ClusterSettings, error)
+	// PutClusterSettings validates and updates the settings of the cluster.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/cluster-update-settings.html.
+	PutClusterSettings(ctx context.Context, update *ClusterSettingsUpdate, opts ...func(*ClusterPutSettingsRequest)) error
+
+	// NodesInfo returns the info of the nodes ordered by their names.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/cluster-nodes-info.html.
+	NodesInfo(ctx context.Context, opts ...func(*NodesInfoRequest)) ([]*NodeInfo, error)
+	// NodesStats returns the stats of the heap, GC, CPU, thread pools and circuit breakers of the nodes ordered by their names.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/cluster-nodes-stats.html.
+	NodesStats(ctx context.Context, opts ...func(*NodesStatsRequest)) ([]*NodeStats, error)
+	// HotThreads returns the text report of the hot threads of the nodes.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/cluster-nodes-hot-threads.html.
+	HotThreads(ctx context.Context, opts ...func(*NodesHotThreadsRequest)) (string, error)
+
+	// AllocationExplain explains why the shard copy is unassigned or stays on its node, the first unassigned shard
+	// is explained if the shard is nil, the response error of 400 is returned if there is no unassigned shard.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/cluster-allocation-explain.html.
+	AllocationExplain(ctx context.Context, shard *ShardRef, opts ...func(*ClusterAllocationExplainRequest)) (*AllocationExplanation, error)
+
+	// PutEnrichPolicy creates the enrich policy, it's executed by ExecuteEnrichPolicy before being used by the pipelines.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/put-enrich-policy-api.html.
+	PutEnrichPolicy(ctx context.Context, name string, policy *EnrichPolicy, opts ...func(*EnrichPutPolicyRequest)) error
+	// ExecuteEnrichPolicy creates the enrich index of the policy and waits for its completion,
+	// it should be executed again when the source indices change.
+	ExecuteEnrichPolicy(ctx context.Context, name string, opts ...func(*EnrichExecutePolicyRequest)) error
+	DeleteEnrichPolicy(ctx context.Context, name string, opts ...func(*EnrichDeletePolicyRequest)) error
+
+	// PutSLMPolicy creates or updates the snapshot lifecycle policy, the policy is a *SLMPolicy or its JSON form.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/slm-api-put-policy.html.
+	PutSLMPolicy(ctx context.Context, id string, policy interface{}, opts ...func(*SlmPutLifecycleRequest)) error
+	// GetSLMPolicy returns the snapshot lifecycle policy, the response error of 404 is returned if it's missing.
+	GetSLMPolicy(ctx context.Context, id string, opts ...func(*SlmGetLifecycleRequest)) (map[string]interface{}, error)
+	// ExecuteSLMPolicy takes a snapshot by the policy immediately and returns the name of the snapshot.
+	ExecuteSLMPolicy(ctx context.Context, id string, opts ...func(*SlmExecuteLifecycleRequest)) (string, error)
+	DeleteSLMPolicy(ctx context.Context, id string, opts ...func(*SlmDeleteLifecycleRequest)) error
+	// GetSLMStats returns the stats of the snapshots taken and deleted by the policies.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/slm-api-get-stats.html.
+	GetSLMStats(ctx context.Context, opts ...func(*SlmGetStatsRequest)) (*SLMStats, error)
+
+	// Follow creates the follower index replicating the leader index of the remote cluster.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/ccr-put-follow.html.
+	Follow(ctx context.Context, followerIndex string, spec *FollowSpec, opts ...func(*CCRFollowRequest)) error
+	PauseFollow(ctx context.Context, followerIndex string, opts ...func(*CCRPauseFollowRequest)) error
+	ResumeFollow(ctx context.Context, followerIndex string, opts ...func(*CCRResumeFollowRequest)) error
+	// Unfollow converts the follower index into a regular index, it's paused, closed, unfollowed and reopened in turn,
+	// e.g. to promote the follower when the leader cluster fails. The index is reopened if it fails to be unfollowed,
+	// the error tells if it's left closed.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/ccr-post-unfollow.html.
+	Unfollow(ctx context.Context, followerIndex string, opts ...func(*CCRUnfollowRequest)) error
+	// FollowInfo returns the leaders and the statuses of the follower indices.
+	FollowInfo(ctx context.Context, followerIndexes []string, opts ...func(*CCRFollowInfoRequest)) ([]*FollowerInfo, error)
+	// FollowStats returns the replication stats of the follower indices.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/ccr-get-follow-stats.html.
+	FollowStats(ctx context.Context, followerIndexes []string, opts ...func(*CCRFollowStatsRequest)) ([]*FollowerStats, error)
+	// PutAutoFollowPattern creates or updates the auto-follow pattern.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/ccr-put-auto-follow-pattern.html.
+	PutAutoFollowPattern(ctx context.Context, name string, pattern *AutoFollowPattern, opts ...func(*CCRPutAutoFollowPatternRequest)) error
+	GetAutoFollowPatterns(ctx context.Context, opts ...func(*CCRGetAutoFollowPatternRequest)) ([]*AutoFollowPatternInfo, error)
+	DeleteAutoFollowPattern(ctx context.Context, name string, opts ...func(*CCRDeleteAutoFollowPatternRequest)) error
+	PauseAutoFollowPattern(ctx context.Context, name string, opts ...func(*CCRPauseAutoFollowPatternRequest)) error
+	ResumeAutoFollowPattern(ctx context.Context, name string, opts ...func(*CCRResumeAutoFollowPatternRequest)) error
+
+	// CreateSnapshot takes the snapshot of the indices into the repository without the global state and waits for its completion.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/create-snapshot-api.html.
+	CreateSnapshot(ctx context.Context, repo string, snapshot string, indexes []string, opts ...func(*SnapshotCreateRequest)) error
+	// MountSnapshot mounts the index of the snapshot as a searchable snapshot named by MountedIndexName in the storage tier,
+	// one of StorageFullCopy and StorageSharedCache, and waits for its recovery.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/searchable-snapshots-api-mount-snapshot.html.
+	MountSnapshot(ctx context.Context, repo string, snapshot string, index string, storageTier string, opts ...func(*SearchableSnapshotsMountRequest)) error
+
+	// FieldUsageStats returns the usage of the fields of the indices matching the index pattern keyed by the index names
+	// and the field paths, summed over the shards.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/field-usage-stats.html.
+	FieldUsageStats(ctx context.Context, index string, opts ...func(*IndicesFieldUsageStatsRequest)) (map[string]map[string]*FieldUsage, error)
+
+	// ClusterVersion returns the product and the version of the cluster detected by NewESClient, it's detected by the
+	// first call and cached if the detection of NewESClient fails.
+	ClusterVersion(ctx context.Context) (*ClusterVersion, error)
+	// RequireFeature returns an error wrapping ErrUnsupportedByCluster if the feature like FeaturePIT is not supported
+	// by the cluster.
+	RequireFeature(ctx context.Context, feature string) error
+
+	// Bootstrap converges the cluster to the spec, the plan is returned without any change if dryRun is true.
+	Bootstrap(ctx context.Context, spec *BootstrapSpec, dryRun bool) (*BootstrapPlan, error)
+
+	// Analyze performs the analysis process on the text with the analyzer, the index can be empty for the built-in analyzers.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/indices-analyze.html.
+	Analyze(ctx context.Context, index string, analyzer string, text string, opts ...func(*IndicesAnalyzeRequest)) ([]*AnalyzeToken, error)
+	// AnalyzeField performs the analysis process on the text with the analyzer of the field in the index mapping.
+	AnalyzeField(ctx context.Context, index string, field string, text string, opts ...func(*IndicesAnalyzeRequest)) ([]*AnalyzeToken, error)
+}
+
+// NewESAdminOper -
+func NewESAdminOper(client *Client) ESAdminOper {
+	return &esAdminOper{
+		client:  client,
+		version: versionOf(client),
+	}
+}
+
+// WithMaxNumSegments - the number of segments the indexes should be merged into.
+func WithMaxNumSegments(n int) func(*IndicesForcemergeRequest) {
+	return func(r *IndicesForcemergeRequest) {
+		r.MaxNumSegments = &n
+	}
+}
+
+// WithOnlyExpungeDeletes - only merge the segments containing deleted documents.
+func WithOnlyExpungeDeletes() func(*IndicesForcemergeRequest) {
+	return func(r *IndicesForcemergeRequest) {
+		b := true
+		r.OnlyExpungeDeletes = &b
+	}
+}
+
+// WithClearQueryCache - clear the query cache.
+func WithClearQueryCache() func(*IndicesClearCacheRequest) {
+	return func(r *IndicesClearCacheRequest) {
+		b := true
+		r.Query = &b
+	}
+}
+
+// WithClearRequestCache - clear the shard request cache.
+func WithClearRequestCache() func(*IndicesClearCacheRequest) {
+	return func(r *IndicesClearCacheRequest) {
+		b := true
+		r.Request = &b
+	}
+}
+
+// WithClearFielddataCache - clear the fielddata cache, limited to the fields if any is given.
+func WithClearFielddataCache(fields ...string) func(*IndicesClearCacheRequest) {
+	return func(r *IndicesClearCacheRequest) {
+		b := true
+		r.Fielddata = &b
+		r.Fields = fields
+	}
+}
+
+type esAdminOper struct {
+	client  *Client
+	version *clusterVersion
+}
+
+func (e *esAdminOper) ESClient() *Client {
+	return e.client
+}
+
+func (e *esAdminOper) CreateIndex(ctx context.Context, index string, body *CreateIndexBody, opts ...func(*IndicesCreateRequest)) error {
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(body); err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*IndicesCreateRequest){api.Indices.Create.WithContext(ctx), api.Indices.Create.WithBody(buf)}, opts...)
+	resp, err := api.Indices.Create(index, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) DeleteIndex(ctx context.Context, indexes []string, opts ...func(*IndicesDeleteRequest)) error {
+	api := e.client
+	o := append([]func(*IndicesDeleteRequest){api.Indices.Delete.WithContext(ctx)}, opts...)
+	resp, err := api.Indices.Delete(indexes, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) IndexExists(ctx context.Context, indexes []string, opts ...func(*IndicesExistsRequest)) (bool, error) {
+	api := e.client
+	o := append([]func(*IndicesExistsRequest){api.Indices.Exists.WithContext(ctx)}, opts...)
+	resp, err := api.Indices.Exists(indexes, o...)
+	if err != nil {
+		return false, err
+	}
+	defer closeResponse(resp)
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.IsError() {
+		return false, newRespErr(resp)
+	}
+	return true, nil
+}
+
+type indexMappingsResponseBody struct {
+	Mappings map[string]interface{} `json:"mappings"`
+}
+
+func (e *esAdminOper) GetMapping(ctx context.Context, indexes []string, opts ...func(*IndicesGetMappingRequest)) (map[string]map[string]interface{}, error) {
+	api := e.client
+	o := append([]func(*IndicesGetMappingRequest){api.Indices.GetMapping.WithContext(ctx), api.Indices.GetMapping.WithIndex(indexes...)}, opts...)
+	resp, err := api.Indices.GetMapping(o...)
+	if err != nil {
+		return nil, err
+	}
+	respBody := map[string]*indexMappingsResponseBody{}
+	if err := unmarshallResponse(resp, &respBody); err != nil {
+		return nil, err
+	}
+	mappings := make(map[string]map[string]interface{}, len(respBody))
+	for index, m := range respBody {
+		if m != nil {
+			mappings[index] = m.Mappings
+		}
+	}
+	return mappings, nil
+}
+
+func (e *esAdminOper) PutMapping(ctx context.Context, indexes []string, mappings interface{}, opts ...func(*IndicesPutMappingRequest)) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(mappings); err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*IndicesPutMappingRequest){api.Indices.PutMapping.WithContext(ctx)}, opts...)
+	resp, err := api.Indices.PutMapping(indexes, body, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+// AliasAction - one of the add, remove and remove_index actions of the update aliases request.
+type AliasAction struct {
+	Add         *AliasActionParams `json:"add,omitempty"`
+	Remove      *AliasActionParams `json:"remove,omitempty"`
+	RemoveIndex *AliasActionParams `json:"remove_index,omitempty"`
+}
+
+// AliasActionParams -
+type AliasActionParams struct {
+	Index        string      `json:"index,omitempty"`
+	Alias        string      `json:"alias,omitempty"`
+	Filter       interface{} `json:"filter,omitempty"`
+	Routing      string      `json:"routing,omitempty"`
+	IsWriteIndex *bool       `json:"is_write_index,omitempty"`
+	MustExist    *bool       `json:"must_exist,omitempty"`
+}
+
+type updateAliasesRequestBody struct {
+	Actions []*AliasAction `json:"actions"`
+}
+
+func (e *esAdminOper) UpdateAliases(ctx context.Context, actions []*AliasAction, opts ...func(*IndicesUpdateAliasesRequest)) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(&updateAliasesRequestBody{Actions: actions}); err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*IndicesUpdateAliasesRequest){api.Indices.UpdateAliases.WithContext(ctx)}, opts...)
+	resp, err := api.Indices.UpdateAliases(body, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) ForceMerge(ctx context.Context, indexes []string, opts ...func(*IndicesForcemergeRequest)) error {
+	api := e.client
+	o := append([]func(*IndicesForcemergeRequest){api.Indices.Forcemerge.WithContext(ctx), api.Indices.Forcemerge.WithIndex(indexes...)}, opts...)
+	resp, err := api.Indices.Forcemerge(o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) Refresh(ctx context.Context, indexes []string, opts ...func(*IndicesRefreshRequest)) error {
+	api := e.client
+	o := append([]func(*IndicesRefreshRequest){api.Indices.Refresh.WithContext(ctx), api.Indices.Refresh.WithIndex(indexes...)}, opts...)
+	resp, err := api.Indices.Refresh(o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) Flush(ctx context.Context, indexes []string, opts ...func(*IndicesFlushRequest)) error {
+	api := e.client
+	o := append([]func(*IndicesFlushRequest){api.Indices.Flush.WithContext(ctx), api.Indices.Flush.WithIndex(indexes...)}, opts...)
+	resp, err := api.Indices.Flush(o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) ClearCache(ctx context.Context, indexes []string, opts ...func(*IndicesClearCacheRequest)) error {
+	api := e.client
+	o := append([]func(*IndicesClearCacheRequest){api.Indices.ClearCache.WithContext(ctx), api.Indices.ClearCache.WithIndex(indexes...)}, opts...)
+	resp, err := api.Indices.ClearCache(o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
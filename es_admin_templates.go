@@ -0,0 +1,184 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+type indexTemplatesResponseBody struct {
+	IndexTemplates []struct {
+		Name          string                 `json:"name"`
+		IndexTemplate map[string]interface{} `json:"index_template"`
+	} `json:"index_templates"`
+}
+
+type componentTemplatesResponseBody struct {
+	ComponentTemplates []struct {
+		Name              string                 `json:"name"`
+		ComponentTemplate map[string]interface{} `json:"component_template"`
+	} `json:"component_templates"`
+}
+
+type ilmPolicyResponseBody struct {
+	Policy map[string]interface{} `json:"policy"`
+}
+
+type ilmPolicyRequestBody struct {
+	Policy interface{} `json:"policy"`
+}
+
+func (e *esAdminOper) PutIndexTemplate(ctx context.Context, name string, template interface{}, opts ...func(*IndicesPutIndexTemplateRequest)) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(template); err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*IndicesPutIndexTemplateRequest){api.Indices.PutIndexTemplate.WithContext(ctx)}, opts...)
+	resp, err := api.Indices.PutIndexTemplate(name, body, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) GetIndexTemplate(ctx context.Context, name string, opts ...func(*IndicesGetIndexTemplateRequest)) (map[string]interface{}, error) {
+	api := e.client
+	o := append([]func(*IndicesGetIndexTemplateRequest){api.Indices.GetIndexTemplate.WithContext(ctx), api.Indices.GetIndexTemplate.WithName(name)}, opts...)
+	resp, err := api.Indices.GetIndexTemplate(o...)
+	if err != nil {
+		return nil, err
+	}
+	respBody := &indexTemplatesResponseBody{}
+	if err := unmarshallResponse(resp, respBody); err != nil {
+		return nil, err
+	}
+	for _, t := range respBody.IndexTemplates {
+		if t.Name == name {
+			return t.IndexTemplate, nil
+		}
+	}
+	return nil, nil
+}
+
+func (e *esAdminOper) PutComponentTemplate(ctx context.Context, name string, template interface{}, opts ...func(*ClusterPutComponentTemplateRequest)) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(template); err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*ClusterPutComponentTemplateRequest){api.Cluster.PutComponentTemplate.WithContext(ctx)}, opts...)
+	resp, err := api.Cluster.PutComponentTemplate(name, body, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) GetComponentTemplate(ctx context.Context, name string, opts ...func(*ClusterGetComponentTemplateRequest)) (map[string]interface{}, error) {
+	api := e.client
+	o := append([]func(*ClusterGetComponentTemplateRequest){api.Cluster.GetComponentTemplate.WithContext(ctx), api.Cluster.GetComponentTemplate.WithName(name)}, opts...)
+	resp, err := api.Cluster.GetComponentTemplate(o...)
+	if err != nil {
+		return nil, err
+	}
+	respBody := &componentTemplatesResponseBody{}
+	if err := unmarshallResponse(resp, respBody); err != nil {
+		return nil, err
+	}
+	for _, t := range respBody.ComponentTemplates {
+		if t.Name == name {
+			return t.ComponentTemplate, nil
+		}
+	}
+	return nil, nil
+}
+
+func (e *esAdminOper) PutILMPolicy(ctx context.Context, name string, policy interface{}, opts ...func(*ILMPutLifecycleRequest)) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(&ilmPolicyRequestBody{Policy: policy}); err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*ILMPutLifecycleRequest){api.ILM.PutLifecycle.WithContext(ctx), api.ILM.PutLifecycle.WithBody(body)}, opts...)
+	resp, err := api.ILM.PutLifecycle(name, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) GetILMPolicy(ctx context.Context, name string, opts ...func(*ILMGetLifecycleRequest)) (map[string]interface{}, error) {
+	api := e.client
+	o := append([]func(*ILMGetLifecycleRequest){api.ILM.GetLifecycle.WithContext(ctx), api.ILM.GetLifecycle.WithPolicy(name)}, opts...)
+	resp, err := api.ILM.GetLifecycle(o...)
+	if err != nil {
+		return nil, err
+	}
+	respBody := map[string]*ilmPolicyResponseBody{}
+	if err := unmarshallResponse(resp, &respBody); err != nil {
+		return nil, err
+	}
+	if p, ok := respBody[name]; ok && p != nil {
+		return p.Policy, nil
+	}
+	return nil, nil
+}
+
+func (e *esAdminOper) PutPipeline(ctx context.Context, id string, pipeline interface{}, opts ...func(*IngestPutPipelineRequest)) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(pipeline); err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*IngestPutPipelineRequest){api.Ingest.PutPipeline.WithContext(ctx)}, opts...)
+	resp, err := api.Ingest.PutPipeline(id, body, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) GetPipeline(ctx context.Context, id string, opts ...func(*IngestGetPipelineRequest)) (map[string]interface{}, error) {
+	api := e.client
+	o := append([]func(*IngestGetPipelineRequest){api.Ingest.GetPipeline.WithContext(ctx), api.Ingest.GetPipeline.WithPipelineID(id)}, opts...)
+	resp, err := api.Ingest.GetPipeline(o...)
+	if err != nil {
+		return nil, err
+	}
+	respBody := map[string]map[string]interface{}{}
+	if err := unmarshallResponse(resp, &respBody); err != nil {
+		return nil, err
+	}
+	return respBody[id], nil
+}
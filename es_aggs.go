@@ -0,0 +1,404 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Agg - an aggregation of the request body, built by the functions like TermsAgg.
+type Agg map[string]interface{}
+
+// TermsAgg - the terms aggregation of the field with the number of the buckets.
+func TermsAgg(field string, size int) Agg {
+	return Agg{"terms": map[string]interface{}{"field": field, "size": size}}
+}
+
+// TopHitsAgg - the top_hits aggregation with the number of the hits and the optional sort clauses.
+func TopHitsAgg(size int, sort ...interface{}) Agg {
+	params := map[string]interface{}{"size": size}
+	if len(sort) > 0 {
+		params["sort"] = sort
+	}
+	return Agg{"top_hits": params}
+}
+
+// DateHistogramAgg - the date_histogram aggregation of the field, the interval is a calendar one like 1d and month
+// or a fixed one like 30s and 12h.
+func DateHistogramAgg(field string, interval string) Agg {
+	params := map[string]interface{}{"field": field}
+	if isCalendarInterval(interval) {
+		params["calendar_interval"] = interval
+	} else {
+		params["fixed_interval"] = interval
+	}
+	return Agg{"date_histogram": params}
+}
+
+func isCalendarInterval(interval string) bool {
+	switch interval {
+	case "minute", "1m", "hour", "1h", "day", "1d", "week", "1w", "month", "1M", "quarter", "1q", "year", "1y":
+		return true
+	}
+	return false
+}
+
+// AvgAgg - the avg aggregation of the field.
+func AvgAgg(field string) Agg {
+	return Agg{"avg": map[string]interface{}{"field": field}}
+}
+
+// SumAgg - the sum aggregation of the field.
+func SumAgg(field string) Agg {
+	return Agg{"sum": map[string]interface{}{"field": field}}
+}
+
+// MinAgg - the min aggregation of the field.
+func MinAgg(field string) Agg {
+	return Agg{"min": map[string]interface{}{"field": field}}
+}
+
+// MaxAgg - the max aggregation of the field.
+func MaxAgg(field string) Agg {
+	return Agg{"max": map[string]interface{}{"field": field}}
+}
+
+// CardinalityAgg - the cardinality aggregation of the field.
+func CardinalityAgg(field string) Agg {
+	return Agg{"cardinality": map[string]interface{}{"field": field}}
+}
+
+// StatsAgg - the stats aggregation of the field computing the count, min, max, avg and sum of its values.
+func StatsAgg(field string) Agg {
+	return Agg{"stats": map[string]interface{}{"field": field}}
+}
+
+// ValueCountAgg - the value_count aggregation of the field.
+func ValueCountAgg(field string) Agg {
+	return Agg{"value_count": map[string]interface{}{"field": field}}
+}
+
+// SignificantTermsAgg - the significant_terms aggregation of the field, finding the terms unusually frequent
+// in the matched documents compared with the background set, usually used under a SamplerAgg.
+func SignificantTermsAgg(field string, size int) Agg {
+	return Agg{"significant_terms": map[string]interface{}{"field": field, "size": size}}
+}
+
+// SignificantTextAgg - the significant_text aggregation of the text field, the duplicate text is filtered out.
+func SignificantTextAgg(field string, size int) Agg {
+	return Agg{"significant_text": map[string]interface{}{"field": field, "size": size, "filter_duplicate_text": true}}
+}
+
+// SamplerAgg - the sampler aggregation limiting the sub aggregations to the top scoring documents of each shard.
+func SamplerAgg(shardSize int) Agg {
+	return Agg{"sampler": map[string]interface{}{"shard_size": shardSize}}
+}
+
+// DiversifiedSamplerAgg - the diversified_sampler aggregation limiting the number of the sampled documents
+// sharing a value of the field.
+func DiversifiedSamplerAgg(field string, shardSize int, maxDocsPerValue int) Agg {
+	return Agg{"diversified_sampler": map[string]interface{}{
+		"field": field, "shard_size": shardSize, "max_docs_per_value": maxDocsPerValue,
+	}}
+}
+
+// BucketScriptAgg - the bucket_script pipeline aggregation computing the script with the variables
+// mapped to the buckets paths, e.g. {"total": "sales", "count": "_count"} and "params.total / params.count".
+func BucketScriptAgg(bucketsPath map[string]string, script string) Agg {
+	return Agg{"bucket_script": map[string]interface{}{"buckets_path": bucketsPath, "script": script}}
+}
+
+// DerivativeAgg - the derivative pipeline aggregation of the metric in the buckets path,
+// the unit like 1s normalizes the derivative into normalized_value if it's not empty.
+func DerivativeAgg(bucketsPath string, unit string) Agg {
+	params := map[string]interface{}{"buckets_path": bucketsPath}
+	if unit != "" {
+		params["unit"] = unit
+	}
+	return Agg{"derivative": params}
+}
+
+// MovingFnAgg - the moving_fn pipeline aggregation applying the script on the window of the metric,
+// e.g. "MovingFunctions.unweightedAvg(values)".
+func MovingFnAgg(bucketsPath string, window int, script string) Agg {
+	return Agg{"moving_fn": map[string]interface{}{"buckets_path": bucketsPath, "window": window, "script": script}}
+}
+
+// CumulativeSumAgg - the cumulative_sum pipeline aggregation of the metric in the buckets path.
+func CumulativeSumAgg(bucketsPath string) Agg {
+	return Agg{"cumulative_sum": map[string]interface{}{"buckets_path": bucketsPath}}
+}
+
+// BucketSortAgg - the bucket_sort pipeline aggregation sorting and truncating the buckets of the parent,
+// the size is not limited if it's 0.
+func BucketSortAgg(from int, size int, sort ...interface{}) Agg {
+	params := map[string]interface{}{"from": from}
+	if size > 0 {
+		params["size"] = size
+	}
+	if len(sort) > 0 {
+		params["sort"] = sort
+	}
+	return Agg{"bucket_sort": params}
+}
+
+// With sets the param of the aggregation, e.g. TermsAgg("user", 10).With("min_doc_count", 2).
+func (a Agg) With(param string, value interface{}) Agg {
+	for kind, params := range a {
+		if kind == "aggs" || kind == "aggregations" || kind == "meta" {
+			continue
+		}
+		if m, ok := params.(map[string]interface{}); ok {
+			m[param] = value
+		}
+	}
+	return a
+}
+
+// SubAggs sets the sub aggregations of the aggregation.
+func (a Agg) SubAggs(aggs map[string]Agg) Agg {
+	a["aggs"] = aggs
+	return a
+}
+
+// AggsResult - the aggregations of the search response keyed by their names.
+type AggsResult map[string]json.RawMessage
+
+func (r AggsResult) decode(name string, dest interface{}) error {
+	raw, ok := r[name]
+	if !ok {
+		return fmt.Errorf("nes: the aggregation %s is missing in the response", name)
+	}
+	return json.Unmarshal(raw, dest)
+}
+
+// AggBucket - a bucket of the multi bucket aggregation, Aggs are the sub aggregations of the bucket.
+type AggBucket struct {
+	Key         interface{}
+	KeyAsString string
+	DocCount    int64
+	Aggs        AggsResult
+}
+
+func (b *AggBucket) UnmarshalJSON(data []byte) error {
+	m := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	b.Aggs = AggsResult{}
+	for k, v := range m {
+		var err error
+		switch k {
+		case "key":
+			err = json.Unmarshal(v, &b.Key)
+		case "key_as_string":
+			err = json.Unmarshal(v, &b.KeyAsString)
+		case "doc_count":
+			err = json.Unmarshal(v, &b.DocCount)
+		default:
+			if len(v) > 0 && v[0] == '{' {
+				b.Aggs[k] = v
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Value returns the value of the single value aggregation, nil if the value is null, e.g. the avg of no documents.
+func (r AggsResult) Value(name string) (*float64, error) {
+	v := &struct {
+		Value *float64 `json:"value"`
+	}{}
+	if err := r.decode(name, v); err != nil {
+		return nil, err
+	}
+	return v.Value, nil
+}
+
+// StatsResult - the result of the stats aggregation, the Min, Max and Avg are nil if there is no value.
+type StatsResult struct {
+	Count int64    `json:"count"`
+	Min   *float64 `json:"min"`
+	Max   *float64 `json:"max"`
+	Avg   *float64 `json:"avg"`
+	Sum   float64  `json:"sum"`
+}
+
+// Stats returns the result of the stats aggregation.
+func (r AggsResult) Stats(name string) (*StatsResult, error) {
+	result := &StatsResult{}
+	if err := r.decode(name, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SingleBucket returns the bucket of the single bucket aggregation like sampler, filter and nested.
+func (r AggsResult) SingleBucket(name string) (*AggBucket, error) {
+	b := &AggBucket{}
+	if err := r.decode(name, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Sampler returns the bucket of the sampler or diversified_sampler aggregation.
+func (r AggsResult) Sampler(name string) (*AggBucket, error) {
+	return r.SingleBucket(name)
+}
+
+// SignificantBucket - a bucket of the significant_terms or significant_text aggregation.
+type SignificantBucket struct {
+	AggBucket
+	// BgCount is the number of the documents containing the term in the background set.
+	BgCount int64
+	Score   float64
+}
+
+func (b *SignificantBucket) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &b.AggBucket); err != nil {
+		return err
+	}
+	v := &struct {
+		BgCount int64   `json:"bg_count"`
+		Score   float64 `json:"score"`
+	}{}
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	b.BgCount = v.BgCount
+	b.Score = v.Score
+	return nil
+}
+
+// SignificantTermsResult - the result of the significant_terms or significant_text aggregation,
+// DocCount and BgCount are the sizes of the foreground and background sets.
+type SignificantTermsResult struct {
+	DocCount int64                `json:"doc_count"`
+	BgCount  int64                `json:"bg_count"`
+	Buckets  []*SignificantBucket `json:"buckets"`
+}
+
+// SignificantTerms returns the result of the significant_terms or significant_text aggregation.
+func (r AggsResult) SignificantTerms(name string) (*SignificantTermsResult, error) {
+	result := &SignificantTermsResult{}
+	if err := r.decode(name, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DerivativeResult - the result of the derivative pipeline aggregation, the values are nil in the first bucket.
+type DerivativeResult struct {
+	Value           *float64 `json:"value"`
+	NormalizedValue *float64 `json:"normalized_value"`
+}
+
+// Derivative returns the result of the derivative pipeline aggregation, the results of the other pipeline
+// aggregations like bucket_script, moving_fn and cumulative_sum are returned by Value.
+func (r AggsResult) Derivative(name string) (*DerivativeResult, error) {
+	result := &DerivativeResult{}
+	if err := r.decode(name, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// KeyString returns the key_as_string of the bucket if any, or the key formatted as a string.
+func (b *AggBucket) KeyString() string {
+	if b.KeyAsString != "" {
+		return b.KeyAsString
+	}
+	if f, ok := b.Key.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(b.Key)
+}
+
+// Buckets returns the buckets of the multi bucket aggregation, the keys of the keyed buckets are set as Key.
+func (r AggsResult) Buckets(name string) ([]*AggBucket, error) {
+	v := &struct {
+		Buckets json.RawMessage `json:"buckets"`
+	}{}
+	if err := r.decode(name, v); err != nil {
+		return nil, err
+	}
+	if len(v.Buckets) > 0 && v.Buckets[0] == '{' {
+		return keyedBuckets(v.Buckets)
+	}
+	var buckets []*AggBucket
+	if err := json.Unmarshal(v.Buckets, &buckets); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// keyedBuckets decodes the keyed buckets in the order of the response, e.g. the order of the filters or the ranges
+// of the request.
+func keyedBuckets(data json.RawMessage) ([]*AggBucket, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// the opening brace
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	var buckets []*AggBucket
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		b := &AggBucket{}
+		if err := dec.Decode(b); err != nil {
+			return nil, err
+		}
+		b.Key = t.(string)
+		buckets = append(buckets, b)
+	}
+	return buckets, nil
+}
+
+// TopHits decodes the sources of the hits of the top_hits aggregation into the model, which is a pointer to a slice.
+func (r AggsResult) TopHits(name string, model interface{}) error {
+	v := &struct {
+		Hits struct {
+			Hits []struct {
+				Source json.RawMessage `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}{}
+	if err := r.decode(name, v); err != nil {
+		return err
+	}
+	sources := make([]json.RawMessage, 0, len(v.Hits.Hits))
+	for _, h := range v.Hits.Hits {
+		sources = append(sources, h.Source)
+	}
+	b, err := json.Marshal(sources)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, model)
+}
+
+// aggsResponseBody - the search response of which only the aggregations are used.
+type aggsResponseBody struct {
+	Aggregations AggsResult `json:"aggregations"`
+}
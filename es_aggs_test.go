@@ -0,0 +1,46 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestKeyedBucketsKeepTheOrderOfTheResponse(t *testing.T) {
+	aggs := AggsResult{"ranges": json.RawMessage(`{"buckets":{
+		"z-cheap":{"to":10,"doc_count":1},
+		"a-medium":{"from":10,"to":100,"doc_count":2,"avg_price":{"value":42}},
+		"m-expensive":{"from":100,"doc_count":3}
+	}}`)}
+	for i := 0; i < 10; i++ {
+		buckets, err := aggs.Buckets("ranges")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"z-cheap", "a-medium", "m-expensive"}
+		if len(buckets) != len(want) {
+			t.Fatalf("%d buckets, expected %d", len(buckets), len(want))
+		}
+		for j, b := range buckets {
+			if b.Key != want[j] || b.DocCount != int64(j+1) {
+				t.Fatalf("the bucket %d is %v of %d docs, expected %s of %d docs", j, b.Key, b.DocCount, want[j], j+1)
+			}
+		}
+		if v, err := buckets[1].Aggs.Value("avg_price"); err != nil || v == nil || *v != 42 {
+			t.Fatalf("the sub aggregation is %v, %v", v, err)
+		}
+	}
+}
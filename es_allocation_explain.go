@@ -0,0 +1,119 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ShardRef - the reference of a shard copy, the current node is required for the explanation of an assigned replica.
+type ShardRef struct {
+	Index       string `json:"index"`
+	Shard       int    `json:"shard"`
+	Primary     bool   `json:"primary"`
+	CurrentNode string `json:"current_node,omitempty"`
+}
+
+// AllocationExplanation - the explanation of the allocation of a shard copy.
+type AllocationExplanation struct {
+	Index          string `json:"index"`
+	Shard          int    `json:"shard"`
+	Primary        bool   `json:"primary"`
+	CurrentState   string `json:"current_state"`
+	UnassignedInfo *struct {
+		Reason               string `json:"reason"`
+		At                   string `json:"at"`
+		LastAllocationStatus string `json:"last_allocation_status"`
+		Details              string `json:"details"`
+	} `json:"unassigned_info,omitempty"`
+	CurrentNode *struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"current_node,omitempty"`
+	CanAllocate             string                    `json:"can_allocate"`
+	AllocateExplanation     string                    `json:"allocate_explanation"`
+	CanRemainOnCurrentNode  string                    `json:"can_remain_on_current_node"`
+	CanRebalanceCluster     string                    `json:"can_rebalance_cluster"`
+	CanMoveToOtherNode      string                    `json:"can_move_to_other_node"`
+	MoveExplanation         string                    `json:"move_explanation"`
+	RebalanceExplanation    string                    `json:"rebalance_explanation"`
+	NodeAllocationDecisions []*NodeAllocationDecision `json:"node_allocation_decisions"`
+}
+
+// NodeAllocationDecision - the decision of allocating the shard copy to a node.
+type NodeAllocationDecision struct {
+	NodeID       string `json:"node_id"`
+	NodeName     string `json:"node_name"`
+	NodeDecision string `json:"node_decision"`
+	Deciders     []*struct {
+		Decider     string `json:"decider"`
+		Decision    string `json:"decision"`
+		Explanation string `json:"explanation"`
+	} `json:"deciders"`
+}
+
+// String reports the state of the shard copy and why it's not allocated to each node.
+func (e *AllocationExplanation) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "[%s][%d] primary=%t is %s", e.Index, e.Shard, e.Primary, e.CurrentState)
+	if e.UnassignedInfo != nil {
+		fmt.Fprintf(b, " since %s for %s", e.UnassignedInfo.At, e.UnassignedInfo.Reason)
+		if e.UnassignedInfo.Details != "" {
+			fmt.Fprintf(b, ": %s", e.UnassignedInfo.Details)
+		}
+	}
+	for _, s := range []string{e.AllocateExplanation, e.MoveExplanation, e.RebalanceExplanation} {
+		if s != "" {
+			fmt.Fprintf(b, "\n%s", s)
+		}
+	}
+	for _, d := range e.NodeAllocationDecisions {
+		if d == nil {
+			continue
+		}
+		for _, decider := range d.Deciders {
+			if decider != nil && decider.Decision == "NO" {
+				fmt.Fprintf(b, "\n  %s: %s: %s", d.NodeName, decider.Decider, decider.Explanation)
+			}
+		}
+	}
+	return b.String()
+}
+
+func (e *esAdminOper) AllocationExplain(ctx context.Context, shard *ShardRef, opts ...func(*ClusterAllocationExplainRequest)) (*AllocationExplanation, error) {
+	api := e.client
+	o := []func(*ClusterAllocationExplainRequest){api.Cluster.AllocationExplain.WithContext(ctx)}
+	if shard != nil {
+		body := &bytes.Buffer{}
+		if err := json.NewEncoder(body).Encode(shard); err != nil {
+			return nil, err
+		}
+		o = append(o, api.Cluster.AllocationExplain.WithBody(body))
+	}
+	o = append(o, opts...)
+	resp, err := api.Cluster.AllocationExplain(o...)
+	if err != nil {
+		return nil, err
+	}
+	explanation := &AllocationExplanation{}
+	if err := unmarshallResponse(resp, explanation); err != nil {
+		return nil, err
+	}
+	return explanation, nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+// AnalyzeToken - a token produced by the analysis process.
+type AnalyzeToken struct {
+	Token       string `json:"token"`
+	StartOffset int    `json:"start_offset"`
+	EndOffset   int    `json:"end_offset"`
+	Type        string `json:"type"`
+	Position    int    `json:"position"`
+}
+
+type analyzeRequestBody struct {
+	Analyzer string `json:"analyzer,omitempty"`
+	Field    string `json:"field,omitempty"`
+	Text     string `json:"text"`
+}
+
+type analyzeResponseBody struct {
+	Tokens []*AnalyzeToken `json:"tokens"`
+}
+
+func (e *esAdminOper) Analyze(ctx context.Context, index string, analyzer string, text string, opts ...func(*IndicesAnalyzeRequest)) ([]*AnalyzeToken, error) {
+	return e.analyze(ctx, index, &analyzeRequestBody{Analyzer: analyzer, Text: text}, opts...)
+}
+
+func (e *esAdminOper) AnalyzeField(ctx context.Context, index string, field string, text string, opts ...func(*IndicesAnalyzeRequest)) ([]*AnalyzeToken, error) {
+	return e.analyze(ctx, index, &analyzeRequestBody{Field: field, Text: text}, opts...)
+}
+
+func (e *esAdminOper) analyze(ctx context.Context, index string, reqBody *analyzeRequestBody, opts ...func(*IndicesAnalyzeRequest)) ([]*AnalyzeToken, error) {
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(reqBody); err != nil {
+		return nil, err
+	}
+	api := e.client
+	o := append([]func(*IndicesAnalyzeRequest){api.Indices.Analyze.WithContext(ctx), api.Indices.Analyze.WithIndex(index), api.Indices.Analyze.WithBody(buf)}, opts...)
+	resp, err := api.Indices.Analyze(o...)
+	if err != nil {
+		return nil, err
+	}
+	respBody := &analyzeResponseBody{}
+	if err := unmarshallResponse(resp, respBody); err != nil {
+		return nil, err
+	}
+	return respBody.Tokens, nil
+}
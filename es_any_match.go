@@ -0,0 +1,43 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+)
+
+type anyMatchResponseBody struct {
+	Hits struct {
+		Total struct {
+			Value int64 `json:"value"`
+		} `json:"total"`
+	} `json:"hits"`
+}
+
+// AnyMatch reports whether any document of the indexes matches the query in the request body, the search stops
+// at the first match on each shard and returns no hits, prefer it to Count for the existence checks.
+func AnyMatch(ctx context.Context, oper Searcher, query string, indexes []string, opts ...func(*SearchRequest)) (bool, error) {
+	o := append(append([]func(*SearchRequest){}, opts...), func(r *SearchRequest) {
+		size, terminateAfter := 0, 1
+		r.Size = &size
+		r.TerminateAfter = &terminateAfter
+		r.TrackTotalHits = 1
+	})
+	respBody := &anyMatchResponseBody{}
+	if _, err := oper.Search(ctx, respBody, query, indexes, o...); err != nil {
+		return false, err
+	}
+	return respBody.Hits.Total.Value > 0, nil
+}
@@ -0,0 +1,292 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nf-go/nfgo/ncontext"
+	"github.com/nf-go/nfgo/nlog"
+)
+
+// AuditOp - the type of the audited write operation.
+type AuditOp string
+
+const (
+	// AuditOpCreate -
+	AuditOpCreate AuditOp = "create"
+	// AuditOpIndex -
+	AuditOpIndex AuditOp = "index"
+	// AuditOpUpdate -
+	AuditOpUpdate AuditOp = "update"
+	// AuditOpDelete -
+	AuditOpDelete AuditOp = "delete"
+	// AuditOpBulk - the bulk whose actions can't be read.
+	AuditOpBulk AuditOp = "bulk"
+)
+
+// AuditEvent - the audit record of a write operation.
+type AuditEvent struct {
+	Op     AuditOp         `json:"op"`
+	Index  string          `json:"index"`
+	ID     string          `json:"id,omitempty"`
+	Actor  string          `json:"actor,omitempty"`
+	Time   time.Time       `json:"time"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  interface{}     `json:"after,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// AuditSink - the destination of the audit events.
+type AuditSink interface {
+	WriteAuditEvent(ctx context.Context, event *AuditEvent) error
+}
+
+// AuditSinkFunc - an adapter to allow the use of ordinary functions as AuditSink.
+type AuditSinkFunc func(ctx context.Context, event *AuditEvent) error
+
+// WriteAuditEvent -
+func (f AuditSinkFunc) WriteAuditEvent(ctx context.Context, event *AuditEvent) error {
+	return f(ctx, event)
+}
+
+// NewLogAuditSink - the sink writing the audit events to the nlog logger at info level.
+func NewLogAuditSink() AuditSink {
+	return AuditSinkFunc(func(ctx context.Context, event *AuditEvent) error {
+		b, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		nlog.Logger(ctx).WithField("audit", event.Op).Infof("nes audit: %s", b)
+		return nil
+	})
+}
+
+// NewIndexAuditSink - the sink indexing the audit events into the audit index by the oper.
+func NewIndexAuditSink(oper ESOper, index string) AuditSink {
+	return AuditSinkFunc(func(ctx context.Context, event *AuditEvent) error {
+		return oper.Index(ctx, index, "", event)
+	})
+}
+
+// AuditConfig -
+type AuditConfig struct {
+	Sink AuditSink
+	// FetchBefore fetches the source of the document before the Index, Update and Delete operations
+	// to be recorded as the before payload, which costs an extra Get request per write.
+	FetchBefore bool
+	// Actor extracts the actor from the context, the subject id of the nfgo MDC is used if it's nil.
+	Actor func(ctx context.Context) string
+}
+
+// NewAuditESOper - the oper invoking the audit sink after each Create, Index, Update, UpdateScript, Upsert, Delete and Bulk
+// operation of the oper, an event per action of the bulks. The failure of the audit sink is logged and doesn't fail
+// the write.
+func NewAuditESOper(oper ESOper, config *AuditConfig) ESOper {
+	actor := config.Actor
+	if actor == nil {
+		actor = mdcSubjectID
+	}
+	return &auditESOper{
+		ESOper:      oper,
+		sink:        config.Sink,
+		fetchBefore: config.FetchBefore,
+		actor:       actor,
+	}
+}
+
+func mdcSubjectID(ctx context.Context) string {
+	mdc, err := ncontext.CurrentMDC(ctx)
+	if err != nil {
+		return ""
+	}
+	return mdc.SubjectID()
+}
+
+type getSourceResponseBody struct {
+	Found   bool            `json:"found"`
+	Version int64           `json:"_version"`
+	Source  json.RawMessage `json:"_source"`
+}
+
+type auditESOper struct {
+	ESOper
+	sink        AuditSink
+	fetchBefore bool
+	actor       func(ctx context.Context) string
+}
+
+func (a *auditESOper) before(ctx context.Context, index string, id string) json.RawMessage {
+	if !a.fetchBefore || id == "" {
+		return nil
+	}
+	doc := &getSourceResponseBody{}
+	if _, err := a.ESOper.Get(ctx, doc, index, id); err != nil || !doc.Found {
+		return nil
+	}
+	return doc.Source
+}
+
+func (a *auditESOper) audit(ctx context.Context, op AuditOp, index string, id string, before json.RawMessage, after interface{}, err error) {
+	event := &AuditEvent{
+		Op:     op,
+		Index:  index,
+		ID:     id,
+		Actor:  a.actor(ctx),
+		Time:   time.Now(),
+		Before: before,
+		After:  after,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	if err := a.sink.WriteAuditEvent(ctx, event); err != nil {
+		nlog.Logger(ctx).Errorf("nes audit: fail to write the audit event of %s %s/%s: %s", op, index, id, err)
+	}
+}
+
+func (a *auditESOper) Create(ctx context.Context, index string, id string, obj interface{}, opts ...func(*CreateRequest)) error {
+	err := a.ESOper.Create(ctx, index, id, obj, opts...)
+	a.audit(ctx, AuditOpCreate, index, id, nil, obj, err)
+	return err
+}
+
+func (a *auditESOper) Index(ctx context.Context, index string, id string, obj interface{}, opts ...func(*IndexRequest)) error {
+	before := a.before(ctx, index, id)
+	err := a.ESOper.Index(ctx, index, id, obj, opts...)
+	a.audit(ctx, AuditOpIndex, index, id, before, obj, err)
+	return err
+}
+
+func (a *auditESOper) Delete(ctx context.Context, id string, index string, opts ...func(*DeleteRequest)) error {
+	before := a.before(ctx, index, id)
+	err := a.ESOper.Delete(ctx, id, index, opts...)
+	a.audit(ctx, AuditOpDelete, index, id, before, nil, err)
+	return err
+}
+
+func (a *auditESOper) DeleteDoc(ctx context.Context, ref DocRef, opts ...func(*DeleteRequest)) error {
+	return a.Delete(ctx, ref.ID, ref.Index, opts...)
+}
+
+func (a *auditESOper) Bulk(ctx context.Context, index string, writeReqBody func(ctx context.Context, buf *bytes.Buffer) error, opts ...func(*BulkRequest)) error {
+	_, err := a.BulkWithResponse(ctx, index, writeReqBody, opts...)
+	return err
+}
+
+// BulkWithResponse records an event per action of the bulk body with the error of its item, the bulk is recorded as
+// a single event if the body isn't valid.
+func (a *auditESOper) BulkWithResponse(ctx context.Context, index string, writeReqBody func(ctx context.Context, buf *bytes.Buffer) error, opts ...func(*BulkRequest)) (*BulkIndexerResponse, error) {
+	var body []byte
+	resp, err := a.ESOper.BulkWithResponse(ctx, index, func(ctx context.Context, buf *bytes.Buffer) error {
+		start := buf.Len()
+		err := writeReqBody(ctx, buf)
+		body = append([]byte(nil), buf.Bytes()[start:]...)
+		return err
+	}, opts...)
+	a.auditBulk(ctx, index, body, resp, err)
+	return resp, err
+}
+
+type bulkActionMeta struct {
+	Index string `json:"_index"`
+	ID    string `json:"_id"`
+}
+
+func (a *auditESOper) auditBulk(ctx context.Context, index string, body []byte, resp *BulkIndexerResponse, err error) {
+	type bulkEvent struct {
+		op     AuditOp
+		index  string
+		id     string
+		source json.RawMessage
+	}
+	var events []*bulkEvent
+	for rest := body; len(rest) > 0; {
+		item, next, parseErr := nextBulkItem(rest)
+		if parseErr != nil {
+			a.audit(ctx, AuditOpBulk, index, "", nil, nil, err)
+			return
+		}
+		rest = next
+		action, source := nextLine(item)
+		if len(bytes.TrimSpace(action)) == 0 {
+			continue
+		}
+		m := map[string]*bulkActionMeta{}
+		if json.Unmarshal(action, &m) != nil || len(m) != 1 {
+			a.audit(ctx, AuditOpBulk, index, "", nil, nil, err)
+			return
+		}
+		for name, meta := range m {
+			e := &bulkEvent{op: AuditOp(name), index: index}
+			if meta != nil {
+				if meta.Index != "" {
+					e.index = meta.Index
+				}
+				e.id = meta.ID
+			}
+			if source = bytes.TrimSpace(source); json.Valid(source) {
+				e.source = json.RawMessage(source)
+			}
+			events = append(events, e)
+		}
+	}
+	for i, e := range events {
+		itemErr := err
+		if resp != nil && i < len(resp.Items) {
+			for _, res := range resp.Items[i] {
+				if e.id == "" {
+					e.id = res.DocumentID
+				}
+				if res.Error.Type != "" {
+					itemErr = fmt.Errorf("%s: %s", res.Error.Type, res.Error.Reason)
+				} else {
+					// the item is applied even if the later chunks of the bulk fail
+					itemErr = nil
+				}
+			}
+		}
+		var after interface{}
+		if e.source != nil {
+			after = e.source
+		}
+		a.audit(ctx, e.op, e.index, e.id, nil, after, itemErr)
+	}
+}
+
+func (a *auditESOper) Update(ctx context.Context, index string, id string, obj interface{}, opts ...func(*UpdateRequest)) error {
+	before := a.before(ctx, index, id)
+	err := a.ESOper.Update(ctx, index, id, obj, opts...)
+	a.audit(ctx, AuditOpUpdate, index, id, before, obj, err)
+	return err
+}
+
+func (a *auditESOper) UpdateScript(ctx context.Context, index string, id string, script *Script, opts ...func(*UpdateRequest)) error {
+	before := a.before(ctx, index, id)
+	err := a.ESOper.UpdateScript(ctx, index, id, script, opts...)
+	a.audit(ctx, AuditOpUpdate, index, id, before, script, err)
+	return err
+}
+
+func (a *auditESOper) Upsert(ctx context.Context, index string, id string, obj interface{}, opts ...func(*UpdateRequest)) error {
+	before := a.before(ctx, index, id)
+	err := a.ESOper.Upsert(ctx, index, id, obj, opts...)
+	a.audit(ctx, AuditOpUpdate, index, id, before, obj, err)
+	return err
+}
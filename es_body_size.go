@@ -0,0 +1,60 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultMaxBodySize - the default max size of the request body, the same as the http.max_content_length of the cluster.
+const DefaultMaxBodySize = 100 << 20
+
+// BodyTooLargeError - the request body exceeds the max size and is rejected without being sent.
+type BodyTooLargeError struct {
+	Op   string
+	Size int
+	Max  int
+}
+
+func (e *BodyTooLargeError) Error() string {
+	msg := fmt.Sprintf("nes: the request body of %s is %d bytes, exceeding the max size %d bytes", e.Op, e.Size, e.Max)
+	if e.Op == "Bulk" {
+		chunks := (e.Size + e.Max - 1) / e.Max
+		return msg + fmt.Sprintf(", split it into at least %d chunks or use NewBulkIndexer with FlushBytes below the max size", chunks)
+	}
+	return msg + ", reduce the size of the document or the query"
+}
+
+// IsBodyTooLarge reports whether the error is caused by the request body exceeding the max size.
+func IsBodyTooLarge(err error) bool {
+	var e *BodyTooLargeError
+	return errors.As(err, &e)
+}
+
+// WithMaxBodySize - the max size of the request body, DefaultMaxBodySize is used by default and
+// the size is not checked if it's negative.
+func WithMaxBodySize(max int) ESOperOption {
+	return func(e *esOper) {
+		e.maxBodySize = max
+	}
+}
+
+func (e *esOper) checkBodySize(op string, size int) error {
+	if e.maxBodySize < 0 || size <= e.maxBodySize {
+		return nil
+	}
+	return &BodyTooLargeError{Op: op, Size: size, Max: e.maxBodySize}
+}
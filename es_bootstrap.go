@@ -0,0 +1,313 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// BootstrapSpec - the desired state of the cluster, the resources are converged in the order of
+// ILM policies, SLM policies, ingest pipelines, component templates, index templates and indices.
+type BootstrapSpec struct {
+	ILMPolicies        map[string]interface{} `json:"ilm_policies,omitempty"`
+	SLMPolicies        map[string]interface{} `json:"slm_policies,omitempty"`
+	IngestPipelines    map[string]interface{} `json:"ingest_pipelines,omitempty"`
+	ComponentTemplates map[string]interface{} `json:"component_templates,omitempty"`
+	IndexTemplates     map[string]interface{} `json:"index_templates,omitempty"`
+	Indices            []*IndexSpec           `json:"indices,omitempty"`
+}
+
+// LoadBootstrapSpec decodes the spec from YAML or JSON.
+func LoadBootstrapSpec(data []byte) (*BootstrapSpec, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("nes: fail to decode the bootstrap spec: %w", err)
+	}
+	b, err := json.Marshal(yamlToJSON(v))
+	if err != nil {
+		return nil, fmt.Errorf("nes: fail to decode the bootstrap spec: %w", err)
+	}
+	spec := &BootstrapSpec{}
+	if err := json.Unmarshal(b, spec); err != nil {
+		return nil, fmt.Errorf("nes: fail to decode the bootstrap spec: %w", err)
+	}
+	return spec, nil
+}
+
+// yamlToJSON converts the maps decoded by yaml.v2 into the ones that can be encoded as JSON.
+func yamlToJSON(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			m[fmt.Sprint(k)] = yamlToJSON(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range t {
+			t[i] = yamlToJSON(e)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// BootstrapAction - the action to converge a resource.
+type BootstrapAction string
+
+// the bootstrap actions
+const (
+	BootstrapCreate       BootstrapAction = "create"
+	BootstrapUpdate       BootstrapAction = "update"
+	BootstrapUnchanged    BootstrapAction = "unchanged"
+	BootstrapIncompatible BootstrapAction = "incompatible"
+)
+
+// BootstrapChange - the change of a resource, Diffs are the paths differing from the cluster.
+type BootstrapChange struct {
+	Kind   string          `json:"kind"`
+	Name   string          `json:"name"`
+	Action BootstrapAction `json:"action"`
+	Diffs  []string        `json:"diffs,omitempty"`
+}
+
+// BootstrapPlan - the changes converging the cluster to the spec.
+type BootstrapPlan struct {
+	Changes []*BootstrapChange `json:"changes"`
+}
+
+// Pending reports whether the plan has any change to be applied.
+func (p *BootstrapPlan) Pending() bool {
+	for _, c := range p.Changes {
+		if c.Action != BootstrapUnchanged {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *BootstrapPlan) String() string {
+	var sb strings.Builder
+	for _, c := range p.Changes {
+		fmt.Fprintf(&sb, "%-12s %s %s\n", c.Action, c.Kind, c.Name)
+		for _, d := range c.Diffs {
+			fmt.Fprintf(&sb, "    ~ %s\n", d)
+		}
+	}
+	return sb.String()
+}
+
+// the kinds of the bootstrap resources
+const (
+	bootstrapILMPolicy         = "ilm_policy"
+	bootstrapSLMPolicy         = "slm_policy"
+	bootstrapIngestPipeline    = "ingest_pipeline"
+	bootstrapComponentTemplate = "component_template"
+	bootstrapIndexTemplate     = "index_template"
+	bootstrapIndex             = "index"
+)
+
+type bootstrapResource struct {
+	kind string
+	get  func(ctx context.Context, name string) (map[string]interface{}, error)
+	put  func(ctx context.Context, name string, body interface{}) error
+}
+
+func (e *esAdminOper) Bootstrap(ctx context.Context, spec *BootstrapSpec, dryRun bool) (*BootstrapPlan, error) {
+	plan := &BootstrapPlan{}
+	resources := []struct {
+		bootstrapResource
+		desired map[string]interface{}
+	}{
+		{bootstrapResource{bootstrapILMPolicy,
+			func(ctx context.Context, name string) (map[string]interface{}, error) {
+				return e.GetILMPolicy(ctx, name)
+			},
+			func(ctx context.Context, name string, body interface{}) error { return e.PutILMPolicy(ctx, name, body) },
+		}, spec.ILMPolicies},
+		{bootstrapResource{bootstrapSLMPolicy,
+			func(ctx context.Context, name string) (map[string]interface{}, error) {
+				return e.GetSLMPolicy(ctx, name)
+			},
+			func(ctx context.Context, name string, body interface{}) error { return e.PutSLMPolicy(ctx, name, body) },
+		}, spec.SLMPolicies},
+		{bootstrapResource{bootstrapIngestPipeline,
+			func(ctx context.Context, name string) (map[string]interface{}, error) {
+				return e.GetPipeline(ctx, name)
+			},
+			func(ctx context.Context, name string, body interface{}) error { return e.PutPipeline(ctx, name, body) },
+		}, spec.IngestPipelines},
+		{bootstrapResource{bootstrapComponentTemplate,
+			func(ctx context.Context, name string) (map[string]interface{}, error) {
+				return e.GetComponentTemplate(ctx, name)
+			},
+			func(ctx context.Context, name string, body interface{}) error {
+				return e.PutComponentTemplate(ctx, name, body)
+			},
+		}, spec.ComponentTemplates},
+		{bootstrapResource{bootstrapIndexTemplate,
+			func(ctx context.Context, name string) (map[string]interface{}, error) {
+				return e.GetIndexTemplate(ctx, name)
+			},
+			func(ctx context.Context, name string, body interface{}) error {
+				return e.PutIndexTemplate(ctx, name, body)
+			},
+		}, spec.IndexTemplates},
+	}
+	for _, r := range resources {
+		names := make([]string, 0, len(r.desired))
+		for name := range r.desired {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			c, err := e.bootstrapResource(ctx, r.bootstrapResource, name, r.desired[name], dryRun)
+			if err != nil {
+				return plan, err
+			}
+			plan.Changes = append(plan.Changes, c)
+		}
+	}
+	for _, index := range spec.Indices {
+		c, err := e.bootstrapIndex(ctx, index, dryRun)
+		if err != nil {
+			return plan, err
+		}
+		plan.Changes = append(plan.Changes, c)
+	}
+	return plan, nil
+}
+
+func (e *esAdminOper) bootstrapResource(ctx context.Context, r bootstrapResource, name string, desired interface{}, dryRun bool) (*BootstrapChange, error) {
+	c := &BootstrapChange{Kind: r.kind, Name: name}
+	current, err := r.get(ctx, name)
+	if err != nil && !IsNotFound(err) {
+		return nil, err
+	}
+	if current == nil {
+		c.Action = BootstrapCreate
+	} else {
+		c.Diffs = diffJSONSubset("", normalizeJSON(current), normalizeJSON(desired))
+		if len(c.Diffs) == 0 {
+			c.Action = BootstrapUnchanged
+			return c, nil
+		}
+		c.Action = BootstrapUpdate
+	}
+	if dryRun {
+		return c, nil
+	}
+	return c, r.put(ctx, name, desired)
+}
+
+func (e *esAdminOper) bootstrapIndex(ctx context.Context, spec *IndexSpec, dryRun bool) (*BootstrapChange, error) {
+	c := &BootstrapChange{Kind: bootstrapIndex, Name: spec.Name}
+	exists, err := e.IndexExists(ctx, []string{spec.Name})
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		c.Action = BootstrapCreate
+	} else {
+		mappings, err := e.GetMapping(ctx, []string{spec.Name})
+		if err != nil {
+			return nil, err
+		}
+		desired, _ := normalizeJSON(spec.Mappings).(map[string]interface{})
+		desiredProps, _ := desired["properties"].(map[string]interface{})
+		mc := &mappingChanges{}
+		for _, current := range mappings {
+			currentProps, _ := current["properties"].(map[string]interface{})
+			diffProperties("", currentProps, desiredProps, mc)
+		}
+		for _, f := range mc.added {
+			c.Diffs = append(c.Diffs, "+ mappings."+f)
+		}
+		for _, f := range mc.changed {
+			c.Diffs = append(c.Diffs, "mappings."+f)
+		}
+		for _, f := range mc.incompatible {
+			c.Diffs = append(c.Diffs, "! mappings."+f)
+		}
+		sort.Strings(c.Diffs)
+		switch {
+		case len(mc.incompatible) > 0:
+			c.Action = BootstrapIncompatible
+			sort.Strings(mc.incompatible)
+			return c, &IncompatibleMappingError{Index: spec.Name, Fields: mc.incompatible}
+		case len(c.Diffs) > 0:
+			c.Action = BootstrapUpdate
+		default:
+			// the missing aliases are added by EnsureIndex as well
+			c.Action = BootstrapUnchanged
+		}
+	}
+	if dryRun {
+		return c, nil
+	}
+	return c, e.EnsureIndex(ctx, spec)
+}
+
+// diffJSONSubset returns the paths of the desired values which are missing or different in the current value,
+// the values absent in the desired one are ignored since the cluster fills the defaults.
+func diffJSONSubset(path string, current interface{}, desired interface{}) []string {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		cm, ok := current.(map[string]interface{})
+		if !ok {
+			return []string{bootstrapPath(path)}
+		}
+		var diffs []string
+		for k, v := range d {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			diffs = append(diffs, diffJSONSubset(p, cm[k], v)...)
+		}
+		sort.Strings(diffs)
+		return diffs
+	case []interface{}:
+		cs, ok := current.([]interface{})
+		if !ok || len(cs) != len(d) {
+			return []string{bootstrapPath(path)}
+		}
+		var diffs []string
+		for i := range d {
+			diffs = append(diffs, diffJSONSubset(fmt.Sprintf("%s[%d]", path, i), cs[i], d[i])...)
+		}
+		return diffs
+	default:
+		// the cluster returns the settings as strings
+		if current == nil || fmt.Sprint(current) != fmt.Sprint(desired) {
+			return []string{bootstrapPath(path)}
+		}
+		return nil
+	}
+}
+
+func bootstrapPath(path string) string {
+	if path == "" {
+		return "."
+	}
+	return path
+}
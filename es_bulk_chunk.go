@@ -0,0 +1,133 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultBulkChunkSize - the default max size of each request of the bulk, a margin below DefaultMaxBodySize
+// for the request line and the headers.
+const DefaultBulkChunkSize = 90 << 20
+
+// WithBulkChunkSize - the max size of each request of the bulk, DefaultBulkChunkSize is used by default,
+// it's capped by the max body size of the oper.
+func WithBulkChunkSize(size int) ESOperOption {
+	return func(e *esOper) {
+		e.bulkChunkSize = size
+	}
+}
+
+func (e *esOper) BulkWithResponse(ctx context.Context, index string, writeReqBody func(ctx context.Context, buf *bytes.Buffer) error, opts ...func(*BulkRequest)) (*BulkIndexerResponse, error) {
+	var buf bytes.Buffer
+	if err := writeReqBody(ctx, &buf); err != nil {
+		return nil, err
+	}
+	chunkSize := e.bulkChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultBulkChunkSize
+	}
+	if e.maxBodySize >= 0 && chunkSize > e.maxBodySize {
+		chunkSize = e.maxBodySize
+	}
+	chunks := [][]byte{buf.Bytes()}
+	if buf.Len() > chunkSize {
+		var err error
+		if chunks, err = splitBulkBody(buf.Bytes(), chunkSize); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &BulkIndexerResponse{}
+	for _, chunk := range chunks {
+		resp, err := e.bulk(ctx, index, chunk, opts...)
+		if err != nil {
+			return result, err
+		}
+		result.Took += resp.Took
+		result.HasErrors = result.HasErrors || resp.HasErrors
+		result.Items = append(result.Items, resp.Items...)
+	}
+	return result, nil
+}
+
+func (e *esOper) bulk(ctx context.Context, index string, body []byte, opts ...func(*BulkRequest)) (*BulkIndexerResponse, error) {
+	api := e.client
+	o := append([]func(*BulkRequest){api.Bulk.WithIndex(index), api.Bulk.WithContext(ctx)}, e.bulkDefaults(index)...)
+	o = append(o, opts...)
+	resp, err := api.Bulk(bytes.NewReader(body), o...)
+	if err != nil {
+		return nil, err
+	}
+	respBody := &BulkIndexerResponse{}
+	if err := unmarshallResponse(resp, respBody); err != nil {
+		return nil, err
+	}
+	return respBody, nil
+}
+
+// splitBulkBody splits the NDJSON body of the bulk into the chunks no larger than the size, an action and its source
+// are kept in the same chunk. The error of BodyTooLargeError is returned if a single action exceeds the size.
+func splitBulkBody(body []byte, size int) ([][]byte, error) {
+	var chunks [][]byte
+	var chunk []byte
+	for len(body) > 0 {
+		item, rest, err := nextBulkItem(body)
+		if err != nil {
+			return nil, err
+		}
+		body = rest
+		if len(item) > size {
+			return nil, &BodyTooLargeError{Op: "Bulk", Size: len(item), Max: size}
+		}
+		if len(chunk)+len(item) > size {
+			chunks = append(chunks, chunk)
+			chunk = nil
+		}
+		chunk = append(chunk, item...)
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// nextBulkItem returns the action line with its source line if any, the delete action has no source.
+func nextBulkItem(body []byte) ([]byte, []byte, error) {
+	action, rest := nextLine(body)
+	if len(bytes.TrimSpace(action)) == 0 {
+		return action, rest, nil
+	}
+	m := map[string]json.RawMessage{}
+	if err := json.Unmarshal(action, &m); err != nil {
+		return nil, nil, fmt.Errorf("nes: the bulk action is not a JSON object: %w", err)
+	}
+	if _, ok := m["delete"]; ok {
+		return action, rest, nil
+	}
+	source, rest := nextLine(rest)
+	return body[:len(action)+len(source)], rest, nil
+}
+
+func nextLine(body []byte) ([]byte, []byte) {
+	i := bytes.IndexByte(body, '\n')
+	if i < 0 {
+		return body, nil
+	}
+	return body[:i+1], body[i+1:]
+}
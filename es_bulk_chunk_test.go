@@ -0,0 +1,52 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestBulkChunksStayUnderTheMaxContentLength(t *testing.T) {
+	flaky := &flakyTransport{response: `{"took":1,"errors":false,"items":[]}`}
+	oper := newFlakyOper(t, &ESConfig{}, flaky)
+	doc := bytes.Repeat([]byte("x"), 1<<20)
+	// a bit larger than the http.max_content_length of the cluster
+	const docs = 101
+	err := oper.Bulk(context.Background(), "i", func(ctx context.Context, buf *bytes.Buffer) error {
+		for i := 0; i < docs; i++ {
+			fmt.Fprintf(buf, "{\"index\":{\"_id\":\"%d\"}}\n{\"doc\":\"%s\"}\n", i, doc)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flaky.bodies) < 2 {
+		t.Fatalf("%d requests, expected the bulk to be chunked", len(flaky.bodies))
+	}
+	total := 0
+	for i, body := range flaky.bodies {
+		if len(body) > DefaultBulkChunkSize {
+			t.Errorf("the chunk %d is %d bytes, over the DefaultBulkChunkSize", i, len(body))
+		}
+		total += len(body)
+	}
+	if total <= DefaultMaxBodySize {
+		t.Errorf("the bulk is %d bytes, expected it over the DefaultMaxBodySize", total)
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// FollowSpec - the leader index in the remote cluster replicated into the follower index.
+type FollowSpec struct {
+	RemoteCluster string `json:"remote_cluster"`
+	LeaderIndex   string `json:"leader_index"`
+	// Settings overrides the settings of the follower index copied from the leader index.
+	Settings                     map[string]interface{} `json:"settings,omitempty"`
+	MaxReadRequestOperationCount int                    `json:"max_read_request_operation_count,omitempty"`
+	MaxOutstandingReadRequests   int                    `json:"max_outstanding_read_requests,omitempty"`
+	MaxWriteBufferSize           string                 `json:"max_write_buffer_size,omitempty"`
+	MaxRetryDelay                string                 `json:"max_retry_delay,omitempty"`
+	ReadPollTimeout              string                 `json:"read_poll_timeout,omitempty"`
+}
+
+// FollowerStatus - the status of the follower index.
+type FollowerStatus string
+
+// the statuses of the follower indices
+const (
+	FollowerActive FollowerStatus = "active"
+	FollowerPaused FollowerStatus = "paused"
+)
+
+// FollowerInfo - the leader and the status of a follower index, Parameters are absent if it's paused.
+type FollowerInfo struct {
+	FollowerIndex string                 `json:"follower_index"`
+	RemoteCluster string                 `json:"remote_cluster"`
+	LeaderIndex   string                 `json:"leader_index"`
+	Status        FollowerStatus         `json:"status"`
+	Parameters    map[string]interface{} `json:"parameters,omitempty"`
+}
+
+type followInfoResponseBody struct {
+	FollowerIndices []*FollowerInfo `json:"follower_indices"`
+}
+
+// FollowerStats - the replication stats of a follower index, the lag is the number of the operations
+// the follower shards are behind the leader shards.
+type FollowerStats struct {
+	Index                    string                `json:"index"`
+	TotalGlobalCheckpointLag int64                 `json:"total_global_checkpoint_lag"`
+	Shards                   []*FollowerShardStats `json:"shards"`
+}
+
+// FollowerShardStats - the replication stats of a follower shard.
+type FollowerShardStats struct {
+	RemoteCluster            string                 `json:"remote_cluster"`
+	LeaderIndex              string                 `json:"leader_index"`
+	ShardID                  int                    `json:"shard_id"`
+	LeaderGlobalCheckpoint   int64                  `json:"leader_global_checkpoint"`
+	FollowerGlobalCheckpoint int64                  `json:"follower_global_checkpoint"`
+	OperationsWritten        int64                  `json:"operations_written"`
+	FailedReadRequests       int64                  `json:"failed_read_requests"`
+	FailedWriteRequests      int64                  `json:"failed_write_requests"`
+	TimeSinceLastReadMillis  int64                  `json:"time_since_last_read_millis"`
+	FatalException           map[string]interface{} `json:"fatal_exception,omitempty"`
+}
+
+type followStatsResponseBody struct {
+	Indices []*FollowerStats `json:"indices"`
+}
+
+// AutoFollowPattern - the leader indices in the remote cluster followed automatically once they are created,
+// FollowIndexPattern names the follower indices like {{leader_index}}-follower.
+type AutoFollowPattern struct {
+	RemoteCluster                string                 `json:"remote_cluster"`
+	LeaderIndexPatterns          []string               `json:"leader_index_patterns"`
+	LeaderIndexExclusionPatterns []string               `json:"leader_index_exclusion_patterns,omitempty"`
+	FollowIndexPattern           string                 `json:"follow_index_pattern,omitempty"`
+	Settings                     map[string]interface{} `json:"settings,omitempty"`
+}
+
+// AutoFollowPatternInfo - an auto-follow pattern and whether it's active or paused.
+type AutoFollowPatternInfo struct {
+	Name    string
+	Active  bool
+	Pattern *AutoFollowPattern
+}
+
+type autoFollowPatternsResponseBody struct {
+	Patterns []*struct {
+		Name    string `json:"name"`
+		Pattern *struct {
+			AutoFollowPattern
+			Active bool `json:"active"`
+		} `json:"pattern"`
+	} `json:"patterns"`
+}
+
+func (e *esAdminOper) Follow(ctx context.Context, followerIndex string, spec *FollowSpec, opts ...func(*CCRFollowRequest)) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(spec); err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*CCRFollowRequest){api.CCR.Follow.WithContext(ctx)}, opts...)
+	resp, err := api.CCR.Follow(followerIndex, body, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) PauseFollow(ctx context.Context, followerIndex string, opts ...func(*CCRPauseFollowRequest)) error {
+	api := e.client
+	o := append([]func(*CCRPauseFollowRequest){api.CCR.PauseFollow.WithContext(ctx)}, opts...)
+	resp, err := api.CCR.PauseFollow(followerIndex, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) ResumeFollow(ctx context.Context, followerIndex string, opts ...func(*CCRResumeFollowRequest)) error {
+	api := e.client
+	o := append([]func(*CCRResumeFollowRequest){api.CCR.ResumeFollow.WithContext(ctx)}, opts...)
+	resp, err := api.CCR.ResumeFollow(followerIndex, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) Unfollow(ctx context.Context, followerIndex string, opts ...func(*CCRUnfollowRequest)) error {
+	infos, err := e.FollowInfo(ctx, []string{followerIndex})
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if info.FollowerIndex == followerIndex && info.Status == FollowerActive {
+			if err := e.PauseFollow(ctx, followerIndex); err != nil {
+				return err
+			}
+		}
+	}
+	api := e.client
+	closeResp, err := api.Indices.Close([]string{followerIndex}, api.Indices.Close.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer closeResponse(closeResp)
+	if closeResp.IsError() {
+		return newRespErr(closeResp)
+	}
+
+	o := append([]func(*CCRUnfollowRequest){api.CCR.Unfollow.WithContext(ctx)}, opts...)
+	if err := e.unfollowClosed(followerIndex, o...); err != nil {
+		// reopen the index still following, even if the ctx is done
+		if openErr := e.openIndex(context.WithoutCancel(ctx), followerIndex); openErr != nil {
+			return fmt.Errorf("nes: the follower index %s is left closed, fail to reopen it: %s: %w", followerIndex, openErr, err)
+		}
+		return err
+	}
+	if err := e.openIndex(ctx, followerIndex); err != nil {
+		return fmt.Errorf("nes: the index %s is unfollowed but left closed: %w", followerIndex, err)
+	}
+	return nil
+}
+
+func (e *esAdminOper) unfollowClosed(followerIndex string, opts ...func(*CCRUnfollowRequest)) error {
+	resp, err := e.client.CCR.Unfollow(followerIndex, opts...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) openIndex(ctx context.Context, index string) error {
+	api := e.client
+	resp, err := api.Indices.Open([]string{index}, api.Indices.Open.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) FollowInfo(ctx context.Context, followerIndexes []string, opts ...func(*CCRFollowInfoRequest)) ([]*FollowerInfo, error) {
+	api := e.client
+	o := append([]func(*CCRFollowInfoRequest){api.CCR.FollowInfo.WithContext(ctx)}, opts...)
+	resp, err := api.CCR.FollowInfo(followerIndexes, o...)
+	if err != nil {
+		return nil, err
+	}
+	respBody := &followInfoResponseBody{}
+	if err := unmarshallResponse(resp, respBody); err != nil {
+		return nil, err
+	}
+	return respBody.FollowerIndices, nil
+}
+
+func (e *esAdminOper) FollowStats(ctx context.Context, followerIndexes []string, opts ...func(*CCRFollowStatsRequest)) ([]*FollowerStats, error) {
+	api := e.client
+	o := append([]func(*CCRFollowStatsRequest){api.CCR.FollowStats.WithContext(ctx)}, opts...)
+	resp, err := api.CCR.FollowStats(followerIndexes, o...)
+	if err != nil {
+		return nil, err
+	}
+	respBody := &followStatsResponseBody{}
+	if err := unmarshallResponse(resp, respBody); err != nil {
+		return nil, err
+	}
+	return respBody.Indices, nil
+}
+
+func (e *esAdminOper) PutAutoFollowPattern(ctx context.Context, name string, pattern *AutoFollowPattern, opts ...func(*CCRPutAutoFollowPatternRequest)) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(pattern); err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*CCRPutAutoFollowPatternRequest){api.CCR.PutAutoFollowPattern.WithContext(ctx)}, opts...)
+	resp, err := api.CCR.PutAutoFollowPattern(name, body, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) GetAutoFollowPatterns(ctx context.Context, opts ...func(*CCRGetAutoFollowPatternRequest)) ([]*AutoFollowPatternInfo, error) {
+	api := e.client
+	o := append([]func(*CCRGetAutoFollowPatternRequest){api.CCR.GetAutoFollowPattern.WithContext(ctx)}, opts...)
+	resp, err := api.CCR.GetAutoFollowPattern(o...)
+	if err != nil {
+		return nil, err
+	}
+	respBody := &autoFollowPatternsResponseBody{}
+	if err := unmarshallResponse(resp, respBody); err != nil {
+		return nil, err
+	}
+	patterns := make([]*AutoFollowPatternInfo, 0, len(respBody.Patterns))
+	for _, p := range respBody.Patterns {
+		if p == nil {
+			continue
+		}
+		info := &AutoFollowPatternInfo{Name: p.Name}
+		if p.Pattern != nil {
+			pattern := p.Pattern.AutoFollowPattern
+			info.Active, info.Pattern = p.Pattern.Active, &pattern
+		}
+		patterns = append(patterns, info)
+	}
+	return patterns, nil
+}
+
+func (e *esAdminOper) DeleteAutoFollowPattern(ctx context.Context, name string, opts ...func(*CCRDeleteAutoFollowPatternRequest)) error {
+	api := e.client
+	o := append([]func(*CCRDeleteAutoFollowPatternRequest){api.CCR.DeleteAutoFollowPattern.WithContext(ctx)}, opts...)
+	resp, err := api.CCR.DeleteAutoFollowPattern(name, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) PauseAutoFollowPattern(ctx context.Context, name string, opts ...func(*CCRPauseAutoFollowPatternRequest)) error {
+	api := e.client
+	o := append([]func(*CCRPauseAutoFollowPatternRequest){api.CCR.PauseAutoFollowPattern.WithContext(ctx)}, opts...)
+	resp, err := api.CCR.PauseAutoFollowPattern(name, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) ResumeAutoFollowPattern(ctx context.Context, name string, opts ...func(*CCRResumeAutoFollowPatternRequest)) error {
+	api := e.client
+	o := append([]func(*CCRResumeAutoFollowPatternRequest){api.CCR.ResumeAutoFollowPattern.WithContext(ctx)}, opts...)
+	resp, err := api.CCR.ResumeAutoFollowPattern(name, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUnfollowReopensTheIndexOnFailures(t *testing.T) {
+	routes := map[string]stubResponse{
+		"GET /f/_ccr/info":      {http.StatusOK, `{"follower_indices":[{"follower_index":"f","status":"paused"}]}`},
+		"POST /f/_close":        {http.StatusOK, `{"acknowledged":true}`},
+		"POST /f/_ccr/unfollow": {http.StatusBadRequest, `{"error":{"type":"illegal_argument_exception","reason":"boom"},"status":400}`},
+		"POST /f/_open":         {http.StatusOK, `{"acknowledged":true}`},
+	}
+	stub := &routeTransport{routes: routes}
+	client, err := NewESClient(&ESConfig{Addrs: []string{"http://nes.invalid:9200"}, Version: "8.13.1"}, WithBaseTransport(stub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oper := NewESAdminOper(client)
+
+	err = oper.Unfollow(context.Background(), "f")
+	if err == nil || strings.Contains(err.Error(), "left closed") {
+		t.Fatalf("unfollow error %v, want the error of the unfollowing only", err)
+	}
+	if sent := stub.sent(); sent[len(sent)-1] != "POST /f/_open" {
+		t.Errorf("requests %v, want the index reopened", sent)
+	}
+
+	routes["POST /f/_open"] = stubResponse{http.StatusForbidden, `{"error":{"type":"security_exception","reason":"denied"},"status":403}`}
+	if err := oper.Unfollow(context.Background(), "f"); err == nil || !strings.Contains(err.Error(), "left closed") {
+		t.Fatalf("unfollow error %v, want it to tell the index is left closed", err)
+	}
+}
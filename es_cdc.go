@@ -0,0 +1,320 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nf-go/nfgo/nlog"
+	"github.com/nf-go/nfgo/nutil/graceful"
+	"go.uber.org/multierr"
+)
+
+// ChangeOp - the type of the change.
+type ChangeOp string
+
+const (
+	// ChangeOpUpsert - the document is indexed.
+	ChangeOpUpsert ChangeOp = "upsert"
+	// ChangeOpDelete - the document is deleted.
+	ChangeOpDelete ChangeOp = "delete"
+)
+
+// ChangeEvent - a change of a document consumed from the source.
+type ChangeEvent struct {
+	Op    ChangeOp
+	Index string
+	ID    string
+	// Doc is the source of the upserted document.
+	Doc json.RawMessage
+	// Version is the external version of the change like the LSN, stale changes are discarded by the cluster
+	// if it's positive.
+	Version int64
+	// Position is the position of the event in the source like the Kafka offset, used by the source to commit.
+	Position interface{}
+}
+
+// ChangeSource - the source of the change events.
+type ChangeSource interface {
+	// Next blocks until the next event is consumed or the context is done.
+	Next(ctx context.Context) (*ChangeEvent, error)
+	// Commit commits the positions of the events which are applied to the cluster.
+	Commit(ctx context.Context, events []*ChangeEvent) error
+}
+
+// CDCConfig -
+type CDCConfig struct {
+	Source ChangeSource
+	Oper   ESOper
+	// DeadLetterSink receives the changes failed to be applied instead of the sink of the oper, the batch is not
+	// committed and the bridge stops if a change fails without the sink.
+	DeadLetterSink DeadLetterSink
+	// BatchSize is the max number of the events of a batch, 1000 by default.
+	BatchSize int
+	// FlushInterval is the max time the events wait in a batch, 1s by default.
+	FlushInterval time.Duration
+}
+
+// NewCDCBridge - the bridge applying the change events of the source to the cluster in batches, the events are
+// applied in the order they're consumed within a single bulk worker, so the changes of the same document keep their
+// order, and the positions are committed after each batch is flushed. It's a graceful.ShutdownServer.
+func NewCDCBridge(config *CDCConfig) graceful.ShutdownServer {
+	c := *config
+	if c.BatchSize <= 0 {
+		c.BatchSize = 1000
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = time.Second
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &cdcBridge{
+		config:  &c,
+		ctx:     ctx,
+		cancel:  cancel,
+		stopped: make(chan struct{}),
+	}
+}
+
+type cdcBridge struct {
+	config  *CDCConfig
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped chan struct{}
+
+	mu      sync.Mutex
+	serving bool
+}
+
+type consumedChange struct {
+	event *ChangeEvent
+	err   error
+}
+
+func (b *cdcBridge) consume(events chan<- *consumedChange) {
+	defer close(events)
+	for {
+		event, err := b.config.Source.Next(b.ctx)
+		if b.ctx.Err() != nil {
+			return
+		}
+		select {
+		case events <- &consumedChange{event: event, err: err}:
+		case <-b.ctx.Done():
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Serve applies the events until the bridge is shutdown or an error occurs.
+func (b *cdcBridge) Serve() error {
+	b.mu.Lock()
+	b.serving = true
+	b.mu.Unlock()
+	defer close(b.stopped)
+	// stops consuming if it returns on an error
+	defer b.cancel()
+	events := make(chan *consumedChange, b.config.BatchSize)
+	go b.consume(events)
+
+	ticker := time.NewTicker(b.config.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]*ChangeEvent, 0, b.config.BatchSize)
+	// the pending batch is flushed with a fresh context on the shutdown
+	flush := func(ctx context.Context) error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := b.apply(ctx, batch)
+		batch = batch[:0]
+		return err
+	}
+	for {
+		select {
+		case c, ok := <-events:
+			if !ok {
+				return flush(context.Background())
+			}
+			if c.err != nil {
+				return multierr.Append(c.err, flush(context.Background()))
+			}
+			batch = append(batch, c.event)
+			if len(batch) >= b.config.BatchSize {
+				if err := flush(b.ctx); err != nil {
+					return err
+				}
+			}
+		case <-ticker.C:
+			if err := flush(b.ctx); err != nil {
+				return err
+			}
+		case <-b.ctx.Done():
+			return flush(context.Background())
+		}
+	}
+}
+
+func (b *cdcBridge) apply(ctx context.Context, batch []*ChangeEvent) error {
+	indexer, err := b.config.Oper.NewBulkIndexer(BulkIndexerConfig{NumWorkers: 1, FlushBytes: DefaultMaxBodySize / 2})
+	if err != nil {
+		return err
+	}
+	var mu sync.Mutex
+	var failures []error
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failures = append(failures, err)
+	}
+	// the failed items are dead-lettered to the sink of the bridge by the indexer instead of the one of the oper
+	var sink DeadLetterSink
+	if b.config.DeadLetterSink != nil {
+		sink = DeadLetterSinkFunc(func(ctx context.Context, letter *DeadLetter) error {
+			if err := b.config.DeadLetterSink.WriteDeadLetter(ctx, letter); err != nil {
+				fail(fmt.Errorf("nes cdc: fail to write the dead letter of %s %s/%s: %w", letter.Action, letter.Index, letter.DocumentID, err))
+				return err
+			}
+			return nil
+		})
+	}
+	addCtx := contextWithDeadLetterSink(ctx, sink)
+	for _, event := range batch {
+		item, err := changeItem(event)
+		if err != nil {
+			fail(err)
+			continue
+		}
+		if sink == nil {
+			item.OnFailure = func(ctx context.Context, item BulkIndexerItem, res BulkIndexerResponseItem, err error) {
+				if err == nil && item.VersionType != "" && IsStaleVersion(res) {
+					return
+				}
+				if err == nil {
+					err = fmt.Errorf("nes cdc: fail to %s %s/%s: %s %s", item.Action, item.Index, item.DocumentID, res.Error.Type, res.Error.Reason)
+				}
+				fail(err)
+			}
+		}
+		if err := indexer.Add(addCtx, item); err != nil {
+			fail(err)
+		}
+	}
+	if err := indexer.Close(ctx); err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		return multierr.Combine(failures...)
+	}
+	return b.config.Source.Commit(ctx, batch)
+}
+
+func changeItem(event *ChangeEvent) (BulkIndexerItem, error) {
+	item := BulkIndexerItem{Index: event.Index, DocumentID: event.ID}
+	switch event.Op {
+	case ChangeOpUpsert:
+		item.Action = "index"
+		item.Body = bytes.NewReader(event.Doc)
+	case ChangeOpDelete:
+		item.Action = "delete"
+	default:
+		return item, fmt.Errorf("nes cdc: unknown change op %s of %s/%s", event.Op, event.Index, event.ID)
+	}
+	if event.Version > 0 {
+		item = ExternalVersionItem(item, event.Version, VersionTypeExternal)
+	}
+	return item, nil
+}
+
+func (b *cdcBridge) MustServe() {
+	if err := b.Serve(); err != nil {
+		nlog.Fatal("fail to serve the nes cdc bridge: ", err)
+	}
+}
+
+// Shutdown stops consuming the events, the pending batch is flushed and committed before it returns, it returns at
+// once if it's not served.
+func (b *cdcBridge) Shutdown(ctx context.Context) error {
+	b.cancel()
+	b.mu.Lock()
+	serving := b.serving
+	b.mu.Unlock()
+	if !serving {
+		return nil
+	}
+	select {
+	case <-b.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// KafkaMessage - the message of the Kafka consumer.
+type KafkaMessage struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   map[string][]byte
+}
+
+// KafkaConsumer - the Kafka consumer adapted as the change source, e.g. the Reader of segmentio/kafka-go
+// whose FetchMessage and CommitMessages convert the messages. The messages of the same document should be
+// produced with the same key, so they're in the same partition and consumed in order.
+type KafkaConsumer interface {
+	Fetch(ctx context.Context) (*KafkaMessage, error)
+	Commit(ctx context.Context, msgs ...*KafkaMessage) error
+}
+
+// NewKafkaChangeSource - the change source consuming the messages decoded into the events by the decoder.
+func NewKafkaChangeSource(consumer KafkaConsumer, decode func(msg *KafkaMessage) (*ChangeEvent, error)) ChangeSource {
+	return &kafkaChangeSource{consumer: consumer, decode: decode}
+}
+
+type kafkaChangeSource struct {
+	consumer KafkaConsumer
+	decode   func(msg *KafkaMessage) (*ChangeEvent, error)
+}
+
+func (s *kafkaChangeSource) Next(ctx context.Context) (*ChangeEvent, error) {
+	msg, err := s.consumer.Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	event, err := s.decode(msg)
+	if err != nil {
+		return nil, fmt.Errorf("nes cdc: fail to decode the message %s/%d/%d: %w", msg.Topic, msg.Partition, msg.Offset, err)
+	}
+	event.Position = msg
+	return event, nil
+}
+
+func (s *kafkaChangeSource) Commit(ctx context.Context, events []*ChangeEvent) error {
+	msgs := make([]*KafkaMessage, 0, len(events))
+	for _, e := range events {
+		if msg, ok := e.Position.(*KafkaMessage); ok {
+			msgs = append(msgs, msg)
+		}
+	}
+	return s.consumer.Commit(ctx, msgs...)
+}
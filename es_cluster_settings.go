@@ -0,0 +1,253 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	settingDiskWatermarkLow        = "cluster.routing.allocation.disk.watermark.low"
+	settingDiskWatermarkHigh       = "cluster.routing.allocation.disk.watermark.high"
+	settingDiskWatermarkFloodStage = "cluster.routing.allocation.disk.watermark.flood_stage"
+	settingAllocationEnable        = "cluster.routing.allocation.enable"
+	settingRebalanceEnable         = "cluster.routing.rebalance.enable"
+	settingConcurrentRebalance     = "cluster.routing.allocation.cluster_concurrent_rebalance"
+)
+
+// ClusterSettings - the flat settings of the cluster, e.g. cluster.routing.allocation.enable.
+type ClusterSettings struct {
+	Persistent map[string]interface{} `json:"persistent"`
+	Transient  map[string]interface{} `json:"transient"`
+	Defaults   map[string]interface{} `json:"defaults,omitempty"`
+}
+
+// Get returns the effective value of the setting, the transient one takes precedence over the persistent one,
+// which takes precedence over the default one.
+func (s *ClusterSettings) Get(key string) (interface{}, bool) {
+	for _, settings := range []map[string]interface{}{s.Transient, s.Persistent, s.Defaults} {
+		if v, ok := settings[key]; ok && v != nil {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// GetString returns the effective value of the setting as a string.
+func (s *ClusterSettings) GetString(key string) string {
+	v, ok := s.Get(key)
+	if !ok {
+		return ""
+	}
+	if str, ok := v.(string); ok {
+		return str
+	}
+	return fmt.Sprint(v)
+}
+
+// DiskWatermarks - the disk watermarks of the shard allocation, all of them are percentages like 85%, ratios
+// like 0.85 or byte values of the free space like 50gb.
+type DiskWatermarks struct {
+	Low        string
+	High       string
+	FloodStage string
+}
+
+// DiskWatermarks returns the effective disk watermarks.
+func (s *ClusterSettings) DiskWatermarks() *DiskWatermarks {
+	return &DiskWatermarks{
+		Low:        s.GetString(settingDiskWatermarkLow),
+		High:       s.GetString(settingDiskWatermarkHigh),
+		FloodStage: s.GetString(settingDiskWatermarkFloodStage),
+	}
+}
+
+// AllocationEnable returns the effective cluster.routing.allocation.enable.
+func (s *ClusterSettings) AllocationEnable() string {
+	return s.GetString(settingAllocationEnable)
+}
+
+// RebalanceEnable returns the effective cluster.routing.rebalance.enable.
+func (s *ClusterSettings) RebalanceEnable() string {
+	return s.GetString(settingRebalanceEnable)
+}
+
+// ConcurrentRebalance returns the effective cluster.routing.allocation.cluster_concurrent_rebalance, -1 if it's unknown.
+func (s *ClusterSettings) ConcurrentRebalance() int {
+	n, err := strconv.Atoi(s.GetString(settingConcurrentRebalance))
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// ClusterSettingsUpdate - the settings to be updated, a nil value resets the setting to the default.
+type ClusterSettingsUpdate struct {
+	Persistent map[string]interface{} `json:"persistent,omitempty"`
+	Transient  map[string]interface{} `json:"transient,omitempty"`
+}
+
+// Set sets the persistent setting, or the transient one if transient is true.
+func (u *ClusterSettingsUpdate) Set(key string, value interface{}, transient bool) *ClusterSettingsUpdate {
+	if transient {
+		if u.Transient == nil {
+			u.Transient = map[string]interface{}{}
+		}
+		u.Transient[key] = value
+		return u
+	}
+	if u.Persistent == nil {
+		u.Persistent = map[string]interface{}{}
+	}
+	u.Persistent[key] = value
+	return u
+}
+
+// SetDiskWatermarks sets the non-empty disk watermarks.
+func (u *ClusterSettingsUpdate) SetDiskWatermarks(w *DiskWatermarks, transient bool) *ClusterSettingsUpdate {
+	for key, v := range map[string]string{
+		settingDiskWatermarkLow:        w.Low,
+		settingDiskWatermarkHigh:       w.High,
+		settingDiskWatermarkFloodStage: w.FloodStage,
+	} {
+		if v != "" {
+			u.Set(key, v, transient)
+		}
+	}
+	return u
+}
+
+// SetAllocationEnable sets the cluster.routing.allocation.enable, one of all, primaries, new_primaries and none.
+func (u *ClusterSettingsUpdate) SetAllocationEnable(v string, transient bool) *ClusterSettingsUpdate {
+	return u.Set(settingAllocationEnable, v, transient)
+}
+
+// SetRebalanceEnable sets the cluster.routing.rebalance.enable, one of all, primaries, replicas and none.
+func (u *ClusterSettingsUpdate) SetRebalanceEnable(v string, transient bool) *ClusterSettingsUpdate {
+	return u.Set(settingRebalanceEnable, v, transient)
+}
+
+// SetConcurrentRebalance sets the cluster.routing.allocation.cluster_concurrent_rebalance.
+func (u *ClusterSettingsUpdate) SetConcurrentRebalance(n int, transient bool) *ClusterSettingsUpdate {
+	return u.Set(settingConcurrentRebalance, n, transient)
+}
+
+var clusterSettingEnums = map[string][]string{
+	settingAllocationEnable: {"all", "primaries", "new_primaries", "none"},
+	settingRebalanceEnable:  {"all", "primaries", "replicas", "none"},
+}
+
+// Validate checks the values of the known settings and the order of the disk watermarks of percentages or ratios.
+func (u *ClusterSettingsUpdate) Validate() error {
+	for _, settings := range []map[string]interface{}{u.Persistent, u.Transient} {
+		for key, allowed := range clusterSettingEnums {
+			v, ok := settings[key]
+			if !ok || v == nil {
+				continue
+			}
+			if str, _ := v.(string); !containsString(allowed, str) {
+				return fmt.Errorf("nes: the %s is %v, but it should be one of %s", key, v, strings.Join(allowed, ", "))
+			}
+		}
+		if v, ok := settings[settingConcurrentRebalance]; ok && v != nil {
+			if n, err := strconv.Atoi(fmt.Sprint(v)); err != nil || n < -1 {
+				return fmt.Errorf("nes: the %s is %v, but it should be an integer not less than -1", settingConcurrentRebalance, v)
+			}
+		}
+		if err := validateDiskWatermarks(settings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateDiskWatermarks(settings map[string]interface{}) error {
+	keys := []string{settingDiskWatermarkLow, settingDiskWatermarkHigh, settingDiskWatermarkFloodStage}
+	var prev float64
+	var prevKey string
+	for _, key := range keys {
+		v, ok := settings[key]
+		if !ok || v == nil {
+			continue
+		}
+		ratio, ok := watermarkRatio(fmt.Sprint(v))
+		if !ok {
+			// the byte values are not compared
+			continue
+		}
+		if ratio > 1 {
+			return fmt.Errorf("nes: the %s is %v, exceeding 100%%", key, v)
+		}
+		if prevKey != "" && ratio < prev {
+			return fmt.Errorf("nes: the %s is %v, lower than the %s", key, v, prevKey)
+		}
+		prev, prevKey = ratio, key
+	}
+	return nil
+}
+
+// watermarkRatio parses the watermark of a percentage like 85% or a ratio like 0.85.
+func watermarkRatio(v string) (float64, bool) {
+	if strings.HasSuffix(v, "%") {
+		f, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+		return f / 100, err == nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	return f, err == nil
+}
+
+func (e *esAdminOper) GetClusterSettings(ctx context.Context, includeDefaults bool, opts ...func(*ClusterGetSettingsRequest)) (*ClusterSettings, error) {
+	api := e.client
+	o := append([]func(*ClusterGetSettingsRequest){
+		api.Cluster.GetSettings.WithContext(ctx),
+		api.Cluster.GetSettings.WithFlatSettings(true),
+		api.Cluster.GetSettings.WithIncludeDefaults(includeDefaults),
+	}, opts...)
+	resp, err := api.Cluster.GetSettings(o...)
+	if err != nil {
+		return nil, err
+	}
+	settings := &ClusterSettings{}
+	if err := unmarshallResponse(resp, settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func (e *esAdminOper) PutClusterSettings(ctx context.Context, update *ClusterSettingsUpdate, opts ...func(*ClusterPutSettingsRequest)) error {
+	if err := update.Validate(); err != nil {
+		return err
+	}
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(update); err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*ClusterPutSettingsRequest){api.Cluster.PutSettings.WithContext(ctx)}, opts...)
+	resp, err := api.Cluster.PutSettings(body, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
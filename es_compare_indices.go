@@ -0,0 +1,285 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CompareFull - the sample size of CompareIndices comparing all the documents.
+const CompareFull = 0
+
+const compareBatchSize = 500
+
+// DocDifference - a document of the first index whose source differs from the one of the second index.
+type DocDifference struct {
+	ID string `json:"id"`
+	// Paths are the dotted paths of the differing values, sorted.
+	Paths []string `json:"paths"`
+}
+
+// IndexComparison - the report of CompareIndices.
+type IndexComparison struct {
+	IndexA string `json:"index_a"`
+	IndexB string `json:"index_b"`
+	CountA int64  `json:"count_a"`
+	CountB int64  `json:"count_b"`
+	// MappingEqual reports whether the normalized properties of the indices are equal,
+	// Mapping is the difference of IndexB from IndexA otherwise.
+	MappingEqual bool         `json:"mapping_equal"`
+	Mapping      *MappingDiff `json:"mapping,omitempty"`
+	// Compared is the number of the documents of IndexA compared.
+	Compared int `json:"compared"`
+	// Missing are the ids of the compared documents missing in IndexB.
+	Missing   []string         `json:"missing,omitempty"`
+	Different []*DocDifference `json:"different,omitempty"`
+}
+
+// Equal reports whether no difference is found.
+func (c *IndexComparison) Equal() bool {
+	return c.CountA == c.CountB && c.MappingEqual && len(c.Missing) == 0 && len(c.Different) == 0
+}
+
+func (c *IndexComparison) String() string {
+	if c.Equal() {
+		return fmt.Sprintf("%s = %s: %d documents, %d compared", c.IndexA, c.IndexB, c.CountA, c.Compared)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s != %s:", c.IndexA, c.IndexB)
+	if c.CountA != c.CountB {
+		fmt.Fprintf(&b, "\n  count: %d != %d", c.CountA, c.CountB)
+	}
+	if !c.MappingEqual && c.Mapping != nil {
+		b.WriteString("\n  mapping " + c.Mapping.String())
+	}
+	for _, id := range c.Missing {
+		b.WriteString("\n  missing: " + id)
+	}
+	for _, d := range c.Different {
+		fmt.Fprintf(&b, "\n  different: %s %s", d.ID, strings.Join(d.Paths, ", "))
+	}
+	return b.String()
+}
+
+type mgetSourcesResponseBody struct {
+	Docs []struct {
+		ID     string          `json:"_id"`
+		Found  bool            `json:"found"`
+		Source json.RawMessage `json:"_source"`
+	} `json:"docs"`
+}
+
+// CompareIndices compares the document counts, the mappings and the documents of the indices, e.g. to validate
+// a reindex or a migration. The documents of indexA are sampled randomly by the sampleSize and looked up in indexB
+// by their ids, all of them are compared if the sampleSize is CompareFull.
+func CompareIndices(ctx context.Context, oper ESOper, admin ESAdminOper, indexA string, indexB string, sampleSize int) (*IndexComparison, error) {
+	c := &IndexComparison{IndexA: indexA, IndexB: indexB}
+	countQuery, err := NewSearchBody(nil).JSON()
+	if err != nil {
+		return nil, err
+	}
+	if c.CountA, err = oper.Count(ctx, countQuery, []string{indexA}); err != nil {
+		return nil, err
+	}
+	if c.CountB, err = oper.Count(ctx, countQuery, []string{indexB}); err != nil {
+		return nil, err
+	}
+	if err := c.compareMappings(ctx, admin); err != nil {
+		return nil, err
+	}
+	if sampleSize > 0 {
+		err = c.compareSample(ctx, oper, sampleSize)
+	} else {
+		err = c.compareAll(ctx, oper)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(c.Missing)
+	sort.Slice(c.Different, func(i, j int) bool { return c.Different[i].ID < c.Different[j].ID })
+	return c, nil
+}
+
+func (c *IndexComparison) compareMappings(ctx context.Context, admin ESAdminOper) error {
+	propsA, err := indexProperties(ctx, admin, c.IndexA)
+	if err != nil {
+		return err
+	}
+	propsB, err := indexProperties(ctx, admin, c.IndexB)
+	if err != nil {
+		return err
+	}
+	if c.MappingEqual = reflect.DeepEqual(propsA, propsB); c.MappingEqual {
+		return nil
+	}
+	changes := &mappingChanges{params: []*MappingParamChange{}}
+	diffProperties("", propsB, propsA, changes)
+	for _, paths := range [][]string{changes.added, changes.removed, changes.changed, changes.incompatible} {
+		sort.Strings(paths)
+	}
+	c.Mapping = &MappingDiff{
+		Index:        c.IndexB,
+		Added:        changes.added,
+		Removed:      changes.removed,
+		Changed:      changes.changed,
+		Incompatible: changes.incompatible,
+	}
+	if len(changes.params) > 0 {
+		c.Mapping.Params = changes.params
+	}
+	return nil
+}
+
+// indexProperties returns the normalized properties of the index, which must be a concrete index or an alias of one.
+func indexProperties(ctx context.Context, admin ESAdminOper, index string) (map[string]interface{}, error) {
+	mappings, err := admin.GetMapping(ctx, []string{index})
+	if err != nil {
+		return nil, err
+	}
+	if len(mappings) != 1 {
+		return nil, fmt.Errorf("nes: %s resolves to %d indices", index, len(mappings))
+	}
+	for _, m := range mappings {
+		normalized, _ := normalizeJSON(m).(map[string]interface{})
+		props, _ := normalized["properties"].(map[string]interface{})
+		return props, nil
+	}
+	return nil, nil
+}
+
+func (c *IndexComparison) compareSample(ctx context.Context, oper ESOper, sampleSize int) error {
+	query := FunctionScoreQuery(nil, RandomScoreFunction(time.Now().UnixNano(), "_seq_no"))
+	body, err := NewSearchBody(query).WithPage(0, sampleSize).JSON()
+	if err != nil {
+		return err
+	}
+	hits, err := SearchRaw(ctx, oper, body, []string{c.IndexA})
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(hits); start += compareBatchSize {
+		end := start + compareBatchSize
+		if end > len(hits) {
+			end = len(hits)
+		}
+		if err := c.compareBatch(ctx, oper, hits[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *IndexComparison) compareAll(ctx context.Context, oper ESOper) error {
+	body, err := NewSearchBody(nil).WithSort(SortField("_doc")).WithPage(0, compareBatchSize).JSON()
+	if err != nil {
+		return err
+	}
+	it := NewScrollIterator(oper, []string{c.IndexA}, body, 0)
+	defer it.Close(context.WithoutCancel(ctx))
+	batch := make([]*RawHit, 0, compareBatchSize)
+	for it.Next(ctx) {
+		if batch = append(batch, it.Hit()); len(batch) == compareBatchSize {
+			if err := c.compareBatch(ctx, oper, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	return c.compareBatch(ctx, oper, batch)
+}
+
+func (c *IndexComparison) compareBatch(ctx context.Context, oper ESOper, hits []*RawHit) error {
+	if len(hits) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(hits))
+	for _, h := range hits {
+		ids = append(ids, h.ID)
+	}
+	respBody := &mgetSourcesResponseBody{}
+	if _, err := oper.MultiGet(ctx, respBody, c.IndexB, ids); err != nil {
+		return err
+	}
+	sources := make(map[string]json.RawMessage, len(respBody.Docs))
+	for _, d := range respBody.Docs {
+		if d.Found {
+			sources[d.ID] = d.Source
+		}
+	}
+	for _, h := range hits {
+		c.Compared++
+		source, ok := sources[h.ID]
+		if !ok {
+			c.Missing = append(c.Missing, h.ID)
+			continue
+		}
+		var a, b interface{}
+		if err := json.Unmarshal(h.Source, &a); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(source, &b); err != nil {
+			return err
+		}
+		if paths := diffJSON("", a, b, nil); len(paths) > 0 {
+			sort.Strings(paths)
+			c.Different = append(c.Different, &DocDifference{ID: h.ID, Paths: paths})
+		}
+	}
+	return nil
+}
+
+// diffJSON appends the dotted paths of the values differing between the decoded JSON values a and b,
+// the arrays are compared as a whole.
+func diffJSON(path string, a interface{}, b interface{}, paths []string) []string {
+	ma, okA := a.(map[string]interface{})
+	mb, okB := b.(map[string]interface{})
+	if !okA || !okB {
+		if !reflect.DeepEqual(a, b) {
+			paths = append(paths, path)
+		}
+		return paths
+	}
+	for k, va := range ma {
+		p := k
+		if path != "" {
+			p = path + "." + k
+		}
+		vb, ok := mb[k]
+		if !ok {
+			paths = append(paths, p)
+			continue
+		}
+		paths = diffJSON(p, va, vb, paths)
+	}
+	for k := range mb {
+		if _, ok := ma[k]; !ok {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
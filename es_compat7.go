@@ -0,0 +1,138 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// WithCompat7 - the compatibility mode of the 7.x clusters:
+//   - the requests are sent without the compatible-with=8 headers;
+//   - the documents APIs use the mapping type in their URLs, e.g. the indices created by 6.x, if the docType is not
+//     empty or _doc;
+//   - the point in time requests fail with ErrUnsupportedByCluster, use the scroll instead;
+//   - the hits.total of the searches in the int format is converted to the {"value", "relation"} one;
+//   - the product header missing in the responses of the clusters before 7.14 is filled in.
+func WithCompat7(docType string) ESClientOption {
+	return func(t *esTransport) {
+		t.compat7 = &compat7{docType: docType}
+	}
+}
+
+type compat7 struct {
+	docType string
+}
+
+// check rejects the request unsupported by the compatibility mode before it's sent.
+func (c *compat7) check(req *http.Request) error {
+	if strings.HasSuffix(strings.TrimSuffix(req.URL.Path, "/"), "/_pit") {
+		return fmt.Errorf("%w: %s in the 7.x compatibility mode", ErrUnsupportedByCluster, FeaturePIT)
+	}
+	return nil
+}
+
+// request returns the request of the 7.x cluster, the request is cloned before being changed.
+func (c *compat7) request(req *http.Request) *http.Request {
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	req = req.Clone(req.Context())
+	for _, k := range []string{"Accept", "Content-Type"} {
+		if v := req.Header.Get(k); strings.Contains(v, "compatible-with") {
+			req.Header.Set(k, strings.Replace(v, "vnd.elasticsearch+json;compatible-with=8", "json", 1))
+		}
+	}
+	if path, ok := c.typedPath(segments); ok {
+		req.URL.Path = path
+		req.URL.RawPath = ""
+	}
+	return req
+}
+
+// typedPath returns the path with the mapping type of the document APIs, e.g. /index/type/id/_update of /index/_update/id.
+func (c *compat7) typedPath(segments []string) (string, bool) {
+	if c.docType == "" || c.docType == "_doc" || len(segments) < 2 || strings.HasPrefix(segments[0], "_") {
+		return "", false
+	}
+	index, endpoint := segments[0], segments[1]
+	switch {
+	case endpoint == "_doc" && len(segments) <= 3:
+		return "/" + strings.Join(append([]string{index, c.docType}, segments[2:]...), "/"), true
+	case (endpoint == "_create" || endpoint == "_update" || endpoint == "_source" || endpoint == "_explain") && len(segments) == 3:
+		return "/" + strings.Join([]string{index, c.docType, segments[2], endpoint}, "/"), true
+	}
+	return "", false
+}
+
+// response converts the response of the 7.x cluster to the format of 8.x.
+func (c *compat7) response(req *http.Request, resp *http.Response) error {
+	if resp.Header.Get("X-Elastic-Product") == "" {
+		resp.Header.Set("X-Elastic-Product", "Elasticsearch")
+	}
+	if !strings.Contains(req.URL.Path, "_search") && !strings.HasSuffix(req.URL.Path, "_msearch") {
+		return nil
+	}
+	if resp.Body == nil || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	var v map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		// leave the body to the decoding of the caller
+		return nil
+	}
+	converted := convertTotalHits(v)
+	if responses, ok := v["responses"].([]interface{}); ok {
+		for _, item := range responses {
+			if r, ok := item.(map[string]interface{}); ok && convertTotalHits(r) {
+				converted = true
+			}
+		}
+	}
+	if !converted {
+		return nil
+	}
+	if body, err = json.Marshal(v); err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Del("Content-Length")
+	return nil
+}
+
+// convertTotalHits converts the hits.total of the search response in the int format, it reports whether it's converted.
+func convertTotalHits(v map[string]interface{}) bool {
+	hits, ok := v["hits"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	total, ok := hits["total"].(json.Number)
+	if !ok {
+		return false
+	}
+	hits["total"] = map[string]interface{}{"value": total, "relation": "eq"}
+	return true
+}
@@ -0,0 +1,171 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// CompositeBucket - a bucket of the composite aggregation, Raw is the whole bucket including the sub aggregations.
+type CompositeBucket struct {
+	Key      map[string]interface{}
+	DocCount int64
+	Raw      json.RawMessage
+}
+
+// Decode decodes the whole bucket into the model.
+func (b *CompositeBucket) Decode(model interface{}) error {
+	return json.Unmarshal(b.Raw, model)
+}
+
+func (b *CompositeBucket) UnmarshalJSON(data []byte) error {
+	v := &struct {
+		Key      map[string]interface{} `json:"key"`
+		DocCount int64                  `json:"doc_count"`
+	}{}
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	b.Key = v.Key
+	b.DocCount = v.DocCount
+	b.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+type compositeResponseBody struct {
+	Aggregations map[string]*struct {
+		AfterKey map[string]interface{} `json:"after_key"`
+		Buckets  []*CompositeBucket     `json:"buckets"`
+	} `json:"aggregations"`
+}
+
+// CompositeIterator pages through the buckets of a composite aggregation with the after_key.
+//
+//	it := nes.NewCompositeIterator(oper, indexes, query, "by_user")
+//	for it.Next(ctx) {
+//		b := it.Bucket()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type CompositeIterator struct {
+	oper    ESOper
+	indexes []string
+	agg     string
+	opts    []func(*SearchRequest)
+
+	body     map[string]interface{}
+	err      error
+	buckets  []*CompositeBucket
+	pos      int
+	afterKey map[string]interface{}
+	done     bool
+}
+
+// NewCompositeIterator - the iterator of the composite aggregation named agg in the request body of the query,
+// the size of the composite aggregation is the page size.
+func NewCompositeIterator(oper ESOper, indexes []string, query string, agg string, opts ...func(*SearchRequest)) *CompositeIterator {
+	it := &CompositeIterator{
+		oper:    oper,
+		indexes: indexes,
+		agg:     agg,
+		opts:    opts,
+	}
+	if err := json.Unmarshal([]byte(query), &it.body); err != nil {
+		it.err = fmt.Errorf("nes: the request body is not a JSON object: %w", err)
+		return it
+	}
+	// the body of a null query is nil
+	if it.composite() == nil {
+		it.err = fmt.Errorf("nes: the request body has no composite aggregation named %s", agg)
+		return it
+	}
+	// only the buckets are needed
+	it.body["size"] = 0
+	return it
+}
+
+func (it *CompositeIterator) composite() map[string]interface{} {
+	aggs, ok := it.body["aggs"].(map[string]interface{})
+	if !ok {
+		aggs, _ = it.body["aggregations"].(map[string]interface{})
+	}
+	agg, _ := aggs[it.agg].(map[string]interface{})
+	composite, _ := agg["composite"].(map[string]interface{})
+	return composite
+}
+
+// Next advances to the next bucket, a new page is requested when the current one is consumed.
+func (it *CompositeIterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+	if it.pos < len(it.buckets) {
+		it.pos++
+	}
+	for it.pos >= len(it.buckets) {
+		if it.done {
+			return false
+		}
+		if err := it.fetch(ctx); err != nil {
+			it.err = err
+			return false
+		}
+	}
+	return true
+}
+
+func (it *CompositeIterator) fetch(ctx context.Context) error {
+	composite := it.composite()
+	if it.afterKey != nil {
+		composite["after"] = it.afterKey
+	}
+	b, err := json.Marshal(it.body)
+	if err != nil {
+		return err
+	}
+	respBody := &compositeResponseBody{}
+	if _, err := it.oper.Search(ctx, respBody, string(b), it.indexes, it.opts...); err != nil {
+		return err
+	}
+	result := respBody.Aggregations[it.agg]
+	if result == nil {
+		return fmt.Errorf("nes: the response has no composite aggregation named %s", it.agg)
+	}
+	it.buckets = result.Buckets
+	it.pos = 0
+	it.afterKey = result.AfterKey
+	it.done = len(result.Buckets) == 0 || result.AfterKey == nil
+	return nil
+}
+
+// Bucket returns the current bucket.
+func (it *CompositeIterator) Bucket() *CompositeBucket {
+	if it.pos < len(it.buckets) {
+		return it.buckets[it.pos]
+	}
+	return nil
+}
+
+// AfterKey returns the after_key of the current page, it can be persisted to resume the iteration.
+func (it *CompositeIterator) AfterKey() map[string]interface{} {
+	return it.afterKey
+}
+
+// Err returns the error stopping the iteration.
+func (it *CompositeIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,32 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCompositeIteratorRejectsTheBodiesWithoutTheAggregation(t *testing.T) {
+	for _, query := range []string{"null", "{}", `{"aggs":{"other":{"terms":{"field":"f"}}}}`} {
+		it := NewCompositeIterator(nil, []string{"i"}, query, "pages")
+		if it.Next(context.Background()) {
+			t.Errorf("%s: next is true", query)
+		}
+		if it.Err() == nil {
+			t.Errorf("%s: no error", query)
+		}
+	}
+}
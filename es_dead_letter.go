@@ -0,0 +1,150 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nf-go/nfgo/nlog"
+)
+
+// DeadLetter - a bulk item failed after the retries, with its original payload.
+type DeadLetter struct {
+	Index      string          `json:"index"`
+	Action     string          `json:"action"`
+	DocumentID string          `json:"document_id,omitempty"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	Status     int             `json:"status,omitempty"`
+	ErrorType  string          `json:"error_type,omitempty"`
+	Reason     string          `json:"reason,omitempty"`
+	Time       time.Time       `json:"time"`
+}
+
+// DeadLetterSink - the destination of the dead letters.
+type DeadLetterSink interface {
+	WriteDeadLetter(ctx context.Context, letter *DeadLetter) error
+}
+
+// DeadLetterSinkFunc - an adapter to allow the use of ordinary functions as DeadLetterSink.
+type DeadLetterSinkFunc func(ctx context.Context, letter *DeadLetter) error
+
+// WriteDeadLetter -
+func (f DeadLetterSinkFunc) WriteDeadLetter(ctx context.Context, letter *DeadLetter) error {
+	return f(ctx, letter)
+}
+
+// NewNDJSONDeadLetterSink - the sink writing the dead letters to the writer line by line.
+func NewNDJSONDeadLetterSink(w io.Writer) DeadLetterSink {
+	var mu sync.Mutex
+	return DeadLetterSinkFunc(func(ctx context.Context, letter *DeadLetter) error {
+		b, err := json.Marshal(letter)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		_, err = w.Write(append(b, '\n'))
+		return err
+	})
+}
+
+// NewFileDeadLetterSink - the sink appending the dead letters to the local NDJSON file, the file is created if it's missing.
+func NewFileDeadLetterSink(path string) (DeadLetterSink, io.Closer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewNDJSONDeadLetterSink(f), f, nil
+}
+
+// NewIndexDeadLetterSink - the sink indexing the dead letters into the DLQ index by the oper.
+func NewIndexDeadLetterSink(oper ESOper, index string) DeadLetterSink {
+	return DeadLetterSinkFunc(func(ctx context.Context, letter *DeadLetter) error {
+		return oper.Index(ctx, index, "", letter)
+	})
+}
+
+// WithDeadLetterSink - the sink of the items failed in the bulk indexers created by the oper,
+// the OnFailure callbacks of the items are still invoked. The items of the stale external versions are not dead letters.
+func WithDeadLetterSink(sink DeadLetterSink) ESOperOption {
+	return func(e *esOper) {
+		e.deadLetterSink = sink
+	}
+}
+
+type deadLetterSinkCtxKey struct{}
+
+// contextWithDeadLetterSink - the items added to the bulk indexers of the oper with the context are dead-lettered
+// to the sink instead of the one of the oper, or not dead-lettered if it's nil.
+func contextWithDeadLetterSink(ctx context.Context, sink DeadLetterSink) context.Context {
+	return context.WithValue(ctx, deadLetterSinkCtxKey{}, &sink)
+}
+
+func (b *trackedBulkIndexer) Add(ctx context.Context, item BulkIndexerItem) error {
+	sink := b.oper.deadLetterSink
+	if s, ok := ctx.Value(deadLetterSinkCtxKey{}).(*DeadLetterSink); ok {
+		sink = *s
+	}
+	if sink == nil {
+		return b.BulkIndexer.Add(ctx, item)
+	}
+	var payload []byte
+	if item.Body != nil {
+		var err error
+		if payload, err = io.ReadAll(item.Body); err != nil {
+			return err
+		}
+		item.Body = bytes.NewReader(payload)
+	}
+	onFailure := item.OnFailure
+	item.OnFailure = func(ctx context.Context, item BulkIndexerItem, res BulkIndexerResponseItem, err error) {
+		if onFailure != nil {
+			onFailure(ctx, item, res, err)
+		}
+		// the stale versions are discarded by design
+		if err == nil && isExternalVersionType(item.VersionType) && IsStaleVersion(res) {
+			return
+		}
+		letter := &DeadLetter{
+			Index:      item.Index,
+			Action:     item.Action,
+			DocumentID: item.DocumentID,
+			Status:     res.Status,
+			ErrorType:  res.Error.Type,
+			Reason:     res.Error.Reason,
+			Time:       time.Now(),
+		}
+		if json.Valid(payload) {
+			letter.Payload = payload
+		}
+		if err != nil {
+			letter.ErrorType = "client_error"
+			letter.Reason = err.Error()
+		}
+		if letter.Index == "" {
+			letter.Index = res.Index
+		}
+		if err := sink.WriteDeadLetter(ctx, letter); err != nil {
+			nlog.Logger(ctx).Errorf("nes bulk indexer: fail to write the dead letter of %s %s/%s: %s", letter.Action, letter.Index, letter.DocumentID, err)
+		}
+	}
+	return b.BulkIndexer.Add(ctx, item)
+}
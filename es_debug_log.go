@@ -0,0 +1,122 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nf-go/nfgo/nlog"
+)
+
+// DebugLogConfig - the debug logs of the operations of the oper, an entry of the request metadata is logged for
+// each operation sampled: the op, the indexes, the body size, the duration, the status of the last response and
+// the error.
+type DebugLogConfig struct {
+	// SampleRate is the ratio of the operations logged, 1 by default.
+	SampleRate float64
+	// BodySampleRate is the ratio of the logged operations whose bodies are logged too, the bodies are redacted
+	// by the redactor of the oper, or the DefaultRedactor if the oper has none. No bodies are logged if it's
+	// not positive.
+	BodySampleRate float64
+	// MaxBodySize is the max size of the bodies logged, 1KB by default.
+	MaxBodySize int
+}
+
+// DefaultDebugLogConfig - the debug logs of the oper by default, logging the metadata of all the operations without
+// their bodies.
+var DefaultDebugLogConfig = &DebugLogConfig{SampleRate: 1}
+
+// WithDebugLogging - the debug logs of the operations of the oper, DefaultDebugLogConfig by default,
+// they are disabled if the config is nil. The entries are written only if the logger enables the debug level.
+func WithDebugLogging(config *DebugLogConfig) ESOperOption {
+	return func(e *esOper) {
+		e.debugLog = config
+	}
+}
+
+func (e *esOper) debugLogInterceptor(config *DebugLogConfig) Interceptor {
+	c := *config
+	if c.SampleRate <= 0 || c.SampleRate > 1 {
+		c.SampleRate = 1
+	}
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = 1 << 10
+	}
+	return func(ctx context.Context, info *OperInfo, next func(ctx context.Context) error) error {
+		logger := e.logger(ctx)
+		if !logger.IsLevelEnabled(nlog.DebugLevel) || (c.SampleRate < 1 && rand.Float64() >= c.SampleRate) {
+			return next(ctx)
+		}
+		ctx, status := contextWithStatus(ctx)
+		start := time.Now()
+		err := next(ctx)
+		fields := nlog.Fields{
+			"op":          info.Name,
+			"indexes":     strings.Join(info.Indexes, ","),
+			"body_size":   len(info.Body),
+			"duration_ms": time.Since(start).Milliseconds(),
+			// 0 if no response is received
+			"status": status.get(),
+		}
+		if info.Body != "" && c.BodySampleRate > 0 && rand.Float64() < c.BodySampleRate {
+			redactor := e.redactor
+			if redactor == nil {
+				redactor = DefaultRedactor
+			}
+			body := redactor.Redact(info.Body)
+			if len(body) > c.MaxBodySize {
+				body = body[:c.MaxBodySize] + "...(truncated)"
+			}
+			fields["body"] = body
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+		}
+		logger.WithFields(fields).Debugf("nes es oper %s", info.Name)
+		return err
+	}
+}
+
+type statusCtxKey struct{}
+
+// operStatus - the status of the last response of an operation, recorded by the transport of the client.
+type operStatus struct {
+	mu     sync.Mutex
+	status int
+}
+
+func (s *operStatus) get() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+func contextWithStatus(ctx context.Context) (context.Context, *operStatus) {
+	s := &operStatus{}
+	return context.WithValue(ctx, statusCtxKey{}, s), s
+}
+
+// recordStatus records the status of the response of the request made with the context.
+func recordStatus(ctx context.Context, status int) {
+	if s, ok := ctx.Value(statusCtxKey{}).(*operStatus); ok {
+		s.mu.Lock()
+		s.status = status
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// scrollTracker tracks the scroll contexts opened by the oper until they are cleared or expired.
+type scrollTracker struct {
+	mu       sync.Mutex
+	expiries map[string]time.Time
+}
+
+// track replaces the previous scroll id with the one of the response, which expires after the keep-alive.
+func (t *scrollTracker) track(previous string, scrollID string, keepAlive time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.expiries, previous)
+	t.expiries[scrollID] = time.Now().Add(keepAlive)
+}
+
+func (t *scrollTracker) untrack(scrollIDs ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, id := range scrollIDs {
+		delete(t.expiries, id)
+	}
+}
+
+// active returns the scroll ids not expired yet, the expired ones are dropped.
+func (t *scrollTracker) active() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	ids := make([]string, 0, len(t.expiries))
+	for id, expiry := range t.expiries {
+		if now.After(expiry) {
+			delete(t.expiries, id)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// searchScrollKeepAlive returns the keep-alive of the scroll set by the options of a search.
+func searchScrollKeepAlive(opts []func(*SearchRequest)) time.Duration {
+	r := &SearchRequest{}
+	for _, o := range opts {
+		o(r)
+	}
+	return r.Scroll
+}
+
+func (e *esOper) scrollRequestKeepAlive(opts []func(*ScrollRequest)) time.Duration {
+	r := &ScrollRequest{Scroll: e.scrollKeepAlive}
+	for _, o := range opts {
+		o(r)
+	}
+	return r.Scroll
+}
+
+func (e *esOper) DebugVars() map[string]interface{} {
+	e.mu.Lock()
+	var pending, failed uint64
+	for b := range e.bulkIndexers {
+		s := b.Stats()
+		pending += s.NumAdded - s.NumFlushed
+		failed += s.NumFailed
+	}
+	bulkIndexers := len(e.bulkIndexers)
+	e.mu.Unlock()
+
+	vars := map[string]interface{}{
+		"active_scrolls": len(e.scrolls.active()),
+		"bulk_indexers":  bulkIndexers,
+		// the items added to the bulk indexers but not flushed yet
+		"bulk_queue_depth":  pending,
+		"bulk_failed_items": failed,
+		"stats_since":       e.stats.since,
+	}
+	if h := e.hedging; h != nil {
+		vars["hedged_requests"] = atomic.LoadInt64(&h.hedged)
+		vars["hedged_requests_won"] = atomic.LoadInt64(&h.won)
+	}
+	return vars
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DecodeError - the error of a successful response whose body can't be decoded, e.g. it's malformed, truncated
+// or of an unexpected shape, or the codec panics on it.
+type DecodeError struct {
+	// Op is the operation like Count, empty if it's unknown.
+	Op         string
+	StatusCode int
+	Err        error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Op == "" {
+		return fmt.Sprintf("nes: fail to decode the response of the status %d: %s", e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("nes: fail to decode the response of %s of the status %d: %s", e.Op, e.StatusCode, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// IsDecodeError reports whether the err is a DecodeError.
+func IsDecodeError(err error) bool {
+	var decodeErr *DecodeError
+	return errors.As(err, &decodeErr)
+}
+
+// decodeSafely calls the decode and returns the DecodeError of its error or panic, e.g. the one of a custom codec
+// or UnmarshalJSON.
+func decodeSafely(op string, resp *Response, decode func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &DecodeError{Op: op, StatusCode: resp.StatusCode, Err: fmt.Errorf("panic: %v", r)}
+		}
+	}()
+	if err := decode(); err != nil {
+		return &DecodeError{Op: op, StatusCode: resp.StatusCode, Err: err}
+	}
+	return nil
+}
+
+// missingField is the error of a required field missing in the response.
+func missingField(op string, resp *Response, field string) error {
+	return &DecodeError{Op: op, StatusCode: resp.StatusCode, Err: fmt.Errorf("the %s is missing", field)}
+}
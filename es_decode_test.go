@@ -0,0 +1,133 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// stubBodyTransport - responds to every request with the status and the body.
+type stubBodyTransport struct {
+	status int
+	body   []byte
+}
+
+func (s *stubBodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_, _ = io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+	}
+	header := http.Header{}
+	header.Set("X-Elastic-Product", "Elasticsearch")
+	header.Set("Content-Type", "application/json")
+	return &http.Response{
+		StatusCode:    s.status,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(s.body)),
+		ContentLength: int64(len(s.body)),
+		Request:       req,
+	}, nil
+}
+
+func newStubOper(t testing.TB, status int, body []byte) ESOper {
+	t.Helper()
+	client, err := NewESClient(&ESConfig{Addrs: []string{"http://nes.invalid:9200"}, Version: "8.13.1"},
+		WithBaseTransport(&stubBodyTransport{status: status, body: body}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewESOper(client, WithDebugLogging(nil))
+}
+
+var decodeSeeds = []string{
+	``,
+	`{`,
+	`null`,
+	`[]`,
+	`"hits"`,
+	`{"count":"1"}`,
+	`{"count":1}`,
+	`{"hits":{"hits":[{"_id":1}]}}`,
+	`{"hits":{"total":{"value":"x"},"hits":{}}}`,
+	`{"_shards":{"total":"x"}}`,
+	`{"found":true,"_source":[1,2`,
+	"\x00\xff{}",
+}
+
+// checkDecodeErr fails unless the err is nil, a DecodeError or the partial results of a valid response.
+func checkDecodeErr(t *testing.T, body []byte, err error) {
+	t.Helper()
+	if err != nil && !IsDecodeError(err) && !IsPartialResults(err) {
+		t.Fatalf("the error of %q is %T %v, not a *DecodeError", body, err, err)
+	}
+}
+
+func FuzzDecodeSearch(f *testing.F) {
+	for _, s := range decodeSeeds {
+		f.Add([]byte(s))
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		oper := newStubOper(t, http.StatusOK, body)
+		var model struct {
+			Hits struct {
+				Hits []struct {
+					ID     string                 `json:"_id"`
+					Source map[string]interface{} `json:"_source"`
+				} `json:"hits"`
+			} `json:"hits"`
+		}
+		_, err := oper.Search(context.Background(), &model, `{"query":{"match_all":{}}}`, []string{"i"})
+		checkDecodeErr(t, body, err)
+	})
+}
+
+func FuzzDecodeCount(f *testing.F) {
+	for _, s := range decodeSeeds {
+		f.Add([]byte(s))
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		oper := newStubOper(t, http.StatusOK, body)
+		_, err := oper.Count(context.Background(), `{"query":{"match_all":{}}}`, []string{"i"})
+		checkDecodeErr(t, body, err)
+	})
+}
+
+func FuzzDecodeGet(f *testing.F) {
+	for _, s := range decodeSeeds {
+		f.Add([]byte(s))
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		oper := newStubOper(t, http.StatusOK, body)
+		var model struct {
+			Found  bool                   `json:"found"`
+			Source map[string]interface{} `json:"_source"`
+		}
+		_, err := oper.Get(context.Background(), &model, "i", "1")
+		checkDecodeErr(t, body, err)
+	})
+}
+
+func TestDecodeErrorOfMalformedCount(t *testing.T) {
+	for _, body := range []string{`{`, `{}`, `{"count":"1"}`} {
+		oper := newStubOper(t, http.StatusOK, []byte(body))
+		if _, err := oper.Count(context.Background(), `{}`, []string{"i"}); !IsDecodeError(err) {
+			t.Errorf("the error of %q is %v, not a *DecodeError", body, err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+const (
+	distinctAggName = "nes_distinct"
+	// distinctPageSize is the max size of the pages of the distinct values.
+	distinctPageSize = 1000
+	// DefaultMaxDistinctValues - the max number of the distinct values if the maxSize is not positive.
+	DefaultMaxDistinctValues = 10000
+)
+
+// DistinctValues returns the distinct values of the field in the documents of the index matching the query in the
+// request body, e.g. the options of the filter dropdowns, in the ascending order and formatted as strings.
+// The values are paged through by a composite aggregation until the maxSize ones are returned.
+func DistinctValues(ctx context.Context, oper ESOper, index string, field string, query string, maxSize int, opts ...func(*SearchRequest)) ([]string, error) {
+	keys, err := distinctValues(ctx, oper, index, field, query, maxSize, opts...)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if f, ok := key.(float64); ok {
+			values = append(values, strconv.FormatFloat(f, 'f', -1, 64))
+			continue
+		}
+		values = append(values, fmt.Sprint(key))
+	}
+	return values, nil
+}
+
+// DistinctTypedValues decodes the distinct values of the field like DistinctValues into the model, which is a pointer
+// to a slice of the type of the field, e.g. *[]int64 or *[]bool.
+func DistinctTypedValues(ctx context.Context, oper ESOper, model interface{}, index string, field string, query string, maxSize int, opts ...func(*SearchRequest)) error {
+	keys, err := distinctValues(ctx, oper, index, field, query, maxSize, opts...)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, model)
+}
+
+func distinctValues(ctx context.Context, oper ESOper, index string, field string, query string, maxSize int, opts ...func(*SearchRequest)) ([]interface{}, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxDistinctValues
+	}
+	body := map[string]interface{}{}
+	if query != "" {
+		if err := json.Unmarshal([]byte(query), &body); err != nil {
+			return nil, fmt.Errorf("nes: the request body is not a JSON object: %w", err)
+		}
+		// a null query matches all the documents like an empty one
+		if body == nil {
+			body = map[string]interface{}{}
+		}
+	}
+	pageSize := maxSize
+	if pageSize > distinctPageSize {
+		pageSize = distinctPageSize
+	}
+	body["aggs"] = map[string]interface{}{
+		distinctAggName: map[string]interface{}{
+			"composite": map[string]interface{}{
+				"size":    pageSize,
+				"sources": []interface{}{map[string]interface{}{"value": map[string]interface{}{"terms": map[string]interface{}{"field": field}}}},
+			},
+		},
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	var values []interface{}
+	it := NewCompositeIterator(oper, []string{index}, string(b), distinctAggName, opts...)
+	for len(values) < maxSize && it.Next(ctx) {
+		values = append(values, it.Bucket().Key["value"])
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestDistinctValuesOfANullQuery(t *testing.T) {
+	oper, stub := newRouteOper(t, map[string]stubResponse{
+		"POST /i/_search": {http.StatusOK, `{"took":1,"hits":{"hits":[]},"aggregations":{"nes_distinct":{"buckets":[
+			{"key":{"value":"a"},"doc_count":2},
+			{"key":{"value":1.5},"doc_count":1}
+		]}}}`},
+	})
+	for _, query := range []string{"", "null", "{}"} {
+		values, err := DistinctValues(context.Background(), oper, "i", "f", query, 10)
+		if err != nil {
+			t.Fatalf("%q: %v", query, err)
+		}
+		if !reflect.DeepEqual(values, []string{"a", "1.5"}) {
+			t.Errorf("%q: values %v", query, values)
+		}
+	}
+	if n := len(stub.sent()); n != 3 {
+		t.Errorf("%d requests, want 3", n)
+	}
+	if _, err := DistinctValues(context.Background(), oper, "i", "f", "[]", 10); err == nil {
+		t.Error("no error on a non-object query")
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+type downsampleRequestBody struct {
+	FixedInterval string `json:"fixed_interval"`
+}
+
+// RollupJob - the legacy rollup job, prefer Downsample on the time series indices.
+type RollupJob struct {
+	IndexPattern string                 `json:"index_pattern"`
+	RollupIndex  string                 `json:"rollup_index"`
+	Cron         string                 `json:"cron"`
+	PageSize     int                    `json:"page_size"`
+	Groups       map[string]interface{} `json:"groups"`
+	Metrics      []*RollupMetric        `json:"metrics,omitempty"`
+}
+
+// RollupMetric - the metrics like min, max, sum, avg and value_count collected of the field.
+type RollupMetric struct {
+	Field   string   `json:"field"`
+	Metrics []string `json:"metrics"`
+}
+
+func (e *esAdminOper) AddWriteBlock(ctx context.Context, indexes []string, opts ...func(*IndicesAddBlockRequest)) error {
+	api := e.client
+	o := append([]func(*IndicesAddBlockRequest){api.Indices.AddBlock.WithContext(ctx)}, opts...)
+	resp, err := api.Indices.AddBlock(indexes, "write", o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) Downsample(ctx context.Context, sourceIndex string, targetIndex string, fixedInterval string, opts ...func(*IndicesDownsampleRequest)) error {
+	if err := e.RequireFeature(ctx, FeatureDownsample); err != nil {
+		return err
+	}
+	if err := e.AddWriteBlock(ctx, []string{sourceIndex}); err != nil {
+		return err
+	}
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(&downsampleRequestBody{FixedInterval: fixedInterval}); err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*IndicesDownsampleRequest){api.Indices.Downsample.WithContext(ctx)}, opts...)
+	resp, err := api.Indices.Downsample(sourceIndex, body, targetIndex, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) PutRollupJob(ctx context.Context, id string, job *RollupJob, opts ...func(*RollupPutJobRequest)) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(job); err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*RollupPutJobRequest){api.Rollup.PutJob.WithContext(ctx)}, opts...)
+	resp, err := api.Rollup.PutJob(id, body, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) StartRollupJob(ctx context.Context, id string, opts ...func(*RollupStartJobRequest)) error {
+	api := e.client
+	o := append([]func(*RollupStartJobRequest){api.Rollup.StartJob.WithContext(ctx)}, opts...)
+	resp, err := api.Rollup.StartJob(id, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) StopRollupJob(ctx context.Context, id string, opts ...func(*RollupStopJobRequest)) error {
+	api := e.client
+	o := append([]func(*RollupStopJobRequest){api.Rollup.StopJob.WithContext(ctx)}, opts...)
+	resp, err := api.Rollup.StopJob(id, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) DeleteRollupJob(ctx context.Context, id string, opts ...func(*RollupDeleteJobRequest)) error {
+	api := e.client
+	o := append([]func(*RollupDeleteJobRequest){api.Rollup.DeleteJob.WithContext(ctx)}, opts...)
+	resp, err := api.Rollup.DeleteJob(id, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
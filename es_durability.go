@@ -0,0 +1,103 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"strconv"
+	"time"
+)
+
+// AllActiveShards - all the shard copies, the primary and the replicas, must be active before the write.
+const AllActiveShards = "all"
+
+// ActiveShards returns the number of the active shard copies as the value of wait_for_active_shards.
+func ActiveShards(n int) string {
+	return strconv.Itoa(n)
+}
+
+// WriteDurability - the durability options of the writes, converted into the options of each write request, e.g.
+//
+//	oper.Index(ctx, index, id, obj, nes.WithWaitForActiveShards(nes.AllActiveShards).WithTimeout(time.Second).Index())
+type WriteDurability struct {
+	waitForActiveShards string
+	timeout             time.Duration
+}
+
+// WithWaitForActiveShards - the number of the active shard copies required before the write, like 2 or all.
+func WithWaitForActiveShards(n string) *WriteDurability {
+	return &WriteDurability{waitForActiveShards: n}
+}
+
+// WithTimeout sets the time to wait for the active shards.
+func (d *WriteDurability) WithTimeout(timeout time.Duration) *WriteDurability {
+	d.timeout = timeout
+	return d
+}
+
+func (d *WriteDurability) apply(waitForActiveShards *string, timeout *time.Duration) {
+	setDefault(waitForActiveShards, d.waitForActiveShards)
+	if d.timeout > 0 {
+		*timeout = d.timeout
+	}
+}
+
+// Create returns the option of Create.
+func (d *WriteDurability) Create() func(*CreateRequest) {
+	return func(r *CreateRequest) {
+		d.apply(&r.WaitForActiveShards, &r.Timeout)
+	}
+}
+
+// Index returns the option of Index.
+func (d *WriteDurability) Index() func(*IndexRequest) {
+	return func(r *IndexRequest) {
+		d.apply(&r.WaitForActiveShards, &r.Timeout)
+	}
+}
+
+// Update returns the option of Update.
+func (d *WriteDurability) Update() func(*UpdateRequest) {
+	return func(r *UpdateRequest) {
+		d.apply(&r.WaitForActiveShards, &r.Timeout)
+	}
+}
+
+// Delete returns the option of Delete.
+func (d *WriteDurability) Delete() func(*DeleteRequest) {
+	return func(r *DeleteRequest) {
+		d.apply(&r.WaitForActiveShards, &r.Timeout)
+	}
+}
+
+// Bulk returns the option of Bulk.
+func (d *WriteDurability) Bulk() func(*BulkRequest) {
+	return func(r *BulkRequest) {
+		d.apply(&r.WaitForActiveShards, &r.Timeout)
+	}
+}
+
+// UpdateByQuery returns the option of UpdateByQuery.
+func (d *WriteDurability) UpdateByQuery() func(*UpdateByQueryRequest) {
+	return func(r *UpdateByQueryRequest) {
+		d.apply(&r.WaitForActiveShards, &r.Timeout)
+	}
+}
+
+// DeleteByQuery returns the option of DeleteByQuery.
+func (d *WriteDurability) DeleteByQuery() func(*DeleteByQueryRequest) {
+	return func(r *DeleteByQueryRequest) {
+		d.apply(&r.WaitForActiveShards, &r.Timeout)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+// EnrichPolicy - the enrich policy looking up the documents of the source indices by the match field,
+// the Type is one of match, geo_match and range.
+type EnrichPolicy struct {
+	Type         string
+	Indices      []string
+	MatchField   string
+	EnrichFields []string
+	// Query filters the documents of the source indices.
+	Query interface{}
+}
+
+// MarshalJSON -
+func (p *EnrichPolicy) MarshalJSON() ([]byte, error) {
+	params := map[string]interface{}{
+		"indices":       p.Indices,
+		"match_field":   p.MatchField,
+		"enrich_fields": p.EnrichFields,
+	}
+	if p.Query != nil {
+		params["query"] = p.Query
+	}
+	return json.Marshal(map[string]interface{}{p.Type: params})
+}
+
+// EnrichProcessor - the enrich processor of the ingest pipelines, which adds the enrich fields of the document of
+// the policy matching the field into the target field, e.g. the region of the user.
+type EnrichProcessor struct {
+	PolicyName    string `json:"policy_name"`
+	Field         string `json:"field"`
+	TargetField   string `json:"target_field"`
+	MaxMatches    int    `json:"max_matches,omitempty"`
+	Override      *bool  `json:"override,omitempty"`
+	IgnoreMissing bool   `json:"ignore_missing,omitempty"`
+}
+
+// Processor returns the processor of the pipeline definition.
+func (p *EnrichProcessor) Processor() map[string]interface{} {
+	return map[string]interface{}{"enrich": p}
+}
+
+func (e *esAdminOper) PutEnrichPolicy(ctx context.Context, name string, policy *EnrichPolicy, opts ...func(*EnrichPutPolicyRequest)) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(policy); err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*EnrichPutPolicyRequest){api.EnrichPutPolicy.WithContext(ctx)}, opts...)
+	resp, err := api.EnrichPutPolicy(name, body, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) ExecuteEnrichPolicy(ctx context.Context, name string, opts ...func(*EnrichExecutePolicyRequest)) error {
+	api := e.client
+	o := append([]func(*EnrichExecutePolicyRequest){api.EnrichExecutePolicy.WithContext(ctx), api.EnrichExecutePolicy.WithWaitForCompletion(true)}, opts...)
+	resp, err := api.EnrichExecutePolicy(name, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) DeleteEnrichPolicy(ctx context.Context, name string, opts ...func(*EnrichDeletePolicyRequest)) error {
+	api := e.client
+	o := append([]func(*EnrichDeletePolicyRequest){api.EnrichDeletePolicy.WithContext(ctx)}, opts...)
+	resp, err := api.EnrichDeletePolicy(name, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+// EnsureEnrichPipeline adds the enrich processors to the pipeline, which is created if it's missing. The processor
+// of the same policy and target field is replaced, so it's safe to be called on every startup.
+func EnsureEnrichPipeline(ctx context.Context, oper ESAdminOper, pipelineID string, processors ...*EnrichProcessor) error {
+	pipeline, err := oper.GetPipeline(ctx, pipelineID)
+	if err != nil && !IsNotFound(err) {
+		return err
+	}
+	if pipeline == nil {
+		pipeline = map[string]interface{}{"description": "the enrich pipeline created by nes"}
+	}
+	existing, _ := pipeline["processors"].([]interface{})
+	merged := make([]interface{}, 0, len(existing)+len(processors))
+	for _, p := range existing {
+		if !replacedEnrichProcessor(p, processors) {
+			merged = append(merged, p)
+		}
+	}
+	for _, p := range processors {
+		merged = append(merged, p.Processor())
+	}
+	pipeline["processors"] = merged
+	return oper.PutPipeline(ctx, pipelineID, pipeline)
+}
+
+func replacedEnrichProcessor(processor interface{}, processors []*EnrichProcessor) bool {
+	m, _ := processor.(map[string]interface{})
+	enrich, ok := m["enrich"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for _, p := range processors {
+		if enrich["policy_name"] == p.PolicyName && enrich["target_field"] == p.TargetField {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,264 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// IndexSpec - the desired state of an index.
+type IndexSpec struct {
+	Name     string                 `json:"name"`
+	Settings *IndexSettings         `json:"settings,omitempty"`
+	Mappings map[string]interface{} `json:"mappings,omitempty"`
+	Aliases  map[string]interface{} `json:"aliases,omitempty"`
+}
+
+// IncompatibleMappingError - the desired mappings can't be applied to the existing index in place,
+// the index should be reindexed instead.
+type IncompatibleMappingError struct {
+	Index  string
+	Fields []string
+}
+
+func (e *IncompatibleMappingError) Error() string {
+	return fmt.Sprintf("nes: the desired mappings of the index %s are incompatible with the existing fields: %s", e.Index, strings.Join(e.Fields, ", "))
+}
+
+// updatableMappingParams - the mapping parameters which can be changed on the existing fields.
+var updatableMappingParams = map[string]bool{
+	"ignore_above":          true,
+	"search_analyzer":       true,
+	"search_quote_analyzer": true,
+	"meta":                  true,
+}
+
+// mappingChanges - the changes turning the current properties into the desired ones,
+// the properties to be put are returned by diffProperties.
+type mappingChanges struct {
+	// added are the paths of the new fields.
+	added []string
+	// removed are the paths of the fields missing in the desired properties.
+	removed []string
+	// changed are the paths of the fields whose parameters are changed, with the updatable ones.
+	changed []string
+	// incompatible are the paths of the fields whose parameters can't be changed.
+	incompatible []string
+	// params are the parameters changed, they are collected only if it's not nil.
+	params []*MappingParamChange
+}
+
+func diffProperties(prefix string, current map[string]interface{}, desired map[string]interface{}, c *mappingChanges) map[string]interface{} {
+	updates := map[string]interface{}{}
+	for name, d := range desired {
+		path := prefix + name
+		desiredField, _ := d.(map[string]interface{})
+		currentField, ok := current[name].(map[string]interface{})
+		if !ok {
+			updates[name] = d
+			c.added = append(c.added, path)
+			continue
+		}
+		if u := diffField(path, currentField, desiredField, c); u != nil {
+			updates[name] = u
+		}
+	}
+	for name := range current {
+		if _, ok := desired[name]; !ok {
+			c.removed = append(c.removed, prefix+name)
+		}
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return updates
+}
+
+func diffField(path string, current map[string]interface{}, desired map[string]interface{}, c *mappingChanges) map[string]interface{} {
+	var update map[string]interface{}
+	changed, incompatible := false, false
+	for param, d := range desired {
+		if param == "properties" || param == "fields" {
+			continue
+		}
+		if param == "type" && fieldType(current) == fieldType(desired) {
+			continue
+		}
+		if param != "type" && reflect.DeepEqual(normalizeJSON(current[param]), normalizeJSON(d)) {
+			continue
+		}
+		changed = true
+		if c.params != nil {
+			change := &MappingParamChange{Path: path, Param: param, Current: current[param], Desired: d, Incompatible: !updatableMappingParams[param]}
+			if param == "type" {
+				change.Current = fieldType(current)
+			}
+			c.params = append(c.params, change)
+		}
+		if !updatableMappingParams[param] {
+			incompatible = true
+			continue
+		}
+		if update == nil {
+			update = copyFieldType(desired)
+		}
+		update[param] = d
+	}
+	if incompatible {
+		c.incompatible = append(c.incompatible, path)
+		return nil
+	}
+	if changed {
+		c.changed = append(c.changed, path)
+	}
+	for _, sub := range []string{"properties", "fields"} {
+		desiredSub, _ := desired[sub].(map[string]interface{})
+		if desiredSub == nil {
+			continue
+		}
+		currentSub, _ := current[sub].(map[string]interface{})
+		if u := diffProperties(path+".", currentSub, desiredSub, c); u != nil {
+			if update == nil {
+				update = copyFieldType(desired)
+			}
+			update[sub] = u
+		}
+	}
+	return update
+}
+
+// fieldType returns the type of the field, which is object if the field has properties but no type.
+func fieldType(field map[string]interface{}) string {
+	if t, ok := field["type"].(string); ok {
+		return t
+	}
+	if _, ok := field["properties"]; ok {
+		return "object"
+	}
+	return ""
+}
+
+func copyFieldType(field map[string]interface{}) map[string]interface{} {
+	m := map[string]interface{}{}
+	if t, ok := field["type"]; ok {
+		m["type"] = t
+	}
+	return m
+}
+
+// normalizeJSON makes the values decoded from the cluster and the ones built in Go comparable.
+func normalizeJSON(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var n interface{}
+	if err := json.Unmarshal(b, &n); err != nil {
+		return v
+	}
+	return n
+}
+
+func (e *esAdminOper) EnsureIndex(ctx context.Context, spec *IndexSpec) error {
+	exists, err := e.IndexExists(ctx, []string{spec.Name})
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return e.CreateIndex(ctx, spec.Name, &CreateIndexBody{
+			Settings: spec.Settings,
+			Mappings: spec.Mappings,
+			Aliases:  spec.Aliases,
+		})
+	}
+
+	if len(spec.Mappings) > 0 {
+		if err := e.ensureMappings(ctx, spec); err != nil {
+			return err
+		}
+	}
+	if len(spec.Aliases) > 0 {
+		return e.ensureAliases(ctx, spec)
+	}
+	return nil
+}
+
+func (e *esAdminOper) ensureMappings(ctx context.Context, spec *IndexSpec) error {
+	mappings, err := e.GetMapping(ctx, []string{spec.Name})
+	if err != nil {
+		return err
+	}
+	desired, _ := normalizeJSON(spec.Mappings).(map[string]interface{})
+	desiredProps, _ := desired["properties"].(map[string]interface{})
+	for index, current := range mappings {
+		currentProps, _ := current["properties"].(map[string]interface{})
+		c := &mappingChanges{}
+		updates := diffProperties("", currentProps, desiredProps, c)
+		if len(c.incompatible) > 0 {
+			sort.Strings(c.incompatible)
+			return &IncompatibleMappingError{Index: index, Fields: c.incompatible}
+		}
+		if updates == nil {
+			continue
+		}
+		if err := e.PutMapping(ctx, []string{index}, map[string]interface{}{"properties": updates}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type indexAliasesResponseBody struct {
+	Aliases map[string]interface{} `json:"aliases"`
+}
+
+func (e *esAdminOper) ensureAliases(ctx context.Context, spec *IndexSpec) error {
+	api := e.client
+	resp, err := api.Indices.GetAlias(api.Indices.GetAlias.WithContext(ctx), api.Indices.GetAlias.WithIndex(spec.Name))
+	if err != nil {
+		return err
+	}
+	respBody := map[string]*indexAliasesResponseBody{}
+	if err := unmarshallResponse(resp, &respBody); err != nil {
+		return err
+	}
+	var actions []*AliasAction
+	for index, current := range respBody {
+		if current == nil {
+			current = &indexAliasesResponseBody{}
+		}
+		for alias, params := range spec.Aliases {
+			if _, ok := current.Aliases[alias]; ok {
+				continue
+			}
+			action := &AliasActionParams{}
+			if b, err := json.Marshal(params); err == nil {
+				_ = json.Unmarshal(b, action)
+			}
+			action.Index = index
+			action.Alias = alias
+			actions = append(actions, &AliasAction{Add: action})
+		}
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+	return e.UpdateAliases(ctx, actions)
+}
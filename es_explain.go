@@ -0,0 +1,265 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Explanation - how the score of a document is computed.
+type Explanation struct {
+	Value       float64        `json:"value"`
+	Description string         `json:"description"`
+	Details     []*Explanation `json:"details"`
+}
+
+type explainResponseBody struct {
+	Matched     bool         `json:"matched"`
+	Explanation *Explanation `json:"explanation"`
+}
+
+// ClauseReport - whether a clause of the bool query matches the document, with the values of the fields it refers.
+type ClauseReport struct {
+	// Occur is one of must, filter, should and must_not.
+	Occur   string
+	Clause  json.RawMessage
+	Matched bool
+	// Fields are the values of the fields in the source of the document, nil if the field is missing.
+	Fields map[string]interface{}
+}
+
+// Excluding reports whether the clause excludes the document from the results.
+func (c *ClauseReport) Excluding() bool {
+	if c.Occur == "must_not" {
+		return c.Matched
+	}
+	return !c.Matched && c.Occur != "should"
+}
+
+// MatchReport - the report of WhyNotMatched.
+type MatchReport struct {
+	Index       string
+	ID          string
+	Matched     bool
+	Explanation *Explanation
+	// Clauses are the reports of the clauses of the top level bool query, a non bool query is reported as a must clause.
+	Clauses []*ClauseReport
+}
+
+func (r *MatchReport) String() string {
+	var sb strings.Builder
+	if r.Matched {
+		score := 0.0
+		if r.Explanation != nil {
+			score = r.Explanation.Value
+		}
+		fmt.Fprintf(&sb, "document %s/%s matches the query with score %g\n", r.Index, r.ID, score)
+	} else {
+		fmt.Fprintf(&sb, "document %s/%s doesn't match the query\n", r.Index, r.ID)
+	}
+	for _, c := range r.Clauses {
+		mark := "ok"
+		if c.Excluding() {
+			mark = "EXCLUDES"
+		} else if !c.Matched {
+			mark = "no match"
+		}
+		fmt.Fprintf(&sb, "  [%s] %s %s\n", mark, c.Occur, c.Clause)
+		fields := make([]string, 0, len(c.Fields))
+		for f := range c.Fields {
+			fields = append(fields, f)
+		}
+		sort.Strings(fields)
+		for _, f := range fields {
+			v, _ := json.Marshal(c.Fields[f])
+			fmt.Fprintf(&sb, "      %s = %s\n", f, v)
+		}
+	}
+	return sb.String()
+}
+
+// WhyNotMatched explains whether the document matches the query, each clause of the top level bool query is
+// explained separately so the ones excluding the document are reported along with the field values of the document.
+// The query is the request body containing the query.
+func (e *esOper) WhyNotMatched(ctx context.Context, index string, id string, query string) (*MatchReport, error) {
+	body := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(query), &body); err != nil {
+		return nil, fmt.Errorf("nes: the request body is not a JSON object: %w", err)
+	}
+	q, ok := body["query"]
+	if !ok {
+		q = json.RawMessage(`{"match_all":{}}`)
+	}
+
+	report := &MatchReport{Index: index, ID: id}
+	explained, err := e.explain(ctx, index, id, q)
+	if err != nil {
+		return nil, err
+	}
+	report.Matched = explained.Matched
+	report.Explanation = explained.Explanation
+
+	doc := &getSourceResponseBody{}
+	if _, err := e.Get(ctx, doc, index, id); err != nil {
+		return nil, err
+	}
+	source := map[string]interface{}{}
+	if err := json.Unmarshal(doc.Source, &source); err != nil {
+		return nil, err
+	}
+
+	for _, c := range splitBoolClauses(q) {
+		r, err := e.explain(ctx, index, id, c.Clause)
+		if err != nil {
+			return nil, err
+		}
+		c.Matched = r.Matched
+		c.Fields = map[string]interface{}{}
+		for _, f := range clauseFields(c.Clause) {
+			c.Fields[f] = sourceValue(source, f)
+		}
+		report.Clauses = append(report.Clauses, c)
+	}
+	return report, nil
+}
+
+func (e *esOper) explain(ctx context.Context, index string, id string, query json.RawMessage) (*explainResponseBody, error) {
+	b, err := json.Marshal(map[string]json.RawMessage{"query": query})
+	if err != nil {
+		return nil, err
+	}
+	api := e.client
+	resp, err := api.Explain(index, id, api.Explain.WithContext(ctx), api.Explain.WithBody(strings.NewReader(string(b))))
+	if err != nil {
+		return nil, err
+	}
+	// the response of 404 still reports the document isn't matched if the document exists
+	if resp.StatusCode == http.StatusNotFound {
+		defer closeResponse(resp)
+		respBody := &explainResponseBody{}
+		if err := json.NewDecoder(resp.Body).Decode(respBody); err == nil && respBody.Explanation != nil {
+			return respBody, nil
+		}
+		return nil, newRespErr(resp)
+	}
+	respBody := &explainResponseBody{}
+	if err := unmarshallResponse(resp, respBody); err != nil {
+		return nil, err
+	}
+	return respBody, nil
+}
+
+func splitBoolClauses(query json.RawMessage) []*ClauseReport {
+	q := map[string]map[string]json.RawMessage{}
+	if err := json.Unmarshal(query, &q); err != nil || len(q) != 1 || q["bool"] == nil {
+		return []*ClauseReport{{Occur: "must", Clause: query}}
+	}
+	var clauses []*ClauseReport
+	for _, occur := range []string{"must", "filter", "should", "must_not"} {
+		raw, ok := q["bool"][occur]
+		if !ok {
+			continue
+		}
+		var list []json.RawMessage
+		if err := json.Unmarshal(raw, &list); err != nil {
+			list = []json.RawMessage{raw}
+		}
+		for _, c := range list {
+			clauses = append(clauses, &ClauseReport{Occur: occur, Clause: c})
+		}
+	}
+	return clauses
+}
+
+// clauseFieldParams - the params of the leaf queries which are not field names.
+var clauseFieldParams = map[string]bool{
+	"boost": true, "_name": true, "minimum_should_match": true, "format": true, "time_zone": true,
+}
+
+// clauseFields returns the fields referred by the clause, the nested compound queries are inspected recursively.
+func clauseFields(clause json.RawMessage) []string {
+	q := map[string]interface{}{}
+	if err := json.Unmarshal(clause, &q); err != nil {
+		return nil
+	}
+	var fields []string
+	var walk func(q map[string]interface{})
+	walk = func(q map[string]interface{}) {
+		for kind, v := range q {
+			params, _ := v.(map[string]interface{})
+			switch kind {
+			case "bool":
+				for _, occur := range params {
+					switch t := occur.(type) {
+					case []interface{}:
+						for _, c := range t {
+							if m, ok := c.(map[string]interface{}); ok {
+								walk(m)
+							}
+						}
+					case map[string]interface{}:
+						walk(t)
+					}
+				}
+			case "exists":
+				if f, ok := params["field"].(string); ok {
+					fields = append(fields, f)
+				}
+			case "multi_match", "query_string", "simple_query_string":
+				if fs, ok := params["fields"].([]interface{}); ok {
+					for _, f := range fs {
+						fields = append(fields, strings.SplitN(fmt.Sprint(f), "^", 2)[0])
+					}
+				}
+			case "ids", "match_all", "match_none":
+			default:
+				for f := range params {
+					if !clauseFieldParams[f] {
+						fields = append(fields, f)
+					}
+				}
+			}
+		}
+	}
+	walk(q)
+	sort.Strings(fields)
+	return fields
+}
+
+// sourceValue returns the value of the field in the source, the sub fields like .keyword fall back to their parents.
+func sourceValue(source map[string]interface{}, field string) interface{} {
+	if v, ok := source[field]; ok {
+		return v
+	}
+	parts := strings.Split(field, ".")
+	var cur interface{} = source
+	for _, p := range parts {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return cur
+		}
+		v, ok := m[p]
+		if !ok {
+			return nil
+		}
+		cur = v
+	}
+	return cur
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// the version types of the versions maintained outside the cluster
+const (
+	// VersionTypeExternal - the write succeeds only if the version is greater than the stored one.
+	VersionTypeExternal = "external"
+	// VersionTypeExternalGTE - the write succeeds if the version is greater than or equal to the stored one.
+	VersionTypeExternalGTE = "external_gte"
+)
+
+// ErrStaleVersion - the write is discarded since the stored document has a newer version.
+var ErrStaleVersion = errors.New("nes: the version is stale, the stored document has a newer version")
+
+// WithExternalVersion - the version of the document carried by the source, e.g. the LSN of the CDC event.
+func WithExternalVersion(version int64, versionType string) func(*IndexRequest) {
+	v := int(version)
+	return func(r *IndexRequest) {
+		r.Version = &v
+		r.VersionType = versionType
+	}
+}
+
+// IndexExternalVersion indexes the document with the external version, ErrStaleVersion is returned
+// if the stored document has a newer version so the stale write can be skipped safely.
+func IndexExternalVersion(ctx context.Context, oper ESOper, index string, id string, obj interface{}, version int64, versionType string, opts ...func(*IndexRequest)) error {
+	o := append([]func(*IndexRequest){WithExternalVersion(version, versionType)}, opts...)
+	err := oper.Index(ctx, index, id, obj, o...)
+	if IsConflict(err) {
+		return fmt.Errorf("%w: %s/%s version %d: %s", ErrStaleVersion, index, id, version, err)
+	}
+	return err
+}
+
+// ExternalVersionItem returns the bulk indexer item with the external version.
+func ExternalVersionItem(item BulkIndexerItem, version int64, versionType string) BulkIndexerItem {
+	item.Version = &version
+	item.VersionType = versionType
+	return item
+}
+
+type bulkIndexAction struct {
+	Index       string `json:"_index,omitempty"`
+	ID          string `json:"_id"`
+	Version     int64  `json:"version"`
+	VersionType string `json:"version_type"`
+}
+
+// WriteBulkIndexExternalVersion writes the index action with the external version and the document
+// into the request body of Bulk.
+func WriteBulkIndexExternalVersion(buf *bytes.Buffer, index string, id string, obj interface{}, version int64, versionType string) error {
+	enc := json.NewEncoder(buf)
+	action := map[string]*bulkIndexAction{"index": {Index: index, ID: id, Version: version, VersionType: versionType}}
+	if err := enc.Encode(action); err != nil {
+		return err
+	}
+	return enc.Encode(obj)
+}
+
+// IsStaleVersion reports whether the bulk item is rejected since the stored document has a newer version.
+func IsStaleVersion(res BulkIndexerResponseItem) bool {
+	return res.Status == http.StatusConflict && res.Error.Type == "version_conflict_engine_exception"
+}
+
+func isExternalVersionType(versionType string) bool {
+	return strings.HasPrefix(versionType, VersionTypeExternal)
+}
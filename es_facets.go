@@ -0,0 +1,258 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	facetAggPrefix   = "nes_facet_"
+	facetValuesAgg   = "values"
+	facetDocsAgg     = "docs"
+	defaultFacetSize = 10
+)
+
+// Facet - a facet of the faceted search, the terms of the field or the ranges of it if the Ranges are not empty.
+type Facet struct {
+	Name  string
+	Field string
+	// Size is the max number of the terms, 10 by default.
+	Size   int
+	Ranges []*FacetRange
+	// NestedPath is the path of the nested objects of the field, the counts are the ones of the root documents.
+	NestedPath string
+}
+
+// FacetRange - a range of the range facet, the From is inclusive and the To is exclusive, either can be nil.
+type FacetRange struct {
+	Key  string      `json:"key"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+// TermsFacet - the facet of the top size terms of the field, e.g. the brands.
+func TermsFacet(name string, field string, size int) *Facet {
+	return &Facet{Name: name, Field: field, Size: size}
+}
+
+// RangeFacet - the facet of the ranges of the field, e.g. the price ranges.
+func RangeFacet(name string, field string, ranges ...*FacetRange) *Facet {
+	return &Facet{Name: name, Field: field, Ranges: ranges}
+}
+
+// Nested - the field is one of the nested objects at the path, e.g. the colors of the variants of the products.
+func (f *Facet) Nested(path string) *Facet {
+	f.NestedPath = path
+	return f
+}
+
+// FacetedSearch - the search with the facets, the selections of each facet filter the hits and the counts of the
+// other facets, but not the counts of the facet itself, so the other values of the facet can still be selected.
+type FacetedSearch struct {
+	// Query is the query of the search, match_all if it's nil.
+	Query  *Query
+	Facets []*Facet
+	// Selections are the values selected of the facets keyed by the facet names, the terms or the keys of the ranges.
+	Selections map[string][]interface{}
+	Sort       []*Sort
+	From       *int
+	Size       *int
+}
+
+// FacetValue - a value of the facet with the number of the documents matching it.
+type FacetValue struct {
+	Value    interface{}
+	Count    int64
+	Selected bool
+}
+
+// FacetResult - the values of a facet, the selected values are included even if no document matches them.
+type FacetResult struct {
+	Name   string
+	Values []*FacetValue
+}
+
+func (f *Facet) agg() Agg {
+	var agg Agg
+	if len(f.Ranges) > 0 {
+		agg = Agg{"range": map[string]interface{}{"field": f.Field, "ranges": f.Ranges}}
+	} else {
+		size := f.Size
+		if size <= 0 {
+			size = defaultFacetSize
+		}
+		agg = TermsAgg(f.Field, size)
+	}
+	if f.NestedPath == "" {
+		return agg
+	}
+	// the root documents are counted by the reverse_nested one instead of the nested objects
+	agg = agg.SubAggs(map[string]Agg{facetDocsAgg: {"reverse_nested": map[string]interface{}{}}})
+	return Agg{"nested": map[string]interface{}{"path": f.NestedPath}}.SubAggs(map[string]Agg{facetValuesAgg: agg})
+}
+
+// filter returns the query of the selected values of the facet, nil if nothing is selected.
+func (f *Facet) filter(selected []interface{}) *Query {
+	if len(selected) == 0 {
+		return nil
+	}
+	var q *Query
+	if len(f.Ranges) == 0 {
+		q = TermsQuery(f.Field, selected...)
+	} else {
+		q = BoolQuery().MinimumShouldMatch(1)
+		for _, r := range f.Ranges {
+			if !containsValue(selected, r.Key) {
+				continue
+			}
+			rq := RangeQuery(f.Field)
+			if r.From != nil {
+				rq.Gte(r.From)
+			}
+			if r.To != nil {
+				rq.Lt(r.To)
+			}
+			q.Should(rq)
+		}
+	}
+	if f.NestedPath != "" {
+		q = NestedQuery(f.NestedPath, q)
+	}
+	return q
+}
+
+func containsValue(values []interface{}, v interface{}) bool {
+	for _, value := range values {
+		if fmt.Sprint(value) == fmt.Sprint(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// body returns the request body of the faceted search, the selections are applied as the post_filter, and each
+// facet aggregation is filtered by the selections of the other facets.
+func (s *FacetedSearch) body() (string, error) {
+	filters := map[string]*Query{}
+	for _, f := range s.Facets {
+		if q := f.filter(s.Selections[f.Name]); q != nil {
+			filters[f.Name] = q
+		}
+	}
+	body := NewSearchBody(s.Query).WithSort(s.Sort...)
+	body.From, body.Size = s.From, s.Size
+	if len(filters) > 0 {
+		post := BoolQuery()
+		for _, f := range s.Facets {
+			if q, ok := filters[f.Name]; ok {
+				post.Filter(q)
+			}
+		}
+		body.PostFilter = post
+	}
+	for _, f := range s.Facets {
+		others := BoolQuery()
+		for _, o := range s.Facets {
+			if q, ok := filters[o.Name]; ok && o.Name != f.Name {
+				others.Filter(q)
+			}
+		}
+		body.WithAgg(facetAggPrefix+f.Name, Agg{"filter": others}.SubAggs(map[string]Agg{facetValuesAgg: f.agg()}))
+	}
+	return body.JSON()
+}
+
+type facetedResponseBody struct {
+	Aggregations AggsResult `json:"aggregations"`
+}
+
+// SearchFacets searches the hits decoded into the model like Search and returns the results of the facets keyed by
+// the facet names, the model can be nil if only the facets are needed.
+func SearchFacets(ctx context.Context, oper Searcher, model interface{}, search *FacetedSearch, indexes []string, opts ...func(*SearchRequest)) (map[string]*FacetResult, error) {
+	query, err := search.body()
+	if err != nil {
+		return nil, err
+	}
+	var raw json.RawMessage
+	// the partial results are returned with the PartialResultsError
+	_, searchErr := oper.Search(ctx, &raw, query, indexes, opts...)
+	if searchErr != nil && !IsPartialResults(searchErr) {
+		return nil, searchErr
+	}
+	if model != nil {
+		if err := json.Unmarshal(raw, model); err != nil {
+			return nil, err
+		}
+	}
+	respBody := &facetedResponseBody{}
+	if err := json.Unmarshal(raw, respBody); err != nil {
+		return nil, err
+	}
+	results := make(map[string]*FacetResult, len(search.Facets))
+	for _, f := range search.Facets {
+		r, err := f.result(respBody.Aggregations, search.Selections[f.Name])
+		if err != nil {
+			return nil, err
+		}
+		results[f.Name] = r
+	}
+	return results, searchErr
+}
+
+func (f *Facet) result(aggs AggsResult, selected []interface{}) (*FacetResult, error) {
+	filtered, err := aggs.SingleBucket(facetAggPrefix + f.Name)
+	if err != nil {
+		return nil, err
+	}
+	values := filtered.Aggs
+	if f.NestedPath != "" {
+		nested, err := values.SingleBucket(facetValuesAgg)
+		if err != nil {
+			return nil, err
+		}
+		values = nested.Aggs
+	}
+	buckets, err := values.Buckets(facetValuesAgg)
+	if err != nil {
+		return nil, err
+	}
+	result := &FacetResult{Name: f.Name, Values: make([]*FacetValue, 0, len(buckets))}
+	var seen []interface{}
+	for _, b := range buckets {
+		v := &FacetValue{Value: b.Key, Count: b.DocCount}
+		if b.KeyAsString != "" && len(f.Ranges) == 0 {
+			v.Value = b.KeyAsString
+		}
+		if f.NestedPath != "" {
+			docs, err := b.Aggs.SingleBucket(facetDocsAgg)
+			if err != nil {
+				return nil, err
+			}
+			v.Count = docs.DocCount
+		}
+		v.Selected = containsValue(selected, v.Value)
+		seen = append(seen, v.Value)
+		result.Values = append(result.Values, v)
+	}
+	for _, s := range selected {
+		if !containsValue(seen, s) {
+			result.Values = append(result.Values, &FacetValue{Value: s, Selected: true})
+		}
+	}
+	return result, nil
+}
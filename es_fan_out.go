@@ -0,0 +1,240 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// IndexGroup - the indices searched by SearchFanOut, the groups should be disjoint, e.g. the indices of the regions.
+type IndexGroup struct {
+	Name    string
+	Indexes []string
+	// Oper searches the group if it's not nil, e.g. the oper of the cluster of the region.
+	Oper Searcher
+	// Optional groups are allowed to fail, their failures are returned as a FanOutError along with the results of
+	// the others. The failure of a required group fails the fan-out and cancels the searches of the others.
+	Optional bool
+}
+
+// FanOutGroupResult - the hits of the query searched in a group.
+type FanOutGroupResult struct {
+	Group *IndexGroup
+	Total int64
+	Hits  []*RawHit
+}
+
+// FanOutMerge - merges the results of the groups succeeded into the hits of the fan-out.
+type FanOutMerge func(results []*FanOutGroupResult) []*RawHit
+
+// FanOutError - the optional groups failed, the results of the others are returned with it.
+type FanOutError struct {
+	// Failures are the errors of the groups failed keyed by their names.
+	Failures map[string]error
+}
+
+func (e *FanOutError) Error() string {
+	names := make([]string, 0, len(e.Failures))
+	for name := range e.Failures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", name, e.Failures[name]))
+	}
+	return fmt.Sprintf("nes: the fan-out search is partial, %d groups failed: %s", len(names), strings.Join(msgs, "; "))
+}
+
+// IsPartialFanOut reports whether the error is caused by the failures of some optional groups of SearchFanOut.
+func IsPartialFanOut(err error) bool {
+	var e *FanOutError
+	return errors.As(err, &e)
+}
+
+type fanOutResponseBody struct {
+	Hits struct {
+		Total struct {
+			Value int64 `json:"value"`
+		} `json:"total"`
+		Hits []*RawHit `json:"hits"`
+	} `json:"hits"`
+}
+
+// SearchFanOut searches the query in the groups concurrently and merges their hits by the merge, MergeSorted(size) if
+// it's nil. The sources of the merged hits are decoded into the model if it's not nil, which is a pointer to a slice.
+// The merged hits are returned along with a FanOutError if some optional groups fail.
+func SearchFanOut(ctx context.Context, oper Searcher, model interface{}, query string, groups []*IndexGroup, merge FanOutMerge, opts ...func(*SearchRequest)) ([]*RawHit, error) {
+	if merge == nil {
+		merge = MergeSorted(0)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make([]*FanOutGroupResult, len(groups))
+	errs := make([]error, len(groups))
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+		// failed is the first required group failed, the others may fail since they are canceled
+		failed = -1
+	)
+	for i, group := range groups {
+		wg.Add(1)
+		go func(i int, group *IndexGroup) {
+			defer wg.Done()
+			searcher := group.Oper
+			if searcher == nil {
+				searcher = oper
+			}
+			respBody := &fanOutResponseBody{}
+			if _, err := searcher.Search(ctx, respBody, query, group.Indexes, opts...); err != nil {
+				errs[i] = err
+				if !group.Optional {
+					mu.Lock()
+					if failed < 0 {
+						failed = i
+						cancel()
+					}
+					mu.Unlock()
+				}
+				return
+			}
+			results[i] = &FanOutGroupResult{Group: group, Total: respBody.Hits.Total.Value, Hits: respBody.Hits.Hits}
+		}(i, group)
+	}
+	wg.Wait()
+	if failed >= 0 {
+		return nil, fmt.Errorf("nes: fail to search the group %s: %w", groups[failed].Name, errs[failed])
+	}
+
+	succeeded := make([]*FanOutGroupResult, 0, len(groups))
+	var partial *FanOutError
+	for i, group := range groups {
+		if errs[i] == nil {
+			succeeded = append(succeeded, results[i])
+			continue
+		}
+		if partial == nil {
+			partial = &FanOutError{Failures: map[string]error{}}
+		}
+		partial.Failures[group.Name] = errs[i]
+	}
+	if len(succeeded) == 0 && partial != nil {
+		return nil, partial
+	}
+
+	hits := merge(succeeded)
+	if model != nil {
+		sources := make([]json.RawMessage, 0, len(hits))
+		for _, hit := range hits {
+			sources = append(sources, hit.Source)
+		}
+		b, err := json.Marshal(sources)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, model); err != nil {
+			return nil, err
+		}
+	}
+	if partial != nil {
+		return hits, partial
+	}
+	return hits, nil
+}
+
+// MergeSorted - merges the hits of the groups by their sort values in the order of the sorts, which must be the sorts
+// of the query, or by their scores descending if there is no sort. The first size hits are kept if the size is positive.
+func MergeSorted(size int, sorts ...*Sort) FanOutMerge {
+	return func(results []*FanOutGroupResult) []*RawHit {
+		var hits []*RawHit
+		for _, r := range results {
+			hits = append(hits, r.Hits...)
+		}
+		sort.SliceStable(hits, func(i, j int) bool {
+			if len(sorts) == 0 {
+				return scoreOf(hits[i]) > scoreOf(hits[j])
+			}
+			for k, s := range sorts {
+				c := compareSortValues(sortValueOf(hits[i], k), sortValueOf(hits[j], k))
+				if c == 0 {
+					continue
+				}
+				if s.descending() {
+					return c > 0
+				}
+				return c < 0
+			}
+			return false
+		})
+		if size > 0 && len(hits) > size {
+			hits = hits[:size]
+		}
+		return hits
+	}
+}
+
+// descending reports whether the sort is descending, which is the default order of the score.
+func (s *Sort) descending() bool {
+	order, _ := s.params["order"].(string)
+	if order == "" {
+		return s.key == "_score"
+	}
+	return order == "desc"
+}
+
+func scoreOf(hit *RawHit) float64 {
+	if hit.Score == nil {
+		return 0
+	}
+	return *hit.Score
+}
+
+func sortValueOf(hit *RawHit, i int) interface{} {
+	if i >= len(hit.Sort) {
+		return nil
+	}
+	return hit.Sort[i]
+}
+
+// compareSortValues compares the sort values of the hits, the missing values are the greatest ones.
+func compareSortValues(a interface{}, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	if x, ok := a.(float64); ok {
+		if y, ok := b.(float64); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
@@ -0,0 +1,247 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FieldTransformer - transforms the value of a field of the documents before it's indexed and after it's read,
+// e.g. encrypts the sensitive fields at the application level.
+type FieldTransformer interface {
+	EncodeField(value interface{}) (interface{}, error)
+	DecodeField(value interface{}) (interface{}, error)
+}
+
+// WithFieldTransformers - the transformers of the fields keyed by their dotted paths like user.ssn, the values are
+// encoded by Create, Index, Update and Upsert and decoded in the _source of Get, MultiGet and the searches.
+// The bodies of the bulk requests are written by the callers and are not transformed.
+func WithFieldTransformers(transformers map[string]FieldTransformer) ESOperOption {
+	return func(e *esOper) {
+		if e.fieldTransformers == nil {
+			e.fieldTransformers = map[string]FieldTransformer{}
+		}
+		for path, t := range transformers {
+			e.fieldTransformers[path] = t
+		}
+	}
+}
+
+// encodeDoc encodes the document with the fields transformed.
+func (e *esOper) encodeDoc(w io.Writer, doc interface{}) error {
+	doc, err := e.encodeFields(doc)
+	if err != nil {
+		return err
+	}
+	return e.encode(w, doc)
+}
+
+// encodeFields returns the document as a map with the fields transformed, or the document itself if there are no
+// transformers.
+func (e *esOper) encodeFields(doc interface{}) (interface{}, error) {
+	if len(e.fieldTransformers) == 0 || doc == nil {
+		return doc, nil
+	}
+	buf := &bytes.Buffer{}
+	if err := e.encode(buf, doc); err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	dec := json.NewDecoder(buf)
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	for path, t := range e.fieldTransformers {
+		if err := transformField(m, strings.Split(path, "."), t.EncodeField); err != nil {
+			return nil, fmt.Errorf("nes: fail to encode the field %s: %w", path, err)
+		}
+	}
+	return m, nil
+}
+
+// decodeFields returns the response body with the fields of every _source in it transformed.
+func (e *esOper) decodeFields(body []byte) ([]byte, error) {
+	if len(e.fieldTransformers) == 0 {
+		return body, nil
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if err := e.decodeSources(v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// decodeSources transforms the fields of the _source objects in the value, including the ones of the inner hits.
+func (e *esOper) decodeSources(v interface{}) error {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if source, ok := item.(map[string]interface{}); ok && k == "_source" {
+				for path, t := range e.fieldTransformers {
+					if err := transformField(source, strings.Split(path, "."), t.DecodeField); err != nil {
+						return fmt.Errorf("nes: fail to decode the field %s: %w", path, err)
+					}
+				}
+				continue
+			}
+			if err := e.decodeSources(item); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range val {
+			if err := e.decodeSources(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// transformField transforms the values of the path in the object, the arrays of objects along the path are traversed.
+func transformField(obj map[string]interface{}, path []string, transform func(value interface{}) (interface{}, error)) error {
+	v, ok := obj[path[0]]
+	if !ok || v == nil {
+		return nil
+	}
+	if len(path) == 1 {
+		t, err := transform(v)
+		if err != nil {
+			return err
+		}
+		obj[path[0]] = t
+		return nil
+	}
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return transformField(val, path[1:], transform)
+	case []interface{}:
+		for _, item := range val {
+			if m, ok := item.(map[string]interface{}); ok {
+				if err := transformField(m, path[1:], transform); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// NewAESFieldTransformer - the transformer encrypting the values by AES-GCM with the 16, 24 or 32 bytes key, the values are
+// stored as base64 strings, which should be mapped as binary or keyword fields not indexed. The values not encrypted
+// are decoded as they are, so the fields can be encrypted gradually.
+func NewAESFieldTransformer(key []byte) (FieldTransformer, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &aesFieldTransformer{gcm: gcm}, nil
+}
+
+// encryptedPrefix marks the encrypted values.
+const encryptedPrefix = "nes:aes:"
+
+type aesFieldTransformer struct {
+	gcm cipher.AEAD
+}
+
+func (t *aesFieldTransformer) EncodeField(value interface{}) (interface{}, error) {
+	plain, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, t.gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := t.gcm.Seal(nonce, nonce, plain, nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (t *aesFieldTransformer) DecodeField(value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok || !strings.HasPrefix(s, encryptedPrefix) {
+		return value, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(s[len(encryptedPrefix):])
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < t.gcm.NonceSize() {
+		return nil, errors.New("the encrypted value is too short")
+	}
+	nonce, ciphertext := sealed[:t.gcm.NonceSize()], sealed[t.gcm.NonceSize():]
+	plain, err := t.gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(plain))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// NewTokenFieldTransformer - the transformer replacing the values with their HMAC-SHA256 tokens, which can't be decoded
+// but can be matched exactly by the term queries of the tokens, e.g. the emails looked up by their tokens.
+func NewTokenFieldTransformer(key []byte) FieldTransformer {
+	return &tokenFieldTransformer{key: key}
+}
+
+type tokenFieldTransformer struct {
+	key []byte
+}
+
+func (t *tokenFieldTransformer) EncodeField(value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		s = string(b)
+	}
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func (t *tokenFieldTransformer) DecodeField(value interface{}) (interface{}, error) {
+	return value, nil
+}
@@ -0,0 +1,156 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"sort"
+)
+
+// FieldUsage - the number of the times the data structures of a field are accessed by the searches.
+type FieldUsage struct {
+	Any           int64               `json:"any"`
+	InvertedIndex *InvertedIndexUsage `json:"inverted_index"`
+	StoredFields  int64               `json:"stored_fields"`
+	DocValues     int64               `json:"doc_values"`
+	Points        int64               `json:"points"`
+	Norms         int64               `json:"norms"`
+	TermVectors   int64               `json:"term_vectors"`
+	KnnVectors    int64               `json:"knn_vectors"`
+}
+
+// InvertedIndexUsage - the number of the times the parts of the inverted index of a field are accessed.
+type InvertedIndexUsage struct {
+	Terms           int64 `json:"terms"`
+	Postings        int64 `json:"postings"`
+	Proximity       int64 `json:"proximity"`
+	Positions       int64 `json:"positions"`
+	TermFrequencies int64 `json:"term_frequencies"`
+	Offsets         int64 `json:"offsets"`
+	Payloads        int64 `json:"payloads"`
+}
+
+func (u *FieldUsage) add(o *FieldUsage) {
+	u.Any += o.Any
+	u.StoredFields += o.StoredFields
+	u.DocValues += o.DocValues
+	u.Points += o.Points
+	u.Norms += o.Norms
+	u.TermVectors += o.TermVectors
+	u.KnnVectors += o.KnnVectors
+	if o.InvertedIndex == nil {
+		return
+	}
+	if u.InvertedIndex == nil {
+		u.InvertedIndex = &InvertedIndexUsage{}
+	}
+	u.InvertedIndex.Terms += o.InvertedIndex.Terms
+	u.InvertedIndex.Postings += o.InvertedIndex.Postings
+	u.InvertedIndex.Proximity += o.InvertedIndex.Proximity
+	u.InvertedIndex.Positions += o.InvertedIndex.Positions
+	u.InvertedIndex.TermFrequencies += o.InvertedIndex.TermFrequencies
+	u.InvertedIndex.Offsets += o.InvertedIndex.Offsets
+	u.InvertedIndex.Payloads += o.InvertedIndex.Payloads
+}
+
+type fieldUsageIndexBody struct {
+	Shards []struct {
+		Stats struct {
+			Fields map[string]*FieldUsage `json:"fields"`
+		} `json:"stats"`
+	} `json:"shards"`
+}
+
+func (e *esAdminOper) FieldUsageStats(ctx context.Context, index string, opts ...func(*IndicesFieldUsageStatsRequest)) (map[string]map[string]*FieldUsage, error) {
+	api := e.client
+	o := append([]func(*IndicesFieldUsageStatsRequest){api.Indices.FieldUsageStats.WithContext(ctx)}, opts...)
+	resp, err := api.Indices.FieldUsageStats(index, o...)
+	if err != nil {
+		return nil, err
+	}
+	respBody := map[string]*fieldUsageIndexBody{}
+	if err := unmarshallResponse(resp, &respBody); err != nil {
+		return nil, err
+	}
+	delete(respBody, "_shards")
+	stats := make(map[string]map[string]*FieldUsage, len(respBody))
+	for name, body := range respBody {
+		if body == nil {
+			continue
+		}
+		fields := map[string]*FieldUsage{}
+		for _, shard := range body.Shards {
+			for field, usage := range shard.Stats.Fields {
+				if usage == nil {
+					continue
+				}
+				if _, ok := fields[field]; !ok {
+					fields[field] = &FieldUsage{}
+				}
+				fields[field].add(usage)
+			}
+		}
+		stats[name] = fields
+	}
+	return stats, nil
+}
+
+// UnusedFields returns the sorted paths of the mapped fields never accessed by the searches of each index matching
+// the index pattern, including the multi-fields like title.keyword, the candidates of the mapping cleanups.
+// The usage is tracked since the shards are started, so the indices should serve the traffic long enough.
+func UnusedFields(ctx context.Context, oper ESAdminOper, index string) (map[string][]string, error) {
+	mappings, err := oper.GetMapping(ctx, []string{index})
+	if err != nil {
+		return nil, err
+	}
+	stats, err := oper.FieldUsageStats(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+	unused := make(map[string][]string, len(mappings))
+	for name, mapping := range mappings {
+		props, _ := mapping["properties"].(map[string]interface{})
+		fields := []string{}
+		for _, path := range mappedFieldPaths("", props) {
+			if usage, ok := stats[name][path]; !ok || usage.Any == 0 {
+				fields = append(fields, path)
+			}
+		}
+		sort.Strings(fields)
+		unused[name] = fields
+	}
+	return unused, nil
+}
+
+// mappedFieldPaths returns the paths of the leaf fields of the properties, the object fields are walked through.
+func mappedFieldPaths(prefix string, props map[string]interface{}) []string {
+	var paths []string
+	for name, p := range props {
+		field, _ := p.(map[string]interface{})
+		path := prefix + name
+		if sub, ok := field["properties"].(map[string]interface{}); ok {
+			paths = append(paths, mappedFieldPaths(path+".", sub)...)
+			continue
+		}
+		if t := fieldType(field); t == "object" || t == "alias" {
+			continue
+		}
+		paths = append(paths, path)
+		if sub, ok := field["fields"].(map[string]interface{}); ok {
+			paths = append(paths, mappedFieldPaths(path+".", sub)...)
+		}
+	}
+	return paths
+}
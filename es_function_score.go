@@ -0,0 +1,163 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"encoding/json"
+)
+
+// FunctionScoreQuery - the function_score query modifying the scores of the query by the functions,
+// the score_mode, boost_mode and max_boost are set by With.
+//
+// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/query-dsl-function-score-query.html.
+func FunctionScoreQuery(query *Query, functions ...*ScoreFunction) *Query {
+	if query == nil {
+		query = MatchAllQuery()
+	}
+	return newQuery("function_score", map[string]interface{}{"query": query, "functions": functions})
+}
+
+// AddFunctions adds the functions of the function_score query.
+func (q *Query) AddFunctions(functions ...*ScoreFunction) *Query {
+	existing, _ := q.params["functions"].([]*ScoreFunction)
+	q.params["functions"] = append(existing, functions...)
+	return q
+}
+
+// ScoreFunction - a function of the function_score query, applied on the documents matching the filter if any.
+type ScoreFunction struct {
+	kind string
+	// field is the field of the decay functions, whose params are under the field
+	field  string
+	params map[string]interface{}
+	filter *Query
+	weight *float64
+}
+
+// FieldValueFactorFunction - the score by the value of the field multiplied by the factor and then modified by the
+// modifier like log1p, the missing is the value of the documents missing the field.
+func FieldValueFactorFunction(field string, factor float64, modifier string, missing float64) *ScoreFunction {
+	params := map[string]interface{}{"field": field, "factor": factor, "missing": missing}
+	if modifier != "" {
+		params["modifier"] = modifier
+	}
+	return &ScoreFunction{kind: "field_value_factor", params: params}
+}
+
+// DecayFunction - the gauss, exp or linear decay of the score by the distance of the field value from the origin,
+// the score is decayed to 0.5 at the scale by default, e.g. DecayFunction("gauss", "published_at", "now", "7d").
+func DecayFunction(kind string, field string, origin interface{}, scale interface{}) *ScoreFunction {
+	return &ScoreFunction{kind: kind, field: field, params: map[string]interface{}{"origin": origin, "scale": scale}}
+}
+
+// Offset - the distance from the origin within which the decay function doesn't decay the score.
+func (f *ScoreFunction) Offset(offset interface{}) *ScoreFunction {
+	return f.With("offset", offset)
+}
+
+// Decay - the score of the decay function at the scale.
+func (f *ScoreFunction) Decay(decay float64) *ScoreFunction {
+	return f.With("decay", decay)
+}
+
+// ScriptScoreFunction - the score computed by the script, e.g. _score * doc['likes'].value.
+func ScriptScoreFunction(script *Script) *ScoreFunction {
+	return &ScoreFunction{kind: "script_score", params: map[string]interface{}{"script": script}}
+}
+
+// RandomScoreFunction - the random score, which is reproducible with the same seed and field like _seq_no.
+func RandomScoreFunction(seed interface{}, field string) *ScoreFunction {
+	params := map[string]interface{}{}
+	if seed != nil {
+		params["seed"] = seed
+		params["field"] = field
+	}
+	return &ScoreFunction{kind: "random_score", params: params}
+}
+
+// WeightFunction - the constant score of the weight, usually combined with a filter.
+func WeightFunction(weight float64) *ScoreFunction {
+	return (&ScoreFunction{}).Weight(weight)
+}
+
+// Filter - the function is only applied on the documents matching the filter.
+func (f *ScoreFunction) Filter(filter *Query) *ScoreFunction {
+	f.filter = filter
+	return f
+}
+
+// Weight - the weight multiplied by the score of the function.
+func (f *ScoreFunction) Weight(weight float64) *ScoreFunction {
+	f.weight = &weight
+	return f
+}
+
+// With sets the parameter of the function.
+func (f *ScoreFunction) With(param string, value interface{}) *ScoreFunction {
+	f.params[param] = value
+	return f
+}
+
+// MarshalJSON -
+func (f *ScoreFunction) MarshalJSON() ([]byte, error) {
+	body := map[string]interface{}{}
+	switch {
+	case f.kind == "":
+	case f.field != "":
+		body[f.kind] = map[string]interface{}{f.field: f.params}
+	default:
+		body[f.kind] = f.params
+	}
+	if f.filter != nil {
+		body["filter"] = f.filter
+	}
+	if f.weight != nil {
+		body["weight"] = *f.weight
+	}
+	return json.Marshal(body)
+}
+
+// Rescore - the rescoring of the top window size hits of each shard by the query.
+//
+// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/filter-search-results.html#rescore.
+type Rescore struct {
+	WindowSize int           `json:"window_size"`
+	Query      *RescoreQuery `json:"query"`
+}
+
+// RescoreQuery - the query of the rescoring, the score_mode is one of total, multiply, avg, max and min.
+type RescoreQuery struct {
+	RescoreQuery       *Query   `json:"rescore_query"`
+	QueryWeight        *float64 `json:"query_weight,omitempty"`
+	RescoreQueryWeight *float64 `json:"rescore_query_weight,omitempty"`
+	ScoreMode          string   `json:"score_mode,omitempty"`
+}
+
+// NewRescore - the rescoring of the top window size hits by the query.
+func NewRescore(windowSize int, query *Query) *Rescore {
+	return &Rescore{WindowSize: windowSize, Query: &RescoreQuery{RescoreQuery: query}}
+}
+
+// Weights - the weights of the original score and the score of the rescore query, both are 1 by default.
+func (r *Rescore) Weights(queryWeight float64, rescoreQueryWeight float64) *Rescore {
+	r.Query.QueryWeight, r.Query.RescoreQueryWeight = &queryWeight, &rescoreQueryWeight
+	return r
+}
+
+// ScoreMode - the way the scores are combined, total by default.
+func (r *Rescore) ScoreMode(mode string) *Rescore {
+	r.Query.ScoreMode = mode
+	return r
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFunctionScoreBuilders(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected string
+	}{
+		{
+			"field_value_factor",
+			FieldValueFactorFunction("likes", 1.2, "log1p", 1),
+			`{"field_value_factor":{"factor":1.2,"field":"likes","missing":1,"modifier":"log1p"}}`,
+		},
+		{
+			"field_value_factor without modifier",
+			FieldValueFactorFunction("likes", 1, "", 0),
+			`{"field_value_factor":{"factor":1,"field":"likes","missing":0}}`,
+		},
+		{
+			"decay",
+			DecayFunction("gauss", "published_at", "now", "7d").Offset("1d").Decay(0.3),
+			`{"gauss":{"published_at":{"decay":0.3,"offset":"1d","origin":"now","scale":"7d"}}}`,
+		},
+		{
+			"script_score",
+			ScriptScoreFunction(&Script{Source: "_score * doc['likes'].value"}),
+			`{"script_score":{"script":{"source":"_score * doc['likes'].value"}}}`,
+		},
+		{
+			"random_score",
+			RandomScoreFunction(42, "_seq_no"),
+			`{"random_score":{"field":"_seq_no","seed":42}}`,
+		},
+		{
+			"random_score without seed",
+			RandomScoreFunction(nil, ""),
+			`{"random_score":{}}`,
+		},
+		{
+			"weight with filter",
+			WeightFunction(2).Filter(TermQuery("tag", "hot")),
+			`{"filter":{"term":{"tag":{"value":"hot"}}},"weight":2}`,
+		},
+		{
+			"weighted function",
+			FieldValueFactorFunction("likes", 1, "", 0).Weight(0.5),
+			`{"field_value_factor":{"factor":1,"field":"likes","missing":0},"weight":0.5}`,
+		},
+		{
+			"function_score",
+			FunctionScoreQuery(MatchQuery("title", "nes"), WeightFunction(2)).With("score_mode", "sum").With("boost_mode", "multiply"),
+			`{"function_score":{"boost_mode":"multiply","functions":[{"weight":2}],"query":{"match":{"title":{"query":"nes"}}},"score_mode":"sum"}}`,
+		},
+		{
+			"function_score of match_all",
+			FunctionScoreQuery(nil).AddFunctions(RandomScoreFunction(1, "_seq_no")).AddFunctions(WeightFunction(3)),
+			`{"function_score":{"functions":[{"random_score":{"field":"_seq_no","seed":1}},{"weight":3}],"query":{"match_all":{}}}}`,
+		},
+		{
+			"rescore",
+			NewRescore(100, MatchQuery("title", "nes")).Weights(0.7, 1.5).ScoreMode("max"),
+			`{"window_size":100,"query":{"rescore_query":{"match":{"title":{"query":"nes"}}},"query_weight":0.7,"rescore_query_weight":1.5,"score_mode":"max"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !jsonEqual(t, b, []byte(tt.expected)) {
+				t.Errorf("got %s, expected %s", b, tt.expected)
+			}
+		})
+	}
+}
+
+func jsonEqual(t *testing.T, a []byte, b []byte) bool {
+	t.Helper()
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatal(err)
+	}
+	return len(diffJSON("", va, vb, nil)) == 0
+}
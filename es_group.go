@@ -0,0 +1,83 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	groupAggName   = "groups"
+	topHitsAggName = "top_hits"
+	// defaultGroupSize - the number of the groups if the query has no size.
+	defaultGroupSize = 10
+)
+
+// SearchGroupTopHits searches the top n hits of each group of the field, e.g. the latest n orders of each user.
+// The size of the query is the number of the groups, its sort and _source are applied on the hits of each group.
+// The model is a pointer to a map from the group keys to the slices of the hits, e.g. *map[string][]*Order.
+func SearchGroupTopHits(ctx context.Context, oper ESOper, model interface{}, groupField string, topN int, query string, indexes []string, opts ...func(*SearchRequest)) (interface{}, error) {
+	body := map[string]interface{}{}
+	if query != "" {
+		if err := json.Unmarshal([]byte(query), &body); err != nil {
+			return nil, fmt.Errorf("nes: the request body is not a JSON object: %w", err)
+		}
+	}
+	groupSize := defaultGroupSize
+	if size, ok := body["size"].(float64); ok && size > 0 {
+		groupSize = int(size)
+	}
+	topHits := TopHitsAgg(topN)
+	for _, param := range []string{"sort", "_source"} {
+		if v, ok := body[param]; ok {
+			topHits.With(param, v)
+			delete(body, param)
+		}
+	}
+	body["size"] = 0
+	body["aggs"] = map[string]Agg{
+		groupAggName: TermsAgg(groupField, groupSize).SubAggs(map[string]Agg{topHitsAggName: topHits}),
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody := &aggsResponseBody{}
+	if _, err := oper.Search(ctx, respBody, string(b), indexes, opts...); err != nil {
+		return nil, err
+	}
+	buckets, err := respBody.Aggregations.Buckets(groupAggName)
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[string]json.RawMessage, len(buckets))
+	for _, bucket := range buckets {
+		var hits json.RawMessage
+		if err := bucket.Aggs.TopHits(topHitsAggName, &hits); err != nil {
+			return nil, err
+		}
+		groups[bucket.KeyString()] = hits
+	}
+	if b, err = json.Marshal(groups); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, model); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HedgingConfig - the hedging of the Get and Search requests, a backup request is sent if the primary one takes
+// longer than the Delay and the first response is taken. The backup request goes to another node since the client
+// selects the nodes in a round-robin manner. The scroll searches are never hedged.
+type HedgingConfig struct {
+	// Delay is the latency threshold of the backup requests, e.g. the p95 latency of the requests.
+	Delay time.Duration
+	// BudgetRatio is the max ratio of the backup requests to the primary ones, 0.1 by default.
+	BudgetRatio float64
+	// MaxBudget is the max number of the backup requests saved by the budget for the bursts, 10 by default.
+	MaxBudget float64
+}
+
+// WithHedging - the hedging of the Get and Search requests, they are not hedged by default.
+func WithHedging(config *HedgingConfig) ESOperOption {
+	return func(e *esOper) {
+		if config == nil || config.Delay <= 0 {
+			e.hedging = nil
+			return
+		}
+		h := &hedging{HedgingConfig: *config}
+		if h.BudgetRatio <= 0 {
+			h.BudgetRatio = 0.1
+		}
+		if h.MaxBudget <= 0 {
+			h.MaxBudget = 10
+		}
+		e.hedging = h
+	}
+}
+
+type hedging struct {
+	HedgingConfig
+
+	mu     sync.Mutex
+	budget float64
+
+	hedged int64
+	won    int64
+}
+
+// earn adds the budget of a primary request.
+func (h *hedging) earn() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.budget += h.BudgetRatio
+	if h.budget > h.MaxBudget {
+		h.budget = h.MaxBudget
+	}
+}
+
+// spend reports whether there is the budget for a backup request.
+func (h *hedging) spend() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.budget < 1 {
+		return false
+	}
+	h.budget--
+	return true
+}
+
+type hedgedResult struct {
+	resp   *Response
+	err    error
+	cancel context.CancelFunc
+	backup bool
+}
+
+// succeeded reports whether the result can be taken, the errors of the server may be transient.
+func (r *hedgedResult) succeeded() bool {
+	return r.err == nil && r.resp.StatusCode < 500
+}
+
+// cancelOnClose cancels the context of the response once the body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// do performs the request, and a backup one if the primary one is slow and there is the budget,
+// the context of the response taken is canceled once its body is closed.
+func (h *hedging) do(ctx context.Context, perform func(ctx context.Context) (*Response, error)) (*Response, error) {
+	h.earn()
+	results := make(chan *hedgedResult, 2)
+	start := func(backup bool) {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			resp, err := perform(attemptCtx)
+			results <- &hedgedResult{resp: resp, err: err, cancel: cancel, backup: backup}
+		}()
+	}
+	start(false)
+	timer := time.NewTimer(h.Delay)
+	defer timer.Stop()
+
+	pending := 1
+	var failed *hedgedResult
+	for {
+		select {
+		case <-timer.C:
+			// the timer fires only if the primary one is pending
+			if h.spend() {
+				atomic.AddInt64(&h.hedged, 1)
+				pending++
+				start(true)
+			}
+		case r := <-results:
+			pending--
+			if !r.succeeded() && pending > 0 {
+				// wait for the other attempt
+				failed = r
+				continue
+			}
+			if failed != nil {
+				failed.discard()
+			}
+			if pending > 0 {
+				// the other attempt is canceled and discarded once it returns
+				go func() {
+					(<-results).discard()
+				}()
+			}
+			return r.take(h)
+		}
+	}
+}
+
+func (r *hedgedResult) take(h *hedging) (*Response, error) {
+	if r.backup && r.succeeded() {
+		atomic.AddInt64(&h.won, 1)
+	}
+	if r.err != nil {
+		r.cancel()
+		return nil, r.err
+	}
+	r.resp.Body = &cancelOnClose{ReadCloser: r.resp.Body, cancel: r.cancel}
+	return r.resp, nil
+}
+
+func (r *hedgedResult) discard() {
+	r.cancel()
+	if r.resp != nil {
+		closeResponse(r.resp)
+	}
+}
+
+func (e *esOper) hedge(ctx context.Context, perform func(ctx context.Context) (*Response, error)) (*Response, error) {
+	if e.hedging == nil {
+		return perform(ctx)
+	}
+	return e.hedging.do(ctx, perform)
+}
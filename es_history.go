@@ -0,0 +1,197 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// HistoryIndexSuffix - the suffix of the companion index keeping the previous versions of the documents.
+const HistoryIndexSuffix = "-history"
+
+// DocVersion - a previous version of a document.
+type DocVersion struct {
+	Index      string          `json:"index"`
+	ID         string          `json:"doc_id"`
+	Version    int64           `json:"version"`
+	Source     json.RawMessage `json:"source"`
+	ArchivedAt time.Time       `json:"archived_at"`
+	Actor      string          `json:"actor,omitempty"`
+}
+
+// HistoryESOper - the oper appending the previous version of the document into the companion history index
+// on each Index, Update, UpdateScript and Upsert operation. The history index is ensured with the explicit mappings before
+// its first version is appended, an *IncompatibleMappingError is returned if it's mapped dynamically before.
+type HistoryESOper interface {
+	ESOper
+
+	// History returns the previous versions of the document archived in the history index, the latest first.
+	History(ctx context.Context, index string, id string, size int) ([]*DocVersion, error)
+}
+
+// HistoryConfig -
+type HistoryConfig struct {
+	// HistoryIndex returns the history index of the index, index+HistoryIndexSuffix is used if it's nil.
+	HistoryIndex func(index string) string
+	// Actor extracts the actor from the context, the subject id of the nfgo MDC is used if it's nil.
+	Actor func(ctx context.Context) string
+}
+
+// NewHistoryESOper -
+func NewHistoryESOper(oper ESOper, config *HistoryConfig) HistoryESOper {
+	h := &historyESOper{
+		ESOper:       oper,
+		historyIndex: config.HistoryIndex,
+		actor:        config.Actor,
+	}
+	if h.historyIndex == nil {
+		h.historyIndex = func(index string) string {
+			return index + HistoryIndexSuffix
+		}
+	}
+	if h.actor == nil {
+		h.actor = mdcSubjectID
+	}
+	return h
+}
+
+type historyESOper struct {
+	ESOper
+	historyIndex func(index string) string
+	actor        func(ctx context.Context) string
+	// the history indexes ensured
+	ensured sync.Map
+}
+
+// historyMappings - the mappings of the history indexes, the sources of the versions are kept but not indexed.
+var historyMappings = map[string]interface{}{
+	"dynamic": "strict",
+	"properties": map[string]interface{}{
+		"index":       map[string]interface{}{"type": "keyword"},
+		"doc_id":      map[string]interface{}{"type": "keyword"},
+		"version":     map[string]interface{}{"type": "long"},
+		"source":      map[string]interface{}{"type": "object", "enabled": false},
+		"archived_at": map[string]interface{}{"type": "date"},
+		"actor":       map[string]interface{}{"type": "keyword"},
+	},
+}
+
+func (h *historyESOper) ensureHistoryIndex(ctx context.Context, index string) error {
+	if _, ok := h.ensured.Load(index); ok {
+		return nil
+	}
+	if err := h.ESOper.EnsureIndex(ctx, &IndexSpec{Name: index, Mappings: historyMappings}); err != nil {
+		return err
+	}
+	h.ensured.Store(index, struct{}{})
+	return nil
+}
+
+// archive appends the current version of the document into the history index, the missing document is skipped.
+func (h *historyESOper) archive(ctx context.Context, index string, id string) error {
+	if id == "" {
+		return nil
+	}
+	doc := &getSourceResponseBody{}
+	if _, err := h.ESOper.Get(ctx, doc, index, id); err != nil {
+		if IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !doc.Found {
+		return nil
+	}
+	historyIndex := h.historyIndex(index)
+	if err := h.ensureHistoryIndex(ctx, historyIndex); err != nil {
+		return err
+	}
+	return h.ESOper.Index(ctx, historyIndex, "", &DocVersion{
+		Index:      index,
+		ID:         id,
+		Version:    doc.Version,
+		Source:     doc.Source,
+		ArchivedAt: time.Now(),
+		Actor:      h.actor(ctx),
+	})
+}
+
+func (h *historyESOper) Index(ctx context.Context, index string, id string, obj interface{}, opts ...func(*IndexRequest)) error {
+	if err := h.archive(ctx, index, id); err != nil {
+		return err
+	}
+	return h.ESOper.Index(ctx, index, id, obj, opts...)
+}
+
+func (h *historyESOper) Update(ctx context.Context, index string, id string, obj interface{}, opts ...func(*UpdateRequest)) error {
+	if err := h.archive(ctx, index, id); err != nil {
+		return err
+	}
+	return h.ESOper.Update(ctx, index, id, obj, opts...)
+}
+
+func (h *historyESOper) UpdateScript(ctx context.Context, index string, id string, script *Script, opts ...func(*UpdateRequest)) error {
+	if err := h.archive(ctx, index, id); err != nil {
+		return err
+	}
+	return h.ESOper.UpdateScript(ctx, index, id, script, opts...)
+}
+
+func (h *historyESOper) Upsert(ctx context.Context, index string, id string, obj interface{}, opts ...func(*UpdateRequest)) error {
+	if err := h.archive(ctx, index, id); err != nil {
+		return err
+	}
+	return h.ESOper.Upsert(ctx, index, id, obj, opts...)
+}
+
+type docVersionSearchResponseBody struct {
+	Hits struct {
+		Hits []struct {
+			Source *DocVersion `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+func (h *historyESOper) History(ctx context.Context, index string, id string, size int) ([]*DocVersion, error) {
+	b, err := json.Marshal(map[string]interface{}{
+		"size": size,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []interface{}{
+					map[string]interface{}{"term": map[string]interface{}{"doc_id": id}},
+					map[string]interface{}{"term": map[string]interface{}{"index": index}},
+				},
+			},
+		},
+		"sort": []interface{}{
+			map[string]interface{}{"version": "desc"},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := &docVersionSearchResponseBody{}
+	if _, err := h.ESOper.Search(ctx, resp, string(b), []string{h.historyIndex(index)}); err != nil {
+		return nil, err
+	}
+	versions := make([]*DocVersion, 0, len(resp.Hits.Hits))
+	for _, hit := range resp.Hits.Hits {
+		versions = append(versions, hit.Source)
+	}
+	return versions, nil
+}
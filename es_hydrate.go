@@ -0,0 +1,77 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+)
+
+// EntityLoader - loads the entities of the ids from the source of truth, e.g. the database or the cache,
+// the entities not found are absent in the map returned.
+type EntityLoader func(ctx context.Context, ids []string) (map[string]interface{}, error)
+
+// HydrateResult - the entities of the hits of SearchHydrate in the order of the hits.
+type HydrateResult struct {
+	Entities []interface{}
+	// Hits are the hits of the entities without their sources, e.g. their scores and sort values for the next page.
+	Hits []*RawHit
+	// Missing are the ids of the hits whose entities are not found, e.g. the index lags behind the deletions.
+	Missing []string
+}
+
+// WithoutSource - the search returns the metadata of the hits like the ids, the scores and the sort values only.
+func WithoutSource() func(*SearchRequest) {
+	return func(r *SearchRequest) {
+		r.Source = []string{"false"}
+		r.SourceIncludes, r.SourceExcludes = nil, nil
+	}
+}
+
+// SearchHydrate searches the ids of the hits of the query without their sources and then loads the entities of them
+// by the loader, for the indices keeping the _source slim. The opts can't enable the source, it's disabled after them.
+func SearchHydrate(ctx context.Context, oper Searcher, query string, indexes []string, loader EntityLoader, opts ...func(*SearchRequest)) (*HydrateResult, error) {
+	o := append(append([]func(*SearchRequest){}, opts...), WithoutSource())
+	hits, err := SearchRaw(ctx, oper, query, indexes, o...)
+	if err != nil {
+		return nil, err
+	}
+	result := &HydrateResult{}
+	if len(hits) == 0 {
+		return result, nil
+	}
+	ids := make([]string, 0, len(hits))
+	seen := make(map[string]bool, len(hits))
+	for _, hit := range hits {
+		// the hits of the different indices may share the ids
+		if !seen[hit.ID] {
+			seen[hit.ID] = true
+			ids = append(ids, hit.ID)
+		}
+	}
+	entities, err := loader(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, hit := range hits {
+		entity, ok := entities[hit.ID]
+		if !ok {
+			result.Missing = append(result.Missing, hit.ID)
+			continue
+		}
+		result.Entities = append(result.Entities, entity)
+		result.Hits = append(result.Hits, hit)
+	}
+	return result, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// DocComparator reports whether the existing source of the document matches the obj to be written.
+type DocComparator func(existing json.RawMessage, obj interface{}) (bool, error)
+
+// JSONEqual - the comparator reporting whether the existing source and the obj are equal as JSON values.
+func JSONEqual(existing json.RawMessage, obj interface{}) (bool, error) {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return false, err
+	}
+	var want, got interface{}
+	if err := json.Unmarshal(b, &want); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(existing, &got); err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(want, got), nil
+}
+
+// CreateIdempotent creates the document, the 409 Conflict is treated as a success if the existing document
+// matches the obj by the comparator, JSONEqual is used if the comparator is nil.
+// It makes the at-least-once pipelines safe to retry the creation.
+func CreateIdempotent(ctx context.Context, oper ESOper, index string, id string, obj interface{}, equal DocComparator, opts ...func(*CreateRequest)) error {
+	err := oper.Create(ctx, index, id, obj, opts...)
+	if !IsConflict(err) {
+		return err
+	}
+	return resolveConflict(ctx, oper, index, id, obj, equal, err)
+}
+
+// IndexIdempotent indexes the document with the op_type create, the 409 Conflict is treated as a success
+// if the existing document matches the obj by the comparator, JSONEqual is used if the comparator is nil.
+func IndexIdempotent(ctx context.Context, oper ESOper, index string, id string, obj interface{}, equal DocComparator, opts ...func(*IndexRequest)) error {
+	o := append([]func(*IndexRequest){withIndexOpType("create")}, opts...)
+	err := oper.Index(ctx, index, id, obj, o...)
+	if !IsConflict(err) {
+		return err
+	}
+	return resolveConflict(ctx, oper, index, id, obj, equal, err)
+}
+
+func withIndexOpType(opType string) func(*IndexRequest) {
+	return func(r *IndexRequest) {
+		r.OpType = opType
+	}
+}
+
+func resolveConflict(ctx context.Context, oper ESOper, index string, id string, obj interface{}, equal DocComparator, conflict error) error {
+	if equal == nil {
+		equal = JSONEqual
+	}
+	doc := &getSourceResponseBody{}
+	if _, err := oper.Get(ctx, doc, index, id); err != nil {
+		return fmt.Errorf("nes: fail to get the conflicting document %s/%s: %w", index, id, err)
+	}
+	matched, err := equal(doc.Source, obj)
+	if err != nil {
+		return err
+	}
+	if !matched {
+		return conflict
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxIDBytes - the maximum length of the document ids in bytes accepted by Elasticsearch.
+const MaxIDBytes = 512
+
+// DefaultIDSeparator - the separator of the parts of the composite ids.
+const DefaultIDSeparator = ":"
+
+// ErrInvalidID - the id is empty or longer than MaxIDBytes, the error is wrapped with the details.
+var ErrInvalidID = errors.New("nes: the document id is invalid")
+
+// ValidateID checks the id is not empty and not longer than MaxIDBytes.
+func ValidateID(id string) error {
+	if id == "" {
+		return fmt.Errorf("%w: the id is empty", ErrInvalidID)
+	}
+	if len(id) > MaxIDBytes {
+		return fmt.Errorf("%w: the id is %d bytes, longer than %d", ErrInvalidID, len(id), MaxIDBytes)
+	}
+	return nil
+}
+
+// NewUUIDv7 returns a random UUID of the version 7 ordered by the creation time in milliseconds, so the ids of
+// the documents written together are close in the index, which is friendlier to the indexing than the random UUIDs.
+func NewUUIDv7() string {
+	var u [16]byte
+	if _, err := rand.Read(u[6:]); err != nil {
+		panic(err)
+	}
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(time.Now().UnixMilli()))
+	copy(u[:6], ms[2:])
+	u[6] = u[6]&0x0f | 0x70
+	u[8] = u[8]&0x3f | 0x80
+	b := make([]byte, 36)
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return string(b)
+}
+
+// ContentHashID returns the hex SHA-256 of the values of the fields of the obj, so the same content always gets
+// the same id, e.g. to deduplicate the documents. The fields are the JSON paths like user.name, the missing ones
+// are hashed as null, the obj is encoded by encoding/json.
+func ContentHashID(obj interface{}, fields ...string) (string, error) {
+	if len(fields) == 0 {
+		return "", errors.New("nes: the fields of the content hash id are required")
+	}
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return "", err
+	}
+	values := make([]interface{}, 0, len(fields))
+	for _, f := range fields {
+		values = append(values, lookupPath(doc, f))
+	}
+	// the maps of the values are encoded with the sorted keys, the hash is stable
+	b, err = json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func lookupPath(doc map[string]interface{}, path string) interface{} {
+	var v interface{} = doc
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+// CompositeID joins the parts by the DefaultIDSeparator, e.g. tenant:order:line, see CompositeIDWith.
+func CompositeID(parts ...string) (string, error) {
+	return CompositeIDWith(DefaultIDSeparator, parts...)
+}
+
+// CompositeIDWith joins the parts by the separator, the parts must be non-empty and free of the separator,
+// so the id can be split back by SplitCompositeID, and the id must be valid by ValidateID.
+func CompositeIDWith(separator string, parts ...string) (string, error) {
+	if separator == "" {
+		return "", errors.New("nes: the separator of the composite id is empty")
+	}
+	for i, p := range parts {
+		if p == "" {
+			return "", fmt.Errorf("%w: the part %d of the composite id is empty", ErrInvalidID, i)
+		}
+		if strings.Contains(p, separator) {
+			return "", fmt.Errorf("%w: the part %q of the composite id contains the separator %q", ErrInvalidID, p, separator)
+		}
+	}
+	id := strings.Join(parts, separator)
+	if err := ValidateID(id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// SplitCompositeID splits the composite id into the n parts joined by the separator.
+func SplitCompositeID(id string, separator string, n int) ([]string, error) {
+	parts := strings.Split(id, separator)
+	if len(parts) != n {
+		return nil, fmt.Errorf("%w: the composite id %q has %d parts, expected %d", ErrInvalidID, id, len(parts), n)
+	}
+	return parts, nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LifecycleExplain - the lifecycle state of an index, only the Index and Managed are set if it's not managed by ILM.
+type LifecycleExplain struct {
+	Index         string
+	Managed       bool
+	Policy        string
+	Phase         string
+	Action        string
+	Step          string
+	FailedStep    string
+	LifecycleDate time.Time
+	PhaseTime     time.Time
+	StepInfo      map[string]interface{}
+}
+
+type lifecycleExplainBody struct {
+	Index               string                 `json:"index"`
+	Managed             bool                   `json:"managed"`
+	Policy              string                 `json:"policy"`
+	Phase               string                 `json:"phase"`
+	Action              string                 `json:"action"`
+	Step                string                 `json:"step"`
+	FailedStep          string                 `json:"failed_step"`
+	LifecycleDateMillis int64                  `json:"lifecycle_date_millis"`
+	PhaseTimeMillis     int64                  `json:"phase_time_millis"`
+	StepInfo            map[string]interface{} `json:"step_info"`
+}
+
+func millisTime(millis int64) time.Time {
+	if millis == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(millis)
+}
+
+func (e *esAdminOper) ExplainLifecycle(ctx context.Context, indexes []string, opts ...func(*ILMExplainLifecycleRequest)) (map[string]*LifecycleExplain, error) {
+	api := e.client
+	o := append([]func(*ILMExplainLifecycleRequest){api.ILM.ExplainLifecycle.WithContext(ctx)}, opts...)
+	resp, err := api.ILM.ExplainLifecycle(strings.Join(indexes, ","), o...)
+	if err != nil {
+		return nil, err
+	}
+	var respBody struct {
+		Indices map[string]*lifecycleExplainBody `json:"indices"`
+	}
+	if err := unmarshallResponse(resp, &respBody); err != nil {
+		return nil, err
+	}
+	explains := make(map[string]*LifecycleExplain, len(respBody.Indices))
+	for name, b := range respBody.Indices {
+		if b == nil {
+			continue
+		}
+		explains[name] = &LifecycleExplain{
+			Index:         name,
+			Managed:       b.Managed,
+			Policy:        b.Policy,
+			Phase:         b.Phase,
+			Action:        b.Action,
+			Step:          b.Step,
+			FailedStep:    b.FailedStep,
+			LifecycleDate: millisTime(b.LifecycleDateMillis),
+			PhaseTime:     millisTime(b.PhaseTimeMillis),
+			StepInfo:      b.StepInfo,
+		}
+	}
+	return explains, nil
+}
+
+// IndexAge - the age of an index reported by IndexAges, the lifecycle fields are empty if it's not managed by ILM.
+type IndexAge struct {
+	Index        string
+	CreationDate time.Time
+	// Age is the time since the creation of the index.
+	Age     time.Duration
+	Managed bool
+	Policy  string
+	Phase   string
+	// LifecycleDate is the date the phases of the policy are timed from, the rollover date if the index is rolled over.
+	LifecycleDate time.Time
+	// LifecycleAge is the time since the LifecycleDate, which the min_age of the phases is compared with.
+	LifecycleAge time.Duration
+	// PhaseAge is the time since the index entered the current phase.
+	PhaseAge time.Duration
+	// FailedStep is the step of ILM failed on the index, the index is stuck until the step is retried.
+	FailedStep string
+}
+
+// IndexAges reports the ages of the indices matching the pattern, e.g. logs-*, ordered by their names, combining
+// the creation dates of their settings and the lifecycle states explained by ILM for the retention audits.
+func IndexAges(ctx context.Context, oper ESAdminOper, pattern string) ([]*IndexAge, error) {
+	api := oper.ESClient()
+	resp, err := api.Indices.GetSettings(
+		api.Indices.GetSettings.WithContext(ctx),
+		api.Indices.GetSettings.WithIndex(pattern),
+		api.Indices.GetSettings.WithName("index.creation_date"),
+		api.Indices.GetSettings.WithFlatSettings(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var settings map[string]struct {
+		Settings map[string]string `json:"settings"`
+	}
+	if err := unmarshallResponse(resp, &settings); err != nil {
+		return nil, err
+	}
+	if len(settings) == 0 {
+		return nil, nil
+	}
+	explains, err := oper.ExplainLifecycle(ctx, []string{pattern})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	ages := make([]*IndexAge, 0, len(settings))
+	for name, s := range settings {
+		age := &IndexAge{Index: name}
+		if millis, err := strconv.ParseInt(s.Settings["index.creation_date"], 10, 64); err == nil {
+			age.CreationDate = time.UnixMilli(millis)
+			age.Age = now.Sub(age.CreationDate)
+		}
+		if explain, ok := explains[name]; ok && explain.Managed {
+			age.Managed = true
+			age.Policy = explain.Policy
+			age.Phase = explain.Phase
+			age.FailedStep = explain.FailedStep
+			age.LifecycleDate = explain.LifecycleDate
+			if !explain.LifecycleDate.IsZero() {
+				age.LifecycleAge = now.Sub(explain.LifecycleDate)
+			}
+			if !explain.PhaseTime.IsZero() {
+				age.PhaseAge = now.Sub(explain.PhaseTime)
+			}
+		}
+		ages = append(ages, age)
+	}
+	sort.Slice(ages, func(i, j int) bool {
+		return ages[i].Index < ages[j].Index
+	})
+	return ages, nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+// IndexDefaults - the default options of the writes to an index, the options of the call sites take precedence.
+type IndexDefaults struct {
+	// Refresh is one of true, false and wait_for.
+	Refresh string
+	// Pipeline is the ingest pipeline of Create, Index and Bulk.
+	Pipeline string
+	// WaitForActiveShards is the number of the active shard copies like 2 or all.
+	WaitForActiveShards string
+	// Routing extracts the routing of the document, the obj is nil for Update and Delete.
+	Routing func(id string, obj interface{}) string
+}
+
+// WithIndexDefaults - the default options of the writes to the index.
+func WithIndexDefaults(index string, defaults *IndexDefaults) ESOperOption {
+	return func(e *esOper) {
+		if e.indexDefaults == nil {
+			e.indexDefaults = map[string]*IndexDefaults{}
+		}
+		e.indexDefaults[index] = defaults
+	}
+}
+
+func (d *IndexDefaults) routing(id string, obj interface{}) string {
+	if d.Routing == nil {
+		return ""
+	}
+	return d.Routing(id, obj)
+}
+
+// defaultsOf returns the defaults of the writes to the index, the default refresh of the oper fills in the missing refresh.
+func (e *esOper) defaultsOf(index string) (*IndexDefaults, bool) {
+	d, ok := e.indexDefaults[index]
+	if e.defaultRefresh == "" || (ok && d.Refresh != "") {
+		return d, ok
+	}
+	merged := &IndexDefaults{}
+	if ok {
+		*merged = *d
+	}
+	merged.Refresh = e.defaultRefresh
+	return merged, true
+}
+
+func setDefault(dest *string, v string) {
+	if v != "" {
+		*dest = v
+	}
+}
+
+func (e *esOper) createDefaults(index string, id string, obj interface{}) []func(*CreateRequest) {
+	d, ok := e.defaultsOf(index)
+	if !ok {
+		return nil
+	}
+	return []func(*CreateRequest){func(r *CreateRequest) {
+		setDefault(&r.Refresh, d.Refresh)
+		setDefault(&r.Pipeline, d.Pipeline)
+		setDefault(&r.WaitForActiveShards, d.WaitForActiveShards)
+		setDefault(&r.Routing, d.routing(id, obj))
+	}}
+}
+
+func (e *esOper) indexRequestDefaults(index string, id string, obj interface{}) []func(*IndexRequest) {
+	d, ok := e.defaultsOf(index)
+	if !ok {
+		return nil
+	}
+	return []func(*IndexRequest){func(r *IndexRequest) {
+		setDefault(&r.Refresh, d.Refresh)
+		setDefault(&r.Pipeline, d.Pipeline)
+		setDefault(&r.WaitForActiveShards, d.WaitForActiveShards)
+		setDefault(&r.Routing, d.routing(id, obj))
+	}}
+}
+
+func (e *esOper) updateDefaults(index string, id string) []func(*UpdateRequest) {
+	d, ok := e.defaultsOf(index)
+	if !ok {
+		return nil
+	}
+	return []func(*UpdateRequest){func(r *UpdateRequest) {
+		setDefault(&r.Refresh, d.Refresh)
+		setDefault(&r.WaitForActiveShards, d.WaitForActiveShards)
+		setDefault(&r.Routing, d.routing(id, nil))
+	}}
+}
+
+func (e *esOper) deleteDefaults(index string, id string) []func(*DeleteRequest) {
+	d, ok := e.defaultsOf(index)
+	if !ok {
+		return nil
+	}
+	return []func(*DeleteRequest){func(r *DeleteRequest) {
+		setDefault(&r.Refresh, d.Refresh)
+		setDefault(&r.WaitForActiveShards, d.WaitForActiveShards)
+		setDefault(&r.Routing, d.routing(id, nil))
+	}}
+}
+
+func (e *esOper) bulkDefaults(index string) []func(*BulkRequest) {
+	d, ok := e.defaultsOf(index)
+	if !ok {
+		return nil
+	}
+	return []func(*BulkRequest){func(r *BulkRequest) {
+		setDefault(&r.Refresh, d.Refresh)
+		setDefault(&r.Pipeline, d.Pipeline)
+		setDefault(&r.WaitForActiveShards, d.WaitForActiveShards)
+	}}
+}
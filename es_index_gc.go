@@ -0,0 +1,246 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/nf-go/nfgo/nlog"
+	"go.uber.org/multierr"
+)
+
+// the reasons of the indices collected by IndexGC
+const (
+	// GCReasonEmpty - the index has no documents.
+	GCReasonEmpty = "empty"
+	// GCReasonUnused - the index is neither searched nor indexed since its shards were started, which is only judged
+	// when all the nodes are up for the MinAge, since the counters are reset when the shards are restarted or
+	// relocated.
+	GCReasonUnused = "unused"
+	// GCReasonOrphaned - the index has no alias, the time-partitioned indices are usually not aliased, exclude them.
+	GCReasonOrphaned = "orphaned"
+)
+
+// IndexGCConfig - the indices collected by IndexGC, an index is collected if it matches any of the Patterns,
+// none of the Excludes, is older than the MinAge and has all the enabled reasons, none is collected if no reason is
+// enabled. The indices are only reported unless Delete is set, deleting them is not reversible, so check the report
+// of a run without it first.
+type IndexGCConfig struct {
+	Oper     ESAdminOper
+	Patterns []string
+	// Excludes are the patterns of the indices never collected like the path.Match ones, the hidden indices starting
+	// with a dot are never collected.
+	Excludes []string
+	// MinAge is the age of the indices the younger ones are never collected, 24 hours by default.
+	MinAge time.Duration
+	// Empty, Unused and Orphaned enable the reasons of the collection.
+	Empty    bool
+	Unused   bool
+	Orphaned bool
+	// Delete deletes the indices collected, they're only reported and logged by default.
+	Delete bool
+}
+
+// IndexGCCandidate - an index collected with its reasons.
+type IndexGCCandidate struct {
+	Index   *IndexInfo
+	Reasons []string
+	// Searches and Writes are the numbers of the queries and the indexing operations since the shards were started.
+	Searches int64
+	Writes   int64
+}
+
+func (c *IndexGCCandidate) String() string {
+	return fmt.Sprintf("%s (%s, docs %d, searches %d, writes %d, created at %s)", c.Index.Name, strings.Join(c.Reasons, ", "),
+		c.Index.DocsCount, c.Searches, c.Writes, c.Index.CreationDate.Format(time.RFC3339))
+}
+
+// IndexGCReport - the result of IndexGC.
+type IndexGCReport struct {
+	Candidates []*IndexGCCandidate
+	// Deleted are the names of the candidates deleted, it's empty unless Delete is set.
+	Deleted []string
+}
+
+type indexActivityStats struct {
+	Total struct {
+		Search struct {
+			QueryTotal int64 `json:"query_total"`
+		} `json:"search"`
+		Indexing struct {
+			IndexTotal int64 `json:"index_total"`
+		} `json:"indexing"`
+	} `json:"total"`
+}
+
+// IndexGC collects the indices having all the enabled reasons and deletes them if Delete is set, the report is
+// returned even if some of the deletions fail. Run it without Delete first and check the report.
+func IndexGC(ctx context.Context, config *IndexGCConfig) (*IndexGCReport, error) {
+	minAge := config.MinAge
+	if minAge <= 0 {
+		minAge = 24 * time.Hour
+	}
+	report := &IndexGCReport{}
+	var errs error
+	for _, pattern := range config.Patterns {
+		candidates, err := indexGCCandidates(ctx, config, pattern, minAge)
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		for _, c := range candidates {
+			report.Candidates = append(report.Candidates, c)
+			if !config.Delete {
+				nlog.Logger(ctx).Infof("nes index gc: dry run: delete %s", c)
+				continue
+			}
+			if err := config.Oper.DeleteIndex(ctx, []string{c.Index.Name}); err != nil {
+				errs = multierr.Append(errs, fmt.Errorf("nes index gc: fail to delete %s: %w", c.Index.Name, err))
+				continue
+			}
+			report.Deleted = append(report.Deleted, c.Index.Name)
+			nlog.Logger(ctx).Infof("nes index gc: delete %s", c)
+		}
+	}
+	return report, errs
+}
+
+func indexGCCandidates(ctx context.Context, config *IndexGCConfig, pattern string, minAge time.Duration) ([]*IndexGCCandidate, error) {
+	if !config.Empty && !config.Unused && !config.Orphaned {
+		return nil, nil
+	}
+	indices, err := ListIndices(ctx, config.Oper, pattern)
+	if err != nil {
+		return nil, err
+	}
+	var stats map[string]*indexActivityStats
+	if config.Unused {
+		uptime, err := clusterUptime(ctx, config.Oper)
+		if err != nil {
+			return nil, err
+		}
+		// the counters since a recent restart don't tell the indices are unused
+		if uptime < minAge {
+			nlog.Logger(ctx).Infof("nes index gc: the cluster is up for %s only, no index is unused", uptime)
+			return nil, nil
+		}
+		if stats, err = indexActivity(ctx, config.Oper, pattern); err != nil {
+			return nil, err
+		}
+	}
+	var aliases map[string]*indexAliasesResponseBody
+	if config.Orphaned {
+		if aliases, err = indexAliases(ctx, config.Oper, pattern); err != nil {
+			return nil, err
+		}
+	}
+	bornBefore := time.Now().Add(-minAge)
+	var candidates []*IndexGCCandidate
+	for _, index := range indices {
+		if strings.HasPrefix(index.Name, ".") || index.CreationDate.IsZero() || index.CreationDate.After(bornBefore) || excluded(index.Name, config.Excludes) {
+			continue
+		}
+		c := &IndexGCCandidate{Index: index}
+		if config.Empty {
+			// the docs count of a closed index is unknown
+			if index.Status != "open" || index.DocsCount != 0 {
+				continue
+			}
+			c.Reasons = append(c.Reasons, GCReasonEmpty)
+		}
+		if config.Unused {
+			s, ok := stats[index.Name]
+			if !ok || s == nil {
+				continue
+			}
+			c.Searches, c.Writes = s.Total.Search.QueryTotal, s.Total.Indexing.IndexTotal
+			if c.Searches != 0 || c.Writes != 0 {
+				continue
+			}
+			c.Reasons = append(c.Reasons, GCReasonUnused)
+		}
+		if config.Orphaned {
+			if a, ok := aliases[index.Name]; !ok || (a != nil && len(a.Aliases) > 0) {
+				continue
+			}
+			c.Reasons = append(c.Reasons, GCReasonOrphaned)
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, nil
+}
+
+// clusterUptime returns the uptime of the node started last.
+func clusterUptime(ctx context.Context, oper ESAdminOper) (time.Duration, error) {
+	nodes, err := oper.NodesStats(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(nodes) == 0 {
+		return 0, nil
+	}
+	uptime := time.Duration(nodes[0].JVM.UptimeMillis) * time.Millisecond
+	for _, n := range nodes[1:] {
+		if d := time.Duration(n.JVM.UptimeMillis) * time.Millisecond; d < uptime {
+			uptime = d
+		}
+	}
+	return uptime, nil
+}
+
+func excluded(index string, excludes []string) bool {
+	for _, pattern := range excludes {
+		if ok, _ := path.Match(pattern, index); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func indexActivity(ctx context.Context, oper ESAdminOper, pattern string) (map[string]*indexActivityStats, error) {
+	api := oper.ESClient()
+	resp, err := api.Indices.Stats(
+		api.Indices.Stats.WithContext(ctx),
+		api.Indices.Stats.WithIndex(pattern),
+		api.Indices.Stats.WithMetric("search", "indexing"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var respBody struct {
+		Indices map[string]*indexActivityStats `json:"indices"`
+	}
+	if err := unmarshallResponse(resp, &respBody); err != nil {
+		return nil, err
+	}
+	return respBody.Indices, nil
+}
+
+func indexAliases(ctx context.Context, oper ESAdminOper, pattern string) (map[string]*indexAliasesResponseBody, error) {
+	api := oper.ESClient()
+	resp, err := api.Indices.GetAlias(api.Indices.GetAlias.WithContext(ctx), api.Indices.GetAlias.WithIndex(pattern))
+	if err != nil {
+		return nil, err
+	}
+	respBody := map[string]*indexAliasesResponseBody{}
+	if err := unmarshallResponse(resp, &respBody); err != nil {
+		return nil, err
+	}
+	return respBody, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newGCRoutes(uptime time.Duration) map[string]stubResponse {
+	created := time.Now().Add(-48 * time.Hour).UnixMilli()
+	return map[string]stubResponse{
+		"GET /_cat/indices/logs-*": {http.StatusOK, fmt.Sprintf(`[
+			{"index":"logs-a","health":"green","status":"open","pri":"1","docs.count":"0","store.size":"225","creation.date":"%d"},
+			{"index":"logs-b","health":"green","status":"open","pri":"1","docs.count":"0","store.size":"225","creation.date":"%d"},
+			{"index":"logs-c","health":"green","status":"open","pri":"1","docs.count":"7","store.size":"4096","creation.date":"%d"}
+		]`, created, created, created)},
+		"GET /logs-*/_stats/search,indexing": {http.StatusOK, `{"indices":{
+			"logs-a":{"total":{"search":{"query_total":0},"indexing":{"index_total":0}}},
+			"logs-b":{"total":{"search":{"query_total":3},"indexing":{"index_total":0}}},
+			"logs-c":{"total":{"search":{"query_total":0},"indexing":{"index_total":0}}}
+		}}`},
+		"GET /logs-*/_alias": {http.StatusOK, `{"logs-a":{"aliases":{}},"logs-b":{"aliases":{}},"logs-c":{"aliases":{}}}`},
+		"GET /_nodes/stats/jvm,os,thread_pool,breaker": {http.StatusOK, fmt.Sprintf(`{"nodes":{
+			"n1":{"name":"n1","jvm":{"uptime_in_millis":%d}},
+			"n2":{"name":"n2","jvm":{"uptime_in_millis":%d}}
+		}}`, (72 * time.Hour).Milliseconds(), uptime.Milliseconds())},
+		"DELETE /logs-a": {http.StatusOK, `{"acknowledged":true}`},
+	}
+}
+
+func newGCOper(t *testing.T, routes map[string]stubResponse) (ESAdminOper, *routeTransport) {
+	t.Helper()
+	stub := &routeTransport{routes: routes}
+	client, err := NewESClient(&ESConfig{Addrs: []string{"http://nes.invalid:9200"}, Version: "8.13.1"}, WithBaseTransport(stub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewESAdminOper(client), stub
+}
+
+func candidateNames(report *IndexGCReport) []string {
+	var names []string
+	for _, c := range report.Candidates {
+		names = append(names, c.Index.Name)
+	}
+	return names
+}
+
+func deletes(stub *routeTransport) []string {
+	var deleted []string
+	for _, r := range stub.sent() {
+		if strings.HasPrefix(r, "DELETE ") {
+			deleted = append(deleted, r)
+		}
+	}
+	return deleted
+}
+
+func TestIndexGCRequiresAllTheReasons(t *testing.T) {
+	oper, stub := newGCOper(t, newGCRoutes(72*time.Hour))
+	report, err := IndexGC(context.Background(), &IndexGCConfig{
+		Oper: oper, Patterns: []string{"logs-*"}, Empty: true, Unused: true, Orphaned: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// logs-b is searched and logs-c is not empty
+	if names := candidateNames(report); len(names) != 1 || names[0] != "logs-a" {
+		t.Fatalf("candidates %v, want [logs-a]", names)
+	}
+	if reasons := report.Candidates[0].Reasons; len(reasons) != 3 {
+		t.Errorf("reasons %v, want all the three", reasons)
+	}
+	if d := deletes(stub); len(d) != 0 || len(report.Deleted) != 0 {
+		t.Errorf("deleted %v without Delete", d)
+	}
+}
+
+func TestIndexGCDeletesOnlyIfDeleteIsSet(t *testing.T) {
+	oper, stub := newGCOper(t, newGCRoutes(72*time.Hour))
+	report, err := IndexGC(context.Background(), &IndexGCConfig{
+		Oper: oper, Patterns: []string{"logs-*"}, Empty: true, Unused: true, Delete: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := deletes(stub); len(d) != 1 || d[0] != "DELETE /logs-a" {
+		t.Errorf("deletes %v, want [DELETE /logs-a]", d)
+	}
+	if len(report.Deleted) != 1 || report.Deleted[0] != "logs-a" {
+		t.Errorf("deleted %v, want [logs-a]", report.Deleted)
+	}
+}
+
+func TestIndexGCDoesNotTrustTheCountersAfterARestart(t *testing.T) {
+	oper, stub := newGCOper(t, newGCRoutes(time.Hour))
+	report, err := IndexGC(context.Background(), &IndexGCConfig{
+		Oper: oper, Patterns: []string{"logs-*"}, Unused: true, Delete: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Candidates) != 0 || len(deletes(stub)) != 0 {
+		t.Errorf("candidates %v after a node restarted an hour ago", candidateNames(report))
+	}
+}
+
+func TestIndexGCCollectsNothingWithoutReasons(t *testing.T) {
+	oper, stub := newGCOper(t, newGCRoutes(72*time.Hour))
+	report, err := IndexGC(context.Background(), &IndexGCConfig{Oper: oper, Patterns: []string{"logs-*"}, Delete: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Candidates) != 0 || len(stub.sent()) != 0 {
+		t.Errorf("candidates %v, requests %v without reasons", candidateNames(report), stub.sent())
+	}
+}
+
+func TestIndexGCFailsOnTheStatsErrors(t *testing.T) {
+	routes := newGCRoutes(72 * time.Hour)
+	routes["GET /logs-*/_stats/search,indexing"] = stubResponse{http.StatusInternalServerError, `{"error":"boom"}`}
+	oper, stub := newGCOper(t, routes)
+	report, err := IndexGC(context.Background(), &IndexGCConfig{
+		Oper: oper, Patterns: []string{"logs-*"}, Empty: true, Unused: true, Delete: true,
+	})
+	if err == nil {
+		t.Fatal("no error on the failed stats")
+	}
+	if len(report.Candidates) != 0 || len(deletes(stub)) != 0 {
+		t.Errorf("candidates %v with the failed stats", candidateNames(report))
+	}
+}
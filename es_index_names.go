@@ -0,0 +1,161 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	dailyIndexLayout   = "2006.01.02"
+	monthlyIndexLayout = "2006.01"
+)
+
+// DailyIndex - the name of the daily index of the time in UTC, e.g. logs-2020.01.02.
+func DailyIndex(prefix string, t time.Time) string {
+	return prefix + "-" + t.UTC().Format(dailyIndexLayout)
+}
+
+// MonthlyIndex - the name of the monthly index of the time in UTC, e.g. logs-2020.01.
+func MonthlyIndex(prefix string, t time.Time) string {
+	return prefix + "-" + t.UTC().Format(monthlyIndexLayout)
+}
+
+// DailyIndices - the names of the daily indices from the day of the from to the day of the to, both inclusive.
+func DailyIndices(prefix string, from time.Time, to time.Time) []string {
+	var indices []string
+	from, to = from.UTC(), to.UTC()
+	for d := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC); !d.After(to); d = d.AddDate(0, 0, 1) {
+		indices = append(indices, DailyIndex(prefix, d))
+	}
+	return indices
+}
+
+// MonthlyIndices - the names of the monthly indices from the month of the from to the month of the to, both inclusive.
+func MonthlyIndices(prefix string, from time.Time, to time.Time) []string {
+	var indices []string
+	from, to = from.UTC(), to.UTC()
+	for m := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC); !m.After(to); m = m.AddDate(0, 1, 0) {
+		indices = append(indices, MonthlyIndex(prefix, m))
+	}
+	return indices
+}
+
+// PartitionPeriod - the period of the time-partitioned indices.
+type PartitionPeriod string
+
+const (
+	// PartitionDaily - the daily indices like logs-2020.01.02.
+	PartitionDaily PartitionPeriod = "daily"
+	// PartitionMonthly - the monthly indices like logs-2020.01.
+	PartitionMonthly PartitionPeriod = "monthly"
+)
+
+// TimePartition - the time-partitioned indices sharing the prefix.
+type TimePartition struct {
+	Prefix string
+	Period PartitionPeriod
+	// Timestamp extracts the time of the document deciding the index it's written to, the current time is used if nil.
+	Timestamp func(obj interface{}) time.Time
+}
+
+// Index - the index of the time.
+func (p *TimePartition) Index(t time.Time) string {
+	if p.Period == PartitionMonthly {
+		return MonthlyIndex(p.Prefix, t)
+	}
+	return DailyIndex(p.Prefix, t)
+}
+
+// Indices - the indices covering the time range, for the reads of the range.
+func (p *TimePartition) Indices(from time.Time, to time.Time) []string {
+	if p.Period == PartitionMonthly {
+		return MonthlyIndices(p.Prefix, from, to)
+	}
+	return DailyIndices(p.Prefix, from, to)
+}
+
+// Pattern - the wildcard pattern of all the indices, e.g. logs-*.
+func (p *TimePartition) Pattern() string {
+	return p.Prefix + "-*"
+}
+
+func (p *TimePartition) writeIndex(obj interface{}) string {
+	if p.Timestamp == nil {
+		return p.Index(time.Now())
+	}
+	return p.Index(p.Timestamp(obj))
+}
+
+// WithTimePartition - Create and Index write the documents of the index named as the prefix of the partition to the
+// index of their timestamps, the index defaults of the prefix are applied on them.
+func WithTimePartition(p *TimePartition) ESOperOption {
+	return func(e *esOper) {
+		if e.timePartitions == nil {
+			e.timePartitions = map[string]*TimePartition{}
+		}
+		e.timePartitions[p.Prefix] = p
+	}
+}
+
+func (e *esOper) partitionIndex(index string, obj interface{}) string {
+	if p, ok := e.timePartitions[index]; ok {
+		return p.writeIndex(obj)
+	}
+	return index
+}
+
+type aliasesResponseBody struct {
+	Aliases map[string]*struct {
+		IsWriteIndex *bool `json:"is_write_index"`
+	} `json:"aliases"`
+}
+
+// ResolveWriteIndex resolves the write index of the alias, e.g. the current index of a rollover alias.
+func ResolveWriteIndex(ctx context.Context, oper ESOper, alias string) (string, error) {
+	api := oper.ESClient()
+	resp, err := api.Indices.GetAlias(api.Indices.GetAlias.WithContext(ctx), api.Indices.GetAlias.WithName(alias))
+	if err != nil {
+		return "", err
+	}
+	respBody := map[string]*aliasesResponseBody{}
+	if err := unmarshallResponse(resp, &respBody); err != nil {
+		return "", err
+	}
+	var candidates []string
+	for index, body := range respBody {
+		if body == nil {
+			continue
+		}
+		params, ok := body.Aliases[alias]
+		if !ok {
+			continue
+		}
+		if params != nil && params.IsWriteIndex != nil {
+			if *params.IsWriteIndex {
+				return index, nil
+			}
+			continue
+		}
+		candidates = append(candidates, index)
+	}
+	// the only index of the alias is the write index if is_write_index is not set
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+	return "", fmt.Errorf("nes: the alias %s has no write index", alias)
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+// CreateIndexBody - the body of the create index request.
+type CreateIndexBody struct {
+	Settings *IndexSettings         `json:"settings,omitempty"`
+	Mappings interface{}            `json:"mappings,omitempty"`
+	Aliases  map[string]interface{} `json:"aliases,omitempty"`
+}
+
+// IndexSettings - the index settings.
+type IndexSettings struct {
+	NumberOfShards   *int      `json:"number_of_shards,omitempty"`
+	NumberOfReplicas *int      `json:"number_of_replicas,omitempty"`
+	MaxResultWindow  *int      `json:"max_result_window,omitempty"`
+	MaxNgramDiff     *int      `json:"max_ngram_diff,omitempty"`
+	Analysis         *Analysis `json:"analysis,omitempty"`
+}
+
+// Analysis - the analysis settings of an index.
+type Analysis struct {
+	Analyzer   map[string]interface{} `json:"analyzer,omitempty"`
+	Tokenizer  map[string]interface{} `json:"tokenizer,omitempty"`
+	Filter     map[string]interface{} `json:"filter,omitempty"`
+	CharFilter map[string]interface{} `json:"char_filter,omitempty"`
+	Normalizer map[string]interface{} `json:"normalizer,omitempty"`
+}
+
+// AnalysisPreset - a reusable piece of the analysis settings.
+type AnalysisPreset func(s *IndexSettings)
+
+// NewIndexSettings - the index settings with the shards, the replicas and the analysis presets.
+func NewIndexSettings(shards int, replicas int, presets ...AnalysisPreset) *IndexSettings {
+	s := &IndexSettings{
+		NumberOfShards:   &shards,
+		NumberOfReplicas: &replicas,
+	}
+	for _, preset := range presets {
+		preset(s)
+	}
+	return s
+}
+
+func (s *IndexSettings) analysis() *Analysis {
+	if s.Analysis == nil {
+		s.Analysis = &Analysis{}
+	}
+	a := s.Analysis
+	if a.Analyzer == nil {
+		a.Analyzer = map[string]interface{}{}
+	}
+	if a.Tokenizer == nil {
+		a.Tokenizer = map[string]interface{}{}
+	}
+	if a.Filter == nil {
+		a.Filter = map[string]interface{}{}
+	}
+	if a.CharFilter == nil {
+		a.CharFilter = map[string]interface{}{}
+	}
+	if a.Normalizer == nil {
+		a.Normalizer = map[string]interface{}{}
+	}
+	return a
+}
+
+// NgramAutocompleteAnalyzer - an edge_ngram analyzer named name for the index time of the search-as-you-type fields,
+// the standard analyzer with lowercase filter named name+"_search" is defined for the search time.
+func NgramAutocompleteAnalyzer(name string, minGram int, maxGram int) AnalysisPreset {
+	return func(s *IndexSettings) {
+		a := s.analysis()
+		tokenizer := name + "_tokenizer"
+		a.Tokenizer[tokenizer] = map[string]interface{}{
+			"type":        "edge_ngram",
+			"min_gram":    minGram,
+			"max_gram":    maxGram,
+			"token_chars": []string{"letter", "digit"},
+		}
+		a.Analyzer[name] = map[string]interface{}{
+			"type":      "custom",
+			"tokenizer": tokenizer,
+			"filter":    []string{"lowercase"},
+		}
+		a.Analyzer[name+"_search"] = map[string]interface{}{
+			"type":      "custom",
+			"tokenizer": "standard",
+			"filter":    []string{"lowercase"},
+		}
+		if diff := maxGram - minGram; diff > 1 && (s.MaxNgramDiff == nil || *s.MaxNgramDiff < diff) {
+			s.MaxNgramDiff = &diff
+		}
+	}
+}
+
+// IKAnalyzer - a Chinese analyzer named name based on the ik_max_word tokenizer,
+// the ik_smart based analyzer named name+"_smart" is defined for the search time.
+// The analysis-ik plugin is required.
+func IKAnalyzer(name string) AnalysisPreset {
+	return func(s *IndexSettings) {
+		a := s.analysis()
+		a.Analyzer[name] = map[string]interface{}{
+			"type":      "custom",
+			"tokenizer": "ik_max_word",
+			"filter":    []string{"lowercase"},
+		}
+		a.Analyzer[name+"_smart"] = map[string]interface{}{
+			"type":      "custom",
+			"tokenizer": "ik_smart",
+			"filter":    []string{"lowercase"},
+		}
+	}
+}
+
+// PinyinAnalyzer - a Chinese pinyin analyzer named name which keeps the full pinyin,
+// the first letters and the original text. The analysis-pinyin plugin is required.
+func PinyinAnalyzer(name string) AnalysisPreset {
+	return func(s *IndexSettings) {
+		a := s.analysis()
+		tokenizer := name + "_tokenizer"
+		a.Tokenizer[tokenizer] = map[string]interface{}{
+			"type":                       "pinyin",
+			"keep_first_letter":          true,
+			"keep_separate_first_letter": false,
+			"keep_full_pinyin":           true,
+			"keep_original":              true,
+			"limit_first_letter_length":  16,
+			"lowercase":                  true,
+			"remove_duplicated_term":     true,
+			"keep_none_chinese_in_joined_full_pinyin": true,
+		}
+		a.Analyzer[name] = map[string]interface{}{
+			"type":      "custom",
+			"tokenizer": tokenizer,
+		}
+	}
+}
+
+// LowercaseKeywordNormalizer - a normalizer named name for the keyword fields to be matched case-insensitively.
+func LowercaseKeywordNormalizer(name string) AnalysisPreset {
+	return func(s *IndexSettings) {
+		a := s.analysis()
+		a.Normalizer[name] = map[string]interface{}{
+			"type":   "custom",
+			"filter": []string{"lowercase", "asciifolding"},
+		}
+	}
+}
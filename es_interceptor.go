@@ -0,0 +1,257 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"time"
+)
+
+// OperInfo - the operation intercepted, Name is the method name of the oper like Search.
+type OperInfo struct {
+	Name    string
+	Indexes []string
+	// Body is the query of the query operations like Search and DeleteByQuery as passed by the caller,
+	// it's empty for the other operations.
+	Body string
+}
+
+// Interceptor - wraps an operation of the oper, next performs the operation and must be called at most once.
+type Interceptor func(ctx context.Context, info *OperInfo, next func(ctx context.Context) error) error
+
+// MetricsCollector - observes the operations of the oper.
+type MetricsCollector interface {
+	ObserveOper(ctx context.Context, info *OperInfo, duration time.Duration, err error)
+}
+
+// MetricsCollectorFunc - the func adapter of the MetricsCollector.
+type MetricsCollectorFunc func(ctx context.Context, info *OperInfo, duration time.Duration, err error)
+
+// ObserveOper -
+func (f MetricsCollectorFunc) ObserveOper(ctx context.Context, info *OperInfo, duration time.Duration, err error) {
+	f(ctx, info, duration, err)
+}
+
+func metricsInterceptor(collector MetricsCollector) Interceptor {
+	return func(ctx context.Context, info *OperInfo, next func(ctx context.Context) error) error {
+		start := time.Now()
+		err := next(ctx)
+		collector.ObserveOper(ctx, info, time.Since(start), err)
+		return err
+	}
+}
+
+type interceptedESOper struct {
+	ESOper
+	interceptors []Interceptor
+	// render renders the templates of the oper, so the template methods are intercepted as the others
+	render func(ctx context.Context, op string, t *TemplateParam) (string, error)
+}
+
+func newInterceptedESOper(oper ESOper, renderTemplate func(ctx context.Context, op string, t *TemplateParam) (string, error), interceptors []Interceptor) ESOper {
+	return &interceptedESOper{ESOper: oper, render: renderTemplate, interceptors: interceptors}
+}
+
+func (o *interceptedESOper) renderTemplate(ctx context.Context, op string, t *TemplateParam) (string, error) {
+	return o.render(ctx, op, t)
+}
+
+func (o *interceptedESOper) rewriteRequest(ctx context.Context, op string, indexes []string, query string) ([]string, string, error) {
+	return rewriteRequestBy(ctx, o.ESOper, op, indexes, query)
+}
+
+func (o *interceptedESOper) invoke(ctx context.Context, name string, indexes []string, call func(ctx context.Context) error) error {
+	return o.invokeQuery(ctx, name, indexes, "", call)
+}
+
+func (o *interceptedESOper) invokeQuery(ctx context.Context, name string, indexes []string, query string, call func(ctx context.Context) error) error {
+	info := &OperInfo{Name: name, Indexes: indexes, Body: query}
+	next := call
+	for i := len(o.interceptors) - 1; i >= 0; i-- {
+		interceptor, inner := o.interceptors[i], next
+		next = func(ctx context.Context) error {
+			return interceptor(ctx, info, inner)
+		}
+	}
+	return next(ctx)
+}
+
+func (o *interceptedESOper) Get(ctx context.Context, model interface{}, index string, id string, opts ...func(*GetRequest)) (res interface{}, err error) {
+	err = o.invoke(ctx, "Get", []string{index}, func(ctx context.Context) (err error) {
+		res, err = o.ESOper.Get(ctx, model, index, id, opts...)
+		return
+	})
+	return
+}
+
+func (o *interceptedESOper) MultiGet(ctx context.Context, model interface{}, index string, ids []string, opts ...func(*MgetRequest)) (res interface{}, err error) {
+	err = o.invoke(ctx, "MultiGet", []string{index}, func(ctx context.Context) (err error) {
+		res, err = o.ESOper.MultiGet(ctx, model, index, ids, opts...)
+		return
+	})
+	return
+}
+
+func (o *interceptedESOper) Exists(ctx context.Context, index string, id string, opts ...func(*ExistsRequest)) (exists bool, err error) {
+	err = o.invoke(ctx, "Exists", []string{index}, func(ctx context.Context) (err error) {
+		exists, err = o.ESOper.Exists(ctx, index, id, opts...)
+		return
+	})
+	return
+}
+
+func (o *interceptedESOper) GetSource(ctx context.Context, model interface{}, index string, id string, opts ...func(*GetSourceRequest)) (res interface{}, err error) {
+	err = o.invoke(ctx, "GetSource", []string{index}, func(ctx context.Context) (err error) {
+		res, err = o.ESOper.GetSource(ctx, model, index, id, opts...)
+		return
+	})
+	return
+}
+
+func (o *interceptedESOper) SourceExists(ctx context.Context, index string, id string, opts ...func(*ExistsSourceRequest)) (exists bool, err error) {
+	err = o.invoke(ctx, "SourceExists", []string{index}, func(ctx context.Context) (err error) {
+		exists, err = o.ESOper.SourceExists(ctx, index, id, opts...)
+		return
+	})
+	return
+}
+
+func (o *interceptedESOper) Bulk(ctx context.Context, index string, writeReqBody func(ctx context.Context, buf *bytes.Buffer) error, opts ...func(*BulkRequest)) error {
+	_, err := o.BulkWithResponse(ctx, index, writeReqBody, opts...)
+	return err
+}
+
+func (o *interceptedESOper) BulkWithResponse(ctx context.Context, index string, writeReqBody func(ctx context.Context, buf *bytes.Buffer) error, opts ...func(*BulkRequest)) (res *BulkIndexerResponse, err error) {
+	err = o.invoke(ctx, "Bulk", []string{index}, func(ctx context.Context) (err error) {
+		res, err = o.ESOper.BulkWithResponse(ctx, index, writeReqBody, opts...)
+		return
+	})
+	return
+}
+
+func (o *interceptedESOper) Create(ctx context.Context, index string, id string, obj interface{}, opts ...func(*CreateRequest)) error {
+	return o.invoke(ctx, "Create", []string{index}, func(ctx context.Context) error {
+		return o.ESOper.Create(ctx, index, id, obj, opts...)
+	})
+}
+
+func (o *interceptedESOper) Index(ctx context.Context, index string, id string, obj interface{}, opts ...func(*IndexRequest)) error {
+	return o.invoke(ctx, "Index", []string{index}, func(ctx context.Context) error {
+		return o.ESOper.Index(ctx, index, id, obj, opts...)
+	})
+}
+
+func (o *interceptedESOper) Update(ctx context.Context, index string, id string, obj interface{}, opts ...func(*UpdateRequest)) error {
+	return o.invoke(ctx, "Update", []string{index}, func(ctx context.Context) error {
+		return o.ESOper.Update(ctx, index, id, obj, opts...)
+	})
+}
+
+func (o *interceptedESOper) UpdateScript(ctx context.Context, index string, id string, script *Script, opts ...func(*UpdateRequest)) error {
+	return o.invoke(ctx, "UpdateScript", []string{index}, func(ctx context.Context) error {
+		return o.ESOper.UpdateScript(ctx, index, id, script, opts...)
+	})
+}
+
+func (o *interceptedESOper) Upsert(ctx context.Context, index string, id string, obj interface{}, opts ...func(*UpdateRequest)) error {
+	return o.invoke(ctx, "Upsert", []string{index}, func(ctx context.Context) error {
+		return o.ESOper.Upsert(ctx, index, id, obj, opts...)
+	})
+}
+
+func (o *interceptedESOper) Delete(ctx context.Context, id string, index string, opts ...func(*DeleteRequest)) error {
+	return o.invoke(ctx, "Delete", []string{index}, func(ctx context.Context) error {
+		return o.ESOper.Delete(ctx, id, index, opts...)
+	})
+}
+
+func (o *interceptedESOper) DeleteDoc(ctx context.Context, ref DocRef, opts ...func(*DeleteRequest)) error {
+	return o.Delete(ctx, ref.ID, ref.Index, opts...)
+}
+
+func (o *interceptedESOper) DeleteByQuery(ctx context.Context, query string, indexes []string, opts ...func(*DeleteByQueryRequest)) error {
+	return o.invokeQuery(ctx, "DeleteByQuery", indexes, query, func(ctx context.Context) error {
+		return o.ESOper.DeleteByQuery(ctx, query, indexes, opts...)
+	})
+}
+
+func (o *interceptedESOper) DeleteByQueryTemplate(ctx context.Context, t *TemplateParam, indexes []string, opts ...func(*DeleteByQueryRequest)) error {
+	query, err := o.renderTemplate(ctx, "DeleteByQueryTemplate", t)
+	if err != nil {
+		return err
+	}
+	return o.DeleteByQuery(ctx, query, indexes, opts...)
+}
+
+func (o *interceptedESOper) UpdateByQuery(ctx context.Context, query string, indexes []string, opts ...func(*UpdateByQueryRequest)) error {
+	return o.invokeQuery(ctx, "UpdateByQuery", indexes, query, func(ctx context.Context) error {
+		return o.ESOper.UpdateByQuery(ctx, query, indexes, opts...)
+	})
+}
+
+func (o *interceptedESOper) UpdateByQueryTemplate(ctx context.Context, t *TemplateParam, indexes []string, opts ...func(*UpdateByQueryRequest)) error {
+	query, err := o.renderTemplate(ctx, "UpdateByQueryTemplate", t)
+	if err != nil {
+		return err
+	}
+	return o.UpdateByQuery(ctx, query, indexes, opts...)
+}
+
+func (o *interceptedESOper) Count(ctx context.Context, query string, indexes []string, opts ...func(*CountRequest)) (count int64, err error) {
+	err = o.invokeQuery(ctx, "Count", indexes, query, func(ctx context.Context) (err error) {
+		count, err = o.ESOper.Count(ctx, query, indexes, opts...)
+		return
+	})
+	return
+}
+
+func (o *interceptedESOper) CountTemplate(ctx context.Context, t *TemplateParam, indexes []string, opts ...func(*CountRequest)) (int64, error) {
+	query, err := o.renderTemplate(ctx, "CountTemplate", t)
+	if err != nil {
+		return 0, err
+	}
+	return o.Count(ctx, query, indexes, opts...)
+}
+
+func (o *interceptedESOper) Search(ctx context.Context, model interface{}, query string, indexes []string, opts ...func(*SearchRequest)) (res interface{}, err error) {
+	err = o.invokeQuery(ctx, "Search", indexes, query, func(ctx context.Context) (err error) {
+		res, err = o.ESOper.Search(ctx, model, query, indexes, opts...)
+		return
+	})
+	return
+}
+
+func (o *interceptedESOper) SearchTemplate(ctx context.Context, model interface{}, t *TemplateParam, indexes []string, opts ...func(*SearchRequest)) (interface{}, error) {
+	query, err := o.renderTemplate(ctx, "SearchTemplate", t)
+	if err != nil {
+		return nil, err
+	}
+	return o.Search(ctx, model, query, indexes, opts...)
+}
+
+func (o *interceptedESOper) SearchByScrollID(ctx context.Context, model interface{}, scrollID string, opts ...func(*ScrollRequest)) (res interface{}, err error) {
+	err = o.invoke(ctx, "SearchByScrollID", nil, func(ctx context.Context) (err error) {
+		res, err = o.ESOper.SearchByScrollID(ctx, model, scrollID, opts...)
+		return
+	})
+	return
+}
+
+func (o *interceptedESOper) ClearScroll(ctx context.Context, scrollIDs ...string) error {
+	return o.invoke(ctx, "ClearScroll", nil, func(ctx context.Context) error {
+		return o.ESOper.ClearScroll(ctx, scrollIDs...)
+	})
+}
@@ -0,0 +1,192 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esutil"
+	"github.com/nf-go/nfgo/nlog"
+	"github.com/nf-go/nfgo/nutil/graceful"
+	"go.uber.org/multierr"
+)
+
+// HealthChecker pings the cluster periodically, it's a graceful.ShutdownServer
+// to be served and shutdown along with the other servers of the application.
+type HealthChecker interface {
+	graceful.ShutdownServer
+
+	// Healthy reports whether the last ping succeeded.
+	Healthy() bool
+	// LastError returns the error of the last ping, nil if it succeeded.
+	LastError() error
+}
+
+// NewHealthChecker - the checker pinging the cluster at the interval, 30s by default, each ping is bounded by
+// the interval as well.
+func NewHealthChecker(client *Client, interval time.Duration) HealthChecker {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &healthChecker{
+		client:   client,
+		interval: interval,
+		lastErr:  errors.New("nes health checker: the cluster is not checked yet"),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+type healthChecker struct {
+	client   *Client
+	interval time.Duration
+
+	mu      sync.RWMutex
+	lastErr error
+	serving bool
+
+	once    sync.Once
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func (h *healthChecker) Healthy() bool {
+	return h.LastError() == nil
+}
+
+func (h *healthChecker) LastError() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.lastErr
+}
+
+func (h *healthChecker) ping() {
+	ctx, cancel := context.WithTimeout(context.Background(), h.interval)
+	defer cancel()
+	api := h.client
+	resp, err := api.Ping(api.Ping.WithContext(ctx))
+	if err == nil {
+		if resp.IsError() {
+			err = newRespErr(resp)
+		}
+		closeResponse(resp)
+	}
+	if err != nil {
+		nlog.Logger(ctx).Warnf("nes health checker: fail to ping the cluster: %s", err)
+	}
+	h.mu.Lock()
+	h.lastErr = err
+	h.mu.Unlock()
+}
+
+// Serve pings the cluster until the checker is shutdown.
+func (h *healthChecker) Serve() error {
+	h.mu.Lock()
+	h.serving = true
+	h.mu.Unlock()
+	defer close(h.stopped)
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		h.ping()
+		select {
+		case <-h.done:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *healthChecker) MustServe() {
+	if err := h.Serve(); err != nil {
+		nlog.Fatal("fail to serve the nes health checker: ", err)
+	}
+}
+
+// Shutdown stops pinging, it returns at once if it's not served.
+func (h *healthChecker) Shutdown(ctx context.Context) error {
+	h.once.Do(func() {
+		close(h.done)
+	})
+	h.mu.RLock()
+	serving := h.serving
+	h.mu.RUnlock()
+	if !serving {
+		return nil
+	}
+	select {
+	case <-h.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// trackedBulkIndexer removes itself from the oper once it's closed.
+type trackedBulkIndexer struct {
+	BulkIndexer
+	oper *esOper
+}
+
+func (b *trackedBulkIndexer) Close(ctx context.Context) error {
+	defer b.oper.untrackBulkIndexer(b)
+	return b.BulkIndexer.Close(ctx)
+}
+
+func (e *esOper) NewBulkIndexer(config BulkIndexerConfig) (BulkIndexer, error) {
+	if config.Client == nil {
+		config.Client = e.client
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// the indexers created during the shutdown would be missed by Close
+	if e.gate.closed() {
+		return nil, ErrShutdown
+	}
+	indexer, err := esutil.NewBulkIndexer(config)
+	if err != nil {
+		return nil, err
+	}
+	tracked := &trackedBulkIndexer{BulkIndexer: indexer, oper: e}
+	if e.bulkIndexers == nil {
+		e.bulkIndexers = map[*trackedBulkIndexer]struct{}{}
+	}
+	e.bulkIndexers[tracked] = struct{}{}
+	return tracked, nil
+}
+
+func (e *esOper) untrackBulkIndexer(b *trackedBulkIndexer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.bulkIndexers, b)
+}
+
+func (e *esOper) Close(ctx context.Context) error {
+	e.mu.Lock()
+	indexers := make([]*trackedBulkIndexer, 0, len(e.bulkIndexers))
+	for b := range e.bulkIndexers {
+		indexers = append(indexers, b)
+	}
+	e.mu.Unlock()
+
+	var errs error
+	for _, b := range indexers {
+		errs = multierr.Append(errs, b.Close(ctx))
+	}
+	return errs
+}
@@ -0,0 +1,221 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// the rules of Lint
+const (
+	// LintLeadingWildcard - the wildcard, regexp and query_string patterns starting with a wildcard scan all the terms.
+	LintLeadingWildcard = "leading_wildcard"
+	// LintScriptQuery - the script queries out of the filter context are evaluated on every document without caching.
+	LintScriptQuery = "script_query"
+	// LintHugeTerms - the terms queries with more than MaxLintTerms values.
+	LintHugeTerms = "huge_terms"
+	// LintMissingFilter - the term level queries in the must clauses are scored for nothing, they belong to the filter clauses.
+	LintMissingFilter = "missing_filter"
+)
+
+// MaxLintTerms - the max number of the values of a terms query not flagged by Lint.
+const MaxLintTerms = 1024
+
+// LintWarning - an anti-pattern found by Lint, Path is the path of the query in the body like query.bool.must[0].
+type LintWarning struct {
+	Rule    string
+	Path    string
+	Message string
+}
+
+func (w *LintWarning) String() string {
+	return fmt.Sprintf("%s at %s: %s", w.Rule, w.Path, w.Message)
+}
+
+// LintError - the error of the template methods rejected in the LintReject mode.
+type LintError struct {
+	Op       string
+	Warnings []*LintWarning
+}
+
+func (e *LintError) Error() string {
+	warnings := make([]string, 0, len(e.Warnings))
+	for _, w := range e.Warnings {
+		warnings = append(warnings, w.String())
+	}
+	return fmt.Sprintf("nes: the query of %s is rejected by the lint: %s", e.Op, strings.Join(warnings, "; "))
+}
+
+// LintMode - how the template methods handle the warnings of Lint.
+type LintMode int
+
+const (
+	// LintOff doesn't lint the queries.
+	LintOff LintMode = iota
+	// LintWarn logs the warnings and sends the queries.
+	LintWarn
+	// LintReject returns a *LintError instead of sending the queries with warnings.
+	LintReject
+)
+
+// WithTemplateLint - lints the queries rendered by the template methods before they are sent, LintOff by default.
+func WithTemplateLint(mode LintMode) ESOperOption {
+	return func(e *esOper) {
+		e.lintMode = mode
+	}
+}
+
+// Lint checks the request body of a search, count or by-query request for the known anti-patterns, the warnings
+// are ordered by their paths.
+func Lint(query string) ([]*LintWarning, error) {
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(query), &body); err != nil {
+		return nil, fmt.Errorf("nes: the query is not a JSON object: %w", err)
+	}
+	l := &linter{}
+	if q, ok := body["query"]; ok {
+		l.walk("query", q, false, "")
+	}
+	if q, ok := body["post_filter"]; ok {
+		l.walk("post_filter", q, true, "")
+	}
+	sort.SliceStable(l.warnings, func(i, j int) bool { return l.warnings[i].Path < l.warnings[j].Path })
+	return l.warnings, nil
+}
+
+// leadingWildcard matches the terms of the query strings starting with a wildcard.
+var leadingWildcard = regexp.MustCompile(`(^|[\s(:])[*?]`)
+
+type linter struct {
+	warnings []*LintWarning
+}
+
+func (l *linter) warn(rule string, path string, format string, args ...interface{}) {
+	l.warnings = append(l.warnings, &LintWarning{Rule: rule, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// walk lints the query, filter reports whether it's in the filter context, clause is the bool clause of the query.
+func (l *linter) walk(path string, query interface{}, filter bool, clause string) {
+	q, ok := query.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for kind, v := range q {
+		p := path + "." + kind
+		body, _ := v.(map[string]interface{})
+		switch kind {
+		case "bool":
+			for _, c := range []string{"must", "should", "filter", "must_not"} {
+				l.walkClauses(p+"."+c, body[c], filter || c == "filter" || c == "must_not", c)
+			}
+		case "constant_score":
+			l.walk(p+".filter", body["filter"], true, "")
+		case "function_score", "script_score", "nested", "has_child", "has_parent":
+			l.walk(p+".query", body["query"], filter, "")
+		case "dis_max":
+			l.walkClauses(p+".queries", body["queries"], filter, "")
+		case "boosting":
+			l.walk(p+".positive", body["positive"], filter, "")
+			l.walk(p+".negative", body["negative"], filter, "")
+		case "wildcard", "regexp":
+			for field, param := range body {
+				value := param
+				if m, ok := param.(map[string]interface{}); ok {
+					value = m["value"]
+					if kind == "wildcard" && value == nil {
+						value = m["wildcard"]
+					}
+				}
+				s, _ := value.(string)
+				if kind == "wildcard" && (strings.HasPrefix(s, "*") || strings.HasPrefix(s, "?")) ||
+					kind == "regexp" && strings.HasPrefix(s, ".*") {
+					l.warn(LintLeadingWildcard, p+"."+field, "the pattern %q starts with a wildcard", s)
+				}
+			}
+		case "query_string":
+			s, _ := body["query"].(string)
+			if body["allow_leading_wildcard"] != false && leadingWildcard.MatchString(s) {
+				l.warn(LintLeadingWildcard, p, "the query %q has a term starting with a wildcard", s)
+			}
+		case "script":
+			if !filter {
+				l.warn(LintScriptQuery, p, "the script query is out of the filter context")
+			}
+		case "terms":
+			for field, values := range body {
+				if vs, ok := values.([]interface{}); ok && len(vs) > MaxLintTerms {
+					l.warn(LintHugeTerms, p+"."+field, "the terms query has %d values, more than %d", len(vs), MaxLintTerms)
+				}
+			}
+		}
+		switch kind {
+		case "term", "terms", "range", "exists", "ids":
+			if clause == "must" && !filter {
+				l.warn(LintMissingFilter, p, "the %s query is scored in the must clause, it belongs to the filter clause", kind)
+			}
+		}
+	}
+}
+
+// walkClauses lints the clauses which can be a single query or an array of queries.
+func (l *linter) walkClauses(path string, clauses interface{}, filter bool, clause string) {
+	switch c := clauses.(type) {
+	case []interface{}:
+		for i, q := range c {
+			l.walk(fmt.Sprintf("%s[%d]", path, i), q, filter, clause)
+		}
+	case map[string]interface{}:
+		l.walk(path, c, filter, clause)
+	}
+}
+
+// renderTemplate renders the template of the op and lints the query in the lint mode of the oper.
+func (e *esOper) renderTemplate(ctx context.Context, op string, t *TemplateParam) (string, error) {
+	query, err := t.Render()
+	if err != nil || e.lintMode == LintOff {
+		return query, err
+	}
+	warnings, err := Lint(query)
+	if err != nil || len(warnings) == 0 {
+		return query, err
+	}
+	if e.lintMode == LintReject {
+		return "", &LintError{Op: op, Warnings: warnings}
+	}
+	logger := e.logger(ctx)
+	for _, w := range warnings {
+		logger.Warnf("nes es oper %s: lint: %s", op, w)
+	}
+	return query, nil
+}
+
+// templateRenderer - the opers rendering the templates in their lint mode, the wrappers of the opers delegate
+// their templates to it.
+type templateRenderer interface {
+	renderTemplate(ctx context.Context, op string, t *TemplateParam) (string, error)
+}
+
+// renderTemplateBy renders the template by the oper in its lint mode, or as it is if the oper isn't a templateRenderer.
+func renderTemplateBy(ctx context.Context, oper ESOper, op string, t *TemplateParam) (string, error) {
+	if r, ok := oper.(templateRenderer); ok {
+		return r.renderTemplate(ctx, op, t)
+	}
+	return t.Render()
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MappingParamChange - a mapping parameter of a field changed by the desired mappings.
+type MappingParamChange struct {
+	Path    string      `json:"path"`
+	Param   string      `json:"param"`
+	Current interface{} `json:"current"`
+	Desired interface{} `json:"desired"`
+	// Incompatible reports whether the parameter can't be changed in place, the index should be reindexed.
+	Incompatible bool `json:"incompatible"`
+}
+
+// MappingDiff - the difference between the mappings of an index and the desired ones, the paths are sorted.
+type MappingDiff struct {
+	Index string `json:"index"`
+	// Added are the fields missing in the index.
+	Added []string `json:"added,omitempty"`
+	// Removed are the fields of the index missing in the desired mappings, e.g. the ones added by the dynamic mapping,
+	// the fields can't be removed without reindexing.
+	Removed []string `json:"removed,omitempty"`
+	// Changed are the fields whose parameters are changed in place.
+	Changed []string `json:"changed,omitempty"`
+	// Incompatible are the fields whose parameters can't be changed in place.
+	Incompatible []string `json:"incompatible,omitempty"`
+	// Params are the parameters changed of the Changed and the Incompatible fields.
+	Params []*MappingParamChange `json:"params,omitempty"`
+}
+
+// Drifted reports whether the mappings of the index differ from the desired ones.
+func (d *MappingDiff) Drifted() bool {
+	return len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Changed) > 0 || len(d.Incompatible) > 0
+}
+
+// Compatible reports whether the desired mappings can be applied to the index by EnsureIndex.
+func (d *MappingDiff) Compatible() bool {
+	return len(d.Incompatible) == 0
+}
+
+func (d *MappingDiff) String() string {
+	if !d.Drifted() {
+		return d.Index + ": no drift"
+	}
+	var b strings.Builder
+	b.WriteString(d.Index + ":")
+	for _, f := range d.Added {
+		b.WriteString("\n  + " + f)
+	}
+	for _, f := range d.Removed {
+		b.WriteString("\n  - " + f)
+	}
+	for _, p := range d.Params {
+		mark := "~"
+		if p.Incompatible {
+			mark = "!"
+		}
+		fmt.Fprintf(&b, "\n  %s %s.%s: %v -> %v", mark, p.Path, p.Param, p.Current, p.Desired)
+	}
+	return b.String()
+}
+
+// DiffMapping returns the differences between the properties of the indices and the desired mappings, keyed by
+// the names of the indices, which can be the ones of an alias or a pattern, e.g. the CI checks of the mapping drift.
+func (e *esAdminOper) DiffMapping(ctx context.Context, index string, desired map[string]interface{}) (map[string]*MappingDiff, error) {
+	mappings, err := e.GetMapping(ctx, []string{index})
+	if err != nil {
+		return nil, err
+	}
+	normalized, _ := normalizeJSON(desired).(map[string]interface{})
+	desiredProps, _ := normalized["properties"].(map[string]interface{})
+	diffs := make(map[string]*MappingDiff, len(mappings))
+	for name, current := range mappings {
+		currentProps, _ := current["properties"].(map[string]interface{})
+		c := &mappingChanges{params: []*MappingParamChange{}}
+		diffProperties("", currentProps, desiredProps, c)
+		for _, paths := range [][]string{c.added, c.removed, c.changed, c.incompatible} {
+			sort.Strings(paths)
+		}
+		sort.Slice(c.params, func(i, j int) bool {
+			if c.params[i].Path != c.params[j].Path {
+				return c.params[i].Path < c.params[j].Path
+			}
+			return c.params[i].Param < c.params[j].Param
+		})
+		diffs[name] = &MappingDiff{
+			Index:        name,
+			Added:        c.added,
+			Removed:      c.removed,
+			Changed:      c.changed,
+			Incompatible: c.incompatible,
+		}
+		if len(c.params) > 0 {
+			diffs[name].Params = c.params
+		}
+	}
+	return diffs, nil
+}
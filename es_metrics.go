@@ -0,0 +1,75 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+const metricAggName = "nes_metric"
+
+// CountDistinct returns the approximate number of the distinct values of the field in the documents of the index
+// matching the query in the request body. The counts below the precisionThreshold are expected to be close to
+// accurate, the default one of the cluster is used if it's not positive, the max one is 40000.
+//
+// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/search-aggregations-metrics-cardinality-aggregation.html.
+func CountDistinct(ctx context.Context, oper Searcher, index string, field string, query string, precisionThreshold int, opts ...func(*SearchRequest)) (int64, error) {
+	agg := CardinalityAgg(field)
+	if precisionThreshold > 0 {
+		agg = agg.With("precision_threshold", precisionThreshold)
+	}
+	aggs, err := searchMetric(ctx, oper, index, query, agg, opts...)
+	if err != nil {
+		return 0, err
+	}
+	v, err := aggs.Value(metricAggName)
+	if err != nil || v == nil {
+		return 0, err
+	}
+	return int64(*v), nil
+}
+
+// FieldStats returns the count, min, max, avg and sum of the values of the numeric or date field in the documents of
+// the index matching the query in the request body.
+func FieldStats(ctx context.Context, oper Searcher, index string, field string, query string, opts ...func(*SearchRequest)) (*StatsResult, error) {
+	aggs, err := searchMetric(ctx, oper, index, query, StatsAgg(field), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return aggs.Stats(metricAggName)
+}
+
+// searchMetric searches the metric aggregation of the documents matching the query without the hits.
+func searchMetric(ctx context.Context, oper Searcher, index string, query string, agg Agg, opts ...func(*SearchRequest)) (AggsResult, error) {
+	body := map[string]interface{}{}
+	if query != "" {
+		if err := json.Unmarshal([]byte(query), &body); err != nil {
+			return nil, fmt.Errorf("nes: the request body is not a JSON object: %w", err)
+		}
+	}
+	body["size"] = 0
+	body["aggs"] = map[string]Agg{metricAggName: agg}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	respBody := &aggsResponseBody{}
+	if _, err := oper.Search(ctx, respBody, string(b), []string{index}, opts...); err != nil {
+		return nil, err
+	}
+	return respBody.Aggregations, nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// MoreLikeThisQuery - the more_like_this query of the documents similar to the likes on the fields, the likes are
+// the texts or the documents referenced by LikeDoc. The fields default to the index.query.default_field if empty.
+//
+// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/query-dsl-mlt-query.html.
+func MoreLikeThisQuery(fields []string, likes ...interface{}) *Query {
+	params := map[string]interface{}{"like": likes}
+	if len(fields) > 0 {
+		params["fields"] = fields
+	}
+	return newQuery("more_like_this", params)
+}
+
+// LikeDoc - the reference of the document liked by the more_like_this query.
+func LikeDoc(index string, id string) interface{} {
+	return map[string]string{"_index": index, "_id": id}
+}
+
+// MinTermFreq - the min frequency of the terms in the likes of the more_like_this query, 2 by default.
+func (q *Query) MinTermFreq(n int) *Query {
+	return q.With("min_term_freq", n)
+}
+
+// MinDocFreq - the min number of the documents containing the terms of the more_like_this query, 5 by default.
+func (q *Query) MinDocFreq(n int) *Query {
+	return q.With("min_doc_freq", n)
+}
+
+// MaxQueryTerms - the max number of the terms selected from the likes of the more_like_this query, 25 by default.
+func (q *Query) MaxQueryTerms(n int) *Query {
+	return q.With("max_query_terms", n)
+}
+
+// SimilarOption - the option of SimilarDocuments.
+type SimilarOption func(*similarParams)
+
+type similarParams struct {
+	fields []string
+	size   int
+	filter *Query
+	tune   func(q *Query)
+	opts   []func(*SearchRequest)
+}
+
+// WithSimilarFields - the fields compared, the index.query.default_field by default.
+func WithSimilarFields(fields ...string) SimilarOption {
+	return func(p *similarParams) {
+		p.fields = fields
+	}
+}
+
+// WithSimilarSize - the max number of the similar documents, 10 by default.
+func WithSimilarSize(size int) SimilarOption {
+	return func(p *similarParams) {
+		p.size = size
+	}
+}
+
+// WithSimilarFilter - the similar documents must match the filter, e.g. the items in stock.
+func WithSimilarFilter(filter *Query) SimilarOption {
+	return func(p *similarParams) {
+		p.filter = filter
+	}
+}
+
+// WithSimilarTuning - tunes the more_like_this query, e.g. by MinTermFreq and MaxQueryTerms.
+func WithSimilarTuning(tune func(q *Query)) SimilarOption {
+	return func(p *similarParams) {
+		p.tune = tune
+	}
+}
+
+// WithSimilarSearchOptions - the options of the search request.
+func WithSimilarSearchOptions(opts ...func(*SearchRequest)) SimilarOption {
+	return func(p *similarParams) {
+		p.opts = append(p.opts, opts...)
+	}
+}
+
+// SimilarDocuments searches the documents of the index similar to the document of the id, e.g. the related items,
+// the document itself is excluded. The model is a pointer to a slice of the documents, e.g. *[]*Product.
+func SimilarDocuments(ctx context.Context, oper Searcher, model interface{}, index string, id string, opts ...SimilarOption) (interface{}, error) {
+	p := &similarParams{size: 10}
+	for _, opt := range opts {
+		opt(p)
+	}
+	mlt := MoreLikeThisQuery(p.fields, LikeDoc(index, id))
+	if p.tune != nil {
+		p.tune(mlt)
+	}
+	query := BoolQuery().Must(mlt)
+	if p.filter != nil {
+		query.Filter(p.filter)
+	}
+	body, err := NewSearchBody(query).WithPage(0, p.size).JSON()
+	if err != nil {
+		return nil, err
+	}
+	hits, err := SearchRaw(ctx, oper, body, []string{index}, p.opts...)
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]json.RawMessage, 0, len(hits))
+	for _, hit := range hits {
+		sources = append(sources, hit.Source)
+	}
+	b, err := json.Marshal(sources)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, model); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
@@ -0,0 +1,225 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/elastic/elastic-transport-go/v8/elastictransport"
+)
+
+// NodeHealthConfig - the thresholds of the node health tracker, the zero values are replaced by the defaults.
+type NodeHealthConfig struct {
+	// MaxErrorRate is the error rate above which the node is excluded, 0.5 by default.
+	MaxErrorRate float64
+	// MaxLatencyFactor is the factor of the median latency of the nodes above which the node is excluded, 3 by default.
+	MaxLatencyFactor float64
+	// MinRequests is the number of the requests of the node before it's judged, 20 by default.
+	MinRequests int64
+	// ExclusionPeriod is how long the unhealthy node is excluded, 30 seconds by default.
+	ExclusionPeriod time.Duration
+	// Decay is the weight of the latest request in the moving averages, 0.1 by default.
+	Decay float64
+}
+
+// NodeHealth - the health of a node tracked by the client, the error rate and the latency are the exponentially
+// weighted moving averages.
+type NodeHealth struct {
+	Host          string        `json:"host"`
+	Requests      int64         `json:"requests"`
+	Errors        int64         `json:"errors"`
+	ErrorRate     float64       `json:"error_rate"`
+	Latency       time.Duration `json:"latency"`
+	Excluded      bool          `json:"excluded"`
+	ExcludedUntil time.Time     `json:"excluded_until,omitempty"`
+	// Manual reports whether the exclusion is set by Exclude.
+	Manual bool `json:"manual"`
+}
+
+// NodeHealthTracker - tracks the errors and the latencies of the requests per node and excludes the unhealthy nodes
+// from the selection temporarily, on top of the dead nodes marked by the client. All the nodes are selected if all of
+// them are excluded. It's the elastictransport.Selector of the client created with WithNodeHealthTracker.
+type NodeHealthTracker struct {
+	config NodeHealthConfig
+
+	mu    sync.Mutex
+	nodes map[string]*nodeHealth
+	next  int
+}
+
+type nodeHealth struct {
+	// samples are the requests in the moving averages, which start over after the node is excluded
+	samples       int64
+	requests      int64
+	errors        int64
+	errorRate     float64
+	latency       float64
+	excludedUntil time.Time
+	manual        bool
+}
+
+// NewNodeHealthTracker -
+func NewNodeHealthTracker(config *NodeHealthConfig) *NodeHealthTracker {
+	c := NodeHealthConfig{}
+	if config != nil {
+		c = *config
+	}
+	if c.MaxErrorRate <= 0 {
+		c.MaxErrorRate = 0.5
+	}
+	if c.MaxLatencyFactor <= 0 {
+		c.MaxLatencyFactor = 3
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = 20
+	}
+	if c.ExclusionPeriod <= 0 {
+		c.ExclusionPeriod = 30 * time.Second
+	}
+	if c.Decay <= 0 || c.Decay > 1 {
+		c.Decay = 0.1
+	}
+	return &NodeHealthTracker{config: c, nodes: map[string]*nodeHealth{}}
+}
+
+// WithNodeHealthTracker - the tracker of the health of the nodes, which selects the nodes of the requests.
+func WithNodeHealthTracker(tracker *NodeHealthTracker) ESClientOption {
+	return func(t *esTransport) {
+		t.nodeHealth = tracker
+	}
+}
+
+func (t *NodeHealthTracker) node(host string) *nodeHealth {
+	n, ok := t.nodes[host]
+	if !ok {
+		n = &nodeHealth{}
+		t.nodes[host] = n
+	}
+	return n
+}
+
+// observe records a request to the host, the failures are the transport errors and the 5xx responses.
+func (t *NodeHealthTracker) observe(host string, latency time.Duration, failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := t.node(host)
+	n.requests++
+	n.samples++
+	var e float64
+	if failed {
+		n.errors++
+		e = 1
+	}
+	if n.samples == 1 {
+		n.errorRate, n.latency = e, float64(latency)
+	} else {
+		d := t.config.Decay
+		n.errorRate = d*e + (1-d)*n.errorRate
+		n.latency = d*float64(latency) + (1-d)*n.latency
+	}
+	if n.manual && n.excluded(time.Now()) || n.samples < t.config.MinRequests {
+		return
+	}
+	if n.errorRate > t.config.MaxErrorRate || n.latency > t.config.MaxLatencyFactor*t.medianLatency() {
+		// the node is judged by the new requests once it's included again
+		n.excludedUntil = time.Now().Add(t.config.ExclusionPeriod)
+		n.samples = 0
+	}
+}
+
+func (t *NodeHealthTracker) medianLatency() float64 {
+	latencies := make([]float64, 0, len(t.nodes))
+	for _, n := range t.nodes {
+		if n.samples >= t.config.MinRequests {
+			latencies = append(latencies, n.latency)
+		}
+	}
+	sort.Float64s(latencies)
+	return latencies[len(latencies)/2]
+}
+
+// excluded reports whether the node is excluded at the time, the manual exclusion is cleared once it expires so
+// the node is judged by its health again.
+func (n *nodeHealth) excluded(now time.Time) bool {
+	if !now.Before(n.excludedUntil) {
+		n.manual = false
+		return false
+	}
+	return true
+}
+
+// Select selects the healthy connections in a round-robin manner.
+func (t *NodeHealthTracker) Select(conns []*elastictransport.Connection) (*elastictransport.Connection, error) {
+	if len(conns) == 0 {
+		return nil, errors.New("no connection available")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	healthy := make([]*elastictransport.Connection, 0, len(conns))
+	for _, c := range conns {
+		if n, ok := t.nodes[c.URL.Host]; !ok || !n.excluded(now) {
+			healthy = append(healthy, c)
+		}
+	}
+	if len(healthy) == 0 {
+		healthy = conns
+	}
+	t.next = (t.next + 1) % len(healthy)
+	return healthy[t.next], nil
+}
+
+// Exclude excludes the node of the host like 10.0.0.1:9200 for the duration regardless of its health.
+func (t *NodeHealthTracker) Exclude(host string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := t.node(host)
+	n.excludedUntil = time.Now().Add(d)
+	n.manual = true
+}
+
+// Include includes the node of the host again and resets its health.
+func (t *NodeHealthTracker) Include(host string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nodes[host] = &nodeHealth{}
+}
+
+// Nodes returns the health of the nodes requested ordered by their hosts.
+func (t *NodeHealthTracker) Nodes() []*NodeHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	nodes := make([]*NodeHealth, 0, len(t.nodes))
+	for host, n := range t.nodes {
+		h := &NodeHealth{
+			Host:      host,
+			Requests:  n.requests,
+			Errors:    n.errors,
+			ErrorRate: n.errorRate,
+			Latency:   time.Duration(n.latency),
+			Excluded:  n.excluded(now),
+		}
+		if h.Excluded {
+			h.ExcludedUntil, h.Manual = n.excludedUntil, n.manual
+		}
+		nodes = append(nodes, h)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Host < nodes[j].Host })
+	return nodes
+}
@@ -0,0 +1,157 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"io"
+	"sort"
+)
+
+// NodeInfo - the most-used fields of the info of a node.
+type NodeInfo struct {
+	ID      string   `json:"-"`
+	Name    string   `json:"name"`
+	Host    string   `json:"host"`
+	IP      string   `json:"ip"`
+	Version string   `json:"version"`
+	Roles   []string `json:"roles"`
+	JVM     struct {
+		Version string `json:"version"`
+		Mem     struct {
+			HeapMaxBytes int64 `json:"heap_max_in_bytes"`
+		} `json:"mem"`
+	} `json:"jvm"`
+	OS struct {
+		AvailableProcessors int `json:"available_processors"`
+	} `json:"os"`
+}
+
+// NodeStats - the most-used fields of the stats of a node for diagnosing the pressure.
+type NodeStats struct {
+	ID   string `json:"-"`
+	Name string `json:"name"`
+	Host string `json:"host"`
+	JVM  struct {
+		UptimeMillis int64 `json:"uptime_in_millis"`
+		Mem          struct {
+			HeapUsedBytes   int64 `json:"heap_used_in_bytes"`
+			HeapMaxBytes    int64 `json:"heap_max_in_bytes"`
+			HeapUsedPercent int   `json:"heap_used_percent"`
+		} `json:"mem"`
+		GC struct {
+			Collectors map[string]*GCCollectorStats `json:"collectors"`
+		} `json:"gc"`
+	} `json:"jvm"`
+	OS struct {
+		CPU struct {
+			Percent int `json:"percent"`
+		} `json:"cpu"`
+	} `json:"os"`
+	ThreadPools map[string]*ThreadPoolStats `json:"thread_pool"`
+	Breakers    map[string]*BreakerStats    `json:"breakers"`
+}
+
+// GCCollectorStats - the stats of a garbage collector like young and old.
+type GCCollectorStats struct {
+	CollectionCount  int64 `json:"collection_count"`
+	CollectionMillis int64 `json:"collection_time_in_millis"`
+}
+
+// ThreadPoolStats - the stats of a thread pool like search and write, the rejections indicate the pressure.
+type ThreadPoolStats struct {
+	Threads   int   `json:"threads"`
+	Queue     int   `json:"queue"`
+	Active    int   `json:"active"`
+	Rejected  int64 `json:"rejected"`
+	Completed int64 `json:"completed"`
+}
+
+// BreakerStats - the stats of a circuit breaker like parent and fielddata.
+type BreakerStats struct {
+	LimitSizeBytes     int64   `json:"limit_size_in_bytes"`
+	EstimatedSizeBytes int64   `json:"estimated_size_in_bytes"`
+	Overhead           float64 `json:"overhead"`
+	Tripped            int64   `json:"tripped"`
+}
+
+type nodesInfoResponseBody struct {
+	Nodes map[string]*NodeInfo `json:"nodes"`
+}
+
+type nodesStatsResponseBody struct {
+	Nodes map[string]*NodeStats `json:"nodes"`
+}
+
+func (e *esAdminOper) NodesInfo(ctx context.Context, opts ...func(*NodesInfoRequest)) ([]*NodeInfo, error) {
+	api := e.client
+	o := append([]func(*NodesInfoRequest){api.Nodes.Info.WithContext(ctx)}, opts...)
+	resp, err := api.Nodes.Info(o...)
+	if err != nil {
+		return nil, err
+	}
+	respBody := &nodesInfoResponseBody{}
+	if err := unmarshallResponse(resp, respBody); err != nil {
+		return nil, err
+	}
+	nodes := make([]*NodeInfo, 0, len(respBody.Nodes))
+	for id, node := range respBody.Nodes {
+		node.ID = id
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes, nil
+}
+
+func (e *esAdminOper) NodesStats(ctx context.Context, opts ...func(*NodesStatsRequest)) ([]*NodeStats, error) {
+	api := e.client
+	o := append([]func(*NodesStatsRequest){
+		api.Nodes.Stats.WithContext(ctx),
+		api.Nodes.Stats.WithMetric("jvm", "os", "thread_pool", "breaker"),
+	}, opts...)
+	resp, err := api.Nodes.Stats(o...)
+	if err != nil {
+		return nil, err
+	}
+	respBody := &nodesStatsResponseBody{}
+	if err := unmarshallResponse(resp, respBody); err != nil {
+		return nil, err
+	}
+	nodes := make([]*NodeStats, 0, len(respBody.Nodes))
+	for id, node := range respBody.Nodes {
+		node.ID = id
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes, nil
+}
+
+func (e *esAdminOper) HotThreads(ctx context.Context, opts ...func(*NodesHotThreadsRequest)) (string, error) {
+	api := e.client
+	o := append([]func(*NodesHotThreadsRequest){api.Nodes.HotThreads.WithContext(ctx)}, opts...)
+	resp, err := api.Nodes.HotThreads(o...)
+	if err != nil {
+		return "", err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return "", newRespErr(resp)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -18,37 +18,73 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/nf-go/nfgo/nlog"
 	"github.com/nf-go/nfgo/nutil/ntemplate"
 )
 
-// ESOper -
-type ESOper interface {
-	ESClient() *Client
-
+// DocReader - the read operations of the documents by id.
+type DocReader interface {
 	Get(ctx context.Context, model interface{}, index string, id string, opts ...func(*GetRequest)) (interface{}, error)
 
 	MultiGet(ctx context.Context, model interface{}, index string, ids []string, opts ...func(*MgetRequest)) (interface{}, error)
 
+	Exists(ctx context.Context, index string, id string, opts ...func(*ExistsRequest)) (bool, error)
+
+	// GetSource decodes the _source of the document into the model without the metadata envelope of Get,
+	// the response error of 404 is returned if it's missing.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/docs-get.html#get-source-api.
+	GetSource(ctx context.Context, model interface{}, index string, id string, opts ...func(*GetSourceRequest)) (interface{}, error)
+	// SourceExists reports whether the document exists with its _source stored.
+	SourceExists(ctx context.Context, index string, id string, opts ...func(*ExistsSourceRequest)) (bool, error)
+}
+
+// DocWriter - the write operations of the documents.
+type DocWriter interface {
 	// Bulk allows to perform multiple index/update/delete operations in a single request.
 	//
 	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/docs-bulk.html.
 	Bulk(ctx context.Context, index string, writeReqBody func(ctx context.Context, buf *bytes.Buffer) error, opts ...func(*BulkRequest)) error
+	// BulkWithResponse is the Bulk returning the response, the request body exceeding the bulk chunk size is split into
+	// multiple requests sent in order, the items of their responses are aggregated in the order of the request body.
+	// The chunks are not atomic: if a chunk fails, the earlier ones are already applied and the later ones are not sent,
+	// the error is returned with the response of the items of the earlier chunks.
+	BulkWithResponse(ctx context.Context, index string, writeReqBody func(ctx context.Context, buf *bytes.Buffer) error, opts ...func(*BulkRequest)) (*BulkIndexerResponse, error)
 
 	Create(ctx context.Context, index string, id string, obj interface{}, opts ...func(*CreateRequest)) error
 	Index(ctx context.Context, index string, id string, obj interface{}, opts ...func(*IndexRequest)) error
-
+	// Update updates the document partially with the fields of the obj.
+	Update(ctx context.Context, index string, id string, obj interface{}, opts ...func(*UpdateRequest)) error
+	// UpdateScript updates the document by the script.
+	UpdateScript(ctx context.Context, index string, id string, script *Script, opts ...func(*UpdateRequest)) error
+	// Upsert updates the document partially with the fields of the obj, the obj is indexed if the document is missing.
+	Upsert(ctx context.Context, index string, id string, obj interface{}, opts ...func(*UpdateRequest)) error
+
+	// Delete deletes the document.
+	//
+	// Deprecated: the order of the id and the index differs from the other methods, use DeleteDoc instead.
 	Delete(ctx context.Context, id string, index string, opts ...func(*DeleteRequest)) error
+	// DeleteDoc deletes the document referenced by the ref.
+	DeleteDoc(ctx context.Context, ref DocRef, opts ...func(*DeleteRequest)) error
 	DeleteByQuery(ctx context.Context, query string, indexes []string, opts ...func(*DeleteByQueryRequest)) error
 	DeleteByQueryTemplate(ctx context.Context, t *TemplateParam, indexes []string, opts ...func(*DeleteByQueryRequest)) error
 
 	UpdateByQuery(ctx context.Context, query string, indexes []string, opts ...func(*UpdateByQueryRequest)) error
 	UpdateByQueryTemplate(ctx context.Context, t *TemplateParam, indexes []string, opts ...func(*UpdateByQueryRequest)) error
 
+	// NewBulkIndexer creates a bulk indexer tracked by the oper, the client of the oper is used if the config has no client.
+	NewBulkIndexer(config BulkIndexerConfig) (BulkIndexer, error)
+}
+
+// Searcher - the search operations.
+type Searcher interface {
 	Count(ctx context.Context, query string, indexes []string, opts ...func(*CountRequest)) (int64, error)
 	CountTemplate(ctx context.Context, t *TemplateParam, indexes []string, opts ...func(*CountRequest)) (int64, error)
 	Search(ctx context.Context, model interface{}, query string, indexes []string, opts ...func(*SearchRequest)) (interface{}, error)
@@ -58,36 +94,157 @@ type ESOper interface {
 	// We no longer recommend using the scroll API for deep pagination.
 	// If you need to preserve the index state while paging through more than 10,000 hits, use the search_after parameter with a point in time (PIT).
 	// See documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/paginate-search-results.html#scroll-search-results
+	//
+	// The defaults of the oper like the scroll keep-alive are applied before the opts, so the opts take precedence.
 	SearchByScrollID(ctx context.Context, model interface{}, scrollID string, opts ...func(*ScrollRequest)) (interface{}, error)
+	// ClearScroll releases the scroll contexts, the ones expired already are ignored.
+	ClearScroll(ctx context.Context, scrollIDs ...string) error
+
+	// WhyNotMatched reports which clauses of the query exclude the document, for debugging the searches.
+	WhyNotMatched(ctx context.Context, index string, id string, query string) (*MatchReport, error)
+
+	// RankEval evaluates the quality of the ranked search results over the rated requests.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/search-rank-eval.html.
+	RankEval(ctx context.Context, indexes []string, requests []*RatedRequest, metric RankEvalMetric, opts ...func(*RankEvalRequest)) (*RankEvalResult, error)
 }
 
-// NewESOper -
-func NewESOper(client *Client) ESOper {
-	return &esOper{
-		client: client,
+// ESOper - the operations of the documents, the searches and the indices. The services depending on a part of them,
+// e.g. the read-only ones, should depend on DocReader, DocWriter, Searcher or IndexAdmin instead.
+type ESOper interface {
+	ESClient() *Client
+
+	DocReader
+	DocWriter
+	Searcher
+	IndexAdmin
+
+	// Close drains and closes the bulk indexers created by the oper which are not closed yet.
+	Close(ctx context.Context) error
+	// Shutdown rejects the new document and search operations and bulk indexers with ErrShutdown, waits for the ones
+	// in flight, closes the bulk indexers and clears the scroll contexts opened by the oper, it's bounded by the ctx.
+	Shutdown(ctx context.Context) error
+	// Stats returns the stats of the document and search operations since the oper is created.
+	Stats() *StatsSnapshot
+	// DebugVars returns the runtime state of the oper to be published by expvar, e.g.
+	//
+	//	expvar.Publish("nes", expvar.Func(func() interface{} { return oper.DebugVars() }))
+	DebugVars() map[string]interface{}
+}
+
+// ESOperOption - the option of the oper.
+type ESOperOption func(*esOper)
+
+// WithRedactor - the redactor applied on the bodies sampled into the debug logs.
+func WithRedactor(r *Redactor) ESOperOption {
+	return func(e *esOper) {
+		e.redactor = r
 	}
 }
 
-// TemplateParam -
+// NewESOper - the oper of the client, the options default to the JSONCodec, nlog.Logger, the refresh policy of the cluster,
+// the DefaultMaxBodySize, the DefaultBulkChunkSize, the DefaultScrollKeepAlive, the DefaultDebugLogConfig and no
+// interceptors other than the ones of the Stats and the debug logs.
+func NewESOper(client *Client, opts ...ESOperOption) ESOper {
+	e := &esOper{
+		esAdminOper:   &esAdminOper{client: client, version: versionOf(client)},
+		client:        client,
+		maxBodySize:   DefaultMaxBodySize,
+		bulkChunkSize: DefaultBulkChunkSize,
+		codec:         JSONCodec,
+		logger:        nlog.Logger,
+
+		scrollKeepAlive: DefaultScrollKeepAlive,
+		stats:           newOperStats(),
+		scrolls:         &scrollTracker{expiries: map[string]time.Time{}},
+		gate:            newShutdownGate(),
+		debugLog:        DefaultDebugLogConfig,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	// the shutdown gate rejects the operations before the stats, which observe the latencies seen by the callers
+	interceptors := []Interceptor{e.gate.interceptor(), e.stats.interceptor()}
+	if e.debugLog != nil {
+		interceptors = append(interceptors, e.debugLogInterceptor(e.debugLog))
+	}
+	return newInterceptedESOper(e, e.renderTemplate, append(interceptors, e.interceptors...))
+}
+
+// TemplateParam - the template of the request body, the Query is used if it's not nil, which escapes the values.
 type TemplateParam struct {
 	Template *ntemplate.TextTemplate
+	Query    *QueryTemplate
 	Data     interface{}
 	Name     string
 }
 
 func (t *TemplateParam) execute() (string, error) {
+	if t.Query != nil {
+		if t.Name == "" {
+			return t.Query.Execute(t.Data)
+		}
+		return t.Query.ExecuteTemplate(t.Name, t.Data)
+	}
 	if t.Name == "" {
 		return t.Template.Execute(t.Data)
 	}
 	return t.Template.ExecuteTemplate(t.Name, t.Data)
 }
 
+// DocRef - the reference of a document.
+type DocRef struct {
+	Index string
+	ID    string
+}
+
+type countResponseBody struct {
+	Count  *int64      `json:"count"`
+	Shards *ShardStats `json:"_shards"`
+}
+
 type mgetRequestBody struct {
 	IDs []string `json:"ids"`
 }
 
 type esOper struct {
-	client *Client
+	// the index admin operations are provided by the admin oper
+	*esAdminOper
+
+	client   *Client
+	redactor *Redactor
+
+	mu           sync.Mutex
+	bulkIndexers map[*trackedBulkIndexer]struct{}
+
+	indexDefaults map[string]*IndexDefaults
+	maxBodySize   int
+	bulkChunkSize int
+
+	deadLetterSink DeadLetterSink
+
+	codec          Codec
+	logger         func(ctx context.Context) nlog.NLogger
+	defaultRefresh string
+	interceptors   []Interceptor
+	debugLog       *DebugLogConfig
+
+	scrollKeepAlive time.Duration
+
+	partialResultsError bool
+	renderOnly          func(ctx context.Context, req *RenderedRequest)
+
+	timePartitions map[string]*TimePartition
+
+	stats   *operStats
+	scrolls *scrollTracker
+	gate    *shutdownGate
+	hedging *hedging
+
+	lintMode  LintMode
+	rewriters []QueryRewriter
+
+	fieldTransformers map[string]FieldTransformer
 }
 
 func (e *esOper) ESClient() *Client {
@@ -96,12 +253,29 @@ func (e *esOper) ESClient() *Client {
 
 func (e *esOper) Get(ctx context.Context, model interface{}, index string, id string, opts ...func(*GetRequest)) (interface{}, error) {
 	api := e.client
-	o := append([]func(*GetRequest){api.Get.WithContext(ctx)}, opts...)
-	resp, err := api.Get(index, id, o...)
+	resp, err := e.hedge(ctx, func(ctx context.Context) (*Response, error) {
+		o := append([]func(*GetRequest){api.Get.WithContext(ctx)}, opts...)
+		return api.Get(index, id, o...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := e.decodeResponse(resp, model); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func (e *esOper) GetSource(ctx context.Context, model interface{}, index string, id string, opts ...func(*GetSourceRequest)) (interface{}, error) {
+	api := e.client
+	resp, err := e.hedge(ctx, func(ctx context.Context) (*Response, error) {
+		o := append([]func(*GetSourceRequest){api.GetSource.WithContext(ctx)}, opts...)
+		return api.GetSource(index, id, o...)
+	})
 	if err != nil {
 		return nil, err
 	}
-	if err := unmarshallResponse(resp, model); err != nil {
+	if err := e.decodeSource(resp, model); err != nil {
 		return nil, err
 	}
 	return model, nil
@@ -119,42 +293,33 @@ func (e *esOper) MultiGet(ctx context.Context, model interface{}, index string,
 	if err != nil {
 		return nil, err
 	}
-	if err := unmarshallResponse(resp, model); err != nil {
+	if err := e.decodeResponse(resp, model); err != nil {
 		return nil, err
 	}
 	return model, nil
 }
 
 func (e *esOper) Bulk(ctx context.Context, index string, writeReqBody func(ctx context.Context, buf *bytes.Buffer) error, opts ...func(*BulkRequest)) error {
-	api := e.client
-	var buf bytes.Buffer
-	if err := writeReqBody(ctx, &buf); err != nil {
-		return err
-	}
-	o := append([]func(*BulkRequest){api.Bulk.WithIndex(index), api.Bulk.WithContext(ctx)}, opts...)
-	resp, err := api.Bulk(&buf, o...)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.IsError() {
-		return newRespErr(resp)
-	}
-	return nil
+	_, err := e.BulkWithResponse(ctx, index, writeReqBody, opts...)
+	return err
 }
 
 func (e *esOper) Create(ctx context.Context, index string, id string, obj interface{}, opts ...func(*CreateRequest)) error {
 	body := &bytes.Buffer{}
-	if err := json.NewEncoder(body).Encode(obj); err != nil {
+	if err := e.encodeDoc(body, obj); err != nil {
+		return err
+	}
+	if err := e.checkBodySize("Create", body.Len()); err != nil {
 		return err
 	}
 	api := e.client
-	o := append([]func(*CreateRequest){api.API.Create.WithContext(ctx)}, opts...)
-	resp, err := api.Create(index, id, body, o...)
+	o := append([]func(*CreateRequest){api.API.Create.WithContext(ctx)}, e.createDefaults(index, id, obj)...)
+	o = append(o, opts...)
+	resp, err := api.Create(e.partitionIndex(index, obj), id, body, o...)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	defer closeResponse(resp)
 	if resp.IsError() {
 		return newRespErr(resp)
 	}
@@ -163,17 +328,21 @@ func (e *esOper) Create(ctx context.Context, index string, id string, obj interf
 
 func (e *esOper) Index(ctx context.Context, index string, id string, obj interface{}, opts ...func(*IndexRequest)) error {
 	body := &bytes.Buffer{}
-	if err := json.NewEncoder(body).Encode(obj); err != nil {
+	if err := e.encodeDoc(body, obj); err != nil {
+		return err
+	}
+	if err := e.checkBodySize("Index", body.Len()); err != nil {
 		return err
 	}
 
 	api := e.client
-	o := append([]func(*IndexRequest){api.API.Index.WithContext(ctx), api.API.Index.WithDocumentID(id)}, opts...)
-	resp, err := api.Index(index, body, o...)
+	o := append([]func(*IndexRequest){api.API.Index.WithContext(ctx), api.API.Index.WithDocumentID(id)}, e.indexRequestDefaults(index, id, obj)...)
+	o = append(o, opts...)
+	resp, err := api.Index(e.partitionIndex(index, obj), body, o...)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	defer closeResponse(resp)
 	if resp.IsError() {
 		return newRespErr(resp)
 	}
@@ -182,47 +351,120 @@ func (e *esOper) Index(ctx context.Context, index string, id string, obj interfa
 }
 
 type updateDoc struct {
-	Doc interface{} `json:"doc"`
+	Doc         interface{} `json:"doc,omitempty"`
+	DocAsUpsert bool        `json:"doc_as_upsert,omitempty"`
+	Script      *Script     `json:"script,omitempty"`
+}
+
+// Script - the script of the update, e.g. {Source: "ctx._source.count += params.n", Params: {"n": 1}}.
+type Script struct {
+	Source string                 `json:"source"`
+	Lang   string                 `json:"lang,omitempty"`
+	Params map[string]interface{} `json:"params,omitempty"`
 }
 
 func (e *esOper) Update(ctx context.Context, index string, id string, obj interface{}, opts ...func(*UpdateRequest)) error {
-	body := &bytes.Buffer{}
-	err := json.NewEncoder(body).Encode(&updateDoc{
-		Doc: obj,
-	})
+	return e.update(ctx, index, id, &updateDoc{Doc: obj}, opts...)
+}
+
+func (e *esOper) UpdateScript(ctx context.Context, index string, id string, script *Script, opts ...func(*UpdateRequest)) error {
+	return e.update(ctx, index, id, &updateDoc{Script: script}, opts...)
+}
+
+func (e *esOper) Upsert(ctx context.Context, index string, id string, obj interface{}, opts ...func(*UpdateRequest)) error {
+	return e.update(ctx, index, id, &updateDoc{Doc: obj, DocAsUpsert: true}, opts...)
+}
+
+func (e *esOper) update(ctx context.Context, index string, id string, reqBody *updateDoc, opts ...func(*UpdateRequest)) error {
+	doc, err := e.encodeFields(reqBody.Doc)
 	if err != nil {
 		return err
 	}
+	reqBody.Doc = doc
+	body := &bytes.Buffer{}
+	if err := e.encode(body, reqBody); err != nil {
+		return err
+	}
+	if err := e.checkBodySize("Update", body.Len()); err != nil {
+		return err
+	}
 	api := e.client
-	o := append([]func(*UpdateRequest){api.Update.WithContext(ctx)}, opts...)
+	o := append([]func(*UpdateRequest){api.Update.WithContext(ctx)}, e.updateDefaults(index, id)...)
+	o = append(o, opts...)
 	resp, err := api.Update(index, id, body, o...)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	defer closeResponse(resp)
 	if resp.IsError() {
 		return newRespErr(resp)
 	}
 	return nil
 }
 
+func (e *esOper) Exists(ctx context.Context, index string, id string, opts ...func(*ExistsRequest)) (bool, error) {
+	api := e.client
+	o := append([]func(*ExistsRequest){api.Exists.WithContext(ctx)}, opts...)
+	resp, err := api.Exists(index, id, o...)
+	if err != nil {
+		return false, err
+	}
+	defer closeResponse(resp)
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.IsError() {
+		return false, newRespErr(resp)
+	}
+	return true, nil
+}
+
+func (e *esOper) SourceExists(ctx context.Context, index string, id string, opts ...func(*ExistsSourceRequest)) (bool, error) {
+	api := e.client
+	o := append([]func(*ExistsSourceRequest){api.ExistsSource.WithContext(ctx)}, opts...)
+	resp, err := api.ExistsSource(index, id, o...)
+	if err != nil {
+		return false, err
+	}
+	defer closeResponse(resp)
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.IsError() {
+		return false, newRespErr(resp)
+	}
+	return true, nil
+}
+
 func (e *esOper) Delete(ctx context.Context, id string, index string, opts ...func(*DeleteRequest)) error {
 	api := e.client
-	o := append([]func(*DeleteRequest){api.Delete.WithContext(ctx)}, opts...)
+	o := append([]func(*DeleteRequest){api.Delete.WithContext(ctx)}, e.deleteDefaults(index, id)...)
+	o = append(o, opts...)
 	resp, err := api.Delete(index, id, o...)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	defer closeResponse(resp)
 	if resp.IsError() {
 		return newRespErr(resp)
 	}
 	return nil
 }
 
+func (e *esOper) DeleteDoc(ctx context.Context, ref DocRef, opts ...func(*DeleteRequest)) error {
+	return e.Delete(ctx, ref.ID, ref.Index, opts...)
+}
+
 func (e *esOper) DeleteByQuery(ctx context.Context, query string, indexes []string, opts ...func(*DeleteByQueryRequest)) error {
-	if nlog.IsLevelEnabled(nlog.DebugLevel) {
-		nlog.Logger(ctx).Debugf("nes es oper DeleteByQuery: the delete query is %s", query)
+	query, err := e.rewriteQuery(ctx, "DeleteByQuery", indexes, query)
+	if err != nil {
+		return err
+	}
+	if err := e.checkBodySize("DeleteByQuery", len(query)); err != nil {
+		return err
+	}
+	if ok, err := e.render(ctx, "DeleteByQuery", indexes, query); ok {
+		return err
 	}
 	api := e.client
 	o := append([]func(*DeleteByQueryRequest){api.DeleteByQuery.WithContext(ctx)}, opts...)
@@ -230,7 +472,7 @@ func (e *esOper) DeleteByQuery(ctx context.Context, query string, indexes []stri
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	defer closeResponse(resp)
 	if resp.IsError() {
 		return newRespErr(resp)
 	}
@@ -238,7 +480,7 @@ func (e *esOper) DeleteByQuery(ctx context.Context, query string, indexes []stri
 }
 
 func (e *esOper) DeleteByQueryTemplate(ctx context.Context, t *TemplateParam, indexes []string, opts ...func(*DeleteByQueryRequest)) error {
-	query, err := t.execute()
+	query, err := e.renderTemplate(ctx, "DeleteByQueryTemplate", t)
 	if err != nil {
 		return err
 	}
@@ -246,8 +488,15 @@ func (e *esOper) DeleteByQueryTemplate(ctx context.Context, t *TemplateParam, in
 }
 
 func (e *esOper) UpdateByQuery(ctx context.Context, query string, indexes []string, opts ...func(*UpdateByQueryRequest)) error {
-	if nlog.IsLevelEnabled(nlog.DebugLevel) {
-		nlog.Logger(ctx).Debugf("nes es oper UpdateByQuery: the update query is %s", query)
+	query, err := e.rewriteQuery(ctx, "UpdateByQuery", indexes, query)
+	if err != nil {
+		return err
+	}
+	if err := e.checkBodySize("UpdateByQuery", len(query)); err != nil {
+		return err
+	}
+	if ok, err := e.render(ctx, "UpdateByQuery", indexes, query); ok {
+		return err
 	}
 	api := e.client
 	o := append([]func(*UpdateByQueryRequest){api.UpdateByQuery.WithBody(strings.NewReader(query)), api.UpdateByQuery.WithContext(ctx)}, opts...)
@@ -255,7 +504,7 @@ func (e *esOper) UpdateByQuery(ctx context.Context, query string, indexes []stri
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	defer closeResponse(resp)
 	if resp.IsError() {
 		return newRespErr(resp)
 	}
@@ -263,7 +512,7 @@ func (e *esOper) UpdateByQuery(ctx context.Context, query string, indexes []stri
 }
 
 func (e *esOper) UpdateByQueryTemplate(ctx context.Context, t *TemplateParam, indexes []string, opts ...func(*UpdateByQueryRequest)) error {
-	query, err := t.execute()
+	query, err := e.renderTemplate(ctx, "UpdateByQueryTemplate", t)
 	if err != nil {
 		return err
 	}
@@ -271,8 +520,15 @@ func (e *esOper) UpdateByQueryTemplate(ctx context.Context, t *TemplateParam, in
 }
 
 func (e *esOper) Count(ctx context.Context, query string, indexes []string, opts ...func(*CountRequest)) (int64, error) {
-	if nlog.IsLevelEnabled(nlog.DebugLevel) {
-		nlog.Logger(ctx).Debugf("nes es oper Count: the count query is %s", query)
+	query, err := e.rewriteQuery(ctx, "Count", indexes, query)
+	if err != nil {
+		return 0, err
+	}
+	if err := e.checkBodySize("Count", len(query)); err != nil {
+		return 0, err
+	}
+	if ok, err := e.render(ctx, "Count", indexes, query); ok {
+		return 0, err
 	}
 	api := e.client
 	o := append([]func(*CountRequest){api.Count.WithContext(ctx), api.Count.WithIndex(indexes...), api.Count.WithBody(strings.NewReader(query))}, opts...)
@@ -280,20 +536,23 @@ func (e *esOper) Count(ctx context.Context, query string, indexes []string, opts
 	if err != nil {
 		return 0, err
 	}
-	defer resp.Body.Close()
+	defer closeResponse(resp)
 	if resp.IsError() {
 		return 0, newRespErr(resp)
 	}
 
-	var m map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+	respBody := &countResponseBody{}
+	if err := decodeSafely("Count", resp, func() error { return json.NewDecoder(resp.Body).Decode(respBody) }); err != nil {
 		return 0, err
 	}
-	return int64(m["count"].(float64)), nil
+	if respBody.Count == nil {
+		return 0, missingField("Count", resp, "count")
+	}
+	return *respBody.Count, e.checkShards(ctx, "Count", respBody.Shards)
 }
 
 func (e *esOper) CountTemplate(ctx context.Context, t *TemplateParam, indexes []string, opts ...func(*CountRequest)) (int64, error) {
-	query, err := t.execute()
+	query, err := e.renderTemplate(ctx, "CountTemplate", t)
 	if err != nil {
 		return 0, err
 	}
@@ -301,24 +560,53 @@ func (e *esOper) CountTemplate(ctx context.Context, t *TemplateParam, indexes []
 }
 
 func (e *esOper) Search(ctx context.Context, model interface{}, query string, indexes []string, opts ...func(*SearchRequest)) (interface{}, error) {
-	if nlog.IsLevelEnabled(nlog.DebugLevel) {
-		nlog.Logger(ctx).Debugf("nes es oper Search: the search query is %s", query)
+	query, err := e.rewriteQuery(ctx, "Search", indexes, query)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.checkBodySize("Search", len(query)); err != nil {
+		return nil, err
+	}
+	if ok, err := e.render(ctx, "Search", indexes, query); ok {
+		if err != nil {
+			return nil, err
+		}
+		return model, nil
 	}
 	api := e.client
-	o := append([]func(*SearchRequest){api.Search.WithContext(ctx), api.Search.WithIndex(indexes...), api.Search.WithBody(strings.NewReader(query))}, opts...)
-	resp, err := api.Search(o...)
+	perform := func(ctx context.Context) (*Response, error) {
+		o := append([]func(*SearchRequest){api.Search.WithContext(ctx), api.Search.WithIndex(indexes...), api.Search.WithBody(strings.NewReader(query))}, opts...)
+		return api.Search(o...)
+	}
+	var (
+		resp      *Response
+		keepAlive = searchScrollKeepAlive(opts)
+	)
+	// the scroll searches are not hedged since the backup ones would leave the scroll contexts behind
+	if keepAlive > 0 {
+		resp, err = perform(ctx)
+	} else {
+		resp, err = e.hedge(ctx, perform)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	if err := unmarshallResponse(resp, model); err != nil {
+	scrollID, err := e.decodeSearchResponse(ctx, "Search", resp, model)
+	if scrollID != "" {
+		e.scrolls.track("", scrollID, keepAlive)
+	}
+	if err != nil {
+		if IsPartialResults(err) {
+			return model, err
+		}
 		return nil, err
 	}
 	return model, nil
 }
 
 func (e *esOper) SearchTemplate(ctx context.Context, model interface{}, t *TemplateParam, indexes []string, opts ...func(*SearchRequest)) (interface{}, error) {
-	query, err := t.execute()
+	query, err := e.renderTemplate(ctx, "SearchTemplate", t)
 	if err != nil {
 		return 0, err
 	}
@@ -327,26 +615,66 @@ func (e *esOper) SearchTemplate(ctx context.Context, model interface{}, t *Templ
 
 func (e *esOper) SearchByScrollID(ctx context.Context, model interface{}, scrollID string, opts ...func(*ScrollRequest)) (interface{}, error) {
 	api := e.client
-	o := append([]func(*ScrollRequest){api.Scroll.WithContext(ctx), api.Scroll.WithScrollID(scrollID), api.Scroll.WithScroll(5 * time.Minute)}, opts...)
+	o := append([]func(*ScrollRequest){api.Scroll.WithContext(ctx), api.Scroll.WithScrollID(scrollID), api.Scroll.WithScroll(e.scrollKeepAlive)}, opts...)
 	resp, err := api.Scroll(o...)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := unmarshallResponse(resp, model); err != nil {
+	newScrollID, err := e.decodeSearchResponse(ctx, "SearchByScrollID", resp, model)
+	if newScrollID != "" {
+		e.scrolls.track(scrollID, newScrollID, e.scrollRequestKeepAlive(opts))
+	}
+	if err != nil {
+		if IsPartialResults(err) {
+			return model, err
+		}
 		return nil, err
 	}
 	return model, nil
 }
 
 func unmarshallResponse(resp *Response, dest interface{}) error {
-	defer resp.Body.Close()
+	defer closeResponse(resp)
 	if resp.IsError() {
 		return newRespErr(resp)
 	}
-	return json.NewDecoder(resp.Body).Decode(dest)
+	return decodeSafely("", resp, func() error {
+		return json.NewDecoder(resp.Body).Decode(dest)
+	})
+}
+
+// RespError - the error indicating the response status is a failure.
+type RespError struct {
+	StatusCode int
+	msg        string
+}
+
+func (e *RespError) Error() string {
+	return e.msg
+}
+
+// IsConflict reports whether the err is a response error of the status 409 Conflict.
+func IsConflict(err error) bool {
+	return respStatusCode(err) == http.StatusConflict
+}
+
+// IsNotFound reports whether the err is a response error of the status 404 Not Found.
+func IsNotFound(err error) bool {
+	return respStatusCode(err) == http.StatusNotFound
+}
+
+func respStatusCode(err error) int {
+	var respErr *RespError
+	if errors.As(err, &respErr) {
+		return respErr.StatusCode
+	}
+	return 0
 }
 
 func newRespErr(resp *Response) error {
-	return fmt.Errorf("esapi's response status indicates failure: %s, %s", resp.Status(), resp.String())
+	return &RespError{
+		StatusCode: resp.StatusCode,
+		msg:        fmt.Sprintf("esapi's response status indicates failure: %s, %s", resp.Status(), resp.String()),
+	}
 }
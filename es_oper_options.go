@@ -0,0 +1,139 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/nf-go/nfgo/nlog"
+)
+
+// Codec - encodes the documents written by the oper and decodes the models of Get, MultiGet and the searches,
+// the encoded documents must be JSON.
+type Codec interface {
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// JSONCodec - the default codec of the oper using encoding/json.
+var JSONCodec Codec = jsonCodec{}
+
+// WithCodec - the codec of the documents and the models, JSONCodec by default.
+func WithCodec(codec Codec) ESOperOption {
+	return func(e *esOper) {
+		if codec != nil {
+			e.codec = codec
+		}
+	}
+}
+
+// WithDefaultRefresh - the default refresh policy of the writes to all the indices, one of true, false and wait_for.
+// The refresh of WithIndexDefaults and of the call sites take precedence.
+func WithDefaultRefresh(refresh string) ESOperOption {
+	return func(e *esOper) {
+		e.defaultRefresh = refresh
+	}
+}
+
+// WithLogger - the logger of the oper, nlog.Logger by default.
+func WithLogger(logger func(ctx context.Context) nlog.NLogger) ESOperOption {
+	return func(e *esOper) {
+		if logger != nil {
+			e.logger = logger
+		}
+	}
+}
+
+// WithInterceptors - the interceptors wrapping the document and search operations of the oper,
+// the first one is the outermost.
+func WithInterceptors(interceptors ...Interceptor) ESOperOption {
+	return func(e *esOper) {
+		e.interceptors = append(e.interceptors, interceptors...)
+	}
+}
+
+// WithMetricsCollector - the collector observing the duration and the error of the document and search operations.
+func WithMetricsCollector(collector MetricsCollector) ESOperOption {
+	return func(e *esOper) {
+		if collector != nil {
+			e.interceptors = append(e.interceptors, metricsInterceptor(collector))
+		}
+	}
+}
+
+func (e *esOper) encode(w io.Writer, v interface{}) error {
+	return e.codec.Encode(w, v)
+}
+
+func (e *esOper) decodeResponse(resp *Response, model interface{}) error {
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	if len(e.fieldTransformers) == 0 {
+		return decodeSafely("", resp, func() error { return e.codec.Decode(resp.Body, model) })
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := decodeSafely("", resp, func() (err error) {
+		body, err = e.decodeFields(body)
+		return
+	}); err != nil {
+		return err
+	}
+	return decodeSafely("", resp, func() error { return e.codec.Decode(bytes.NewReader(body), model) })
+}
+
+// decodeSource decodes the response body of the _source endpoint, which is the _source itself.
+func (e *esOper) decodeSource(resp *Response, model interface{}) error {
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	if len(e.fieldTransformers) == 0 {
+		return decodeSafely("GetSource", resp, func() error { return e.codec.Decode(resp.Body, model) })
+	}
+	var source map[string]interface{}
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	if err := decodeSafely("GetSource", resp, func() error { return dec.Decode(&source) }); err != nil {
+		return err
+	}
+	if err := decodeSafely("GetSource", resp, func() error {
+		return e.decodeSources(map[string]interface{}{"_source": source})
+	}); err != nil {
+		return err
+	}
+	body, err := json.Marshal(source)
+	if err != nil {
+		return err
+	}
+	return decodeSafely("GetSource", resp, func() error { return e.codec.Decode(bytes.NewReader(body), model) })
+}
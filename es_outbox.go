@@ -0,0 +1,297 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nf-go/nfgo/nlog"
+	"github.com/nf-go/nfgo/nutil/graceful"
+)
+
+// OutboxEntry - a pending index operation recorded along with the write of the primary store.
+type OutboxEntry struct {
+	// ID is the id of the entry assigned by the store.
+	ID    string
+	Op    ChangeOp
+	Index string
+	DocID string
+	Doc   json.RawMessage
+	// Version is the version of the row like its update counter, used as the external version if it's positive.
+	Version   int64
+	CreatedAt time.Time
+}
+
+// OutboxStore - the store of the outbox entries, usually a table of the primary database.
+type OutboxStore interface {
+	// Append records the entries, it should join the transaction of the business write carried by the context.
+	Append(ctx context.Context, entries ...*OutboxEntry) error
+	// Pending returns at most limit pending entries in the order they're appended.
+	Pending(ctx context.Context, limit int) ([]*OutboxEntry, error)
+	// Ack removes the entries applied to the cluster.
+	Ack(ctx context.Context, entries []*OutboxEntry) error
+}
+
+// Outbox records the index operations into the store instead of writing the cluster directly,
+// so they're committed or rolled back along with the primary store and applied by the OutboxWorker.
+type Outbox struct {
+	store OutboxStore
+}
+
+// NewOutbox -
+func NewOutbox(store OutboxStore) *Outbox {
+	return &Outbox{store: store}
+}
+
+// Index records the document to be indexed.
+func (o *Outbox) Index(ctx context.Context, index string, id string, obj interface{}, version int64) error {
+	doc, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	return o.store.Append(ctx, &OutboxEntry{Op: ChangeOpUpsert, Index: index, DocID: id, Doc: doc, Version: version, CreatedAt: time.Now()})
+}
+
+// Delete records the document to be deleted.
+func (o *Outbox) Delete(ctx context.Context, index string, id string, version int64) error {
+	return o.store.Append(ctx, &OutboxEntry{Op: ChangeOpDelete, Index: index, DocID: id, Version: version, CreatedAt: time.Now()})
+}
+
+// NewOutboxWorker - the worker draining the pending entries of the store into the cluster by Bulk at the interval,
+// at most batchSize entries per request. The entries are acked once applied or discarded as stale versions,
+// the failed ones are retried in the next round. It's a graceful.ShutdownServer.
+func NewOutboxWorker(store OutboxStore, oper ESOper, interval time.Duration, batchSize int) graceful.ShutdownServer {
+	return &outboxWorker{
+		store:     store,
+		oper:      oper,
+		interval:  interval,
+		batchSize: batchSize,
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+}
+
+type outboxWorker struct {
+	store     OutboxStore
+	oper      ESOper
+	interval  time.Duration
+	batchSize int
+
+	once    sync.Once
+	done    chan struct{}
+	stopped chan struct{}
+
+	mu      sync.Mutex
+	serving bool
+}
+
+func (w *outboxWorker) Serve() error {
+	w.mu.Lock()
+	w.serving = true
+	w.mu.Unlock()
+	defer close(w.stopped)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		// drain the outbox until it's empty or an entry fails, the failed ones are retried after the interval
+		for {
+			n, err := w.drain(context.Background())
+			if err != nil {
+				nlog.Logger(context.Background()).Errorf("nes outbox worker: fail to drain the outbox: %s", err)
+			}
+			if err != nil || n < w.batchSize {
+				break
+			}
+			select {
+			case <-w.done:
+				return nil
+			default:
+			}
+		}
+		select {
+		case <-w.done:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain applies a batch of the pending entries and returns the number of the applied ones.
+func (w *outboxWorker) drain(ctx context.Context) (int, error) {
+	entries, err := w.store.Pending(ctx, w.batchSize)
+	if err != nil || len(entries) == 0 {
+		return 0, err
+	}
+	resp, err := w.oper.BulkWithResponse(ctx, "", func(ctx context.Context, buf *bytes.Buffer) error {
+		for _, e := range entries {
+			if err := writeOutboxEntry(buf, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Items) != len(entries) {
+		return 0, fmt.Errorf("nes outbox worker: %d items are responded for %d entries", len(resp.Items), len(entries))
+	}
+	applied := make([]*OutboxEntry, 0, len(entries))
+	for i, item := range resp.Items {
+		for _, res := range item {
+			if res.Status < 300 || IsStaleVersion(res) || (entries[i].Op == ChangeOpDelete && res.Status == http.StatusNotFound) {
+				applied = append(applied, entries[i])
+				continue
+			}
+			nlog.Logger(ctx).Warnf("nes outbox worker: fail to apply the entry %s of %s/%s: %s %s", entries[i].ID, entries[i].Index, entries[i].DocID, res.Error.Type, res.Error.Reason)
+		}
+	}
+	if len(applied) > 0 {
+		if err := w.store.Ack(ctx, applied); err != nil {
+			return 0, err
+		}
+	}
+	return len(applied), nil
+}
+
+func writeOutboxEntry(buf *bytes.Buffer, e *OutboxEntry) error {
+	if e.Op == ChangeOpUpsert && e.Version > 0 {
+		return WriteBulkIndexExternalVersion(buf, e.Index, e.DocID, e.Doc, e.Version, VersionTypeExternalGTE)
+	}
+	action := map[string]interface{}{"_index": e.Index, "_id": e.DocID}
+	if e.Version > 0 {
+		action["version"] = e.Version
+		action["version_type"] = VersionTypeExternalGTE
+	}
+	enc := json.NewEncoder(buf)
+	switch e.Op {
+	case ChangeOpUpsert:
+		if err := enc.Encode(map[string]interface{}{"index": action}); err != nil {
+			return err
+		}
+		return enc.Encode(e.Doc)
+	case ChangeOpDelete:
+		return enc.Encode(map[string]interface{}{"delete": action})
+	default:
+		return fmt.Errorf("nes outbox: unknown op %s of the entry %s", e.Op, e.ID)
+	}
+}
+
+func (w *outboxWorker) MustServe() {
+	if err := w.Serve(); err != nil {
+		nlog.Fatal("fail to serve the nes outbox worker: ", err)
+	}
+}
+
+// Shutdown stops draining after the round in flight, it returns at once if it's not served.
+func (w *outboxWorker) Shutdown(ctx context.Context) error {
+	w.once.Do(func() {
+		close(w.done)
+	})
+	w.mu.Lock()
+	serving := w.serving
+	w.mu.Unlock()
+	if !serving {
+		return nil
+	}
+	select {
+	case <-w.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RowVersion - the id and the version of a row of the primary store.
+type RowVersion struct {
+	ID      string
+	Version int64
+}
+
+// ReconcileSource - the rows of the primary store to be compared with the documents.
+type ReconcileSource interface {
+	// Rows returns at most limit rows whose ids are greater than the after id, in the order of the ids.
+	Rows(ctx context.Context, after string, limit int) ([]*RowVersion, error)
+}
+
+// ReconcileReport - the rows whose documents are missing or older than the rows.
+type ReconcileReport struct {
+	Checked int
+	Missing []string
+	Stale   []string
+}
+
+type mgetVersionsResponseBody struct {
+	Docs []struct {
+		ID      string `json:"_id"`
+		Found   bool   `json:"found"`
+		Version int64  `json:"_version"`
+	} `json:"docs"`
+}
+
+// Reconcile compares the rows of the source with the documents of the index by the id and the version page by page,
+// the documents should be written with the versions of the rows as the external versions.
+// The missing and stale ones can be repaired by recording them into the outbox again.
+func Reconcile(ctx context.Context, oper ESOper, index string, source ReconcileSource, pageSize int) (*ReconcileReport, error) {
+	report := &ReconcileReport{}
+	after := ""
+	for {
+		rows, err := source.Rows(ctx, after, pageSize)
+		if err != nil {
+			return report, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		ids := make([]string, 0, len(rows))
+		for _, r := range rows {
+			ids = append(ids, r.ID)
+		}
+		respBody := &mgetVersionsResponseBody{}
+		if _, err := oper.MultiGet(ctx, respBody, index, ids, func(r *MgetRequest) { r.Source = []string{"false"} }); err != nil {
+			return report, err
+		}
+		versions := make(map[string]int64, len(respBody.Docs))
+		for _, d := range respBody.Docs {
+			if d.Found {
+				versions[d.ID] = d.Version
+			}
+		}
+		for _, r := range rows {
+			v, ok := versions[r.ID]
+			switch {
+			case !ok:
+				report.Missing = append(report.Missing, r.ID)
+			case v < r.Version:
+				report.Stale = append(report.Stale, r.ID)
+			}
+		}
+		report.Checked += len(rows)
+		after = rows[len(rows)-1].ID
+		if len(rows) < pageSize {
+			break
+		}
+	}
+	sort.Strings(report.Missing)
+	sort.Strings(report.Stale)
+	return report, nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memOutboxStore - the outbox store in memory.
+type memOutboxStore struct {
+	mu      sync.Mutex
+	entries []*OutboxEntry
+	acked   []string
+}
+
+func (s *memOutboxStore) Append(ctx context.Context, entries ...*OutboxEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entries...)
+	return nil
+}
+
+func (s *memOutboxStore) Pending(ctx context.Context, limit int) ([]*OutboxEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) < limit {
+		limit = len(s.entries)
+	}
+	return append([]*OutboxEntry(nil), s.entries[:limit]...), nil
+}
+
+func (s *memOutboxStore) Ack(ctx context.Context, entries []*OutboxEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acked := map[*OutboxEntry]bool{}
+	for _, e := range entries {
+		acked[e] = true
+		s.acked = append(s.acked, e.ID)
+	}
+	pending := s.entries[:0]
+	for _, e := range s.entries {
+		if !acked[e] {
+			pending = append(pending, e)
+		}
+	}
+	s.entries = pending
+	return nil
+}
+
+func TestOutboxWorkerBacksOffTheFailedEntries(t *testing.T) {
+	oper, stub := newRouteOper(t, map[string]stubResponse{
+		"POST /_bulk": {http.StatusOK, `{"took":1,"errors":true,"items":[` +
+			`{"index":{"_index":"i","_id":"1","status":400,"error":{"type":"mapper_parsing_exception","reason":"bad"}}},` +
+			`{"index":{"_index":"i","_id":"2","status":400,"error":{"type":"mapper_parsing_exception","reason":"bad"}}}]}`},
+	})
+	store := &memOutboxStore{}
+	outbox := NewOutbox(store)
+	ctx := context.Background()
+	for _, id := range []string{"1", "2"} {
+		if err := outbox.Index(ctx, "i", id, map[string]string{"id": id}, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	worker := NewOutboxWorker(store, oper, 50*time.Millisecond, 2)
+	go worker.MustServe()
+	time.Sleep(220 * time.Millisecond)
+	if err := worker.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// a round per tick at most
+	if sent := len(stub.sent()); sent == 0 || sent > 6 {
+		t.Errorf("%d bulks are sent in 4 ticks, expected the failed entries retried once per tick", sent)
+	}
+	if len(store.acked) > 0 {
+		t.Errorf("the failed entries %v are acked", store.acked)
+	}
+}
+
+func TestOutboxWorkerAcksTheAppliedEntries(t *testing.T) {
+	oper, _ := newRouteOper(t, map[string]stubResponse{
+		"POST /_bulk": {http.StatusOK, `{"took":1,"errors":true,"items":[` +
+			`{"index":{"_index":"i","_id":"1","status":201}},` +
+			`{"delete":{"_index":"i","_id":"2","status":404}},` +
+			`{"index":{"_index":"i","_id":"3","status":409,"error":{"type":"version_conflict_engine_exception","reason":"stale"}}},` +
+			`{"index":{"_index":"i","_id":"4","status":400,"error":{"type":"mapper_parsing_exception","reason":"bad"}}}]}`},
+	})
+	store := &memOutboxStore{entries: []*OutboxEntry{
+		{ID: "e1", Op: ChangeOpUpsert, Index: "i", DocID: "1", Doc: []byte(`{}`)},
+		{ID: "e2", Op: ChangeOpDelete, Index: "i", DocID: "2"},
+		{ID: "e3", Op: ChangeOpUpsert, Index: "i", DocID: "3", Doc: []byte(`{}`), Version: 2},
+		{ID: "e4", Op: ChangeOpUpsert, Index: "i", DocID: "4", Doc: []byte(`{}`)},
+	}}
+	w := NewOutboxWorker(store, oper, time.Second, 4).(*outboxWorker)
+	n, err := w.drain(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("%d entries are applied, expected 3", n)
+	}
+	if len(store.entries) != 1 || store.entries[0].ID != "e4" {
+		t.Errorf("the pending entries are %v, expected e4", store.entries)
+	}
+}
+
+func TestOutboxWorkerShutdownWithoutServing(t *testing.T) {
+	w := NewOutboxWorker(&memOutboxStore{}, nil, time.Second, 10)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := w.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown before serving: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ShardFailure - a failure of a shard of the search or the count.
+type ShardFailure struct {
+	Shard  int    `json:"shard"`
+	Index  string `json:"index"`
+	Node   string `json:"node"`
+	Reason struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"reason"`
+}
+
+func (f *ShardFailure) String() string {
+	return fmt.Sprintf("[%s][%d] on node %s: %s: %s", f.Index, f.Shard, f.Node, f.Reason.Type, f.Reason.Reason)
+}
+
+// PartialResultsError - some shards of the search or the count failed, the results of the other shards are decoded
+// into the model returned with the error.
+type PartialResultsError struct {
+	Op     string
+	Shards *ShardStats
+}
+
+func (e *PartialResultsError) Error() string {
+	msg := fmt.Sprintf("nes: the results of %s are partial, %d of %d shards failed", e.Op, e.Shards.Failed, e.Shards.Total)
+	if len(e.Shards.Failures) > 0 {
+		msg += ", the first failure is " + e.Shards.Failures[0].String()
+	}
+	return msg
+}
+
+// IsPartialResults reports whether the error is caused by the failures of some shards.
+func IsPartialResults(err error) bool {
+	var e *PartialResultsError
+	return errors.As(err, &e)
+}
+
+// WithPartialResultsError - whether Search, SearchByScrollID and Count return a PartialResultsError along with the
+// partial results if some shards fail, the failures are logged as warnings by default.
+func WithPartialResultsError(enabled bool) ESOperOption {
+	return func(e *esOper) {
+		e.partialResultsError = enabled
+	}
+}
+
+// checkShards logs the failures of the shards or returns them as a PartialResultsError.
+func (e *esOper) checkShards(ctx context.Context, op string, shards *ShardStats) error {
+	if shards == nil || shards.Failed == 0 {
+		return nil
+	}
+	err := &PartialResultsError{Op: op, Shards: shards}
+	if e.partialResultsError {
+		return err
+	}
+	e.logger(ctx).Warnf("nes es oper %s: %s", op, err)
+	return nil
+}
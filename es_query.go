@@ -0,0 +1,52 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// AddFilterClauses adds the clauses to the filter context of the query in the request body,
+// the original query is kept as the must clause of a bool query.
+// The body can be empty, the match_all query is used in the case.
+func AddFilterClauses(body string, clauses ...interface{}) (string, error) {
+	if len(clauses) == 0 {
+		return body, nil
+	}
+	m := map[string]interface{}{}
+	if body != "" {
+		if err := json.Unmarshal([]byte(body), &m); err != nil {
+			return "", fmt.Errorf("nes: the request body is not a JSON object: %w", err)
+		}
+	}
+	query, ok := m["query"]
+	if !ok {
+		query = map[string]interface{}{"match_all": map[string]interface{}{}}
+	}
+	filter := make([]interface{}, 0, len(clauses))
+	filter = append(filter, clauses...)
+	m["query"] = map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must":   []interface{}{query},
+			"filter": filter,
+		},
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
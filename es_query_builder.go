@@ -0,0 +1,250 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"encoding/json"
+)
+
+// Query - a query clause of the request body, built by the functions like MatchQuery and BoolQuery.
+type Query struct {
+	kind string
+	// field is the field of the queries like match and term, whose params are under the field
+	field string
+	// value is the value under the field of the queries like terms, whose params are beside the field
+	value  interface{}
+	params map[string]interface{}
+}
+
+func newQuery(kind string, params map[string]interface{}) *Query {
+	return &Query{kind: kind, params: params}
+}
+
+func newFieldQuery(kind string, field string, params map[string]interface{}) *Query {
+	return &Query{kind: kind, field: field, params: params}
+}
+
+// MatchAllQuery - the match_all query.
+func MatchAllQuery() *Query {
+	return newQuery("match_all", map[string]interface{}{})
+}
+
+// MatchQuery - the match query of the text on the field.
+func MatchQuery(field string, text interface{}) *Query {
+	return newFieldQuery("match", field, map[string]interface{}{"query": text})
+}
+
+// MatchPhraseQuery - the match_phrase query of the text on the field.
+func MatchPhraseQuery(field string, text string) *Query {
+	return newFieldQuery("match_phrase", field, map[string]interface{}{"query": text})
+}
+
+// MultiMatchQuery - the multi_match query of the text on the fields like title^2.
+func MultiMatchQuery(text string, fields ...string) *Query {
+	return newQuery("multi_match", map[string]interface{}{"query": text, "fields": fields})
+}
+
+// TermQuery - the term query of the exact value of the field.
+func TermQuery(field string, value interface{}) *Query {
+	return newFieldQuery("term", field, map[string]interface{}{"value": value})
+}
+
+// TermsQuery - the terms query of any of the exact values of the field.
+func TermsQuery(field string, values ...interface{}) *Query {
+	return &Query{kind: "terms", field: field, value: values, params: map[string]interface{}{}}
+}
+
+// RangeQuery - the range query of the field, the bounds are set by Gt, Gte, Lt and Lte.
+func RangeQuery(field string) *Query {
+	return newFieldQuery("range", field, map[string]interface{}{})
+}
+
+// PrefixQuery - the prefix query of the field.
+func PrefixQuery(field string, prefix string) *Query {
+	return newFieldQuery("prefix", field, map[string]interface{}{"value": prefix})
+}
+
+// ExistsQuery - the query of the documents having a value of the field.
+func ExistsQuery(field string) *Query {
+	return newQuery("exists", map[string]interface{}{"field": field})
+}
+
+// IDsQuery - the query of the documents by the ids.
+func IDsQuery(ids ...string) *Query {
+	return newQuery("ids", map[string]interface{}{"values": ids})
+}
+
+// NestedQuery - the query of the nested objects at the path.
+func NestedQuery(path string, query *Query) *Query {
+	return newQuery("nested", map[string]interface{}{"path": path, "query": query})
+}
+
+// BoolQuery - the bool query, the clauses are added by Must, Should, Filter and MustNot.
+func BoolQuery() *Query {
+	return newQuery("bool", map[string]interface{}{})
+}
+
+// Must adds the clauses of the bool query which must match and contribute to the score.
+func (q *Query) Must(clauses ...*Query) *Query {
+	return q.appendClauses("must", clauses)
+}
+
+// Should adds the clauses of the bool query which should match.
+func (q *Query) Should(clauses ...*Query) *Query {
+	return q.appendClauses("should", clauses)
+}
+
+// Filter adds the clauses of the bool query which must match without scoring.
+func (q *Query) Filter(clauses ...*Query) *Query {
+	return q.appendClauses("filter", clauses)
+}
+
+// MustNot adds the clauses of the bool query which must not match.
+func (q *Query) MustNot(clauses ...*Query) *Query {
+	return q.appendClauses("must_not", clauses)
+}
+
+// MinimumShouldMatch - the number or the percentage of the should clauses which must match.
+func (q *Query) MinimumShouldMatch(v interface{}) *Query {
+	return q.With("minimum_should_match", v)
+}
+
+func (q *Query) appendClauses(occur string, clauses []*Query) *Query {
+	existing, _ := q.params[occur].([]*Query)
+	q.params[occur] = append(existing, clauses...)
+	return q
+}
+
+// Gt - the exclusive lower bound of the range query.
+func (q *Query) Gt(v interface{}) *Query {
+	return q.With("gt", v)
+}
+
+// Gte - the inclusive lower bound of the range query.
+func (q *Query) Gte(v interface{}) *Query {
+	return q.With("gte", v)
+}
+
+// Lt - the exclusive upper bound of the range query.
+func (q *Query) Lt(v interface{}) *Query {
+	return q.With("lt", v)
+}
+
+// Lte - the inclusive upper bound of the range query.
+func (q *Query) Lte(v interface{}) *Query {
+	return q.With("lte", v)
+}
+
+// Boost - the boost of the score of the query.
+func (q *Query) Boost(boost float64) *Query {
+	return q.With("boost", boost)
+}
+
+// Name tags the query with the _name, the names of the matched queries of each hit are in RawHit.MatchedQueries.
+func (q *Query) Name(name string) *Query {
+	return q.With("_name", name)
+}
+
+// With sets the parameter of the query.
+func (q *Query) With(param string, value interface{}) *Query {
+	q.params[param] = value
+	return q
+}
+
+// MarshalJSON -
+func (q *Query) MarshalJSON() ([]byte, error) {
+	switch {
+	case q.field == "":
+		return json.Marshal(map[string]interface{}{q.kind: q.params})
+	case q.value != nil:
+		body := make(map[string]interface{}, len(q.params)+1)
+		for k, v := range q.params {
+			body[k] = v
+		}
+		body[q.field] = q.value
+		return json.Marshal(map[string]interface{}{q.kind: body})
+	default:
+		return json.Marshal(map[string]interface{}{q.kind: map[string]interface{}{q.field: q.params}})
+	}
+}
+
+// String returns the JSON of the query.
+func (q *Query) String() string {
+	b, err := json.Marshal(q)
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
+
+// SearchBody - the request body of the search built from the query, the sorts and the aggregations.
+type SearchBody struct {
+	Query       *Query                 `json:"query,omitempty"`
+	Sort        []*Sort                `json:"sort,omitempty"`
+	From        *int                   `json:"from,omitempty"`
+	Size        *int                   `json:"size,omitempty"`
+	Source      interface{}            `json:"_source,omitempty"`
+	Aggs        map[string]Agg         `json:"aggs,omitempty"`
+	Highlight   map[string]interface{} `json:"highlight,omitempty"`
+	SearchAfter []interface{}          `json:"search_after,omitempty"`
+	TrackTotal  interface{}            `json:"track_total_hits,omitempty"`
+	MinScore    *float64               `json:"min_score,omitempty"`
+	PostFilter  *Query                 `json:"post_filter,omitempty"`
+	Rescore     []*Rescore             `json:"rescore,omitempty"`
+}
+
+// NewSearchBody - the search body of the query, the match_all query is used if the query is nil.
+func NewSearchBody(query *Query) *SearchBody {
+	if query == nil {
+		query = MatchAllQuery()
+	}
+	return &SearchBody{Query: query}
+}
+
+// WithSort sets the sorts of the search.
+func (b *SearchBody) WithSort(sorts ...*Sort) *SearchBody {
+	b.Sort = sorts
+	return b
+}
+
+// WithPage sets the from and the size of the search.
+func (b *SearchBody) WithPage(from int, size int) *SearchBody {
+	b.From, b.Size = &from, &size
+	return b
+}
+
+// WithAgg adds the aggregation named as the name.
+func (b *SearchBody) WithAgg(name string, agg Agg) *SearchBody {
+	if b.Aggs == nil {
+		b.Aggs = map[string]Agg{}
+	}
+	b.Aggs[name] = agg
+	return b
+}
+
+// WithRescore adds the rescorings applied in order.
+func (b *SearchBody) WithRescore(rescores ...*Rescore) *SearchBody {
+	b.Rescore = append(b.Rescore, rescores...)
+	return b
+}
+
+// JSON returns the request body for the searches.
+func (b *SearchBody) JSON() (string, error) {
+	bs, err := json.Marshal(b)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+)
+
+// QueryRewriter - rewrites the request bodies of Search, Count, DeleteByQuery, UpdateByQuery and their template methods
+// before they are sent, e.g. to inject the tenant filters, cap the time ranges or strip the disallowed clauses.
+// Op is the method name like Search.
+type QueryRewriter interface {
+	RewriteQuery(ctx context.Context, op string, indexes []string, query string) (string, error)
+}
+
+// QueryRewriterFunc - the func adapter of the QueryRewriter.
+type QueryRewriterFunc func(ctx context.Context, op string, indexes []string, query string) (string, error)
+
+// RewriteQuery -
+func (f QueryRewriterFunc) RewriteQuery(ctx context.Context, op string, indexes []string, query string) (string, error) {
+	return f(ctx, op, indexes, query)
+}
+
+// FilterRewriter - the rewriter adding the filter clause to the queries, the query is left untouched if the clause is nil.
+func FilterRewriter(clause func(ctx context.Context, op string, indexes []string) (interface{}, error)) QueryRewriter {
+	return QueryRewriterFunc(func(ctx context.Context, op string, indexes []string, query string) (string, error) {
+		c, err := clause(ctx, op, indexes)
+		if err != nil || c == nil {
+			return query, err
+		}
+		return AddFilterClauses(query, c)
+	})
+}
+
+// WithQueryRewriters - the rewriters of the request bodies applied in order.
+func WithQueryRewriters(rewriters ...QueryRewriter) ESOperOption {
+	return func(e *esOper) {
+		e.rewriters = append(e.rewriters, rewriters...)
+	}
+}
+
+func (e *esOper) rewriteQuery(ctx context.Context, op string, indexes []string, query string) (string, error) {
+	for _, r := range e.rewriters {
+		var err error
+		if query, err = r.RewriteQuery(ctx, op, indexes, query); err != nil {
+			return "", err
+		}
+	}
+	return query, nil
+}
+
+// requestRewriter - the opers rewriting the indexes and the queries before they are sent, the wrappers of the opers
+// delegate to the opers they wrap, so the callers like the SearchCache see the requests as they are sent.
+type requestRewriter interface {
+	rewriteRequest(ctx context.Context, op string, indexes []string, query string) ([]string, string, error)
+}
+
+// rewriteRequestBy rewrites the request as the oper would, it's returned as it is if the oper isn't a requestRewriter.
+func rewriteRequestBy(ctx context.Context, oper interface{}, op string, indexes []string, query string) ([]string, string, error) {
+	if r, ok := oper.(requestRewriter); ok {
+		return r.rewriteRequest(ctx, op, indexes, query)
+	}
+	return indexes, query, nil
+}
+
+func (e *esOper) rewriteRequest(ctx context.Context, op string, indexes []string, query string) ([]string, string, error) {
+	query, err := e.rewriteQuery(ctx, op, indexes, query)
+	return indexes, query, err
+}
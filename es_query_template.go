@@ -0,0 +1,260 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/nf-go/nfgo/nlog"
+)
+
+const (
+	// escapeFuncName is the func appended to the printed pipelines in the JSON strings of the query templates.
+	escapeFuncName = "_nesEscape"
+	// literalFuncName is the func appended to the printed pipelines out of the JSON strings.
+	literalFuncName = "_nesLiteral"
+)
+
+// RawJSON - the JSON written into the query templates as it is, the other values are JSON-escaped.
+type RawJSON string
+
+// QueryFuncMap - the funcs of the query templates besides the sprig ones:
+//
+//	jsonEscape  the JSON-escaped string without the quotes, e.g. "{{jsonEscape .Name}}"
+//	json        the JSON of the value, e.g. "terms": {"tags": {{json .Tags}}}
+//	termList    the JSON array of the strings, e.g. "terms": {"tags": {{termList .Tags}}}
+//	dateFormat  the time formatted by the layout, the strict_date_optional_time by default
+//	fuzzy       the fuzzy match query of the field, e.g. {{fuzzy "name" .Name}}
+//	quote       the JSON string of the values joined by the spaces, e.g. "name": {{quote .Name}}
+//	raw         the trusted string written as it is
+func QueryFuncMap() template.FuncMap {
+	m := sprig.TxtFuncMap()
+	m["jsonEscape"] = func(s string) RawJSON { return RawJSON(jsonEscape(s)) }
+	m["json"] = toRawJSON
+	m["toJson"] = toRawJSON
+	m["termList"] = func(values interface{}) (RawJSON, error) { return toRawJSON(values) }
+	m["dateFormat"] = dateFormat
+	m["fuzzy"] = func(field string, text string) (RawJSON, error) {
+		return toRawJSON(map[string]interface{}{
+			"match": map[string]interface{}{field: map[string]interface{}{"query": text, "fuzziness": "AUTO"}},
+		})
+	}
+	m["quote"] = quote
+	m["raw"] = func(s string) RawJSON { return RawJSON(s) }
+	m[escapeFuncName] = escapeValue
+	m[literalFuncName] = literalValue
+	return m
+}
+
+func jsonEscape(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b[1 : len(b)-1])
+}
+
+func toRawJSON(v interface{}) (RawJSON, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return RawJSON(b), nil
+}
+
+func dateFormat(v interface{}, layout ...string) (string, error) {
+	l := "2006-01-02T15:04:05.000Z07:00"
+	if len(layout) > 0 {
+		l = layout[0]
+	}
+	switch t := v.(type) {
+	case time.Time:
+		return t.Format(l), nil
+	case *time.Time:
+		return t.Format(l), nil
+	case int64:
+		return time.UnixMilli(t).Format(l), nil
+	default:
+		return "", fmt.Errorf("nes: dateFormat of the unsupported type %T", v)
+	}
+}
+
+// quote replaces the quote of sprig, whose Go quoted strings aren't always valid JSON.
+func quote(values ...interface{}) (RawJSON, error) {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			s = fmt.Sprint(v)
+		}
+		b, err := json.Marshal(s)
+		if err != nil {
+			return "", err
+		}
+		quoted = append(quoted, string(b))
+	}
+	return RawJSON(strings.Join(quoted, " ")), nil
+}
+
+// literalValue prints the values out of the JSON strings as the JSON literals except RawJSON, e.g. null of nil.
+func literalValue(v interface{}) (RawJSON, error) {
+	if x, ok := v.(RawJSON); ok {
+		return x, nil
+	}
+	return toRawJSON(v)
+}
+
+// escapeValue JSON-escapes the printed values in the JSON strings except RawJSON.
+func escapeValue(v interface{}) string {
+	switch x := v.(type) {
+	case RawJSON:
+		return string(x)
+	case string:
+		return jsonEscape(x)
+	case nil:
+		return ""
+	default:
+		return jsonEscape(fmt.Sprint(x))
+	}
+}
+
+// QueryTemplate - the text template of the request bodies with the QueryFuncMap, the values printed by the actions
+// are JSON-escaped automatically unless they're RawJSON, so "{{.Name}}" is always a valid JSON string, and the ones
+// printed out of the JSON strings are the JSON literals, e.g. {{.Name}} is the quoted string and {{.Missing}} null.
+type QueryTemplate struct {
+	tmpl *template.Template
+}
+
+// NewQueryTemplate -
+func NewQueryTemplate(name string, text string) (*QueryTemplate, error) {
+	tmpl, err := template.New(name).Funcs(QueryFuncMap()).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return newQueryTemplate(tmpl), nil
+}
+
+// MustNewQueryTemplate -
+func MustNewQueryTemplate(name string, text string) *QueryTemplate {
+	t, err := NewQueryTemplate(name, text)
+	if err != nil {
+		nlog.Fatal("fail to create query template: ", err)
+	}
+	return t
+}
+
+// ParseQueryTemplate -
+func ParseQueryTemplate(fsys fs.FS, patterns ...string) (*QueryTemplate, error) {
+	tmpl, err := template.New("$base").Funcs(QueryFuncMap()).ParseFS(fsys, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	return newQueryTemplate(tmpl), nil
+}
+
+// MustParseQueryTemplate -
+func MustParseQueryTemplate(fsys fs.FS, patterns ...string) *QueryTemplate {
+	t, err := ParseQueryTemplate(fsys, patterns...)
+	if err != nil {
+		nlog.Fatal("fail to parse query template: ", err)
+	}
+	return t
+}
+
+func newQueryTemplate(tmpl *template.Template) *QueryTemplate {
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			escapeNode(t.Tree, t.Tree.Root, false)
+		}
+	}
+	return &QueryTemplate{tmpl: tmpl}
+}
+
+// escapeNode appends the escape funcs to the pipelines printing the values, by whether they're in a JSON string,
+// and returns whether the node ends in a JSON string. The branches are assumed to end where they start.
+func escapeNode(tree *parse.Tree, node parse.Node, quoted bool) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return quoted
+		}
+		for _, child := range n.Nodes {
+			quoted = escapeNode(tree, child, quoted)
+		}
+	case *parse.TextNode:
+		return scanQuoted(n.Text, quoted)
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 {
+			return quoted
+		}
+		name := literalFuncName
+		if quoted {
+			name = escapeFuncName
+		}
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{parse.NewIdentifier(name).SetTree(tree).SetPos(n.Pos)},
+		})
+	case *parse.IfNode:
+		escapeNode(tree, n.ElseList, quoted)
+		return escapeNode(tree, n.List, quoted)
+	case *parse.RangeNode:
+		escapeNode(tree, n.ElseList, quoted)
+		return escapeNode(tree, n.List, quoted)
+	case *parse.WithNode:
+		escapeNode(tree, n.ElseList, quoted)
+		return escapeNode(tree, n.List, quoted)
+	}
+	return quoted
+}
+
+// scanQuoted returns whether the text ends in a JSON string, it starts in a JSON string if quoted.
+func scanQuoted(text []byte, quoted bool) bool {
+	for i := 0; i < len(text); i++ {
+		switch {
+		case quoted && text[i] == '\\':
+			i++
+		case text[i] == '"':
+			quoted = !quoted
+		}
+	}
+	return quoted
+}
+
+// Execute -
+func (t *QueryTemplate) Execute(data interface{}) (string, error) {
+	var sb strings.Builder
+	if err := t.tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// ExecuteTemplate -
+func (t *QueryTemplate) ExecuteTemplate(name string, data interface{}) (string, error) {
+	var sb strings.Builder
+	if err := t.tmpl.ExecuteTemplate(&sb, name, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
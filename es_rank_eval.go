@@ -0,0 +1,125 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+// RatedDocument - the rating of a document for a search request.
+type RatedDocument struct {
+	Index  string `json:"_index"`
+	ID     string `json:"_id"`
+	Rating int    `json:"rating"`
+}
+
+// RatedRequest - a search request with the ratings of the documents expected in its results,
+// either Request or TemplateID with Params is set.
+type RatedRequest struct {
+	ID         string                 `json:"id"`
+	Request    interface{}            `json:"request,omitempty"`
+	TemplateID string                 `json:"template_id,omitempty"`
+	Params     map[string]interface{} `json:"params,omitempty"`
+	Ratings    []*RatedDocument       `json:"ratings"`
+}
+
+// RankEvalMetric - the metric of the rank evaluation, built by the functions like PrecisionAtK.
+type RankEvalMetric map[string]interface{}
+
+// PrecisionAtK - the fraction of the relevant documents in the top k results,
+// the documents rated at least threshold are relevant.
+func PrecisionAtK(k int, threshold int, ignoreUnlabeled bool) RankEvalMetric {
+	return RankEvalMetric{"precision": map[string]interface{}{
+		"k": k, "relevant_rating_threshold": threshold, "ignore_unlabeled": ignoreUnlabeled,
+	}}
+}
+
+// RecallAtK - the fraction of the relevant documents found in the top k results.
+func RecallAtK(k int, threshold int) RankEvalMetric {
+	return RankEvalMetric{"recall": map[string]interface{}{
+		"k": k, "relevant_rating_threshold": threshold,
+	}}
+}
+
+// MeanReciprocalRank - the reciprocal of the rank of the first relevant document in the top k results.
+func MeanReciprocalRank(k int, threshold int) RankEvalMetric {
+	return RankEvalMetric{"mean_reciprocal_rank": map[string]interface{}{
+		"k": k, "relevant_rating_threshold": threshold,
+	}}
+}
+
+// DCG - the discounted cumulative gain of the top k results, normalized to nDCG if normalize is true.
+func DCG(k int, normalize bool) RankEvalMetric {
+	return RankEvalMetric{"dcg": map[string]interface{}{
+		"k": k, "normalize": normalize,
+	}}
+}
+
+// ExpectedReciprocalRank - the expected reciprocal rank of the top k results with the max rating.
+func ExpectedReciprocalRank(k int, maxRelevance int) RankEvalMetric {
+	return RankEvalMetric{"expected_reciprocal_rank": map[string]interface{}{
+		"k": k, "maximum_relevance": maxRelevance,
+	}}
+}
+
+// RankEvalResult - the result of the rank evaluation, Details are keyed by the ids of the rated requests.
+type RankEvalResult struct {
+	MetricScore float64                         `json:"metric_score"`
+	Details     map[string]*RankEvalQueryResult `json:"details"`
+	Failures    map[string]interface{}          `json:"failures"`
+}
+
+// RankEvalQueryResult - the result of a rated request.
+type RankEvalQueryResult struct {
+	MetricScore   float64                           `json:"metric_score"`
+	UnratedDocs   []*RatedDocument                  `json:"unrated_docs"`
+	Hits          []*RankEvalHit                    `json:"hits"`
+	MetricDetails map[string]map[string]interface{} `json:"metric_details"`
+}
+
+// RankEvalHit - a hit of the rated request, Rating is nil if the document is unrated.
+type RankEvalHit struct {
+	Hit struct {
+		Index string  `json:"_index"`
+		ID    string  `json:"_id"`
+		Score float64 `json:"_score"`
+	} `json:"hit"`
+	Rating *int `json:"rating"`
+}
+
+type rankEvalRequestBody struct {
+	Requests []*RatedRequest `json:"requests"`
+	Metric   RankEvalMetric  `json:"metric"`
+}
+
+func (e *esOper) RankEval(ctx context.Context, indexes []string, requests []*RatedRequest, metric RankEvalMetric, opts ...func(*RankEvalRequest)) (*RankEvalResult, error) {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(&rankEvalRequestBody{Requests: requests, Metric: metric}); err != nil {
+		return nil, err
+	}
+	api := e.client
+	o := append([]func(*RankEvalRequest){api.RankEval.WithContext(ctx), api.RankEval.WithIndex(indexes...)}, opts...)
+	resp, err := api.RankEval(body, o...)
+	if err != nil {
+		return nil, err
+	}
+	result := &RankEvalResult{}
+	if err := unmarshallResponse(resp, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,242 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRateSampleIndex - the default meta index of the last samples of the rate watches.
+const DefaultRateSampleIndex = ".nes-rate-samples"
+
+// RateSample - a value of a rate watch at a time.
+type RateSample struct {
+	Name  string    `json:"name"`
+	Value float64   `json:"value"`
+	At    time.Time `json:"at"`
+}
+
+// RateStore - keeps the last samples of the rate watches.
+type RateStore interface {
+	// Last returns the last sample of the watch, nil if it's missing.
+	Last(ctx context.Context, name string) (*RateSample, error)
+	Put(ctx context.Context, sample *RateSample) error
+}
+
+// NewMemoryRateStore - the store of the samples in the memory, they are lost when the app restarts.
+func NewMemoryRateStore() RateStore {
+	return &memoryRateStore{samples: map[string]*RateSample{}}
+}
+
+type memoryRateStore struct {
+	mu      sync.Mutex
+	samples map[string]*RateSample
+}
+
+func (s *memoryRateStore) Last(ctx context.Context, name string) (*RateSample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.samples[name], nil
+}
+
+func (s *memoryRateStore) Put(ctx context.Context, sample *RateSample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples[sample.Name] = sample
+	return nil
+}
+
+// NewIndexRateStore - the store of the samples in the meta index, DefaultRateSampleIndex if it's empty,
+// so the watches of the app instances share the samples.
+func NewIndexRateStore(oper ESOper, index string) RateStore {
+	if index == "" {
+		index = DefaultRateSampleIndex
+	}
+	return &indexRateStore{oper: oper, index: index}
+}
+
+type indexRateStore struct {
+	oper  ESOper
+	index string
+}
+
+func (s *indexRateStore) Last(ctx context.Context, name string) (*RateSample, error) {
+	doc := &getSourceResponseBody{}
+	if _, err := s.oper.Get(ctx, doc, s.index, name); err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	sample := &RateSample{}
+	if err := json.Unmarshal(doc.Source, sample); err != nil {
+		return nil, err
+	}
+	return sample, nil
+}
+
+func (s *indexRateStore) Put(ctx context.Context, sample *RateSample) error {
+	return s.oper.Index(ctx, s.index, sample.Name, sample)
+}
+
+// RateThresholds - the thresholds of the changes between the samples, the zero values are not checked.
+// The decreases are the positive amounts, the ratios are relative to the previous values, e.g. 0.5 is 50%.
+type RateThresholds struct {
+	MaxIncrease      float64
+	MaxDecrease      float64
+	MaxIncreaseRatio float64
+	MaxDecreaseRatio float64
+}
+
+// RateAlert - the change of a rate watch exceeding the thresholds.
+type RateAlert struct {
+	Name     string
+	Previous *RateSample
+	Current  *RateSample
+	// Delta is the change from the previous value, normalized by the Per of the watch.
+	Delta float64
+	// Ratio is the Delta relative to the previous value, NaN if the previous value is 0.
+	Ratio float64
+	// Exceeded are the thresholds exceeded like max_increase.
+	Exceeded []string
+}
+
+func (a *RateAlert) String() string {
+	return fmt.Sprintf("%s: %g -> %g (delta %g, ratio %g) exceeds %s", a.Name, a.Previous.Value, a.Current.Value,
+		a.Delta, a.Ratio, strings.Join(a.Exceeded, ", "))
+}
+
+// RateWatch - watches the changes of a value like the count of the events, the Value is sampled at each Check and
+// compared with the previous sample in the Store.
+type RateWatch struct {
+	Name string
+	// Value samples the value, e.g. CountValue or StatValue.
+	Value      func(ctx context.Context) (float64, error)
+	Store      RateStore
+	Thresholds RateThresholds
+	// Per normalizes the deltas to the changes per the duration by the time between the samples if it's positive,
+	// so the thresholds don't depend on the intervals of the checks.
+	Per time.Duration
+	// OnAlert is called with the alerts of the scheduled checks.
+	OnAlert func(ctx context.Context, alert *RateAlert)
+}
+
+// CountValue - the value of the rate watch counting the documents matching the query.
+func CountValue(oper Searcher, query string, indexes []string, opts ...func(*CountRequest)) func(ctx context.Context) (float64, error) {
+	return func(ctx context.Context) (float64, error) {
+		count, err := oper.Count(ctx, query, indexes, opts...)
+		return float64(count), err
+	}
+}
+
+// StatValue - the value of the rate watch of a statistic of the field, one of count, min, max, avg and sum,
+// the missing min, max and avg of no documents are 0.
+func StatValue(oper Searcher, index string, field string, query string, stat string) func(ctx context.Context) (float64, error) {
+	return func(ctx context.Context) (float64, error) {
+		stats, err := FieldStats(ctx, oper, index, field, query)
+		if err != nil {
+			return 0, err
+		}
+		var v *float64
+		switch stat {
+		case "count":
+			return float64(stats.Count), nil
+		case "sum":
+			return stats.Sum, nil
+		case "min":
+			v = stats.Min
+		case "max":
+			v = stats.Max
+		case "avg":
+			v = stats.Avg
+		default:
+			return 0, fmt.Errorf("nes: unknown stat %s", stat)
+		}
+		if v == nil {
+			return 0, nil
+		}
+		return *v, nil
+	}
+}
+
+// Check samples the value, stores the sample and returns the alert if the change from the previous sample exceeds
+// the thresholds, nil otherwise. The first sample has no alert.
+func (w *RateWatch) Check(ctx context.Context) (*RateAlert, error) {
+	if w.Name == "" || w.Value == nil || w.Store == nil {
+		return nil, errors.New("nes rate watch: the name, the value and the store are required")
+	}
+	value, err := w.Value(ctx)
+	if err != nil {
+		return nil, err
+	}
+	current := &RateSample{Name: w.Name, Value: value, At: time.Now()}
+	previous, err := w.Store.Last(ctx, w.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Store.Put(ctx, current); err != nil {
+		return nil, err
+	}
+	if previous == nil {
+		return nil, nil
+	}
+	alert := &RateAlert{Name: w.Name, Previous: previous, Current: current, Delta: current.Value - previous.Value, Ratio: math.NaN()}
+	if elapsed := current.At.Sub(previous.At); w.Per > 0 && elapsed > 0 {
+		alert.Delta = alert.Delta * float64(w.Per) / float64(elapsed)
+	}
+	if previous.Value != 0 {
+		alert.Ratio = alert.Delta / math.Abs(previous.Value)
+	}
+	t := w.Thresholds
+	if t.MaxIncrease > 0 && alert.Delta > t.MaxIncrease {
+		alert.Exceeded = append(alert.Exceeded, "max_increase")
+	}
+	if t.MaxDecrease > 0 && -alert.Delta > t.MaxDecrease {
+		alert.Exceeded = append(alert.Exceeded, "max_decrease")
+	}
+	if t.MaxIncreaseRatio > 0 && alert.Ratio > t.MaxIncreaseRatio {
+		alert.Exceeded = append(alert.Exceeded, "max_increase_ratio")
+	}
+	if t.MaxDecreaseRatio > 0 && -alert.Ratio > t.MaxDecreaseRatio {
+		alert.Exceeded = append(alert.Exceeded, "max_decrease_ratio")
+	}
+	if len(alert.Exceeded) == 0 {
+		return nil, nil
+	}
+	return alert, nil
+}
+
+// Scheduled - the query of the QueryScheduler checking the watch on the schedule, the alerts are passed to OnAlert.
+func (w *RateWatch) Scheduled(schedule string) *ScheduledQuery {
+	return &ScheduledQuery{
+		Name:     w.Name,
+		Schedule: schedule,
+		Run: func(ctx context.Context) (interface{}, error) {
+			return w.Check(ctx)
+		},
+		Sink: QuerySinkFunc(func(ctx context.Context, name string, result interface{}, err error) {
+			if alert, ok := result.(*RateAlert); ok && alert != nil && w.OnAlert != nil {
+				w.OnAlert(ctx, alert)
+			}
+		}),
+	}
+}
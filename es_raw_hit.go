@@ -0,0 +1,83 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// RawHit - a search hit with its metadata, the _source is kept raw to be decoded by Decode.
+type RawHit struct {
+	Index     string                     `json:"_index"`
+	ID        string                     `json:"_id"`
+	Score     *float64                   `json:"_score"`
+	Routing   string                     `json:"_routing,omitempty"`
+	Sort      []interface{}              `json:"sort,omitempty"`
+	Highlight map[string][]string        `json:"highlight,omitempty"`
+	InnerHits map[string]json.RawMessage `json:"inner_hits,omitempty"`
+	// MatchedQueries are the names of the queries tagged by Query.Name the hit matches.
+	MatchedQueries []string        `json:"matched_queries,omitempty"`
+	Source         json.RawMessage `json:"_source,omitempty"`
+	// Fields are the values of the stored fields, the docvalue fields and the fields option keyed by the field names,
+	// the values are always arrays.
+	Fields map[string][]interface{} `json:"fields,omitempty"`
+}
+
+// Decode decodes the source of the hit into the model.
+func (h *RawHit) Decode(model interface{}) error {
+	return json.Unmarshal(h.Source, model)
+}
+
+// Field returns the first value of the field in the Fields, nil if it's missing.
+func (h *RawHit) Field(name string) interface{} {
+	if values := h.Fields[name]; len(values) > 0 {
+		return values[0]
+	}
+	return nil
+}
+
+// DecodeFields decodes the Fields into the model like the _source, the single values are unwrapped from their arrays,
+// so the JSON tags of the model are the field names like user.name.
+func (h *RawHit) DecodeFields(model interface{}) error {
+	m := make(map[string]interface{}, len(h.Fields))
+	for name, values := range h.Fields {
+		if len(values) == 1 {
+			m[name] = values[0]
+		} else {
+			m[name] = values
+		}
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, model)
+}
+
+type rawHitsResponseBody struct {
+	Hits struct {
+		Hits []*RawHit `json:"hits"`
+	} `json:"hits"`
+}
+
+// SearchRaw searches the hits of the query with their metadata but without decoding their sources.
+func SearchRaw(ctx context.Context, oper Searcher, query string, indexes []string, opts ...func(*SearchRequest)) ([]*RawHit, error) {
+	respBody := &rawHitsResponseBody{}
+	if _, err := oper.Search(ctx, respBody, query, indexes, opts...); err != nil {
+		return nil, err
+	}
+	return respBody.Hits.Hits, nil
+}
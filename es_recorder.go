@@ -0,0 +1,307 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nf-go/nfgo/nlog"
+)
+
+// Recording - a request and its response captured by the Recorder, the bodies larger than the max body size of
+// the recorder are truncated after they're redacted. The truncated line of a response larger than the max body size
+// can't be parsed, so it's replaced by TruncatedBodyMark when the redactor redacts the fields.
+type Recording struct {
+	Time     time.Time     `json:"time"`
+	Method   string        `json:"method"`
+	Path     string        `json:"path"`
+	Query    string        `json:"query,omitempty"`
+	Header   http.Header   `json:"header,omitempty"`
+	Body     string        `json:"body,omitempty"`
+	Status   int           `json:"status,omitempty"`
+	Response string        `json:"response,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// TruncatedBodyMark - the mark of the truncated line of a response which isn't recorded since it can't be redacted.
+const TruncatedBodyMark = "...(truncated)"
+
+// recordedHeaders are the headers recorded, the credentials are never recorded.
+var recordedHeaders = []string{"Content-Type", "Accept", HeaderOpaqueID}
+
+// RecorderConfig -
+type RecorderConfig struct {
+	// SampleRate is the ratio of the requests recorded, 1 by default.
+	SampleRate float64
+	// Filter selects the requests to be recorded, e.g. the searches of an index, all of them by default.
+	Filter func(req *http.Request) bool
+	// Redactor redacts the bodies of the requests and the responses.
+	Redactor *Redactor
+	// MaxBodySize is the max size of the bodies recorded, 1MB by default.
+	MaxBodySize int
+}
+
+// Recorder - records the requests of the client and their responses as NDJSON for debugging, the recordings are
+// written once the response bodies are closed. They can be resent by Replay.
+type Recorder struct {
+	config RecorderConfig
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewRecorder - the recorder writing the recordings to the writer.
+func NewRecorder(w io.Writer, config *RecorderConfig) *Recorder {
+	c := RecorderConfig{}
+	if config != nil {
+		c = *config
+	}
+	if c.SampleRate <= 0 || c.SampleRate > 1 {
+		c.SampleRate = 1
+	}
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = 1 << 20
+	}
+	return &Recorder{config: c, w: w}
+}
+
+// NewFileRecorder - the recorder appending the recordings to the local NDJSON file, the file is created if it's missing.
+func NewFileRecorder(path string, config *RecorderConfig) (*Recorder, io.Closer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewRecorder(f, config), f, nil
+}
+
+// WithRecorder - the recorder of the requests of the client.
+func WithRecorder(recorder *Recorder) ESClientOption {
+	return func(t *esTransport) {
+		t.recorder = recorder
+	}
+}
+
+func (r *Recorder) sampled(req *http.Request) bool {
+	if r.config.SampleRate < 1 && rand.Float64() >= r.config.SampleRate {
+		return false
+	}
+	return r.config.Filter == nil || r.config.Filter(req)
+}
+
+// redact redacts the body and truncates it to the max body size, each line of the NDJSON bodies like the bulk ones
+// is redacted alone. The last line of the truncated body is incomplete, it's replaced by TruncatedBodyMark if the
+// redactor redacts the fields, which can't be found in the invalid JSON.
+func (r *Recorder) redact(body []byte, truncated bool) string {
+	redactor := r.config.Redactor
+	if redactor != nil {
+		lines := strings.Split(string(body), "\n")
+		for i, line := range lines {
+			switch {
+			case line == "":
+			case truncated && i == len(lines)-1 && len(redactor.fields) > 0:
+				lines[i] = TruncatedBodyMark
+			default:
+				lines[i] = redactor.Redact(line)
+			}
+		}
+		body = []byte(strings.Join(lines, "\n"))
+	}
+	if len(body) > r.config.MaxBodySize {
+		body = body[:r.config.MaxBodySize]
+	}
+	return string(body)
+}
+
+func (r *Recorder) write(rec *Recording) {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.w.Write(append(b, '\n')); err != nil {
+		nlog.Logger(context.Background()).Warnf("nes recorder: fail to write the recording: %s", err)
+	}
+}
+
+// roundTrip performs the request by the base transport and records it if it's sampled.
+func (r *Recorder) roundTrip(base http.RoundTripper, req *http.Request) (*http.Response, error) {
+	if !r.sampled(req) {
+		return base.RoundTrip(req)
+	}
+	rec := &Recording{Time: time.Now(), Method: req.Method, Path: req.URL.Path, Query: req.URL.RawQuery, Header: http.Header{}}
+	for _, k := range recordedHeaders {
+		if v := req.Header.Get(k); v != "" {
+			rec.Header.Set(k, v)
+		}
+	}
+	if req.Body != nil {
+		body, err := readRequestBody(req)
+		if err != nil {
+			return nil, err
+		}
+		req = body.req
+		rec.Body = r.redact(body.data, false)
+	}
+	resp, err := base.RoundTrip(req)
+	rec.Duration = time.Since(rec.Time)
+	if err != nil {
+		rec.Error = err.Error()
+		r.write(rec)
+		return resp, err
+	}
+	rec.Status = resp.StatusCode
+	resp.Body = &recordingBody{ReadCloser: resp.Body, recorder: r, rec: rec}
+	return resp, nil
+}
+
+type requestBody struct {
+	req  *http.Request
+	data []byte
+}
+
+// readRequestBody reads the body of the request, which is replaced by a copy unless it can be got again.
+func readRequestBody(req *http.Request) (*requestBody, error) {
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		return &requestBody{req: req, data: data}, err
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return &requestBody{req: req, data: data}, nil
+}
+
+// recordingBody captures the response body read by the client and writes the recording once it's closed.
+type recordingBody struct {
+	io.ReadCloser
+	recorder *Recorder
+	rec      *Recording
+	buf      bytes.Buffer
+	once     sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	// a byte more than the max body size is captured to know whether it's truncated
+	if remaining := b.recorder.config.MaxBodySize + 1 - b.buf.Len(); remaining > 0 {
+		if n < remaining {
+			remaining = n
+		}
+		b.buf.Write(p[:remaining])
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	b.once.Do(func() {
+		b.rec.Response = b.recorder.redact(b.buf.Bytes(), b.buf.Len() > b.recorder.config.MaxBodySize)
+		b.recorder.write(b.rec)
+	})
+	return b.ReadCloser.Close()
+}
+
+// ReplayResult - the response of a recording resent by Replay.
+type ReplayResult struct {
+	Recording *Recording
+	Status    int
+	Response  string
+	Duration  time.Duration
+	Err       error
+}
+
+// StatusChanged reports whether the status of the response differs from the recorded one.
+func (r *ReplayResult) StatusChanged() bool {
+	return r.Err == nil && r.Status != r.Recording.Status
+}
+
+// Replay resends the recordings read from the NDJSON reader by the client in order, e.g. against a test cluster,
+// the results are passed to the callback, which stops the replay if it returns an error. The redacted values are
+// resent as they are, and the writes are resent as well unless they are filtered out.
+func Replay(ctx context.Context, client *Client, r io.Reader, filter func(rec *Recording) bool, callback func(res *ReplayResult) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 64<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		rec := &Recording{}
+		if err := json.Unmarshal(line, rec); err != nil {
+			return err
+		}
+		if filter != nil && !filter(rec) {
+			continue
+		}
+		if err := callback(replay(ctx, client, rec)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func replay(ctx context.Context, client *Client, rec *Recording) *ReplayResult {
+	res := &ReplayResult{Recording: rec}
+	target := rec.Path
+	if rec.Query != "" {
+		target += "?" + rec.Query
+	}
+	var body io.Reader
+	if rec.Body != "" {
+		body = strings.NewReader(rec.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, rec.Method, target, body)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	for k, v := range rec.Header {
+		req.Header[k] = v
+	}
+	start := time.Now()
+	resp, err := client.Perform(req)
+	res.Duration = time.Since(start)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	res.Status, res.Response, res.Err = resp.StatusCode, string(b), err
+	return res
+}
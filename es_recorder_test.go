@@ -0,0 +1,78 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRecorderRedactsTheTruncatedBodies(t *testing.T) {
+	const secret = "s3cr3t-value"
+	padding := strings.Repeat("x", 256)
+	response := `{"_index":"i","_id":"1","found":true,"_source":{"password":"` + secret + `","note":"` + padding + `"}}`
+	small := `{"_index":"i","_id":"2","found":true,"_source":{"password":"` + secret + `"}}`
+	stub := &routeTransport{routes: map[string]stubResponse{
+		"GET /i/_doc/1":  {http.StatusOK, response},
+		"GET /i/_doc/2":  {http.StatusOK, small},
+		"POST /i/_count": {http.StatusOK, `{"count":1}`},
+	}}
+	var out bytes.Buffer
+	recorder := NewRecorder(&out, &RecorderConfig{Redactor: NewRedactor([]string{"password"}), MaxBodySize: 128})
+	client, err := NewESClient(&ESConfig{Addrs: []string{"http://nes.invalid:9200"}, Version: "8.13.1"}, WithBaseTransport(stub), WithRecorder(recorder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oper := NewESOper(client, WithDebugLogging(nil))
+	ctx := context.Background()
+	var model map[string]interface{}
+	for _, id := range []string{"1", "2"} {
+		if _, err := oper.Get(ctx, &model, "i", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	query := `{"query":{"term":{"password":"` + secret + `"}},"z_note":"` + padding + `"}`
+	if _, err := oper.Count(ctx, query, []string{"i"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(out.String(), secret) {
+		t.Errorf("the secret is recorded: %s", out.String())
+	}
+	var recs []*Recording
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		rec := &Recording{}
+		if err := json.Unmarshal([]byte(line), rec); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	if len(recs) != 3 {
+		t.Fatalf("%d recordings, expected 3", len(recs))
+	}
+	if recs[0].Response != TruncatedBodyMark {
+		t.Errorf("the truncated response is %q, expected the mark", recs[0].Response)
+	}
+	if !strings.Contains(recs[1].Response, RedactedValue) {
+		t.Errorf("the small response is %q, expected it redacted", recs[1].Response)
+	}
+	if len(recs[2].Body) != 128 || !strings.Contains(recs[2].Body, RedactedValue) {
+		t.Errorf("the request body is %q, expected it redacted and truncated", recs[2].Body)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// RedactedValue - the replacement of the sensitive values.
+const RedactedValue = "***"
+
+// Redactor replaces the sensitive values in the query and body before they are logged.
+type Redactor struct {
+	fields   map[string]struct{}
+	patterns []*regexp.Regexp
+}
+
+// DefaultRedactor - the redactor of the common credentials and personal data, e.g. the passwords, the tokens,
+// the emails and the card numbers, used where the bodies are logged without a redactor configured.
+var DefaultRedactor = NewRedactor(
+	[]string{"password", "passwd", "secret", "token", "access_token", "refresh_token", "api_key", "apikey",
+		"authorization", "email", "phone", "mobile", "ssn", "id_card", "card_number", "credit_card", "address"},
+	regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+)
+
+// NewRedactor - the values under the keys named as one of the fields are replaced entirely,
+// the substrings of the string values matching one of the patterns are replaced as well.
+// A key matches a field if it equals the field or the field with a sub field suffix like ".keyword".
+func NewRedactor(fields []string, patterns ...*regexp.Regexp) *Redactor {
+	r := &Redactor{
+		fields:   make(map[string]struct{}, len(fields)),
+		patterns: patterns,
+	}
+	for _, f := range fields {
+		r.fields[f] = struct{}{}
+	}
+	return r
+}
+
+// Redact returns the redacted body, the patterns are applied on the raw text if the body isn't valid JSON.
+func (r *Redactor) Redact(body string) string {
+	if r == nil {
+		return body
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		return r.redactString(body)
+	}
+	b, err := json.Marshal(r.redactValue(v, false))
+	if err != nil {
+		return r.redactString(body)
+	}
+	return string(b)
+}
+
+func (r *Redactor) sensitive(key string) bool {
+	if _, ok := r.fields[key]; ok {
+		return true
+	}
+	if i := strings.LastIndexByte(key, '.'); i > 0 {
+		_, ok := r.fields[key[:i]]
+		return ok
+	}
+	return false
+}
+
+func (r *Redactor) redactValue(v interface{}, sensitive bool) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = r.redactValue(item, sensitive || r.sensitive(k))
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = r.redactValue(item, sensitive)
+		}
+		return val
+	case string:
+		if sensitive {
+			return RedactedValue
+		}
+		return r.redactString(val)
+	case nil:
+		return nil
+	default:
+		if sensitive {
+			return RedactedValue
+		}
+		return val
+	}
+}
+
+func (r *Redactor) redactString(s string) string {
+	for _, p := range r.patterns {
+		s = p.ReplaceAllString(s, RedactedValue)
+	}
+	return s
+}
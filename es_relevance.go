@@ -0,0 +1,76 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+)
+
+// RelevancePreset - a reusable tuning of the relevance, it contributes the score functions applied on the queries.
+type RelevancePreset interface {
+	Functions(ctx context.Context) []*ScoreFunction
+}
+
+// RelevancePresetFunc - the func adapter of the RelevancePreset.
+type RelevancePresetFunc func(ctx context.Context) []*ScoreFunction
+
+// Functions -
+func (f RelevancePresetFunc) Functions(ctx context.Context) []*ScoreFunction {
+	return f(ctx)
+}
+
+// RecencyPreset - the gauss decay of the score by the age of the date field, the score is halved at the scale like 30d.
+func RecencyPreset(field string, scale string) RelevancePreset {
+	return RelevancePresetFunc(func(ctx context.Context) []*ScoreFunction {
+		return []*ScoreFunction{DecayFunction("gauss", field, "now", scale)}
+	})
+}
+
+// PopularityPreset - the boost of the score by log10(2 + factor * the value of the numeric field) like the likes,
+// which is at least log10(2) so the documents without the value are not zeroed.
+func PopularityPreset(field string, factor float64) RelevancePreset {
+	return RelevancePresetFunc(func(ctx context.Context) []*ScoreFunction {
+		return []*ScoreFunction{FieldValueFactorFunction(field, factor, "log2p", 0)}
+	})
+}
+
+// PersonalizationPreset - the boost of the score by the weight for the documents whose field matches any of the values
+// from the context, e.g. the categories preferred by the current user. Nothing is boosted if there is no value.
+func PersonalizationPreset(field string, weight float64, values func(ctx context.Context) []string) RelevancePreset {
+	return RelevancePresetFunc(func(ctx context.Context) []*ScoreFunction {
+		vs := values(ctx)
+		if len(vs) == 0 {
+			return nil
+		}
+		terms := make([]interface{}, 0, len(vs))
+		for _, v := range vs {
+			terms = append(terms, v)
+		}
+		return []*ScoreFunction{WeightFunction(weight).Filter(TermsQuery(field, terms...))}
+	})
+}
+
+// ApplyRelevancePresets wraps the query into a function_score query of the functions of the presets, whose scores
+// are multiplied with each other and the score of the query. The query is returned as it is if there is no function.
+func ApplyRelevancePresets(ctx context.Context, query *Query, presets ...RelevancePreset) *Query {
+	var functions []*ScoreFunction
+	for _, preset := range presets {
+		functions = append(functions, preset.Functions(ctx)...)
+	}
+	if len(functions) == 0 {
+		return query
+	}
+	return FunctionScoreQuery(query, functions...).With("score_mode", "multiply").With("boost_mode", "multiply")
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// renderSnippetSize is the number of the bytes around the syntax error shown in the error.
+const renderSnippetSize = 40
+
+// Render executes the template and validates the result is a JSON object, the request body of the template methods.
+func (t *TemplateParam) Render() (string, error) {
+	body, err := t.execute()
+	if err != nil {
+		return "", err
+	}
+	if err := validateRequestBody(body); err != nil {
+		if t.Name == "" {
+			return "", fmt.Errorf("nes: the rendered template %w", err)
+		}
+		return "", fmt.Errorf("nes: the rendered template %s %w", t.Name, err)
+	}
+	return body, nil
+}
+
+// validateRequestBody checks the body is a JSON object, the body around the syntax error is reported.
+func validateRequestBody(body string) error {
+	var m map[string]json.RawMessage
+	err := json.Unmarshal([]byte(body), &m)
+	if err == nil {
+		return nil
+	}
+	if syntaxErr, ok := err.(*json.SyntaxError); ok {
+		start, end := int(syntaxErr.Offset)-renderSnippetSize, int(syntaxErr.Offset)+renderSnippetSize
+		if start < 0 {
+			start = 0
+		}
+		if end > len(body) {
+			end = len(body)
+		}
+		return fmt.Errorf("is not valid JSON at the offset %d near %q: %w", syntaxErr.Offset, body[start:end], err)
+	}
+	return fmt.Errorf("is not a JSON object: %w", err)
+}
+
+// RenderedRequest - a request rendered in the render-only mode.
+type RenderedRequest struct {
+	Op      string
+	Indexes []string
+	Body    string
+}
+
+// WithRenderOnly - Search, Count, DeleteByQuery, UpdateByQuery and their template methods validate the request bodies
+// and pass them to the sink instead of sending them, for the unit tests and the reviews of the queries.
+// The models of the searches are left untouched.
+func WithRenderOnly(sink func(ctx context.Context, req *RenderedRequest)) ESOperOption {
+	return func(e *esOper) {
+		e.renderOnly = sink
+	}
+}
+
+// render passes the request to the sink of the render-only mode, it reports whether the oper is in the mode.
+func (e *esOper) render(ctx context.Context, op string, indexes []string, body string) (bool, error) {
+	if e.renderOnly == nil {
+		return false, nil
+	}
+	if err := validateRequestBody(body); err != nil {
+		return true, fmt.Errorf("nes: the request body of %s %w", op, err)
+	}
+	e.renderOnly(ctx, &RenderedRequest{Op: op, Indexes: indexes, Body: body})
+	return true, nil
+}
@@ -0,0 +1,314 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultResizeTimeout - the default timeout of the waits for the relocation and the health of the target.
+const defaultResizeTimeout = 5 * time.Minute
+
+// ResizeSpec - the target index of Shrink, Split and Clone.
+type ResizeSpec struct {
+	// Shards is the number of the primary shards of the target, ignored by Clone.
+	Shards int
+	// Replicas is the number of the replicas of the target, the one of the source by default.
+	Replicas *int
+	// Settings are the other settings of the target like index.codec.
+	Settings map[string]interface{}
+	Aliases  map[string]interface{}
+	// Node is the node a copy of every shard is relocated to before shrinking,
+	// the node holding the most shards of the source by default.
+	Node string
+	// Timeout is the timeout of the waits for the relocation and the health of the target, 5 minutes by default.
+	Timeout time.Duration
+}
+
+func (s *ResizeSpec) timeout() time.Duration {
+	if s.Timeout <= 0 {
+		return defaultResizeTimeout
+	}
+	return s.Timeout
+}
+
+type resizeRequestBody struct {
+	Settings map[string]interface{} `json:"settings,omitempty"`
+	Aliases  map[string]interface{} `json:"aliases,omitempty"`
+}
+
+func (s *ResizeSpec) body(shards bool, settings map[string]interface{}) (*bytes.Buffer, error) {
+	if settings == nil {
+		settings = map[string]interface{}{}
+	}
+	for k, v := range s.Settings {
+		settings[k] = v
+	}
+	if shards {
+		settings["index.number_of_shards"] = s.Shards
+	}
+	if s.Replicas != nil {
+		settings["index.number_of_replicas"] = *s.Replicas
+	}
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(&resizeRequestBody{Settings: settings, Aliases: s.Aliases}); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func (e *esAdminOper) Shrink(ctx context.Context, source string, target string, spec *ResizeSpec, opts ...func(*IndicesShrinkRequest)) error {
+	node := spec.Node
+	if node == "" {
+		nodes, err := e.shardNodes(ctx, source)
+		if err != nil {
+			return err
+		}
+		node = busiestNode(nodes)
+	}
+	if err := e.putIndexSettings(ctx, source, map[string]interface{}{
+		"index.routing.allocation.require._name": node,
+		"index.blocks.write":                     true,
+	}); err != nil {
+		return err
+	}
+	if err := e.waitForHealth(ctx, source, "yellow", spec.timeout()); err != nil {
+		return err
+	}
+	nodes, err := e.shardNodes(ctx, source)
+	if err != nil {
+		return err
+	}
+	for shard, shardNodes := range nodes {
+		if !containsString(shardNodes, node) {
+			return fmt.Errorf("nes: the shard %d of %s is not relocated to the node %s in time", shard, source, node)
+		}
+	}
+
+	// the target doesn't inherit the allocation requirement and the write block of the source
+	body, err := spec.body(true, map[string]interface{}{
+		"index.routing.allocation.require._name": nil,
+		"index.blocks.write":                     nil,
+	})
+	if err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*IndicesShrinkRequest){api.Indices.Shrink.WithContext(ctx), api.Indices.Shrink.WithBody(body)}, opts...)
+	resp, err := api.Indices.Shrink(source, target, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return e.verifyResize(ctx, source, target, spec)
+}
+
+func (e *esAdminOper) Split(ctx context.Context, source string, target string, spec *ResizeSpec, opts ...func(*IndicesSplitRequest)) error {
+	if err := e.AddWriteBlock(ctx, []string{source}); err != nil {
+		return err
+	}
+	body, err := spec.body(true, map[string]interface{}{"index.blocks.write": nil})
+	if err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*IndicesSplitRequest){api.Indices.Split.WithContext(ctx), api.Indices.Split.WithBody(body)}, opts...)
+	resp, err := api.Indices.Split(source, target, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return e.verifyResize(ctx, source, target, spec)
+}
+
+func (e *esAdminOper) Clone(ctx context.Context, source string, target string, spec *ResizeSpec, opts ...func(*IndicesCloneRequest)) error {
+	if err := e.AddWriteBlock(ctx, []string{source}); err != nil {
+		return err
+	}
+	body, err := spec.body(false, map[string]interface{}{"index.blocks.write": nil})
+	if err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*IndicesCloneRequest){api.Indices.Clone.WithContext(ctx), api.Indices.Clone.WithBody(body)}, opts...)
+	resp, err := api.Indices.Clone(source, target, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return e.verifyResize(ctx, source, target, spec)
+}
+
+// verifyResize waits for the primaries of the target and checks it has all the documents of the source.
+func (e *esAdminOper) verifyResize(ctx context.Context, source string, target string, spec *ResizeSpec) error {
+	if err := e.waitForHealth(ctx, target, "yellow", spec.timeout()); err != nil {
+		return err
+	}
+	sourceCount, err := e.docCount(ctx, source)
+	if err != nil {
+		return err
+	}
+	targetCount, err := e.docCount(ctx, target)
+	if err != nil {
+		return err
+	}
+	if sourceCount != targetCount {
+		return fmt.Errorf("nes: the target %s has %d documents, but the source %s has %d", target, targetCount, source, sourceCount)
+	}
+	return nil
+}
+
+func (e *esAdminOper) putIndexSettings(ctx context.Context, index string, settings map[string]interface{}) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(settings); err != nil {
+		return err
+	}
+	api := e.client
+	resp, err := api.Indices.PutSettings(body, api.Indices.PutSettings.WithContext(ctx), api.Indices.PutSettings.WithIndex(index))
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+type healthResponseBody struct {
+	Status   string `json:"status"`
+	TimedOut bool   `json:"timed_out"`
+}
+
+// waitForHealth waits for the status of the index without relocating and initializing shards.
+func (e *esAdminOper) waitForHealth(ctx context.Context, index string, status string, timeout time.Duration) error {
+	api := e.client
+	resp, err := api.Cluster.Health(
+		api.Cluster.Health.WithContext(ctx),
+		api.Cluster.Health.WithIndex(index),
+		api.Cluster.Health.WithWaitForStatus(status),
+		api.Cluster.Health.WithWaitForNoRelocatingShards(true),
+		api.Cluster.Health.WithWaitForNoInitializingShards(true),
+		api.Cluster.Health.WithTimeout(timeout),
+	)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	// the status 408 is responded with the health if it times out
+	if resp.IsError() && resp.StatusCode != http.StatusRequestTimeout {
+		return newRespErr(resp)
+	}
+	respBody := &healthResponseBody{}
+	if err := decodeSafely("ClusterHealth", resp, func() error { return json.NewDecoder(resp.Body).Decode(respBody) }); err != nil {
+		return err
+	}
+	if respBody.TimedOut {
+		return fmt.Errorf("nes: the health of %s is %s after waiting for %s for %s", index, respBody.Status, timeout, status)
+	}
+	return nil
+}
+
+type catShardRow struct {
+	Shard string `json:"shard"`
+	State string `json:"state"`
+	Node  string `json:"node"`
+}
+
+// shardNodes returns the nodes of the started copies of each shard of the index.
+func (e *esAdminOper) shardNodes(ctx context.Context, index string) (map[int][]string, error) {
+	api := e.client
+	resp, err := api.Cat.Shards(
+		api.Cat.Shards.WithContext(ctx),
+		api.Cat.Shards.WithIndex(index),
+		api.Cat.Shards.WithFormat("json"),
+		api.Cat.Shards.WithH("shard", "state", "node"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var rows []*catShardRow
+	if err := unmarshallResponse(resp, &rows); err != nil {
+		return nil, err
+	}
+	nodes := map[int][]string{}
+	for _, row := range rows {
+		shard, err := strconv.Atoi(row.Shard)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := nodes[shard]; !ok {
+			nodes[shard] = nil
+		}
+		if row.State == "STARTED" {
+			nodes[shard] = append(nodes[shard], row.Node)
+		}
+	}
+	return nodes, nil
+}
+
+func busiestNode(shardNodes map[int][]string) string {
+	counts := map[string]int{}
+	var busiest string
+	for _, nodes := range shardNodes {
+		for _, node := range nodes {
+			counts[node]++
+			if counts[node] > counts[busiest] || (counts[node] == counts[busiest] && node < busiest) {
+				busiest = node
+			}
+		}
+	}
+	return busiest
+}
+
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *esAdminOper) docCount(ctx context.Context, index string) (int64, error) {
+	api := e.client
+	resp, err := api.Count(api.Count.WithContext(ctx), api.Count.WithIndex(index))
+	if err != nil {
+		return 0, err
+	}
+	respBody := &countResponseBody{}
+	if err := unmarshallResponse(resp, respBody); err != nil {
+		return 0, err
+	}
+	if respBody.Count == nil {
+		return 0, missingField("Count", resp, "count")
+	}
+	return *respBody.Count, nil
+}
@@ -0,0 +1,33 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"io"
+)
+
+// maxDrainSize is the max size of the unread response body drained before closing it, reading a larger body
+// costs more than opening a new connection.
+const maxDrainSize = 64 << 10
+
+// closeResponse drains and closes the response body, so the connection can be reused even if the body is read
+// partially, e.g. the decoding fails or the response is an error.
+func closeResponse(resp *Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	_, _ = io.CopyN(io.Discard, resp.Body, maxDrainSize)
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,118 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCloseResponseReusesConnections(t *testing.T) {
+	var conns int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_count"):
+			// the decoder stops at the end of the object, the padding under the maxDrainSize is left unread, net/http
+			// drains at most 256KB on close asynchronously as well, so the drain itself is checked by
+			// TestCloseResponseDrainsTheBody
+			_, _ = w.Write([]byte(`{"count":1}` + strings.Repeat(" ", 32<<10)))
+		case strings.Contains(r.URL.Path, "/_doc/missing"):
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"_index":"i","_id":"missing","found":false}`))
+		case strings.HasSuffix(r.URL.Path, "/_search"):
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"type":"parsing_exception","reason":"` + strings.Repeat("x", 4<<10) + `"},"status":400}`))
+		default:
+			_, _ = w.Write([]byte(`{"_index":"i","_id":"1","found":true,"_source":{}}`))
+		}
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	client, err := NewESClient(&ESConfig{Addrs: []string{srv.URL}, Version: "8.13.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oper := NewESOper(client)
+	ctx := context.Background()
+	for i := 0; i < 20; i++ {
+		if _, err := oper.Count(ctx, `{}`, []string{"i"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := oper.Get(ctx, &map[string]interface{}{}, "i", "missing"); !IsNotFound(err) {
+			t.Fatalf("the error is %v, expected not found", err)
+		}
+		if _, err := oper.Search(ctx, &map[string]interface{}{}, `{}`, []string{"i"}); respStatusCode(err) != http.StatusBadRequest {
+			t.Fatalf("the error is %v, expected the status 400", err)
+		}
+		if _, err := oper.Get(ctx, &map[string]interface{}{}, "i", "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Fatalf("%d connections are opened, expected 1", n)
+	}
+}
+
+// eofBody - the body recording whether it's read to the EOF before it's closed.
+type eofBody struct {
+	io.Reader
+	eof       bool
+	eofClosed bool
+}
+
+func (b *eofBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		b.eof = true
+	}
+	return n, err
+}
+
+func (b *eofBody) Close() error {
+	b.eofClosed = b.eof
+	return nil
+}
+
+func TestCloseResponseDrainsTheBody(t *testing.T) {
+	for _, c := range []struct {
+		size int
+		eof  bool
+	}{
+		{0, true},
+		{maxDrainSize - 1, true},
+		// a larger body costs more than a new connection
+		{maxDrainSize + 1, false},
+	} {
+		body := &eofBody{Reader: strings.NewReader(strings.Repeat(" ", c.size))}
+		closeResponse(&Response{StatusCode: http.StatusOK, Body: body})
+		if body.eofClosed != c.eof {
+			t.Errorf("the body of %d bytes is read to the EOF before it's closed: %t, expected %t", c.size, body.eofClosed, c.eof)
+		}
+	}
+}
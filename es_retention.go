@@ -0,0 +1,367 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nf-go/nfgo/nlog"
+	"github.com/nf-go/nfgo/nutil/graceful"
+	"go.uber.org/multierr"
+)
+
+// IndexInfo - the summary of an index listed by ListIndices.
+type IndexInfo struct {
+	Name         string
+	Health       string
+	Status       string
+	Shards       int
+	DocsCount    int64
+	SizeBytes    int64
+	CreationDate time.Time
+}
+
+type catIndexRow struct {
+	Index        string `json:"index"`
+	Health       string `json:"health"`
+	Status       string `json:"status"`
+	Pri          string `json:"pri"`
+	DocsCount    string `json:"docs.count"`
+	StoreSize    string `json:"store.size"`
+	CreationDate string `json:"creation.date"`
+}
+
+// ListIndices lists the indices matching the pattern, e.g. logs-*, ordered by their names.
+func ListIndices(ctx context.Context, oper ESAdminOper, pattern string) ([]*IndexInfo, error) {
+	api := oper.ESClient()
+	resp, err := api.Cat.Indices(
+		api.Cat.Indices.WithContext(ctx),
+		api.Cat.Indices.WithIndex(pattern),
+		api.Cat.Indices.WithFormat("json"),
+		api.Cat.Indices.WithBytes("b"),
+		api.Cat.Indices.WithH("index", "health", "status", "pri", "docs.count", "store.size", "creation.date"),
+		api.Cat.Indices.WithS("index"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var rows []*catIndexRow
+	if err := unmarshallResponse(resp, &rows); err != nil {
+		return nil, err
+	}
+	indices := make([]*IndexInfo, 0, len(rows))
+	for _, row := range rows {
+		info := &IndexInfo{Name: row.Index, Health: row.Health, Status: row.Status}
+		// the counts and the sizes of the closed indices are empty
+		info.Shards, _ = strconv.Atoi(row.Pri)
+		info.DocsCount, _ = strconv.ParseInt(row.DocsCount, 10, 64)
+		info.SizeBytes, _ = strconv.ParseInt(row.StoreSize, 10, 64)
+		if millis, err := strconv.ParseInt(row.CreationDate, 10, 64); err == nil {
+			info.CreationDate = time.UnixMilli(millis)
+		}
+		indices = append(indices, info)
+	}
+	return indices, nil
+}
+
+// RetentionAction - the action applied on the indices matching a retention rule.
+type RetentionAction string
+
+const (
+	// RetentionDelete deletes the indices.
+	RetentionDelete RetentionAction = "delete"
+	// RetentionForceMerge force-merges the indices into MaxNumSegments segments.
+	RetentionForceMerge RetentionAction = "force_merge"
+	// RetentionShrink shrinks the indices into Shards primary shards, the shrunk index named as the index suffixed
+	// with -shrunk replaces the index, which becomes its alias.
+	RetentionShrink RetentionAction = "shrink"
+	// RetentionMount snapshots the indices into Repository and mounts them as the searchable snapshots in StorageTier,
+	// the mounted index named by MountedIndexName replaces the index, which becomes its alias.
+	RetentionMount RetentionAction = "mount"
+)
+
+// RetentionRule - the rule matching the indices at least MinAge old and at least MinSize bytes large,
+// the zero values are not checked.
+type RetentionRule struct {
+	MinAge  time.Duration
+	MinSize int64
+	Action  RetentionAction
+	// MaxNumSegments is the number of the segments of RetentionForceMerge, 1 by default.
+	MaxNumSegments int
+	// Shards is the number of the primary shards of RetentionShrink, 1 by default.
+	Shards int
+	// Repository is the snapshot repository of RetentionMount.
+	Repository string
+	// StorageTier is the storage tier of RetentionMount, StorageFullCopy by default.
+	StorageTier string
+}
+
+func (r *RetentionRule) matches(index *IndexInfo, born time.Time, now time.Time) bool {
+	if r.MinAge > 0 && (born.IsZero() || now.Sub(born) < r.MinAge) {
+		return false
+	}
+	if r.Action == RetentionShrink && index.Shards <= r.shards() {
+		return false
+	}
+	return r.MinSize <= 0 || index.SizeBytes >= r.MinSize
+}
+
+func (r *RetentionRule) storageTier() string {
+	if r.StorageTier == "" {
+		return StorageFullCopy
+	}
+	return r.StorageTier
+}
+
+func (r *RetentionRule) shards() int {
+	if r.Shards <= 0 {
+		return 1
+	}
+	return r.Shards
+}
+
+// RetentionPolicy - the rules of the indices matching the pattern, the first matching rule of an index is applied,
+// so the rules like deletes should come first.
+type RetentionPolicy struct {
+	Pattern string
+	Rules   []*RetentionRule
+	// Exclude keeps the indices from the policy, e.g. the write index of an alias.
+	Exclude func(index *IndexInfo) bool
+	// Born is the time the age of the index is counted from, the creation date by default. The creation date of
+	// a shrunk index is the time it's shrunk, IndexNameTime keeps the age of the date-suffixed indices instead.
+	Born func(index *IndexInfo) time.Time
+}
+
+// IndexNameTime - the time of the date suffix of the index name like logs-2020.01.02 or logs-2020.01, the -shrunk
+// suffix is ignored. The creation date is returned if the name has no date suffix.
+func IndexNameTime(index *IndexInfo) time.Time {
+	name := strings.TrimSuffix(index.Name, "-shrunk")
+	i := strings.LastIndex(name, "-")
+	if i < 0 {
+		return index.CreationDate
+	}
+	suffix := name[i+1:]
+	for _, layout := range []string{dailyIndexLayout, monthlyIndexLayout} {
+		if t, err := time.Parse(layout, suffix); err == nil {
+			return t
+		}
+	}
+	return index.CreationDate
+}
+
+// RetentionDecision - the action of the policy on an index.
+type RetentionDecision struct {
+	Index  *IndexInfo
+	Action RetentionAction
+	Rule   *RetentionRule
+}
+
+func (d *RetentionDecision) String() string {
+	return fmt.Sprintf("%s %s (created at %s, %d bytes)", d.Action, d.Index.Name, d.Index.CreationDate.Format(time.RFC3339), d.Index.SizeBytes)
+}
+
+// Evaluate decides the actions on the indices at the time.
+func (p *RetentionPolicy) Evaluate(indices []*IndexInfo, now time.Time) []*RetentionDecision {
+	var decisions []*RetentionDecision
+	for _, index := range indices {
+		if p.Exclude != nil && p.Exclude(index) {
+			continue
+		}
+		for _, rule := range p.Rules {
+			born := index.CreationDate
+			if p.Born != nil {
+				born = p.Born(index)
+			}
+			if rule.matches(index, born, now) {
+				decisions = append(decisions, &RetentionDecision{Index: index, Action: rule.Action, Rule: rule})
+				break
+			}
+		}
+	}
+	return decisions
+}
+
+// RetentionConfig -
+type RetentionConfig struct {
+	Oper     ESAdminOper
+	Policies []*RetentionPolicy
+	// Interval is the interval of the runs of the manager served, 1 hour by default.
+	Interval time.Duration
+	// DryRun only logs the decisions without applying them.
+	DryRun bool
+}
+
+// RetentionManager - applies the retention policies on the indices, for the clusters without ILM or the custom rules.
+// It's a graceful.ShutdownServer running at the interval.
+type RetentionManager interface {
+	graceful.ShutdownServer
+
+	// RunOnce evaluates the policies and applies the decisions unless it's a dry run, the decisions are returned
+	// even if some of them fail.
+	RunOnce(ctx context.Context) ([]*RetentionDecision, error)
+}
+
+// NewRetentionManager -
+func NewRetentionManager(config *RetentionConfig) RetentionManager {
+	c := *config
+	if c.Interval <= 0 {
+		c.Interval = time.Hour
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &retentionManager{config: &c, ctx: ctx, cancel: cancel, stopped: make(chan struct{}), merged: map[string]bool{}}
+}
+
+type retentionManager struct {
+	config  *RetentionConfig
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped chan struct{}
+
+	mu sync.Mutex
+	// merged are the indices force-merged already, they are not merged again
+	merged map[string]bool
+
+	// serveMu is apart from mu held by the runs, so the shutdown is not blocked by the run in flight
+	serveMu sync.Mutex
+	serving bool
+}
+
+func (m *retentionManager) RunOnce(ctx context.Context) ([]*RetentionDecision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var (
+		decisions []*RetentionDecision
+		errs      error
+	)
+	now := time.Now()
+	for _, policy := range m.config.Policies {
+		indices, err := ListIndices(ctx, m.config.Oper, policy.Pattern)
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		for _, d := range policy.Evaluate(indices, now) {
+			if d.Action == RetentionForceMerge && m.merged[d.Index.Name] {
+				continue
+			}
+			decisions = append(decisions, d)
+			if m.config.DryRun {
+				nlog.Logger(ctx).Infof("nes retention manager: dry run: %s", d)
+				continue
+			}
+			if err := m.apply(ctx, d); err != nil {
+				errs = multierr.Append(errs, fmt.Errorf("nes retention manager: fail to %s: %w", d, err))
+				continue
+			}
+			nlog.Logger(ctx).Infof("nes retention manager: %s", d)
+		}
+	}
+	return decisions, errs
+}
+
+func (m *retentionManager) apply(ctx context.Context, d *RetentionDecision) error {
+	oper := m.config.Oper
+	switch d.Action {
+	case RetentionDelete:
+		return oper.DeleteIndex(ctx, []string{d.Index.Name})
+	case RetentionForceMerge:
+		segments := d.Rule.MaxNumSegments
+		if segments <= 0 {
+			segments = 1
+		}
+		if err := oper.ForceMerge(ctx, []string{d.Index.Name}, WithMaxNumSegments(segments)); err != nil {
+			return err
+		}
+		m.merged[d.Index.Name] = true
+		return nil
+	case RetentionShrink:
+		target := d.Index.Name + "-shrunk"
+		if err := oper.Shrink(ctx, d.Index.Name, target, &ResizeSpec{Shards: d.Rule.shards()}); err != nil {
+			return err
+		}
+		// swap the index with the shrunk one atomically
+		return oper.UpdateAliases(ctx, []*AliasAction{
+			{RemoveIndex: &AliasActionParams{Index: d.Index.Name}},
+			{Add: &AliasActionParams{Index: target, Alias: d.Index.Name}},
+		})
+	case RetentionMount:
+		if d.Rule.Repository == "" {
+			return fmt.Errorf("no repository to mount %s", d.Index.Name)
+		}
+		snapshot := "nes-retention-" + d.Index.Name
+		if err := oper.CreateSnapshot(ctx, d.Rule.Repository, snapshot, []string{d.Index.Name}); err != nil {
+			return err
+		}
+		tier := d.Rule.storageTier()
+		if err := oper.MountSnapshot(ctx, d.Rule.Repository, snapshot, d.Index.Name, tier); err != nil {
+			return err
+		}
+		// swap the index with the mounted one atomically, the snapshot backs the mounted index and must be kept
+		return oper.UpdateAliases(ctx, []*AliasAction{
+			{RemoveIndex: &AliasActionParams{Index: d.Index.Name}},
+			{Add: &AliasActionParams{Index: MountedIndexName(d.Index.Name, tier), Alias: d.Index.Name}},
+		})
+	default:
+		return fmt.Errorf("unknown action %s", d.Action)
+	}
+}
+
+// Serve runs the manager at the interval until it's shutdown, the failures are logged and retried in the next run.
+func (m *retentionManager) Serve() error {
+	m.serveMu.Lock()
+	m.serving = true
+	m.serveMu.Unlock()
+	defer close(m.stopped)
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := m.RunOnce(m.ctx); err != nil && m.ctx.Err() == nil {
+			nlog.Logger(m.ctx).Errorf("nes retention manager: fail to run: %s", err)
+		}
+		select {
+		case <-m.ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *retentionManager) MustServe() {
+	if err := m.Serve(); err != nil {
+		nlog.Fatal("fail to serve the nes retention manager: ", err)
+	}
+}
+
+// Shutdown cancels the run in flight, it returns at once if it's not served.
+func (m *retentionManager) Shutdown(ctx context.Context) error {
+	m.cancel()
+	m.serveMu.Lock()
+	serving := m.serving
+	m.serveMu.Unlock()
+	if !serving {
+		return nil
+	}
+	select {
+	case <-m.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"time"
+)
+
+// maxRetryBackoff caps the exponential backoff of the retries.
+const maxRetryBackoff = 10 * time.Second
+
+// retryBackoff returns the exponential backoff of the retries from the base, nil if the base is not positive.
+func retryBackoff(base time.Duration) func(attempt int) time.Duration {
+	if base <= 0 {
+		return nil
+	}
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < maxRetryBackoff; i++ {
+			d *= 2
+		}
+		if d > maxRetryBackoff {
+			d = maxRetryBackoff
+		}
+		return d
+	}
+}
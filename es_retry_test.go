@@ -0,0 +1,147 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// flakyTransport - fails the first failures attempts by the status, or by the err if it's not nil, and records
+// the bodies of all the attempts.
+type flakyTransport struct {
+	failures int
+	status   int
+	err      error
+	response string
+
+	mu     sync.Mutex
+	bodies [][]byte
+}
+
+func (f *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	f.mu.Lock()
+	f.bodies = append(f.bodies, body)
+	attempt := len(f.bodies)
+	f.mu.Unlock()
+	status, response := http.StatusOK, f.response
+	if attempt <= f.failures {
+		if f.err != nil {
+			return nil, f.err
+		}
+		status, response = f.status, `{"error":"unavailable"}`
+	}
+	header := http.Header{}
+	header.Set("X-Elastic-Product", "Elasticsearch")
+	header.Set("Content-Type", "application/json")
+	return &http.Response{
+		StatusCode: status,
+		Header:     header,
+		Body:       io.NopCloser(bytes.NewReader([]byte(response))),
+		Request:    req,
+	}, nil
+}
+
+func (f *flakyTransport) check(t *testing.T, attempts int) {
+	t.Helper()
+	if len(f.bodies) != attempts {
+		t.Fatalf("%d attempts, expected %d", len(f.bodies), attempts)
+	}
+	if len(f.bodies[0]) == 0 {
+		t.Fatal("the body of the first attempt is empty")
+	}
+	for i, body := range f.bodies[1:] {
+		if !bytes.Equal(body, f.bodies[0]) {
+			t.Fatalf("the body of the retry %d is %q, expected %q", i+1, body, f.bodies[0])
+		}
+	}
+}
+
+func newFlakyOper(t *testing.T, config *ESConfig, flaky *flakyTransport) ESOper {
+	t.Helper()
+	config.Addrs, config.Version = []string{"http://nes.invalid:9200"}, "8.13.1"
+	client, err := NewESClient(config, WithBaseTransport(flaky))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewESOper(client)
+}
+
+func TestRetriesResendTheBodies(t *testing.T) {
+	ops := []struct {
+		name     string
+		response string
+		call     func(ctx context.Context, oper ESOper) error
+	}{
+		{"Index", `{"result":"created"}`, func(ctx context.Context, oper ESOper) error {
+			return oper.Index(ctx, "i", "1", map[string]interface{}{"name": "nes"})
+		}},
+		{"Bulk", `{"errors":false,"items":[{"index":{"_id":"1","status":201}},{"index":{"_id":"2","status":201}}]}`, func(ctx context.Context, oper ESOper) error {
+			return oper.Bulk(ctx, "i", func(ctx context.Context, buf *bytes.Buffer) error {
+				buf.WriteString(`{"index":{"_id":"1"}}` + "\n" + `{"n":1}` + "\n")
+				buf.WriteString(`{"index":{"_id":"2"}}` + "\n" + `{"n":2}` + "\n")
+				return nil
+			})
+		}},
+		{"Search", `{"hits":{"hits":[]}}`, func(ctx context.Context, oper ESOper) error {
+			var model map[string]interface{}
+			_, err := oper.Search(ctx, &model, `{"query":{"match":{"name":"nes"}}}`, []string{"i"})
+			return err
+		}},
+	}
+	failures := []struct {
+		name   string
+		status int
+		err    error
+	}{
+		{"status", http.StatusServiceUnavailable, nil},
+		{"error", 0, errors.New("connection reset by peer")},
+	}
+	for _, op := range ops {
+		for _, f := range failures {
+			t.Run(op.name+"/"+f.name, func(t *testing.T) {
+				flaky := &flakyTransport{failures: 2, status: f.status, err: f.err, response: op.response}
+				oper := newFlakyOper(t, &ESConfig{}, flaky)
+				if err := op.call(context.Background(), oper); err != nil {
+					t.Fatal(err)
+				}
+				flaky.check(t, 3)
+			})
+		}
+	}
+}
+
+func TestRetriesDisabled(t *testing.T) {
+	flaky := &flakyTransport{failures: 1, status: http.StatusServiceUnavailable}
+	oper := newFlakyOper(t, &ESConfig{MaxRetries: -1}, flaky)
+	err := oper.Index(context.Background(), "i", "1", map[string]interface{}{"name": "nes"})
+	if respStatusCode(err) != http.StatusServiceUnavailable {
+		t.Fatalf("the error is %v, expected the status 503", err)
+	}
+	flaky.check(t, 1)
+}
@@ -0,0 +1,149 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+)
+
+// DefaultSavedSearchIndex - the default meta index of the saved searches.
+const DefaultSavedSearchIndex = ".nes-saved-searches"
+
+// maxSavedSearches is the max number of the saved searches listed.
+const maxSavedSearches = 10000
+
+// SavedSearch - a named search persisted in the meta index, e.g. a report defined by the users.
+type SavedSearch struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Indexes     []string `json:"indexes"`
+	// Query is the request body, or the text of a QueryTemplate executed with the params of the run if Template is true.
+	Query     string    `json:"query"`
+	Template  bool      `json:"template,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SavedSearches - the CRUD of the saved searches, the errors of the missing ones satisfy IsNotFound.
+type SavedSearches interface {
+	// Save creates or replaces the saved search, the template is parsed before it's saved.
+	Save(ctx context.Context, s *SavedSearch) error
+	Get(ctx context.Context, name string) (*SavedSearch, error)
+	// List returns the saved searches ordered by their names.
+	List(ctx context.Context) ([]*SavedSearch, error)
+	Delete(ctx context.Context, name string) error
+	// RunSavedSearch searches the saved search into the model like Search, the params are the data of the template.
+	RunSavedSearch(ctx context.Context, model interface{}, name string, params interface{}, opts ...func(*SearchRequest)) (interface{}, error)
+}
+
+// NewSavedSearches - the saved searches in the meta index, DefaultSavedSearchIndex if it's empty.
+func NewSavedSearches(oper ESOper, index string) SavedSearches {
+	if index == "" {
+		index = DefaultSavedSearchIndex
+	}
+	return &savedSearches{oper: oper, index: index}
+}
+
+type savedSearches struct {
+	oper  ESOper
+	index string
+}
+
+func (s *savedSearches) Save(ctx context.Context, search *SavedSearch) error {
+	if search.Name == "" {
+		return errors.New("nes: the name of the saved search is empty")
+	}
+	if search.Template {
+		if _, err := NewQueryTemplate(search.Name, search.Query); err != nil {
+			return err
+		}
+	}
+	saved := *search
+	saved.UpdatedAt = time.Now()
+	if existing, err := s.Get(ctx, search.Name); err == nil {
+		saved.CreatedAt = existing.CreatedAt
+	} else if IsNotFound(err) {
+		saved.CreatedAt = saved.UpdatedAt
+	} else {
+		return err
+	}
+	return s.oper.Index(ctx, s.index, search.Name, &saved, func(r *IndexRequest) {
+		// the saved search is listed right after it's saved
+		r.Refresh = "wait_for"
+	})
+}
+
+func (s *savedSearches) Get(ctx context.Context, name string) (*SavedSearch, error) {
+	doc := &getSourceResponseBody{}
+	if _, err := s.oper.Get(ctx, doc, s.index, name); err != nil {
+		return nil, err
+	}
+	search := &SavedSearch{}
+	if err := json.Unmarshal(doc.Source, search); err != nil {
+		return nil, err
+	}
+	return search, nil
+}
+
+func (s *savedSearches) List(ctx context.Context) ([]*SavedSearch, error) {
+	body, err := NewSearchBody(nil).WithPage(0, maxSavedSearches).JSON()
+	if err != nil {
+		return nil, err
+	}
+	hits, err := SearchRaw(ctx, s.oper, body, []string{s.index})
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	searches := make([]*SavedSearch, 0, len(hits))
+	for _, hit := range hits {
+		search := &SavedSearch{}
+		if err := hit.Decode(search); err != nil {
+			return nil, err
+		}
+		searches = append(searches, search)
+	}
+	sort.Slice(searches, func(i, j int) bool {
+		return searches[i].Name < searches[j].Name
+	})
+	return searches, nil
+}
+
+func (s *savedSearches) Delete(ctx context.Context, name string) error {
+	return s.oper.Delete(ctx, name, s.index, func(r *DeleteRequest) {
+		r.Refresh = "wait_for"
+	})
+}
+
+func (s *savedSearches) RunSavedSearch(ctx context.Context, model interface{}, name string, params interface{}, opts ...func(*SearchRequest)) (interface{}, error) {
+	search, err := s.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !search.Template {
+		return s.oper.Search(ctx, model, search.Query, search.Indexes, opts...)
+	}
+	t, err := NewQueryTemplate(search.Name, search.Query)
+	if err != nil {
+		return nil, err
+	}
+	return s.oper.SearchTemplate(ctx, model, &TemplateParam{Query: t, Data: params}, search.Indexes, opts...)
+}
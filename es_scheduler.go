@@ -0,0 +1,237 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/nf-go/nfgo/nlog"
+	"github.com/nf-go/nfgo/nutil/graceful"
+	"github.com/robfig/cron/v3"
+)
+
+// ScheduledQuery - a query run by the QueryScheduler on a cron schedule.
+type ScheduledQuery struct {
+	Name string
+	// Schedule is the standard cron expression like "*/5 * * * *" or the descriptor like "@hourly" and "@every 1m".
+	Schedule string
+	// Run performs the query and returns its result delivered to the Sink, e.g. SearchQuery or SavedSearchQuery.
+	Run  func(ctx context.Context) (interface{}, error)
+	Sink QuerySink
+	// Jitter delays each run by a random duration up to it, spreading the queries scheduled at the same time.
+	Jitter time.Duration
+	// Timeout bounds each run if it's positive.
+	Timeout time.Duration
+}
+
+// QuerySink - receives the results of the scheduled queries, e.g. sends the alerts or the reports.
+type QuerySink interface {
+	Deliver(ctx context.Context, name string, result interface{}, err error)
+}
+
+// QuerySinkFunc - the func adapter of the QuerySink.
+type QuerySinkFunc func(ctx context.Context, name string, result interface{}, err error)
+
+// Deliver -
+func (f QuerySinkFunc) Deliver(ctx context.Context, name string, result interface{}, err error) {
+	f(ctx, name, result, err)
+}
+
+// SearchQuery - the run of the scheduled query searching the query into the model created by the newModel.
+func SearchQuery(oper Searcher, newModel func() interface{}, query string, indexes []string, opts ...func(*SearchRequest)) func(ctx context.Context) (interface{}, error) {
+	return func(ctx context.Context) (interface{}, error) {
+		return oper.Search(ctx, newModel(), query, indexes, opts...)
+	}
+}
+
+// SavedSearchQuery - the run of the scheduled query running the saved search with the params into the model created
+// by the newModel.
+func SavedSearchQuery(searches SavedSearches, newModel func() interface{}, name string, params interface{}, opts ...func(*SearchRequest)) func(ctx context.Context) (interface{}, error) {
+	return func(ctx context.Context) (interface{}, error) {
+		return searches.RunSavedSearch(ctx, newModel(), name, params, opts...)
+	}
+}
+
+// ScheduledQueryStats - the metrics of a scheduled query.
+type ScheduledQueryStats struct {
+	Runs     int64
+	Failures int64
+	// Skipped is the number of the runs skipped since the previous run was still running.
+	Skipped      int64
+	LastRun      time.Time
+	LastDuration time.Duration
+	LastError    error
+}
+
+// QueryScheduler - runs the scheduled queries in the app, for the lightweight alerting and reporting without Watcher.
+// A run is skipped if the previous run of the query is still running. It's a graceful.ShutdownServer.
+type QueryScheduler interface {
+	graceful.ShutdownServer
+
+	// Register schedules the query, the queries registered after the scheduler is served are scheduled as well.
+	Register(q *ScheduledQuery) error
+	// Stats returns the metrics of the queries keyed by their names.
+	Stats() map[string]ScheduledQueryStats
+}
+
+// NewQueryScheduler - the scheduler of the queries in the local time zone, the collector observes the runs of the
+// queries as the opers named ScheduledQuery if it's not nil.
+func NewQueryScheduler(collector MetricsCollector) QueryScheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &queryScheduler{
+		cron:      cron.New(),
+		collector: collector,
+		ctx:       ctx,
+		cancel:    cancel,
+		stopped:   make(chan struct{}),
+		queries:   map[string]*scheduledQuery{},
+	}
+}
+
+type queryScheduler struct {
+	cron      *cron.Cron
+	collector MetricsCollector
+	ctx       context.Context
+	cancel    context.CancelFunc
+	stopped   chan struct{}
+
+	mu      sync.Mutex
+	queries map[string]*scheduledQuery
+	serving bool
+}
+
+type scheduledQuery struct {
+	*ScheduledQuery
+	running bool
+	stats   ScheduledQueryStats
+}
+
+func (s *queryScheduler) Register(q *ScheduledQuery) error {
+	if q.Name == "" || q.Run == nil || q.Sink == nil {
+		return errors.New("nes query scheduler: the name, the run and the sink of the query are required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.queries[q.Name]; ok {
+		return fmt.Errorf("nes query scheduler: the query %s is registered already", q.Name)
+	}
+	sq := &scheduledQuery{ScheduledQuery: q}
+	if _, err := s.cron.AddFunc(q.Schedule, func() { s.run(sq) }); err != nil {
+		return fmt.Errorf("nes query scheduler: invalid schedule %s of the query %s: %w", q.Schedule, q.Name, err)
+	}
+	s.queries[q.Name] = sq
+	return nil
+}
+
+func (s *queryScheduler) run(q *scheduledQuery) {
+	s.mu.Lock()
+	if q.running {
+		q.stats.Skipped++
+		s.mu.Unlock()
+		nlog.Logger(s.ctx).Warnf("nes query scheduler: skip the query %s since the previous run is still running", q.Name)
+		return
+	}
+	q.running = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		q.running = false
+		s.mu.Unlock()
+	}()
+
+	if q.Jitter > 0 {
+		select {
+		case <-time.After(time.Duration(rand.Int63n(int64(q.Jitter)))):
+		case <-s.ctx.Done():
+			return
+		}
+	}
+	ctx := s.ctx
+	if q.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	result, err := q.Run(ctx)
+	duration := time.Since(start)
+	s.mu.Lock()
+	q.stats.Runs++
+	q.stats.LastRun = start
+	q.stats.LastDuration = duration
+	q.stats.LastError = err
+	if err != nil {
+		q.stats.Failures++
+	}
+	s.mu.Unlock()
+	if s.collector != nil {
+		s.collector.ObserveOper(ctx, &OperInfo{Name: "ScheduledQuery"}, duration, err)
+	}
+	if err != nil {
+		nlog.Logger(ctx).Errorf("nes query scheduler: fail to run the query %s: %s", q.Name, err)
+	}
+	q.Sink.Deliver(ctx, q.Name, result, err)
+}
+
+func (s *queryScheduler) Stats() map[string]ScheduledQueryStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make(map[string]ScheduledQueryStats, len(s.queries))
+	for name, q := range s.queries {
+		stats[name] = q.stats
+	}
+	return stats
+}
+
+// Serve runs the queries on their schedules until it's shutdown.
+func (s *queryScheduler) Serve() error {
+	s.mu.Lock()
+	s.serving = true
+	s.mu.Unlock()
+	defer close(s.stopped)
+	s.cron.Start()
+	<-s.ctx.Done()
+	// wait for the runs in flight, which are canceled by the ctx
+	<-s.cron.Stop().Done()
+	return nil
+}
+
+func (s *queryScheduler) MustServe() {
+	if err := s.Serve(); err != nil {
+		nlog.Fatal("fail to serve the nes query scheduler: ", err)
+	}
+}
+
+// Shutdown stops the schedules and waits for the runs in flight, it returns at once if it's not served.
+func (s *queryScheduler) Shutdown(ctx context.Context) error {
+	s.cancel()
+	s.mu.Lock()
+	serving := s.serving
+	s.mu.Unlock()
+	if !serving {
+		return nil
+	}
+	select {
+	case <-s.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/nf-go/nfgo/nlog"
+)
+
+// DefaultScrollKeepAlive - the default keep-alive of the scroll contexts of SearchByScrollID.
+const DefaultScrollKeepAlive = 5 * time.Minute
+
+// WithScrollKeepAlive - the keep-alive of the scroll contexts of SearchByScrollID, DefaultScrollKeepAlive by default.
+func WithScrollKeepAlive(keepAlive time.Duration) ESOperOption {
+	return func(e *esOper) {
+		if keepAlive > 0 {
+			e.scrollKeepAlive = keepAlive
+		}
+	}
+}
+
+// ScrollKeepAlive - the keep-alive of a single SearchByScrollID call, it takes precedence over WithScrollKeepAlive.
+func ScrollKeepAlive(keepAlive time.Duration) func(*ScrollRequest) {
+	return func(r *ScrollRequest) {
+		r.Scroll = keepAlive
+	}
+}
+
+type clearScrollRequestBody struct {
+	ScrollID []string `json:"scroll_id"`
+}
+
+func (e *esOper) ClearScroll(ctx context.Context, scrollIDs ...string) error {
+	if len(scrollIDs) == 0 {
+		return nil
+	}
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(&clearScrollRequestBody{ScrollID: scrollIDs}); err != nil {
+		return err
+	}
+	api := e.client
+	resp, err := api.ClearScroll(api.ClearScroll.WithContext(ctx), api.ClearScroll.WithBody(body))
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	// the scroll contexts are expired already
+	if resp.StatusCode == http.StatusNotFound {
+		e.scrolls.untrack(scrollIDs...)
+		return nil
+	}
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	e.scrolls.untrack(scrollIDs...)
+	return nil
+}
+
+type scrollResponseBody struct {
+	ScrollID string `json:"_scroll_id"`
+	Hits     struct {
+		Hits []*RawHit `json:"hits"`
+	} `json:"hits"`
+}
+
+// ScrollIterator pages through the hits of a search with the scroll API, the scroll context is cleared
+// when the hits are exhausted, the iteration fails or the iterator is closed.
+//
+//	it := nes.NewScrollIterator(oper, indexes, query, time.Minute)
+//	defer it.Close(ctx)
+//	for it.Next(ctx) {
+//		h := it.Hit()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type ScrollIterator struct {
+	oper      ESOper
+	indexes   []string
+	query     string
+	keepAlive time.Duration
+	opts      []func(*SearchRequest)
+
+	scrollID string
+	err      error
+	hits     []*RawHit
+	pos      int
+	started  bool
+	done     bool
+}
+
+// NewScrollIterator - the iterator of the hits of the query, the size of the query is the page size,
+// the keep-alive is DefaultScrollKeepAlive if it is not positive.
+func NewScrollIterator(oper ESOper, indexes []string, query string, keepAlive time.Duration, opts ...func(*SearchRequest)) *ScrollIterator {
+	if keepAlive <= 0 {
+		keepAlive = DefaultScrollKeepAlive
+	}
+	return &ScrollIterator{
+		oper:      oper,
+		indexes:   indexes,
+		query:     query,
+		keepAlive: keepAlive,
+		opts:      opts,
+	}
+}
+
+// Next advances to the next hit, a new page is requested when the current one is consumed.
+func (it *ScrollIterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+	if it.pos < len(it.hits) {
+		it.pos++
+	}
+	for it.pos >= len(it.hits) {
+		if it.done {
+			return false
+		}
+		if err := it.fetch(ctx); err != nil {
+			it.err = err
+			it.clear(ctx)
+			return false
+		}
+	}
+	return true
+}
+
+func (it *ScrollIterator) fetch(ctx context.Context) error {
+	respBody := &scrollResponseBody{}
+	if !it.started {
+		o := append([]func(*SearchRequest){func(r *SearchRequest) { r.Scroll = it.keepAlive }}, it.opts...)
+		if _, err := it.oper.Search(ctx, respBody, it.query, it.indexes, o...); err != nil {
+			return err
+		}
+		it.started = true
+	} else if _, err := it.oper.SearchByScrollID(ctx, respBody, it.scrollID, ScrollKeepAlive(it.keepAlive)); err != nil {
+		return err
+	}
+	if respBody.ScrollID != "" {
+		it.scrollID = respBody.ScrollID
+	}
+	it.hits = respBody.Hits.Hits
+	it.pos = 0
+	if len(it.hits) == 0 {
+		it.done = true
+		it.clear(ctx)
+	}
+	return nil
+}
+
+func (it *ScrollIterator) clear(ctx context.Context) {
+	if it.scrollID == "" {
+		return
+	}
+	// the scroll context is released even if the ctx is canceled
+	if err := it.oper.ClearScroll(context.WithoutCancel(ctx), it.scrollID); err != nil {
+		nlog.Logger(ctx).Warnf("nes scroll iterator: fail to clear the scroll: %s", err)
+	}
+	it.scrollID = ""
+}
+
+// Hit returns the current hit.
+func (it *ScrollIterator) Hit() *RawHit {
+	if it.pos < len(it.hits) {
+		return it.hits[it.pos]
+	}
+	return nil
+}
+
+// Err returns the error stopping the iteration.
+func (it *ScrollIterator) Err() error {
+	return it.err
+}
+
+// Close clears the scroll context if the iteration is stopped before the hits are exhausted.
+func (it *ScrollIterator) Close(ctx context.Context) error {
+	it.done = true
+	it.hits = nil
+	if it.scrollID == "" {
+		return nil
+	}
+	scrollID := it.scrollID
+	it.scrollID = ""
+	return it.oper.ClearScroll(ctx, scrollID)
+}
@@ -0,0 +1,232 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SearchCacheConfig -
+type SearchCacheConfig struct {
+	// TTL is how long the responses are cached, 1 minute by default.
+	TTL time.Duration
+	// MaxEntries is the max number of the responses cached, the least recently used ones are evicted, 1000 by default.
+	MaxEntries int
+	// Identity returns the identity of the caller the responses are cached for, e.g. the user or the tenant,
+	// so the callers of different identities never share the responses. ContextIdentity by default.
+	Identity func(ctx context.Context) string
+}
+
+// SearchCache - caches the responses of the searches keyed by the fingerprints of the indexes, the bodies and the options
+// as they are sent after the rewriting of the oper, and the identity of the caller, for the expensive aggregations of
+// the dashboards which tolerate slightly stale data. The concurrent searches of the
+// same fingerprint wait for the one in flight instead of hitting the cluster, so the expired entries don't stampede.
+type SearchCache struct {
+	config SearchCacheConfig
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List
+	inflight map[string]*searchCall
+}
+
+type searchCacheEntry struct {
+	key     string
+	body    json.RawMessage
+	expires time.Time
+}
+
+type searchCall struct {
+	done chan struct{}
+	body json.RawMessage
+	err  error
+}
+
+// NewSearchCache -
+func NewSearchCache(config *SearchCacheConfig) *SearchCache {
+	c := SearchCacheConfig{}
+	if config != nil {
+		c = *config
+	}
+	if c.TTL <= 0 {
+		c.TTL = time.Minute
+	}
+	if c.MaxEntries <= 0 {
+		c.MaxEntries = 1000
+	}
+	if c.Identity == nil {
+		c.Identity = ContextIdentity
+	}
+	return &SearchCache{config: c, entries: map[string]*list.Element{}, lru: list.New(), inflight: map[string]*searchCall{}}
+}
+
+// SearchFingerprint - the hash of the indexes regardless of their order, the body regardless of its formatting and the key
+// order, and the parameters set by the options.
+func SearchFingerprint(query string, indexes []string, opts ...func(*SearchRequest)) (string, error) {
+	var body interface{}
+	if err := json.Unmarshal([]byte(query), &body); err != nil {
+		return "", err
+	}
+	r := &SearchRequest{}
+	for _, o := range opts {
+		o(r)
+	}
+	sorted := append([]string(nil), indexes...)
+	sort.Strings(sorted)
+	// the maps are encoded in the order of the keys
+	b, err := json.Marshal([]interface{}{sorted, body, r})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ContextIdentity - the identity carried by the context: the headers of ContextWithHeader like the run-as user and
+// the subject id of the nfgo MDC.
+func ContextIdentity(ctx context.Context) string {
+	h := HeaderFromContext(ctx)
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s=%q;", k, h[k])
+	}
+	fmt.Fprintf(&b, "subject=%q", mdcSubjectID(ctx))
+	return b.String()
+}
+
+// key returns the key of the search as it's sent by the oper for the identity of the context.
+func (c *SearchCache) key(ctx context.Context, oper Searcher, query string, indexes []string, opts []func(*SearchRequest)) (string, error) {
+	indexes, query, err := rewriteRequestBy(ctx, oper, "Search", indexes, query)
+	if err != nil {
+		return "", err
+	}
+	fingerprint, err := SearchFingerprint(query, indexes, opts...)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(fingerprint + "\n" + c.config.Identity(ctx)))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Search searches by the oper unless the response of the same fingerprint is cached, the response is decoded into
+// the model by encoding/json. The failed and the partial results are not cached, the partial results are returned
+// along with the *PartialResultsError as Search does.
+func (c *SearchCache) Search(ctx context.Context, oper Searcher, model interface{}, query string, indexes []string, opts ...func(*SearchRequest)) (interface{}, error) {
+	key, err := c.key(ctx, oper, query, indexes, opts)
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.get(ctx, key, func(ctx context.Context) (json.RawMessage, error) {
+		var raw json.RawMessage
+		_, err := oper.Search(ctx, &raw, query, indexes, opts...)
+		return raw, err
+	})
+	if err != nil && !IsPartialResults(err) {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, model); err != nil {
+		return nil, err
+	}
+	return model, err
+}
+
+// get returns the cached body of the key, or the one searched by the call in flight or by the search.
+// The waiters of a call failed by the context of its caller search again, as their contexts may be still alive.
+func (c *SearchCache) get(ctx context.Context, key string, search func(ctx context.Context) (json.RawMessage, error)) (json.RawMessage, error) {
+	for {
+		body, retry, err := c.tryGet(ctx, key, search)
+		if !retry {
+			return body, err
+		}
+	}
+}
+
+func (c *SearchCache) tryGet(ctx context.Context, key string, search func(ctx context.Context) (json.RawMessage, error)) (body json.RawMessage, retry bool, err error) {
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*searchCacheEntry)
+		if time.Now().Before(entry.expires) {
+			c.lru.MoveToFront(el)
+			c.mu.Unlock()
+			return entry.body, false, nil
+		}
+		c.remove(el)
+	}
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+			if isContextErr(call.err) && ctx.Err() == nil {
+				return nil, true, nil
+			}
+			return call.body, false, call.err
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+	call := &searchCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	call.body, call.err = search(ctx)
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.err == nil {
+		c.put(key, call.body)
+	}
+	c.mu.Unlock()
+	close(call.done)
+	return call.body, false, call.err
+}
+
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func (c *SearchCache) put(key string, body json.RawMessage) {
+	el := c.lru.PushFront(&searchCacheEntry{key: key, body: body, expires: time.Now().Add(c.config.TTL)})
+	c.entries[key] = el
+	for c.lru.Len() > c.config.MaxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *SearchCache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*searchCacheEntry).key)
+}
+
+// Invalidate drops all the cached responses, e.g. after the indices are reloaded.
+func (c *SearchCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]*list.Element{}
+	c.lru.Init()
+}
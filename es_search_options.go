@@ -0,0 +1,160 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+)
+
+// WithPreference - the shard copies the search is executed on, e.g. _local or a custom string like the session id
+// routing the searches of a user to the same copies. The copies are chosen by the adaptive replica selection by default.
+//
+// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/search-shard-routing.html.
+func WithPreference(preference string) func(*SearchRequest) {
+	return func(r *SearchRequest) {
+		r.Preference = preference
+	}
+}
+
+// WithRequestCache - whether the shard request cache is used, the setting of the index is used by default.
+func WithRequestCache(enabled bool) func(*SearchRequest) {
+	return func(r *SearchRequest) {
+		r.RequestCache = &enabled
+	}
+}
+
+// WithAllowPartialSearchResults - whether the partial results are returned if some shards fail,
+// WithAllowPartialSearchResults(false) fails the whole search instead.
+func WithAllowPartialSearchResults(allowed bool) func(*SearchRequest) {
+	return func(r *SearchRequest) {
+		r.AllowPartialSearchResults = &allowed
+	}
+}
+
+// WithBatchedReduceSize - the number of the shard results reduced at once on the coordinating node.
+func WithBatchedReduceSize(size int) func(*SearchRequest) {
+	return func(r *SearchRequest) {
+		r.BatchedReduceSize = &size
+	}
+}
+
+// WithStoredFields - the stored fields returned in the fields of the hits, the _source is not returned unless it's
+// enabled explicitly, e.g. by WithSourceEnabled. The fields must be mapped with store: true.
+//
+// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/search-fields.html#stored-fields.
+func WithStoredFields(fields ...string) func(*SearchRequest) {
+	return func(r *SearchRequest) {
+		r.StoredFields = fields
+	}
+}
+
+// WithDocValueFields - the fields returned in the fields of the hits from their doc values, which avoids loading
+// the large _source documents, combine it with WithoutSource for the high QPS searches.
+//
+// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/search-fields.html#docvalue-fields.
+func WithDocValueFields(fields ...string) func(*SearchRequest) {
+	return func(r *SearchRequest) {
+		r.DocvalueFields = fields
+	}
+}
+
+// WithSourceEnabled - the _source is returned along with the stored fields.
+func WithSourceEnabled() func(*SearchRequest) {
+	return func(r *SearchRequest) {
+		r.Source = []string{"true"}
+	}
+}
+
+// ShardStats - the _shards of the responses, it can be a field of the models, e.g.
+//
+//	Shards nes.ShardStats `json:"_shards"`
+type ShardStats struct {
+	Total      int `json:"total"`
+	Successful int `json:"successful"`
+	Skipped    int `json:"skipped"`
+	Failed     int `json:"failed"`
+
+	Failures []*ShardFailure `json:"failures,omitempty"`
+}
+
+// readSearchMeta reads the _scroll_id and the _shards of the response body, the tokens after the _shards are not read
+// since the _scroll_id comes first.
+func readSearchMeta(body []byte) (string, *ShardStats, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if _, err := dec.Token(); err != nil {
+		return "", nil, err
+	}
+	var scrollID string
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return "", nil, err
+		}
+		switch t {
+		case "_scroll_id":
+			if err := dec.Decode(&scrollID); err != nil {
+				return "", nil, err
+			}
+		case "_shards":
+			shards := &ShardStats{}
+			if err := dec.Decode(shards); err != nil {
+				return "", nil, err
+			}
+			return scrollID, shards, nil
+		default:
+			var skipped json.RawMessage
+			if err := dec.Decode(&skipped); err != nil {
+				return "", nil, err
+			}
+		}
+	}
+	return scrollID, nil, nil
+}
+
+// decodeSearchResponse decodes the search response into the model and returns the scroll id of the response,
+// the failures of the shards are checked after the model is decoded.
+func (e *esOper) decodeSearchResponse(ctx context.Context, op string, resp *Response, model interface{}) (string, error) {
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return "", newRespErr(resp)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if err := decodeSafely(op, resp, func() (err error) {
+		body, err = e.decodeFields(body)
+		return
+	}); err != nil {
+		return "", err
+	}
+	if err := decodeSafely(op, resp, func() error { return e.codec.Decode(bytes.NewReader(body), model) }); err != nil {
+		return "", err
+	}
+	var (
+		scrollID string
+		shards   *ShardStats
+	)
+	if err := decodeSafely(op, resp, func() (err error) {
+		scrollID, shards, err = readSearchMeta(body)
+		return
+	}); err != nil {
+		return "", err
+	}
+	return scrollID, e.checkShards(ctx, op, shards)
+}
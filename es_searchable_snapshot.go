@@ -0,0 +1,92 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+// the storage tiers of the searchable snapshots
+const (
+	// StorageFullCopy loads a full copy of the snapshot into the local storage of the nodes, for the cold tier.
+	StorageFullCopy = "full_copy"
+	// StorageSharedCache caches only the recently searched parts of the snapshot, for the frozen tier.
+	StorageSharedCache = "shared_cache"
+)
+
+type snapshotCreateRequestBody struct {
+	Indices            []string `json:"indices"`
+	IncludeGlobalState bool     `json:"include_global_state"`
+}
+
+type mountRequestBody struct {
+	Index        string `json:"index"`
+	RenamedIndex string `json:"renamed_index"`
+}
+
+// MountedIndexName - the name of the index mounted by MountSnapshot, the index prefixed with restored- for
+// StorageFullCopy or partial- for StorageSharedCache, the same as the ones mounted by ILM.
+func MountedIndexName(index string, storageTier string) string {
+	if storageTier == StorageSharedCache {
+		return "partial-" + index
+	}
+	return "restored-" + index
+}
+
+func (e *esAdminOper) CreateSnapshot(ctx context.Context, repo string, snapshot string, indexes []string, opts ...func(*SnapshotCreateRequest)) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(&snapshotCreateRequestBody{Indices: indexes}); err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*SnapshotCreateRequest){
+		api.Snapshot.Create.WithContext(ctx),
+		api.Snapshot.Create.WithBody(body),
+		api.Snapshot.Create.WithWaitForCompletion(true),
+	}, opts...)
+	resp, err := api.Snapshot.Create(repo, snapshot, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) MountSnapshot(ctx context.Context, repo string, snapshot string, index string, storageTier string, opts ...func(*SearchableSnapshotsMountRequest)) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(&mountRequestBody{Index: index, RenamedIndex: MountedIndexName(index, storageTier)}); err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*SearchableSnapshotsMountRequest){
+		api.SearchableSnapshotsMount.WithContext(ctx),
+		api.SearchableSnapshotsMount.WithStorage(storageTier),
+		api.SearchableSnapshotsMount.WithWaitForCompletion(true),
+	}, opts...)
+	resp, err := api.SearchableSnapshotsMount(repo, snapshot, body, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
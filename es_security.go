@@ -0,0 +1,312 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+// ESSecurityOper - the user, role and API key management operations.
+type ESSecurityOper interface {
+	ESClient() *Client
+
+	// CreateAPIKey creates an API key for the access without requiring basic authentication.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/security-api-create-api-key.html.
+	CreateAPIKey(ctx context.Context, key *CreateAPIKeyBody, opts ...func(*SecurityCreateAPIKeyRequest)) (*APIKey, error)
+	// InvalidateAPIKeys invalidates the API keys by the ids.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/security-api-invalidate-api-key.html.
+	InvalidateAPIKeys(ctx context.Context, ids []string, opts ...func(*SecurityInvalidateAPIKeyRequest)) (*InvalidateAPIKeysResult, error)
+
+	// PutRole adds or updates the role.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/security-api-put-role.html.
+	PutRole(ctx context.Context, name string, role *Role, opts ...func(*SecurityPutRoleRequest)) error
+	// GetRoles retrieves the roles by the names, all the roles are retrieved if no name is given.
+	GetRoles(ctx context.Context, names []string, opts ...func(*SecurityGetRoleRequest)) (map[string]*Role, error)
+
+	// PutRoleMapping adds or updates the role mapping.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/security-api-put-role-mapping.html.
+	PutRoleMapping(ctx context.Context, name string, mapping *RoleMapping, opts ...func(*SecurityPutRoleMappingRequest)) error
+	// GetRoleMappings retrieves the role mappings by the names, all the role mappings are retrieved if no name is given.
+	GetRoleMappings(ctx context.Context, names []string, opts ...func(*SecurityGetRoleMappingRequest)) (map[string]*RoleMapping, error)
+
+	// PutUser adds or updates the user in the native realm.
+	//
+	// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/security-api-put-user.html.
+	PutUser(ctx context.Context, username string, user *User, opts ...func(*SecurityPutUserRequest)) error
+	// GetUsers retrieves the users by the usernames, all the users are retrieved if no username is given.
+	GetUsers(ctx context.Context, usernames []string, opts ...func(*SecurityGetUserRequest)) (map[string]*User, error)
+	DeleteUser(ctx context.Context, username string, opts ...func(*SecurityDeleteUserRequest)) error
+	// ChangePassword changes the password of the user, the current user is used if the username is empty.
+	ChangePassword(ctx context.Context, username string, password string, opts ...func(*SecurityChangePasswordRequest)) error
+}
+
+// NewESSecurityOper -
+func NewESSecurityOper(client *Client) ESSecurityOper {
+	return &esSecurityOper{
+		client: client,
+	}
+}
+
+// CreateAPIKeyBody - the body of the create API key request.
+type CreateAPIKeyBody struct {
+	Name            string                 `json:"name"`
+	Expiration      string                 `json:"expiration,omitempty"`
+	RoleDescriptors map[string]*Role       `json:"role_descriptors,omitempty"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// APIKey - the created API key.
+type APIKey struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Expiration int64  `json:"expiration,omitempty"`
+	APIKey     string `json:"api_key"`
+	Encoded    string `json:"encoded"`
+}
+
+// InvalidateAPIKeysResult - the result of the invalidate API keys request.
+type InvalidateAPIKeysResult struct {
+	InvalidatedAPIKeys           []string                 `json:"invalidated_api_keys"`
+	PreviouslyInvalidatedAPIKeys []string                 `json:"previously_invalidated_api_keys"`
+	ErrorCount                   int                      `json:"error_count"`
+	ErrorDetails                 []map[string]interface{} `json:"error_details,omitempty"`
+}
+
+// Role - the role descriptor.
+type Role struct {
+	Cluster      []string               `json:"cluster,omitempty"`
+	Indices      []*IndicesPrivileges   `json:"indices,omitempty"`
+	Applications []interface{}          `json:"applications,omitempty"`
+	RunAs        []string               `json:"run_as,omitempty"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// IndicesPrivileges - the privileges on the indexes.
+type IndicesPrivileges struct {
+	Names                  []string               `json:"names"`
+	Privileges             []string               `json:"privileges"`
+	FieldSecurity          map[string]interface{} `json:"field_security,omitempty"`
+	Query                  string                 `json:"query,omitempty"`
+	AllowRestrictedIndices bool                   `json:"allow_restricted_indices,omitempty"`
+}
+
+// RoleMapping - the mapping from the users to the roles.
+type RoleMapping struct {
+	Enabled  bool                   `json:"enabled"`
+	Roles    []string               `json:"roles"`
+	Rules    map[string]interface{} `json:"rules"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// User - the user of the native realm.
+type User struct {
+	Username string                 `json:"username,omitempty"`
+	Password string                 `json:"password,omitempty"`
+	Roles    []string               `json:"roles"`
+	FullName string                 `json:"full_name,omitempty"`
+	Email    string                 `json:"email,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	Enabled  *bool                  `json:"enabled,omitempty"`
+}
+
+type invalidateAPIKeysRequestBody struct {
+	IDs []string `json:"ids"`
+}
+
+type changePasswordRequestBody struct {
+	Password string `json:"password"`
+}
+
+type esSecurityOper struct {
+	client *Client
+}
+
+func (e *esSecurityOper) ESClient() *Client {
+	return e.client
+}
+
+func (e *esSecurityOper) CreateAPIKey(ctx context.Context, key *CreateAPIKeyBody, opts ...func(*SecurityCreateAPIKeyRequest)) (*APIKey, error) {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(key); err != nil {
+		return nil, err
+	}
+	api := e.client
+	o := append([]func(*SecurityCreateAPIKeyRequest){api.Security.CreateAPIKey.WithContext(ctx)}, opts...)
+	resp, err := api.Security.CreateAPIKey(body, o...)
+	if err != nil {
+		return nil, err
+	}
+	apiKey := &APIKey{}
+	if err := unmarshallResponse(resp, apiKey); err != nil {
+		return nil, err
+	}
+	return apiKey, nil
+}
+
+func (e *esSecurityOper) InvalidateAPIKeys(ctx context.Context, ids []string, opts ...func(*SecurityInvalidateAPIKeyRequest)) (*InvalidateAPIKeysResult, error) {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(&invalidateAPIKeysRequestBody{IDs: ids}); err != nil {
+		return nil, err
+	}
+	api := e.client
+	o := append([]func(*SecurityInvalidateAPIKeyRequest){api.Security.InvalidateAPIKey.WithContext(ctx)}, opts...)
+	resp, err := api.Security.InvalidateAPIKey(body, o...)
+	if err != nil {
+		return nil, err
+	}
+	result := &InvalidateAPIKeysResult{}
+	if err := unmarshallResponse(resp, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (e *esSecurityOper) PutRole(ctx context.Context, name string, role *Role, opts ...func(*SecurityPutRoleRequest)) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(role); err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*SecurityPutRoleRequest){api.Security.PutRole.WithContext(ctx)}, opts...)
+	resp, err := api.Security.PutRole(name, body, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esSecurityOper) GetRoles(ctx context.Context, names []string, opts ...func(*SecurityGetRoleRequest)) (map[string]*Role, error) {
+	api := e.client
+	o := append([]func(*SecurityGetRoleRequest){api.Security.GetRole.WithContext(ctx), api.Security.GetRole.WithName(names...)}, opts...)
+	resp, err := api.Security.GetRole(o...)
+	if err != nil {
+		return nil, err
+	}
+	roles := map[string]*Role{}
+	if err := unmarshallResponse(resp, &roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (e *esSecurityOper) PutRoleMapping(ctx context.Context, name string, mapping *RoleMapping, opts ...func(*SecurityPutRoleMappingRequest)) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(mapping); err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*SecurityPutRoleMappingRequest){api.Security.PutRoleMapping.WithContext(ctx)}, opts...)
+	resp, err := api.Security.PutRoleMapping(name, body, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esSecurityOper) GetRoleMappings(ctx context.Context, names []string, opts ...func(*SecurityGetRoleMappingRequest)) (map[string]*RoleMapping, error) {
+	api := e.client
+	o := append([]func(*SecurityGetRoleMappingRequest){api.Security.GetRoleMapping.WithContext(ctx), api.Security.GetRoleMapping.WithName(names...)}, opts...)
+	resp, err := api.Security.GetRoleMapping(o...)
+	if err != nil {
+		return nil, err
+	}
+	mappings := map[string]*RoleMapping{}
+	if err := unmarshallResponse(resp, &mappings); err != nil {
+		return nil, err
+	}
+	return mappings, nil
+}
+
+func (e *esSecurityOper) PutUser(ctx context.Context, username string, user *User, opts ...func(*SecurityPutUserRequest)) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(user); err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*SecurityPutUserRequest){api.Security.PutUser.WithContext(ctx)}, opts...)
+	resp, err := api.Security.PutUser(username, body, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esSecurityOper) GetUsers(ctx context.Context, usernames []string, opts ...func(*SecurityGetUserRequest)) (map[string]*User, error) {
+	api := e.client
+	o := append([]func(*SecurityGetUserRequest){api.Security.GetUser.WithContext(ctx), api.Security.GetUser.WithUsername(usernames...)}, opts...)
+	resp, err := api.Security.GetUser(o...)
+	if err != nil {
+		return nil, err
+	}
+	users := map[string]*User{}
+	if err := unmarshallResponse(resp, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (e *esSecurityOper) DeleteUser(ctx context.Context, username string, opts ...func(*SecurityDeleteUserRequest)) error {
+	api := e.client
+	o := append([]func(*SecurityDeleteUserRequest){api.Security.DeleteUser.WithContext(ctx)}, opts...)
+	resp, err := api.Security.DeleteUser(username, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esSecurityOper) ChangePassword(ctx context.Context, username string, password string, opts ...func(*SecurityChangePasswordRequest)) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(&changePasswordRequestBody{Password: password}); err != nil {
+		return err
+	}
+	api := e.client
+	o := []func(*SecurityChangePasswordRequest){api.Security.ChangePassword.WithContext(ctx)}
+	if username != "" {
+		o = append(o, api.Security.ChangePassword.WithUsername(username))
+	}
+	o = append(o, opts...)
+	resp, err := api.Security.ChangePassword(body, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/nf-go/nfgo/nlog"
+	"github.com/nf-go/nfgo/nutil/graceful"
+	"go.uber.org/multierr"
+)
+
+// ErrShutdown - the error of the operations and the bulk indexers requested after the oper is shut down.
+var ErrShutdown = errors.New("nes: the oper is shut down")
+
+// shutdownGate rejects the new operations once it's closed and tracks the operations in flight.
+type shutdownGate struct {
+	mu       sync.Mutex
+	shut     bool
+	inflight int
+	idle     chan struct{}
+}
+
+func newShutdownGate() *shutdownGate {
+	return &shutdownGate{idle: make(chan struct{})}
+}
+
+func (g *shutdownGate) enter() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.shut {
+		return ErrShutdown
+	}
+	g.inflight++
+	return nil
+}
+
+func (g *shutdownGate) leave() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.inflight--
+	if g.shut && g.inflight == 0 {
+		close(g.idle)
+	}
+}
+
+// close rejects the new operations, it can be called more than once.
+func (g *shutdownGate) close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.shut {
+		return
+	}
+	g.shut = true
+	if g.inflight == 0 {
+		close(g.idle)
+	}
+}
+
+func (g *shutdownGate) closed() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.shut
+}
+
+// wait waits for the operations in flight after the gate is closed.
+func (g *shutdownGate) wait(ctx context.Context) error {
+	select {
+	case <-g.idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (g *shutdownGate) interceptor() Interceptor {
+	return func(ctx context.Context, info *OperInfo, next func(ctx context.Context) error) error {
+		if err := g.enter(); err != nil {
+			return err
+		}
+		defer g.leave()
+		return next(ctx)
+	}
+}
+
+func (e *esOper) Shutdown(ctx context.Context) error {
+	e.gate.close()
+	errs := e.gate.wait(ctx)
+	errs = multierr.Append(errs, e.Close(ctx))
+	if ids := e.scrolls.active(); len(ids) > 0 {
+		errs = multierr.Append(errs, e.ClearScroll(ctx, ids...))
+	}
+	return errs
+}
+
+// NewOperServer - the graceful.ShutdownServer of the oper to be shutdown along with the other servers of the application,
+// Serve blocks until the oper is shutdown.
+func NewOperServer(oper ESOper) graceful.ShutdownServer {
+	return &operServer{oper: oper, done: make(chan struct{})}
+}
+
+type operServer struct {
+	oper ESOper
+	once sync.Once
+	done chan struct{}
+}
+
+func (s *operServer) Serve() error {
+	<-s.done
+	return nil
+}
+
+func (s *operServer) MustServe() {
+	if err := s.Serve(); err != nil {
+		nlog.Fatal("fail to serve the nes oper: ", err)
+	}
+}
+
+func (s *operServer) Shutdown(ctx context.Context) error {
+	defer s.once.Do(func() {
+		close(s.done)
+	})
+	return s.oper.Shutdown(ctx)
+}
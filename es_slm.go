@@ -0,0 +1,145 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+// SLMPolicy - the snapshot lifecycle policy taking the snapshots into the repository by the cron schedule,
+// Name is the snapshot name supporting the date math like <nightly-{now/d}>.
+type SLMPolicy struct {
+	Name       string                 `json:"name"`
+	Schedule   string                 `json:"schedule"`
+	Repository string                 `json:"repository"`
+	Config     map[string]interface{} `json:"config,omitempty"`
+	Retention  *SLMRetention          `json:"retention,omitempty"`
+}
+
+// SLMRetention - the retention of the snapshots taken by the policy.
+type SLMRetention struct {
+	ExpireAfter string `json:"expire_after,omitempty"`
+	MinCount    int    `json:"min_count,omitempty"`
+	MaxCount    int    `json:"max_count,omitempty"`
+}
+
+// SLMStats - the stats of the snapshot lifecycle management.
+type SLMStats struct {
+	RetentionRuns                 int64             `json:"retention_runs"`
+	RetentionFailed               int64             `json:"retention_failed"`
+	RetentionTimedOut             int64             `json:"retention_timed_out"`
+	RetentionDeletionTimeMillis   int64             `json:"retention_deletion_time_millis"`
+	TotalSnapshotsTaken           int64             `json:"total_snapshots_taken"`
+	TotalSnapshotsFailed          int64             `json:"total_snapshots_failed"`
+	TotalSnapshotsDeleted         int64             `json:"total_snapshots_deleted"`
+	TotalSnapshotDeletionFailures int64             `json:"total_snapshot_deletion_failures"`
+	PolicyStats                   []*SLMPolicyStats `json:"policy_stats"`
+}
+
+// SLMPolicyStats - the stats of the snapshots taken by a policy.
+type SLMPolicyStats struct {
+	Policy                   string `json:"policy"`
+	SnapshotsTaken           int64  `json:"snapshots_taken"`
+	SnapshotsFailed          int64  `json:"snapshots_failed"`
+	SnapshotsDeleted         int64  `json:"snapshots_deleted"`
+	SnapshotDeletionFailures int64  `json:"snapshot_deletion_failures"`
+}
+
+type slmPolicyResponseBody struct {
+	Policy map[string]interface{} `json:"policy"`
+}
+
+type slmExecuteResponseBody struct {
+	SnapshotName string `json:"snapshot_name"`
+}
+
+func (e *esAdminOper) PutSLMPolicy(ctx context.Context, id string, policy interface{}, opts ...func(*SlmPutLifecycleRequest)) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(policy); err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*SlmPutLifecycleRequest){api.SlmPutLifecycle.WithContext(ctx), api.SlmPutLifecycle.WithBody(body)}, opts...)
+	resp, err := api.SlmPutLifecycle(id, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) GetSLMPolicy(ctx context.Context, id string, opts ...func(*SlmGetLifecycleRequest)) (map[string]interface{}, error) {
+	api := e.client
+	o := append([]func(*SlmGetLifecycleRequest){api.SlmGetLifecycle.WithContext(ctx), api.SlmGetLifecycle.WithPolicyID(id)}, opts...)
+	resp, err := api.SlmGetLifecycle(o...)
+	if err != nil {
+		return nil, err
+	}
+	respBody := map[string]*slmPolicyResponseBody{}
+	if err := unmarshallResponse(resp, &respBody); err != nil {
+		return nil, err
+	}
+	if p, ok := respBody[id]; ok && p != nil {
+		return p.Policy, nil
+	}
+	return nil, nil
+}
+
+func (e *esAdminOper) ExecuteSLMPolicy(ctx context.Context, id string, opts ...func(*SlmExecuteLifecycleRequest)) (string, error) {
+	api := e.client
+	o := append([]func(*SlmExecuteLifecycleRequest){api.SlmExecuteLifecycle.WithContext(ctx)}, opts...)
+	resp, err := api.SlmExecuteLifecycle(id, o...)
+	if err != nil {
+		return "", err
+	}
+	respBody := &slmExecuteResponseBody{}
+	if err := unmarshallResponse(resp, respBody); err != nil {
+		return "", err
+	}
+	return respBody.SnapshotName, nil
+}
+
+func (e *esAdminOper) DeleteSLMPolicy(ctx context.Context, id string, opts ...func(*SlmDeleteLifecycleRequest)) error {
+	api := e.client
+	o := append([]func(*SlmDeleteLifecycleRequest){api.SlmDeleteLifecycle.WithContext(ctx)}, opts...)
+	resp, err := api.SlmDeleteLifecycle(id, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) GetSLMStats(ctx context.Context, opts ...func(*SlmGetStatsRequest)) (*SLMStats, error) {
+	api := e.client
+	o := append([]func(*SlmGetStatsRequest){api.SlmGetStats.WithContext(ctx)}, opts...)
+	resp, err := api.SlmGetStats(o...)
+	if err != nil {
+		return nil, err
+	}
+	stats := &SLMStats{}
+	if err := unmarshallResponse(resp, stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,156 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// DefaultSoftDeleteField - the default field marking the document as deleted.
+const DefaultSoftDeleteField = "deleted_at"
+
+// ErrSoftDeleted - the document is soft deleted.
+var ErrSoftDeleted = errors.New("nes: the document is soft deleted")
+
+// SoftDeleteRepo - the repository style API of an index where the documents are deleted by setting
+// the deleted_at field instead of being removed, the deleted documents are excluded from the searches.
+type SoftDeleteRepo interface {
+	// Get decodes the source of the document into the model, ErrSoftDeleted is returned if it's deleted.
+	Get(ctx context.Context, model interface{}, id string) (interface{}, error)
+	Index(ctx context.Context, id string, obj interface{}, opts ...func(*IndexRequest)) error
+	// Delete marks the document as deleted at the current time, the missing document is reported by IsNotFound.
+	Delete(ctx context.Context, id string, opts ...func(*UpdateRequest)) error
+	// Restore removes the deleted mark of the document, the missing document is reported by IsNotFound.
+	Restore(ctx context.Context, id string, opts ...func(*UpdateRequest)) error
+
+	Count(ctx context.Context, query string, opts ...func(*CountRequest)) (int64, error)
+	Search(ctx context.Context, model interface{}, query string, opts ...func(*SearchRequest)) (interface{}, error)
+
+	// IncludeDeleted returns the repository whose Get, Count and Search don't exclude the deleted documents.
+	IncludeDeleted() SoftDeleteRepo
+	// Purge removes the documents deleted before the retention.
+	Purge(ctx context.Context, retention time.Duration, opts ...func(*DeleteByQueryRequest)) error
+}
+
+// NewSoftDeleteRepo - the repository of the index, DefaultSoftDeleteField is used if the field is empty.
+func NewSoftDeleteRepo(oper ESOper, index string, field string) SoftDeleteRepo {
+	if field == "" {
+		field = DefaultSoftDeleteField
+	}
+	return &softDeleteRepo{
+		oper:  oper,
+		index: index,
+		field: field,
+	}
+}
+
+type softDeleteRepo struct {
+	oper           ESOper
+	index          string
+	field          string
+	includeDeleted bool
+}
+
+func (r *softDeleteRepo) IncludeDeleted() SoftDeleteRepo {
+	c := *r
+	c.includeDeleted = true
+	return &c
+}
+
+func (r *softDeleteRepo) Get(ctx context.Context, model interface{}, id string) (interface{}, error) {
+	doc := &getSourceResponseBody{}
+	if _, err := r.oper.Get(ctx, doc, r.index, id); err != nil {
+		return nil, err
+	}
+	if !r.includeDeleted {
+		var marks map[string]interface{}
+		if err := json.Unmarshal(doc.Source, &marks); err != nil {
+			return nil, err
+		}
+		if marks[r.field] != nil {
+			return nil, ErrSoftDeleted
+		}
+	}
+	if err := json.Unmarshal(doc.Source, model); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func (r *softDeleteRepo) Index(ctx context.Context, id string, obj interface{}, opts ...func(*IndexRequest)) error {
+	return r.oper.Index(ctx, r.index, id, obj, opts...)
+}
+
+func (r *softDeleteRepo) Delete(ctx context.Context, id string, opts ...func(*UpdateRequest)) error {
+	return r.updateByID(ctx, id, "ctx._source[params.field] = params.at", time.Now().Format(time.RFC3339Nano), opts...)
+}
+
+func (r *softDeleteRepo) Restore(ctx context.Context, id string, opts ...func(*UpdateRequest)) error {
+	return r.updateByID(ctx, id, "ctx._source.remove(params.field)", "", opts...)
+}
+
+func (r *softDeleteRepo) updateByID(ctx context.Context, id string, script string, at string, opts ...func(*UpdateRequest)) error {
+	return r.oper.UpdateScript(ctx, r.index, id, &Script{
+		Source: script,
+		Lang:   "painless",
+		Params: map[string]interface{}{"field": r.field, "at": at},
+	}, opts...)
+}
+
+func (r *softDeleteRepo) filter(query string) (string, error) {
+	if r.includeDeleted {
+		return query, nil
+	}
+	return AddFilterClauses(query, map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must_not": map[string]interface{}{
+				"exists": map[string]interface{}{"field": r.field},
+			},
+		},
+	})
+}
+
+func (r *softDeleteRepo) Count(ctx context.Context, query string, opts ...func(*CountRequest)) (int64, error) {
+	query, err := r.filter(query)
+	if err != nil {
+		return 0, err
+	}
+	return r.oper.Count(ctx, query, []string{r.index}, opts...)
+}
+
+func (r *softDeleteRepo) Search(ctx context.Context, model interface{}, query string, opts ...func(*SearchRequest)) (interface{}, error) {
+	query, err := r.filter(query)
+	if err != nil {
+		return nil, err
+	}
+	return r.oper.Search(ctx, model, query, []string{r.index}, opts...)
+}
+
+func (r *softDeleteRepo) Purge(ctx context.Context, retention time.Duration, opts ...func(*DeleteByQueryRequest)) error {
+	b, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"range": map[string]interface{}{
+				r.field: map[string]interface{}{"lt": time.Now().Add(-retention).Format(time.RFC3339Nano)},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return r.oper.DeleteByQuery(ctx, string(b), []string{r.index}, opts...)
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Sort - a sort of the search, e.g. SortField("price").Desc().Missing("_last").
+//
+// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/sort-search-results.html.
+type Sort struct {
+	key    string
+	params map[string]interface{}
+}
+
+// SortField - the sort by the field.
+func SortField(field string) *Sort {
+	return &Sort{key: field, params: map[string]interface{}{}}
+}
+
+// SortScore - the sort by the score.
+func SortScore() *Sort {
+	return SortField("_score")
+}
+
+// SortDoc - the sort by the index order, the most efficient one if the order doesn't matter.
+func SortDoc() *Sort {
+	return SortField("_doc")
+}
+
+// SortScript - the sort by the values computed by the script, the typ is number or string.
+func SortScript(script *Script, typ string) *Sort {
+	return &Sort{key: "_script", params: map[string]interface{}{"type": typ, "script": script}}
+}
+
+// SortGeoDistance - the sort by the distance of the geo_point field from the points, e.g. {"lat": 40, "lon": -70}.
+func SortGeoDistance(field string, points ...interface{}) *Sort {
+	return &Sort{key: "_geo_distance", params: map[string]interface{}{field: points}}
+}
+
+// Asc - the ascending order.
+func (s *Sort) Asc() *Sort {
+	return s.With("order", "asc")
+}
+
+// Desc - the descending order.
+func (s *Sort) Desc() *Sort {
+	return s.With("order", "desc")
+}
+
+// Missing - the sort value of the documents missing the field, _last, _first or a custom value.
+func (s *Sort) Missing(v interface{}) *Sort {
+	return s.With("missing", v)
+}
+
+// Mode - the sort value of the multi-valued fields, one of min, max, sum, avg and median.
+func (s *Sort) Mode(mode string) *Sort {
+	return s.With("mode", mode)
+}
+
+// UnmappedType - the type of the field in the indices the field is not mapped.
+func (s *Sort) UnmappedType(typ string) *Sort {
+	return s.With("unmapped_type", typ)
+}
+
+// Unit - the unit of the geo distance, e.g. km.
+func (s *Sort) Unit(unit string) *Sort {
+	return s.With("unit", unit)
+}
+
+// Nested - the sort by the field of the nested objects at the path, only the nested objects matching the filter are
+// considered if the filter is not nil.
+func (s *Sort) Nested(path string, filter interface{}) *Sort {
+	nested := map[string]interface{}{"path": path}
+	if filter != nil {
+		nested["filter"] = filter
+	}
+	return s.With("nested", nested)
+}
+
+// With sets the parameter of the sort.
+func (s *Sort) With(param string, value interface{}) *Sort {
+	s.params[param] = value
+	return s
+}
+
+// MarshalJSON -
+func (s *Sort) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{s.key: s.params})
+}
+
+// SetSorts replaces the sort of the request body with the sorts, the body can be empty.
+func SetSorts(body string, sorts ...*Sort) (string, error) {
+	m := map[string]interface{}{}
+	if body != "" {
+		if err := json.Unmarshal([]byte(body), &m); err != nil {
+			return "", fmt.Errorf("nes: the request body is not a JSON object: %w", err)
+		}
+	}
+	if len(sorts) == 0 {
+		delete(m, "sort")
+	} else {
+		m["sort"] = sorts
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+)
+
+const spellCheckSuggestName = "spell_check"
+
+// SpellSuggestion - a corrected candidate of the text, Highlighted marks the corrected terms with <em>.
+type SpellSuggestion struct {
+	Text        string  `json:"text"`
+	Highlighted string  `json:"highlighted"`
+	Score       float64 `json:"score"`
+	// CollateMatch reports whether the candidate matches any document, it's only set by WithSpellCheckCollate.
+	CollateMatch *bool `json:"collate_match,omitempty"`
+}
+
+// SpellCheckOption - the option of SpellCheck.
+type SpellCheckOption func(*spellCheckParams)
+
+type spellCheckParams struct {
+	size       int
+	confidence *float64
+	collate    bool
+	prune      bool
+	opts       []func(*SearchRequest)
+}
+
+// WithSpellCheckSize - the max number of the candidates, 5 by default.
+func WithSpellCheckSize(size int) SpellCheckOption {
+	return func(p *spellCheckParams) {
+		p.size = size
+	}
+}
+
+// WithSpellCheckConfidence - the candidates scoring lower than the text multiplied by the confidence are dropped,
+// 1 by default and 0 keeps all of them.
+func WithSpellCheckConfidence(confidence float64) SpellCheckOption {
+	return func(p *spellCheckParams) {
+		p.confidence = &confidence
+	}
+}
+
+// WithSpellCheckCollate - the candidates are checked against the index by a match query of the field, the ones
+// matching no document are dropped, or kept with CollateMatch false if prune is true.
+func WithSpellCheckCollate(prune bool) SpellCheckOption {
+	return func(p *spellCheckParams) {
+		p.collate = true
+		p.prune = prune
+	}
+}
+
+// WithSpellCheckSearchOptions - the options of the search request.
+func WithSpellCheckSearchOptions(opts ...func(*SearchRequest)) SpellCheckOption {
+	return func(p *spellCheckParams) {
+		p.opts = append(p.opts, opts...)
+	}
+}
+
+type spellCheckResponseBody struct {
+	Suggest map[string][]struct {
+		Options []*SpellSuggestion `json:"options"`
+	} `json:"suggest"`
+}
+
+// SpellCheck returns the corrected candidates of the text by the phrase suggester on the field ordered by their scores,
+// e.g. for the did-you-mean of the search box. The field is better a text field with a shingle analyzer.
+//
+// See full documentation at https://www.elastic.co/guide/en/elasticsearch/reference/master/search-suggesters.html#phrase-suggester.
+func SpellCheck(ctx context.Context, oper Searcher, index string, field string, text string, opts ...SpellCheckOption) ([]*SpellSuggestion, error) {
+	p := &spellCheckParams{size: 5}
+	for _, opt := range opts {
+		opt(p)
+	}
+	phrase := map[string]interface{}{
+		"field": field,
+		"size":  p.size,
+		"direct_generator": []map[string]interface{}{
+			{"field": field, "suggest_mode": "always"},
+		},
+		"highlight": map[string]string{"pre_tag": "<em>", "post_tag": "</em>"},
+	}
+	if p.confidence != nil {
+		phrase["confidence"] = *p.confidence
+	}
+	if p.collate {
+		phrase["collate"] = map[string]interface{}{
+			"query": map[string]interface{}{
+				"source": map[string]interface{}{
+					"match": map[string]interface{}{field: map[string]string{"query": "{{suggestion}}", "operator": "and"}},
+				},
+			},
+			"prune": p.prune,
+		}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"size": 0,
+		"suggest": map[string]interface{}{
+			"text":                text,
+			spellCheckSuggestName: map[string]interface{}{"phrase": phrase},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	respBody := &spellCheckResponseBody{}
+	if _, err := oper.Search(ctx, respBody, string(body), []string{index}, p.opts...); err != nil {
+		return nil, err
+	}
+	var suggestions []*SpellSuggestion
+	for _, entry := range respBody.Suggest[spellCheckSuggestName] {
+		suggestions = append(suggestions, entry.Options...)
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// OperStats - the count, the errors and the latencies of the operations, the percentiles are estimated by
+// the exponential buckets from 100µs to 100s, which are at most twice the actual ones.
+type OperStats struct {
+	Count  int64         `json:"count"`
+	Errors int64         `json:"errors"`
+	Mean   time.Duration `json:"mean"`
+	Max    time.Duration `json:"max"`
+	P50    time.Duration `json:"p50"`
+	P90    time.Duration `json:"p90"`
+	P99    time.Duration `json:"p99"`
+}
+
+// StatsSnapshot - the stats of the document and search operations since the oper is created, keyed by the method
+// names like Search and by the index names, which can be published by expvar as it is.
+type StatsSnapshot struct {
+	Since   time.Time             `json:"since"`
+	Methods map[string]*OperStats `json:"methods"`
+	Indexes map[string]*OperStats `json:"indexes"`
+}
+
+const (
+	// statsBuckets are the upper bounds of 100µs * 2^i
+	statsBuckets    = 21
+	statsBucketBase = 100 * time.Microsecond
+	maxStatsIndexes = 1024
+	otherStatsIndex = "_other"
+)
+
+type latencyStats struct {
+	count   int64
+	errors  int64
+	total   time.Duration
+	max     time.Duration
+	buckets [statsBuckets + 1]int64
+}
+
+func (s *latencyStats) observe(d time.Duration, err error) {
+	s.count++
+	if err != nil {
+		s.errors++
+	}
+	s.total += d
+	if d > s.max {
+		s.max = d
+	}
+	i, bound := 0, statsBucketBase
+	for i < statsBuckets && d > bound {
+		i++
+		bound *= 2
+	}
+	s.buckets[i]++
+}
+
+func (s *latencyStats) percentile(p float64) time.Duration {
+	rank := int64(float64(s.count)*p + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var n int64
+	bound := statsBucketBase
+	for i := 0; i < statsBuckets; i++ {
+		n += s.buckets[i]
+		if n >= rank {
+			if bound > s.max {
+				return s.max
+			}
+			return bound
+		}
+		bound *= 2
+	}
+	return s.max
+}
+
+func (s *latencyStats) snapshot() *OperStats {
+	stats := &OperStats{Count: s.count, Errors: s.errors, Max: s.max}
+	if s.count > 0 {
+		stats.Mean = s.total / time.Duration(s.count)
+		stats.P50, stats.P90, stats.P99 = s.percentile(0.5), s.percentile(0.9), s.percentile(0.99)
+	}
+	return stats
+}
+
+type operStats struct {
+	since time.Time
+
+	mu      sync.Mutex
+	methods map[string]*latencyStats
+	indexes map[string]*latencyStats
+}
+
+func newOperStats() *operStats {
+	return &operStats{since: time.Now(), methods: map[string]*latencyStats{}, indexes: map[string]*latencyStats{}}
+}
+
+func (s *operStats) observe(info *OperInfo, d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats(s.methods, info.Name).observe(d, err)
+	for _, index := range info.Indexes {
+		// the indexes are bounded since the time-partitioned indices grow without limit
+		if _, ok := s.indexes[index]; !ok && len(s.indexes) >= maxStatsIndexes {
+			index = otherStatsIndex
+		}
+		s.stats(s.indexes, index).observe(d, err)
+	}
+}
+
+func (s *operStats) stats(m map[string]*latencyStats, key string) *latencyStats {
+	ls, ok := m[key]
+	if !ok {
+		ls = &latencyStats{}
+		m[key] = ls
+	}
+	return ls
+}
+
+func (s *operStats) snapshot() *StatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := &StatsSnapshot{
+		Since:   s.since,
+		Methods: make(map[string]*OperStats, len(s.methods)),
+		Indexes: make(map[string]*OperStats, len(s.indexes)),
+	}
+	for name, ls := range s.methods {
+		snapshot.Methods[name] = ls.snapshot()
+	}
+	for index, ls := range s.indexes {
+		snapshot.Indexes[index] = ls.snapshot()
+	}
+	return snapshot
+}
+
+func (s *operStats) interceptor() Interceptor {
+	return func(ctx context.Context, info *OperInfo, next func(ctx context.Context) error) error {
+		start := time.Now()
+		err := next(ctx)
+		s.observe(info, time.Since(start), err)
+		return err
+	}
+}
+
+func (e *esOper) Stats() *StatsSnapshot {
+	return e.stats.snapshot()
+}
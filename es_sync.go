@@ -0,0 +1,289 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nf-go/nfgo/nlog"
+	"github.com/nf-go/nfgo/nutil/graceful"
+)
+
+// DefaultSyncCheckpointIndex - the default meta index of the checkpoints of the sync jobs.
+const DefaultSyncCheckpointIndex = ".nes-sync-checkpoints"
+
+// SyncDoc - a row of the primary store to be synced.
+type SyncDoc struct {
+	ID        string
+	Doc       interface{}
+	UpdatedAt time.Time
+	// Deleted deletes the document from the index.
+	Deleted bool
+}
+
+// SyncCheckpoint - the position of the sync job in the source.
+type SyncCheckpoint struct {
+	Since     time.Time `json:"since"`
+	Cursor    string    `json:"cursor,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SyncSource - the primary store of the documents.
+type SyncSource interface {
+	// Fetch returns at most limit rows updated since the time and after the cursor in the order of the update time
+	// and the next cursor, the cursor is opaque to the job and tells apart the rows updated at the same time.
+	Fetch(ctx context.Context, since time.Time, cursor string, limit int) ([]*SyncDoc, string, error)
+}
+
+// SyncConfig -
+type SyncConfig struct {
+	// Name identifies the checkpoint of the job.
+	Name   string
+	Source SyncSource
+	Oper   ESOper
+	Index  string
+	// CheckpointIndex is DefaultSyncCheckpointIndex by default.
+	CheckpointIndex string
+	// BatchSize is 500 by default.
+	BatchSize int
+	// Interval is the interval of the runs of the job served, 1 minute by default.
+	Interval time.Duration
+	// MaxRetries is the max retries of a failed batch, 3 by default.
+	MaxRetries int
+}
+
+// SyncStats - the metrics of the sync job.
+type SyncStats struct {
+	Runs      int64
+	Indexed   int64
+	Deleted   int64
+	Failures  int64
+	LastRun   time.Time
+	LastError error
+}
+
+// SyncJob - the job syncing the rows of the source into the index incrementally, the checkpoint is saved
+// after each batch is applied so the job resumes from it. It's a graceful.ShutdownServer running at the interval.
+type SyncJob interface {
+	graceful.ShutdownServer
+
+	// RunOnce syncs the rows updated since the checkpoint until the source is drained.
+	RunOnce(ctx context.Context) error
+	Checkpoint(ctx context.Context) (*SyncCheckpoint, error)
+	Stats() SyncStats
+}
+
+// NewSyncJob -
+func NewSyncJob(config *SyncConfig) SyncJob {
+	c := *config
+	if c.CheckpointIndex == "" {
+		c.CheckpointIndex = DefaultSyncCheckpointIndex
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 500
+	}
+	if c.Interval <= 0 {
+		c.Interval = time.Minute
+	}
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = 3
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &syncJob{config: &c, ctx: ctx, cancel: cancel, stopped: make(chan struct{})}
+}
+
+type syncJob struct {
+	config  *SyncConfig
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped chan struct{}
+
+	mu      sync.Mutex
+	stats   SyncStats
+	serving bool
+}
+
+func (j *syncJob) Stats() SyncStats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.stats
+}
+
+func (j *syncJob) Checkpoint(ctx context.Context) (*SyncCheckpoint, error) {
+	doc := &getSourceResponseBody{}
+	if _, err := j.config.Oper.Get(ctx, doc, j.config.CheckpointIndex, j.config.Name); err != nil {
+		if IsNotFound(err) {
+			return &SyncCheckpoint{}, nil
+		}
+		return nil, err
+	}
+	cp := &SyncCheckpoint{}
+	if err := json.Unmarshal(doc.Source, cp); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+func (j *syncJob) RunOnce(ctx context.Context) error {
+	err := j.run(ctx)
+	j.mu.Lock()
+	j.stats.Runs++
+	j.stats.LastRun = time.Now()
+	j.stats.LastError = err
+	j.mu.Unlock()
+	return err
+}
+
+func (j *syncJob) run(ctx context.Context) error {
+	cp, err := j.Checkpoint(ctx)
+	if err != nil {
+		return err
+	}
+	for {
+		docs, next, err := j.config.Source.Fetch(ctx, cp.Since, cp.Cursor, j.config.BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(docs) == 0 {
+			return nil
+		}
+		if err := j.applyWithRetries(ctx, docs); err != nil {
+			return err
+		}
+		cp = &SyncCheckpoint{Since: docs[len(docs)-1].UpdatedAt, Cursor: next, UpdatedAt: time.Now()}
+		if err := j.config.Oper.Index(ctx, j.config.CheckpointIndex, j.config.Name, cp); err != nil {
+			return err
+		}
+		if len(docs) < j.config.BatchSize {
+			return nil
+		}
+	}
+}
+
+func (j *syncJob) applyWithRetries(ctx context.Context, docs []*SyncDoc) error {
+	var err error
+	for i := 0; i <= j.config.MaxRetries; i++ {
+		if i > 0 {
+			j.mu.Lock()
+			j.stats.Failures++
+			j.mu.Unlock()
+			nlog.Logger(ctx).Warnf("nes sync job %s: retry the batch for the %d time: %s", j.config.Name, i, err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(i*i) * time.Second):
+			}
+		}
+		if err = j.apply(ctx, docs); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (j *syncJob) apply(ctx context.Context, docs []*SyncDoc) error {
+	resp, err := j.config.Oper.BulkWithResponse(ctx, j.config.Index, func(ctx context.Context, buf *bytes.Buffer) error {
+		enc := json.NewEncoder(buf)
+		for _, d := range docs {
+			if d.Deleted {
+				if err := enc.Encode(map[string]interface{}{"delete": map[string]string{"_id": d.ID}}); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := enc.Encode(map[string]interface{}{"index": map[string]string{"_id": d.ID}}); err != nil {
+				return err
+			}
+			if err := enc.Encode(d.Doc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var indexed, deleted int64
+	for i, item := range resp.Items {
+		for _, res := range item {
+			if docs[i].Deleted {
+				// the document deleted already is fine
+				if res.Status >= 300 && res.Status != 404 {
+					return fmt.Errorf("nes sync job %s: fail to delete %s: %s %s", j.config.Name, docs[i].ID, res.Error.Type, res.Error.Reason)
+				}
+				deleted++
+				continue
+			}
+			if res.Status >= 300 {
+				return fmt.Errorf("nes sync job %s: fail to index %s: %s %s", j.config.Name, docs[i].ID, res.Error.Type, res.Error.Reason)
+			}
+			indexed++
+		}
+	}
+	j.mu.Lock()
+	j.stats.Indexed += indexed
+	j.stats.Deleted += deleted
+	j.mu.Unlock()
+	return nil
+}
+
+// Serve runs the job at the interval until it's shutdown, the failed runs are logged and retried in the next run.
+func (j *syncJob) Serve() error {
+	j.mu.Lock()
+	j.serving = true
+	j.mu.Unlock()
+	defer close(j.stopped)
+	ticker := time.NewTicker(j.config.Interval)
+	defer ticker.Stop()
+	for {
+		if err := j.RunOnce(j.ctx); err != nil && j.ctx.Err() == nil {
+			nlog.Logger(j.ctx).Errorf("nes sync job %s: fail to run: %s", j.config.Name, err)
+		}
+		select {
+		case <-j.ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *syncJob) MustServe() {
+	if err := j.Serve(); err != nil {
+		nlog.Fatal("fail to serve the nes sync job: ", err)
+	}
+}
+
+// Shutdown cancels the running batch, its checkpoint is not saved so it's synced again in the next run, it returns
+// at once if it's not served.
+func (j *syncJob) Shutdown(ctx context.Context) error {
+	j.cancel()
+	j.mu.Lock()
+	serving := j.serving
+	j.mu.Unlock()
+	if !serving {
+		return nil
+	}
+	select {
+	case <-j.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchSyncSource - the source returning the batches in order, then nothing.
+type batchSyncSource struct {
+	mu      sync.Mutex
+	batches [][]*SyncDoc
+	err     error
+	fetches int
+}
+
+func (s *batchSyncSource) Fetch(ctx context.Context, since time.Time, cursor string, limit int) ([]*SyncDoc, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetches++
+	if s.err != nil {
+		return nil, "", s.err
+	}
+	if len(s.batches) == 0 {
+		return nil, cursor, nil
+	}
+	docs := s.batches[0]
+	s.batches = s.batches[1:]
+	return docs, docs[len(docs)-1].ID, nil
+}
+
+func syncDocs() []*SyncDoc {
+	at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []*SyncDoc{
+		{ID: "1", Doc: map[string]string{"name": "a"}, UpdatedAt: at},
+		{ID: "2", Deleted: true, UpdatedAt: at.Add(time.Second)},
+	}
+}
+
+func checkpointSaves(stub *routeTransport) int {
+	var n int
+	for _, r := range stub.sent() {
+		if strings.Contains(r, DefaultSyncCheckpointIndex) && !strings.HasPrefix(r, "GET ") {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSyncJobKeepsTheCheckpointOfTheFailedBatch(t *testing.T) {
+	oper, stub := newRouteOper(t, map[string]stubResponse{
+		"POST /docs/_bulk": {http.StatusOK, `{"took":1,"errors":true,"items":[
+			{"index":{"_id":"1","status":400,"error":{"type":"mapper_parsing_exception","reason":"boom"}}},
+			{"delete":{"_id":"2","status":200}}
+		]}`},
+	})
+	job := NewSyncJob(&SyncConfig{Name: "job", Source: &batchSyncSource{batches: [][]*SyncDoc{syncDocs()}}, Oper: oper, Index: "docs", MaxRetries: 1})
+	err := job.RunOnce(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "mapper_parsing_exception") {
+		t.Fatalf("run error %v, want the error of the failed item", err)
+	}
+	if n := checkpointSaves(stub); n != 0 {
+		t.Errorf("the checkpoint is saved %d times after the failed batch", n)
+	}
+	stats := job.Stats()
+	if stats.Failures != 1 || stats.Indexed != 0 || stats.LastError == nil {
+		t.Errorf("stats %+v, want 1 failure and nothing indexed", stats)
+	}
+}
+
+func TestSyncJobSavesTheCheckpointOfTheAppliedBatch(t *testing.T) {
+	oper, stub := newRouteOper(t, map[string]stubResponse{
+		// the document deleted already is fine
+		"POST /docs/_bulk": {http.StatusOK, `{"took":1,"errors":false,"items":[
+			{"index":{"_id":"1","status":201}},
+			{"delete":{"_id":"2","status":404}}
+		]}`},
+		"PUT /" + DefaultSyncCheckpointIndex + "/_doc/job": {http.StatusCreated, `{"result":"created"}`},
+	})
+	job := NewSyncJob(&SyncConfig{Name: "job", Source: &batchSyncSource{batches: [][]*SyncDoc{syncDocs()}}, Oper: oper, Index: "docs"})
+	if err := job.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := checkpointSaves(stub); n != 1 {
+		t.Errorf("the checkpoint is saved %d times, want 1; requests %v", n, stub.sent())
+	}
+	if stats := job.Stats(); stats.Indexed != 1 || stats.Deleted != 1 || stats.Failures != 0 {
+		t.Errorf("stats %+v, want 1 indexed and 1 deleted", stats)
+	}
+}
+
+func TestSyncJobFailsOnTheSourceErrors(t *testing.T) {
+	oper, stub := newRouteOper(t, nil)
+	source := &batchSyncSource{err: errors.New("the source is down")}
+	job := NewSyncJob(&SyncConfig{Name: "job", Source: source, Oper: oper, Index: "docs"})
+	if err := job.RunOnce(context.Background()); !errors.Is(err, source.err) {
+		t.Fatalf("run error %v, want the error of the source", err)
+	}
+	for _, r := range stub.sent() {
+		if strings.HasSuffix(r, "/_bulk") {
+			t.Errorf("%s is sent after the source failed", r)
+		}
+	}
+}
+
+func TestSyncJobShutdownWithoutServing(t *testing.T) {
+	job := NewSyncJob(&SyncConfig{Name: "job"})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := job.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown before serving: %v", err)
+	}
+}
@@ -0,0 +1,956 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrNoTenant - the tenant is missing in the context.
+var ErrNoTenant = errors.New("nes: the tenant is missing in the context")
+
+// ErrNoTenantIndex - the request of the tenant addresses no index, which would be sent to all the indices.
+var ErrNoTenantIndex = errors.New("nes: the request of the tenant addresses no index")
+
+// TenancyResolver isolates the tenants, either by the index per tenant or by the tenant field of the shared index.
+type TenancyResolver interface {
+	// ResolveIndex returns the concrete index of the tenant of the context.
+	ResolveIndex(ctx context.Context, index string) (string, error)
+	// FilterClause returns the clause filtering the documents of the tenant, nil if the index isn't shared.
+	FilterClause(ctx context.Context) (interface{}, error)
+	// Stamp returns the document to be written with the tenant of the context.
+	Stamp(ctx context.Context, obj interface{}) (interface{}, error)
+	// Owns reports whether the source of a document of the index belongs to the tenant of the context.
+	Owns(ctx context.Context, source json.RawMessage) (bool, error)
+}
+
+// NewIndexTenancyResolver - the resolver of the index per tenant, the {index} and {tenant} placeholders
+// of the pattern are replaced, e.g. {index}-{tenant} resolves orders into orders-acme.
+func NewIndexTenancyResolver(pattern string, tenant func(ctx context.Context) string) TenancyResolver {
+	return &indexTenancyResolver{pattern: pattern, tenant: tenant}
+}
+
+type indexTenancyResolver struct {
+	pattern string
+	tenant  func(ctx context.Context) string
+}
+
+func (r *indexTenancyResolver) ResolveIndex(ctx context.Context, index string) (string, error) {
+	tenant := r.tenant(ctx)
+	if tenant == "" {
+		return "", ErrNoTenant
+	}
+	return strings.NewReplacer("{index}", index, "{tenant}", tenant).Replace(r.pattern), nil
+}
+
+func (r *indexTenancyResolver) FilterClause(ctx context.Context) (interface{}, error) {
+	return nil, nil
+}
+
+func (r *indexTenancyResolver) Stamp(ctx context.Context, obj interface{}) (interface{}, error) {
+	return obj, nil
+}
+
+func (r *indexTenancyResolver) Owns(ctx context.Context, source json.RawMessage) (bool, error) {
+	return true, nil
+}
+
+// NewFieldTenancyResolver - the resolver of the index shared by the tenants, the documents are stamped
+// with the tenant in the field and filtered by it.
+func NewFieldTenancyResolver(field string, tenant func(ctx context.Context) string) TenancyResolver {
+	return &fieldTenancyResolver{field: field, tenant: tenant}
+}
+
+type fieldTenancyResolver struct {
+	field  string
+	tenant func(ctx context.Context) string
+}
+
+func (r *fieldTenancyResolver) ResolveIndex(ctx context.Context, index string) (string, error) {
+	if r.tenant(ctx) == "" {
+		return "", ErrNoTenant
+	}
+	return index, nil
+}
+
+func (r *fieldTenancyResolver) FilterClause(ctx context.Context) (interface{}, error) {
+	tenant := r.tenant(ctx)
+	if tenant == "" {
+		return nil, ErrNoTenant
+	}
+	return map[string]interface{}{"term": map[string]interface{}{r.field: tenant}}, nil
+}
+
+func (r *fieldTenancyResolver) Stamp(ctx context.Context, obj interface{}) (interface{}, error) {
+	tenant := r.tenant(ctx)
+	if tenant == "" {
+		return nil, ErrNoTenant
+	}
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	doc[r.field] = tenant
+	return doc, nil
+}
+
+func (r *fieldTenancyResolver) ownerField() string {
+	return r.field
+}
+
+func (r *fieldTenancyResolver) Owns(ctx context.Context, source json.RawMessage) (bool, error) {
+	tenant := r.tenant(ctx)
+	if tenant == "" {
+		return false, ErrNoTenant
+	}
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(source, &doc); err != nil {
+		return false, err
+	}
+	// the field is stamped as is, but it may be written as an object by the other writers
+	v, ok := doc[r.field]
+	if !ok {
+		v = lookupPath(doc, r.field)
+	}
+	owner, _ := v.(string)
+	return owner == tenant, nil
+}
+
+// NewTenantESOper returns the oper isolating the tenants by the resolver: the indices are resolved, the queries are
+// filtered and the written documents are stamped, including the ones of the bulk requests and the bulk indexers.
+// When the index is shared by the tenants, the owners of the documents read by id are checked on the read ones, the
+// documents to be written by id are fetched first, and the ones of the other tenants are reported as not found, the
+// updates and the deletes are conditional on the fetched seq_no, so they can't race with a change of the owner.
+// The requests by the queries without indexes are rejected by ErrNoTenantIndex. The IndexAdmin operations are not
+// resolved.
+func NewTenantESOper(oper ESOper, resolver TenancyResolver) ESOper {
+	return &tenantESOper{ESOper: oper, resolver: resolver}
+}
+
+type tenantESOper struct {
+	ESOper
+	resolver TenancyResolver
+}
+
+// tenantRef - a document addressed by id, the routing is optional.
+type tenantRef struct {
+	index   string
+	id      string
+	routing string
+}
+
+// tenantDoc - a document fetched to check its owner.
+type tenantDoc struct {
+	Index       string          `json:"_index"`
+	ID          string          `json:"_id"`
+	Found       bool            `json:"found"`
+	SeqNo       *int            `json:"_seq_no"`
+	PrimaryTerm *int            `json:"_primary_term"`
+	Source      json.RawMessage `json:"_source"`
+
+	// foreign reports the document exists but belongs to another tenant
+	foreign bool
+}
+
+func (d *tenantDoc) owned() bool {
+	return d.Found && !d.foreign
+}
+
+func tenantNotFound(index string, id string) error {
+	return &RespError{StatusCode: http.StatusNotFound, msg: fmt.Sprintf("nes: the document %s/%s is not found", index, id)}
+}
+
+// shared reports whether the index is shared by the tenants, so the documents addressed by id must be checked.
+func (t *tenantESOper) shared(ctx context.Context) (bool, error) {
+	clause, err := t.resolver.FilterClause(ctx)
+	return clause != nil, err
+}
+
+// fetch fetches the documents of the refs by a multi get in realtime and checks their owners.
+func (t *tenantESOper) fetch(ctx context.Context, refs []tenantRef) ([]*tenantDoc, error) {
+	type mgetDoc struct {
+		Index   string `json:"_index"`
+		ID      string `json:"_id"`
+		Routing string `json:"routing,omitempty"`
+	}
+	reqBody := struct {
+		Docs []mgetDoc `json:"docs"`
+	}{Docs: make([]mgetDoc, 0, len(refs))}
+	for _, r := range refs {
+		reqBody.Docs = append(reqBody.Docs, mgetDoc{Index: r.index, ID: r.id, Routing: r.routing})
+	}
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(reqBody); err != nil {
+		return nil, err
+	}
+	api := t.ESClient()
+	resp, err := api.Mget(buf, api.Mget.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return nil, newRespErr(resp)
+	}
+	respBody := &struct {
+		Docs []*tenantDoc `json:"docs"`
+	}{}
+	if err := decodeSafely("MultiGet", resp, func() error { return json.NewDecoder(resp.Body).Decode(respBody) }); err != nil {
+		return nil, err
+	}
+	if len(respBody.Docs) != len(refs) {
+		return nil, &DecodeError{Op: "MultiGet", StatusCode: resp.StatusCode, Err: fmt.Errorf("%d docs for %d ids", len(respBody.Docs), len(refs))}
+	}
+	for _, d := range respBody.Docs {
+		if !d.Found {
+			continue
+		}
+		owns, err := t.resolver.Owns(ctx, d.Source)
+		if err != nil {
+			return nil, err
+		}
+		d.foreign = !owns
+	}
+	return respBody.Docs, nil
+}
+
+// fetchOne fetches the document of the shared index, it's nil if the index isn't shared.
+func (t *tenantESOper) fetchOne(ctx context.Context, index string, id string, routing string) (*tenantDoc, error) {
+	shared, err := t.shared(ctx)
+	if err != nil || !shared {
+		return nil, err
+	}
+	docs, err := t.fetch(ctx, []tenantRef{{index: index, id: id, routing: routing}})
+	if err != nil {
+		return nil, err
+	}
+	return docs[0], nil
+}
+
+// checkOwned returns the not found error if the document of the shared index is missing or belongs to another tenant.
+func (t *tenantESOper) checkOwned(ctx context.Context, index string, id string, routing string) (*tenantDoc, error) {
+	doc, err := t.fetchOne(ctx, index, id, routing)
+	if err != nil {
+		return nil, err
+	}
+	if doc != nil && !doc.owned() {
+		return nil, tenantNotFound(index, id)
+	}
+	return doc, nil
+}
+
+// checkNotForeign returns the conflict error if the document of the shared index belongs to another tenant,
+// the missing documents are allowed.
+func (t *tenantESOper) checkNotForeign(ctx context.Context, index string, id string, routing string) (*tenantDoc, error) {
+	doc, err := t.fetchOne(ctx, index, id, routing)
+	if err != nil {
+		return nil, err
+	}
+	if doc != nil && doc.foreign {
+		return nil, &RespError{StatusCode: http.StatusConflict, msg: fmt.Sprintf("nes: the document id %s/%s is taken", index, id)}
+	}
+	return doc, nil
+}
+
+// resolveIndexes resolves the indexes of the requests by the queries, ErrNoTenantIndex is returned if there's none.
+func (t *tenantESOper) resolveIndexes(ctx context.Context, indexes []string) ([]string, error) {
+	if len(indexes) == 0 {
+		return nil, ErrNoTenantIndex
+	}
+	resolved := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		if index == "" {
+			return nil, ErrNoTenantIndex
+		}
+		r, err := t.resolver.ResolveIndex(ctx, index)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, r)
+	}
+	return resolved, nil
+}
+
+func (t *tenantESOper) filter(ctx context.Context, query string) (string, error) {
+	clause, err := t.resolver.FilterClause(ctx)
+	if err != nil || clause == nil {
+		return query, err
+	}
+	return AddFilterClauses(query, clause)
+}
+
+func (t *tenantESOper) rewriteRequest(ctx context.Context, op string, indexes []string, query string) ([]string, string, error) {
+	indexes, err := t.resolveIndexes(ctx, indexes)
+	if err != nil {
+		return nil, "", err
+	}
+	if query, err = t.filter(ctx, query); err != nil {
+		return nil, "", err
+	}
+	return rewriteRequestBy(ctx, t.ESOper, op, indexes, query)
+}
+
+func (t *tenantESOper) renderTemplate(ctx context.Context, op string, tp *TemplateParam) (string, error) {
+	return renderTemplateBy(ctx, t.ESOper, op, tp)
+}
+
+// ownerFieldResolver - the resolver keeping the owner of the documents in the field of their sources.
+type ownerFieldResolver interface {
+	ownerField() string
+}
+
+// keepOwner returns the source filters of the read keeping the owner field, so the owner of the document read can be
+// checked, the field is returned along with the fields filtered.
+func (t *tenantESOper) keepOwner(source []string, includes []string, excludes []string) ([]string, []string, []string) {
+	r, ok := t.resolver.(ownerFieldResolver)
+	if !ok {
+		return source, includes, excludes
+	}
+	field := r.ownerField()
+	switch {
+	case len(source) == 1 && source[0] == "false":
+		source = []string{field}
+	case len(source) > 0 && !(len(source) == 1 && source[0] == "true"):
+		source = append(source, field)
+	}
+	if len(includes) > 0 {
+		includes = append(includes, field)
+	}
+	kept := make([]string, 0, len(excludes))
+	for _, e := range excludes {
+		if e != field {
+			kept = append(kept, e)
+		}
+	}
+	return source, includes, kept
+}
+
+// Get reads the document once and checks the owner of the read one when the index is shared.
+func (t *tenantESOper) Get(ctx context.Context, model interface{}, index string, id string, opts ...func(*GetRequest)) (interface{}, error) {
+	index, err := t.resolver.ResolveIndex(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := t.shared(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !shared {
+		return t.ESOper.Get(ctx, model, index, id, opts...)
+	}
+	opts = append(opts, func(r *GetRequest) {
+		r.Source, r.SourceIncludes, r.SourceExcludes = t.keepOwner(r.Source, r.SourceIncludes, r.SourceExcludes)
+	})
+	raw := &json.RawMessage{}
+	if _, err := t.ESOper.Get(ctx, raw, index, id, opts...); err != nil {
+		return nil, err
+	}
+	doc := &tenantDoc{}
+	if err := json.Unmarshal(*raw, doc); err != nil {
+		return nil, &DecodeError{Op: "Get", StatusCode: http.StatusOK, Err: err}
+	}
+	if doc.Found {
+		owns, err := t.resolver.Owns(ctx, doc.Source)
+		if err != nil {
+			return nil, err
+		}
+		if !owns {
+			return nil, tenantNotFound(index, id)
+		}
+	}
+	if err := json.Unmarshal(*raw, model); err != nil {
+		return nil, &DecodeError{Op: "Get", StatusCode: http.StatusOK, Err: err}
+	}
+	return model, nil
+}
+
+// MultiGet leaves out the documents of the other tenants as if they are missing.
+func (t *tenantESOper) MultiGet(ctx context.Context, model interface{}, index string, ids []string, opts ...func(*MgetRequest)) (interface{}, error) {
+	index, err := t.resolver.ResolveIndex(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := t.shared(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if shared && len(ids) > 0 {
+		probe := &MgetRequest{}
+		for _, opt := range opts {
+			opt(probe)
+		}
+		refs := make([]tenantRef, 0, len(ids))
+		for _, id := range ids {
+			refs = append(refs, tenantRef{index: index, id: id, routing: probe.Routing})
+		}
+		docs, err := t.fetch(ctx, refs)
+		if err != nil {
+			return nil, err
+		}
+		owned := make([]string, 0, len(ids))
+		for i, d := range docs {
+			if !d.foreign {
+				owned = append(owned, ids[i])
+			}
+		}
+		if len(owned) == 0 {
+			return model, nil
+		}
+		ids = owned
+	}
+	return t.ESOper.MultiGet(ctx, model, index, ids, opts...)
+}
+
+// GetSource reads the source once and checks its owner when the index is shared.
+func (t *tenantESOper) GetSource(ctx context.Context, model interface{}, index string, id string, opts ...func(*GetSourceRequest)) (interface{}, error) {
+	index, err := t.resolver.ResolveIndex(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := t.shared(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !shared {
+		return t.ESOper.GetSource(ctx, model, index, id, opts...)
+	}
+	opts = append(opts, func(r *GetSourceRequest) {
+		r.Source, r.SourceIncludes, r.SourceExcludes = t.keepOwner(r.Source, r.SourceIncludes, r.SourceExcludes)
+	})
+	raw := &json.RawMessage{}
+	if _, err := t.ESOper.GetSource(ctx, raw, index, id, opts...); err != nil {
+		return nil, err
+	}
+	owns, err := t.resolver.Owns(ctx, *raw)
+	if err != nil {
+		return nil, err
+	}
+	if !owns {
+		return nil, tenantNotFound(index, id)
+	}
+	if err := json.Unmarshal(*raw, model); err != nil {
+		return nil, &DecodeError{Op: "GetSource", StatusCode: http.StatusOK, Err: err}
+	}
+	return model, nil
+}
+
+func (t *tenantESOper) Exists(ctx context.Context, index string, id string, opts ...func(*ExistsRequest)) (bool, error) {
+	index, err := t.resolver.ResolveIndex(ctx, index)
+	if err != nil {
+		return false, err
+	}
+	probe := &ExistsRequest{}
+	for _, opt := range opts {
+		opt(probe)
+	}
+	doc, err := t.fetchOne(ctx, index, id, probe.Routing)
+	if err != nil || doc != nil {
+		return doc != nil && doc.owned(), err
+	}
+	return t.ESOper.Exists(ctx, index, id, opts...)
+}
+
+func (t *tenantESOper) SourceExists(ctx context.Context, index string, id string, opts ...func(*ExistsSourceRequest)) (bool, error) {
+	index, err := t.resolver.ResolveIndex(ctx, index)
+	if err != nil {
+		return false, err
+	}
+	probe := &ExistsSourceRequest{}
+	for _, opt := range opts {
+		opt(probe)
+	}
+	doc, err := t.fetchOne(ctx, index, id, probe.Routing)
+	if err != nil {
+		return false, err
+	}
+	if doc != nil && !doc.owned() {
+		return false, nil
+	}
+	return t.ESOper.SourceExists(ctx, index, id, opts...)
+}
+
+func (t *tenantESOper) Bulk(ctx context.Context, index string, writeReqBody func(ctx context.Context, buf *bytes.Buffer) error, opts ...func(*BulkRequest)) error {
+	_, err := t.BulkWithResponse(ctx, index, writeReqBody, opts...)
+	return err
+}
+
+// BulkWithResponse resolves the indices of the actions and stamps their documents, the whole request is rejected
+// if an action addresses a document of another tenant. The scripts of the updates are not checked.
+func (t *tenantESOper) BulkWithResponse(ctx context.Context, index string, writeReqBody func(ctx context.Context, buf *bytes.Buffer) error, opts ...func(*BulkRequest)) (*BulkIndexerResponse, error) {
+	if index != "" {
+		var err error
+		if index, err = t.resolver.ResolveIndex(ctx, index); err != nil {
+			return nil, err
+		}
+	}
+	return t.ESOper.BulkWithResponse(ctx, index, func(ctx context.Context, buf *bytes.Buffer) error {
+		raw := &bytes.Buffer{}
+		if err := writeReqBody(ctx, raw); err != nil {
+			return err
+		}
+		return t.rewriteBulk(ctx, index, raw.Bytes(), buf)
+	}, opts...)
+}
+
+// rewriteBulk writes the bulk body with the indices of the actions resolved and their documents stamped.
+func (t *tenantESOper) rewriteBulk(ctx context.Context, index string, body []byte, buf *bytes.Buffer) error {
+	var refs []tenantRef
+	lines := bytes.Split(body, []byte("\n"))
+	for i := 0; i < len(lines); i++ {
+		line := bytes.TrimSpace(lines[i])
+		if len(line) == 0 {
+			continue
+		}
+		action := map[string]map[string]interface{}{}
+		if err := json.Unmarshal(line, &action); err != nil || len(action) != 1 {
+			return fmt.Errorf("nes tenancy: invalid bulk action at line %d", i+1)
+		}
+		for name, meta := range action {
+			if meta == nil {
+				meta = map[string]interface{}{}
+				action[name] = meta
+			}
+			target := index
+			if s, _ := meta["_index"].(string); s != "" {
+				r, err := t.resolver.ResolveIndex(ctx, s)
+				if err != nil {
+					return err
+				}
+				meta["_index"], target = r, r
+			}
+			// the creates fail on the existing documents of any tenant
+			if id, _ := meta["_id"].(string); id != "" && name != "create" {
+				routing, _ := meta["routing"].(string)
+				refs = append(refs, tenantRef{index: target, id: id, routing: routing})
+			}
+			b, err := json.Marshal(action)
+			if err != nil {
+				return err
+			}
+			buf.Write(b)
+			buf.WriteByte('\n')
+			if name == "delete" {
+				continue
+			}
+			if i++; i >= len(lines) {
+				return fmt.Errorf("nes tenancy: the bulk %s action at line %d has no document", name, i)
+			}
+			source, err := t.stampSource(ctx, name, bytes.TrimSpace(lines[i]))
+			if err != nil {
+				return err
+			}
+			buf.Write(source)
+			buf.WriteByte('\n')
+		}
+	}
+	return t.checkRefs(ctx, refs)
+}
+
+// stampSource stamps the document of the bulk action, the doc and the upsert of the updates.
+func (t *tenantESOper) stampSource(ctx context.Context, action string, source []byte) ([]byte, error) {
+	if action != "update" {
+		obj, err := t.resolver.Stamp(ctx, json.RawMessage(source))
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(obj)
+	}
+	update := map[string]json.RawMessage{}
+	if err := json.Unmarshal(source, &update); err != nil {
+		return nil, err
+	}
+	for _, key := range []string{"doc", "upsert"} {
+		if doc, ok := update[key]; ok {
+			obj, err := t.resolver.Stamp(ctx, doc)
+			if err != nil {
+				return nil, err
+			}
+			if update[key], err = json.Marshal(obj); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return json.Marshal(update)
+}
+
+// checkRefs returns the not found error of the first document of another tenant in the shared index.
+func (t *tenantESOper) checkRefs(ctx context.Context, refs []tenantRef) error {
+	if len(refs) == 0 {
+		return nil
+	}
+	shared, err := t.shared(ctx)
+	if err != nil || !shared {
+		return err
+	}
+	docs, err := t.fetch(ctx, refs)
+	if err != nil {
+		return err
+	}
+	for i, d := range docs {
+		if d.foreign {
+			return tenantNotFound(refs[i].index, refs[i].id)
+		}
+	}
+	return nil
+}
+
+// NewBulkIndexer returns the bulk indexer resolving the indices and stamping the documents of the items like the
+// BulkWithResponse, the items addressing the documents of the other tenants are rejected by Add.
+func (t *tenantESOper) NewBulkIndexer(config BulkIndexerConfig) (BulkIndexer, error) {
+	index := config.Index
+	// the index of the tenant is resolved by the items
+	config.Index = ""
+	indexer, err := t.ESOper.NewBulkIndexer(config)
+	if err != nil {
+		return nil, err
+	}
+	return &tenantBulkIndexer{BulkIndexer: indexer, oper: t, index: index}, nil
+}
+
+type tenantBulkIndexer struct {
+	BulkIndexer
+	oper  *tenantESOper
+	index string
+}
+
+func (b *tenantBulkIndexer) Add(ctx context.Context, item BulkIndexerItem) error {
+	t := b.oper
+	if item.Index == "" {
+		item.Index = b.index
+	}
+	index, err := t.resolver.ResolveIndex(ctx, item.Index)
+	if err != nil {
+		return err
+	}
+	item.Index = index
+	action := item.Action
+	if action == "" {
+		action = "index"
+	}
+	if item.Body != nil && action != "delete" {
+		source, err := io.ReadAll(item.Body)
+		if err != nil {
+			return err
+		}
+		if source, err = t.stampSource(ctx, action, source); err != nil {
+			return err
+		}
+		item.Body = bytes.NewReader(source)
+	}
+	if item.DocumentID != "" && action != "create" {
+		if err := t.checkRefs(ctx, []tenantRef{{index: index, id: item.DocumentID, routing: item.Routing}}); err != nil {
+			return err
+		}
+	}
+	return b.BulkIndexer.Add(ctx, item)
+}
+
+func (t *tenantESOper) Create(ctx context.Context, index string, id string, obj interface{}, opts ...func(*CreateRequest)) error {
+	index, err := t.resolver.ResolveIndex(ctx, index)
+	if err != nil {
+		return err
+	}
+	if obj, err = t.resolver.Stamp(ctx, obj); err != nil {
+		return err
+	}
+	return t.ESOper.Create(ctx, index, id, obj, opts...)
+}
+
+func (t *tenantESOper) Index(ctx context.Context, index string, id string, obj interface{}, opts ...func(*IndexRequest)) error {
+	index, err := t.resolver.ResolveIndex(ctx, index)
+	if err != nil {
+		return err
+	}
+	if obj, err = t.resolver.Stamp(ctx, obj); err != nil {
+		return err
+	}
+	if id != "" {
+		probe := &IndexRequest{}
+		for _, opt := range opts {
+			opt(probe)
+		}
+		doc, err := t.checkNotForeign(ctx, index, id, probe.Routing)
+		if err != nil {
+			return err
+		}
+		if doc != nil {
+			// the document can't be taken by another tenant meanwhile
+			opts = append([]func(*IndexRequest){func(r *IndexRequest) {
+				if doc.Found {
+					r.IfSeqNo, r.IfPrimaryTerm = doc.SeqNo, doc.PrimaryTerm
+				} else {
+					r.OpType = "create"
+				}
+			}}, opts...)
+		}
+	}
+	return t.ESOper.Index(ctx, index, id, obj, opts...)
+}
+
+// ownedUpdate resolves the index and makes the update conditional on the seq_no of the document of the tenant.
+func (t *tenantESOper) ownedUpdate(ctx context.Context, index string, id string, opts []func(*UpdateRequest), missingOK bool) (string, []func(*UpdateRequest), error) {
+	index, err := t.resolver.ResolveIndex(ctx, index)
+	if err != nil {
+		return "", nil, err
+	}
+	probe := &UpdateRequest{}
+	for _, opt := range opts {
+		opt(probe)
+	}
+	var doc *tenantDoc
+	if missingOK {
+		doc, err = t.checkNotForeign(ctx, index, id, probe.Routing)
+	} else {
+		doc, err = t.checkOwned(ctx, index, id, probe.Routing)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if doc != nil && doc.Found {
+		opts = append([]func(*UpdateRequest){func(r *UpdateRequest) {
+			r.IfSeqNo, r.IfPrimaryTerm = doc.SeqNo, doc.PrimaryTerm
+		}}, opts...)
+	}
+	return index, opts, nil
+}
+
+func (t *tenantESOper) Update(ctx context.Context, index string, id string, obj interface{}, opts ...func(*UpdateRequest)) error {
+	index, opts, err := t.ownedUpdate(ctx, index, id, opts, false)
+	if err != nil {
+		return err
+	}
+	// the partial document can't move the document to another tenant
+	if obj, err = t.resolver.Stamp(ctx, obj); err != nil {
+		return err
+	}
+	return t.ESOper.Update(ctx, index, id, obj, opts...)
+}
+
+func (t *tenantESOper) UpdateScript(ctx context.Context, index string, id string, script *Script, opts ...func(*UpdateRequest)) error {
+	index, opts, err := t.ownedUpdate(ctx, index, id, opts, false)
+	if err != nil {
+		return err
+	}
+	return t.ESOper.UpdateScript(ctx, index, id, script, opts...)
+}
+
+func (t *tenantESOper) Upsert(ctx context.Context, index string, id string, obj interface{}, opts ...func(*UpdateRequest)) error {
+	index, opts, err := t.ownedUpdate(ctx, index, id, opts, true)
+	if err != nil {
+		return err
+	}
+	if obj, err = t.resolver.Stamp(ctx, obj); err != nil {
+		return err
+	}
+	return t.ESOper.Upsert(ctx, index, id, obj, opts...)
+}
+
+func (t *tenantESOper) Delete(ctx context.Context, id string, index string, opts ...func(*DeleteRequest)) error {
+	index, err := t.resolver.ResolveIndex(ctx, index)
+	if err != nil {
+		return err
+	}
+	probe := &DeleteRequest{}
+	for _, opt := range opts {
+		opt(probe)
+	}
+	doc, err := t.checkOwned(ctx, index, id, probe.Routing)
+	if err != nil {
+		return err
+	}
+	if doc != nil {
+		opts = append([]func(*DeleteRequest){func(r *DeleteRequest) {
+			r.IfSeqNo, r.IfPrimaryTerm = doc.SeqNo, doc.PrimaryTerm
+		}}, opts...)
+	}
+	return t.ESOper.Delete(ctx, id, index, opts...)
+}
+
+func (t *tenantESOper) DeleteDoc(ctx context.Context, ref DocRef, opts ...func(*DeleteRequest)) error {
+	return t.Delete(ctx, ref.ID, ref.Index, opts...)
+}
+
+func (t *tenantESOper) DeleteByQuery(ctx context.Context, query string, indexes []string, opts ...func(*DeleteByQueryRequest)) error {
+	indexes, err := t.resolveIndexes(ctx, indexes)
+	if err != nil {
+		return err
+	}
+	if query, err = t.filter(ctx, query); err != nil {
+		return err
+	}
+	return t.ESOper.DeleteByQuery(ctx, query, indexes, opts...)
+}
+
+func (t *tenantESOper) DeleteByQueryTemplate(ctx context.Context, tp *TemplateParam, indexes []string, opts ...func(*DeleteByQueryRequest)) error {
+	query, err := t.renderTemplate(ctx, "DeleteByQueryTemplate", tp)
+	if err != nil {
+		return err
+	}
+	return t.DeleteByQuery(ctx, query, indexes, opts...)
+}
+
+func (t *tenantESOper) UpdateByQuery(ctx context.Context, query string, indexes []string, opts ...func(*UpdateByQueryRequest)) error {
+	indexes, err := t.resolveIndexes(ctx, indexes)
+	if err != nil {
+		return err
+	}
+	if query, err = t.filter(ctx, query); err != nil {
+		return err
+	}
+	return t.ESOper.UpdateByQuery(ctx, query, indexes, opts...)
+}
+
+func (t *tenantESOper) UpdateByQueryTemplate(ctx context.Context, tp *TemplateParam, indexes []string, opts ...func(*UpdateByQueryRequest)) error {
+	query, err := t.renderTemplate(ctx, "UpdateByQueryTemplate", tp)
+	if err != nil {
+		return err
+	}
+	return t.UpdateByQuery(ctx, query, indexes, opts...)
+}
+
+func (t *tenantESOper) Count(ctx context.Context, query string, indexes []string, opts ...func(*CountRequest)) (int64, error) {
+	indexes, err := t.resolveIndexes(ctx, indexes)
+	if err != nil {
+		return 0, err
+	}
+	if query, err = t.filter(ctx, query); err != nil {
+		return 0, err
+	}
+	return t.ESOper.Count(ctx, query, indexes, opts...)
+}
+
+func (t *tenantESOper) CountTemplate(ctx context.Context, tp *TemplateParam, indexes []string, opts ...func(*CountRequest)) (int64, error) {
+	query, err := t.renderTemplate(ctx, "CountTemplate", tp)
+	if err != nil {
+		return 0, err
+	}
+	return t.Count(ctx, query, indexes, opts...)
+}
+
+func (t *tenantESOper) Search(ctx context.Context, model interface{}, query string, indexes []string, opts ...func(*SearchRequest)) (interface{}, error) {
+	indexes, err := t.resolveIndexes(ctx, indexes)
+	if err != nil {
+		return nil, err
+	}
+	if query, err = t.filter(ctx, query); err != nil {
+		return nil, err
+	}
+	return t.ESOper.Search(ctx, model, query, indexes, opts...)
+}
+
+func (t *tenantESOper) SearchTemplate(ctx context.Context, model interface{}, tp *TemplateParam, indexes []string, opts ...func(*SearchRequest)) (interface{}, error) {
+	query, err := t.renderTemplate(ctx, "SearchTemplate", tp)
+	if err != nil {
+		return nil, err
+	}
+	return t.Search(ctx, model, query, indexes, opts...)
+}
+
+// SearchByScrollID checks the scroll belongs to the tenant when the index is shared: the scrolls are opened by the
+// filtered searches, so the first hit of the page tells the tenant of the scroll. The page is decoded into the model
+// by encoding/json then.
+func (t *tenantESOper) SearchByScrollID(ctx context.Context, model interface{}, scrollID string, opts ...func(*ScrollRequest)) (interface{}, error) {
+	shared, err := t.shared(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !shared {
+		return t.ESOper.SearchByScrollID(ctx, model, scrollID, opts...)
+	}
+	raw := &json.RawMessage{}
+	if _, err := t.ESOper.SearchByScrollID(ctx, raw, scrollID, opts...); err != nil {
+		return nil, err
+	}
+	page := &struct {
+		Hits struct {
+			Hits []struct {
+				Index string `json:"_index"`
+				ID    string `json:"_id"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}{}
+	if err := json.Unmarshal(*raw, page); err != nil {
+		return nil, &DecodeError{Op: "SearchByScrollID", Err: err}
+	}
+	if hits := page.Hits.Hits; len(hits) > 0 {
+		docs, err := t.fetch(ctx, []tenantRef{{index: hits[0].Index, id: hits[0].ID}})
+		if err != nil {
+			return nil, err
+		}
+		if docs[0].foreign {
+			return nil, &RespError{StatusCode: http.StatusNotFound, msg: "nes: the scroll is not found"}
+		}
+	}
+	if err := json.Unmarshal(*raw, model); err != nil {
+		return nil, &DecodeError{Op: "SearchByScrollID", Err: err}
+	}
+	return model, nil
+}
+
+func (t *tenantESOper) WhyNotMatched(ctx context.Context, index string, id string, query string) (*MatchReport, error) {
+	index, err := t.resolver.ResolveIndex(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+	if query, err = t.filter(ctx, query); err != nil {
+		return nil, err
+	}
+	return t.ESOper.WhyNotMatched(ctx, index, id, query)
+}
+
+// RankEval filters the requests of the rated requests, the stored templates can't be filtered so they are rejected
+// when the index is shared.
+func (t *tenantESOper) RankEval(ctx context.Context, indexes []string, requests []*RatedRequest, metric RankEvalMetric, opts ...func(*RankEvalRequest)) (*RankEvalResult, error) {
+	indexes, err := t.resolveIndexes(ctx, indexes)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := t.shared(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if shared {
+		filtered := make([]*RatedRequest, 0, len(requests))
+		for _, r := range requests {
+			if r.TemplateID != "" {
+				return nil, fmt.Errorf("nes tenancy: the rated request %s of the template %s can't be filtered by the tenant", r.ID, r.TemplateID)
+			}
+			b, err := json.Marshal(r.Request)
+			if err != nil {
+				return nil, err
+			}
+			query, err := t.filter(ctx, string(b))
+			if err != nil {
+				return nil, err
+			}
+			copied := *r
+			copied.Request = json.RawMessage(query)
+			filtered = append(filtered, &copied)
+		}
+		requests = filtered
+	}
+	return t.ESOper.RankEval(ctx, indexes, requests, metric, opts...)
+}
@@ -0,0 +1,226 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// routeTransport - responds to the requests by the routes of their methods and paths like "GET /i/_doc/1",
+// the requests without a route are answered by 404, and records the requests.
+type routeTransport struct {
+	routes map[string]stubResponse
+
+	mu       sync.Mutex
+	requests []string
+	queries  []string
+	bodies   [][]byte
+}
+
+type stubResponse struct {
+	status int
+	body   string
+}
+
+func (s *routeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	route := req.Method + " " + req.URL.Path
+	s.mu.Lock()
+	s.requests = append(s.requests, route)
+	s.queries = append(s.queries, req.URL.RawQuery)
+	s.bodies = append(s.bodies, body)
+	s.mu.Unlock()
+	res, ok := s.routes[route]
+	if !ok {
+		res = stubResponse{status: http.StatusNotFound, body: `{"error":"no route"}`}
+	}
+	header := http.Header{}
+	header.Set("X-Elastic-Product", "Elasticsearch")
+	header.Set("Content-Type", "application/json")
+	return &http.Response{
+		StatusCode: res.status,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(res.body)),
+		Request:    req,
+	}, nil
+}
+
+func (s *routeTransport) sent() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func newRouteOper(t *testing.T, routes map[string]stubResponse) (ESOper, *routeTransport) {
+	t.Helper()
+	stub := &routeTransport{routes: routes}
+	client, err := NewESClient(&ESConfig{Addrs: []string{"http://nes.invalid:9200"}, Version: "8.13.1"}, WithBaseTransport(stub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewESOper(client, WithDebugLogging(nil)), stub
+}
+
+type tenantCtxKey struct{}
+
+func tenantOf(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantCtxKey{}).(string)
+	return tenant
+}
+
+func TestTenancyRejectsNoIndex(t *testing.T) {
+	ctx := context.WithValue(context.Background(), tenantCtxKey{}, "a")
+	resolvers := map[string]TenancyResolver{
+		"index": NewIndexTenancyResolver("{index}-{tenant}", tenantOf),
+		"field": NewFieldTenancyResolver("tenant", tenantOf),
+	}
+	for name, resolver := range resolvers {
+		t.Run(name, func(t *testing.T) {
+			oper, stub := newRouteOper(t, nil)
+			tenant := NewTenantESOper(oper, resolver)
+			var model map[string]interface{}
+			calls := map[string]func(indexes []string) error{
+				"Search": func(indexes []string) error {
+					_, err := tenant.Search(ctx, &model, `{"query":{"match_all":{}}}`, indexes)
+					return err
+				},
+				"Count": func(indexes []string) error {
+					_, err := tenant.Count(ctx, `{"query":{"match_all":{}}}`, indexes)
+					return err
+				},
+				"DeleteByQuery": func(indexes []string) error {
+					return tenant.DeleteByQuery(ctx, `{"query":{"match_all":{}}}`, indexes)
+				},
+				"UpdateByQuery": func(indexes []string) error {
+					return tenant.UpdateByQuery(ctx, `{"query":{"match_all":{}}}`, indexes)
+				},
+			}
+			for op, call := range calls {
+				for _, indexes := range [][]string{nil, {""}} {
+					if err := call(indexes); !errors.Is(err, ErrNoTenantIndex) {
+						t.Errorf("%s of %q: the error is %v, expected ErrNoTenantIndex", op, indexes, err)
+					}
+				}
+			}
+			if sent := stub.sent(); len(sent) > 0 {
+				t.Errorf("the requests %v are sent", sent)
+			}
+		})
+	}
+}
+
+func TestTenancyGetChecksTheReadDocument(t *testing.T) {
+	oper, stub := newRouteOper(t, map[string]stubResponse{
+		"GET /shared/_doc/1":    {http.StatusOK, `{"_index":"shared","_id":"1","found":true,"_source":{"tenant":"a","name":"mine"}}`},
+		"GET /shared/_doc/2":    {http.StatusOK, `{"_index":"shared","_id":"2","found":true,"_source":{"tenant":"b","name":"theirs"}}`},
+		"GET /shared/_source/1": {http.StatusOK, `{"tenant":"a","name":"mine"}`},
+		"GET /shared/_source/2": {http.StatusOK, `{"tenant":"b","name":"theirs"}`},
+	})
+	tenant := NewTenantESOper(oper, NewFieldTenancyResolver("tenant", tenantOf))
+	ctx := context.WithValue(context.Background(), tenantCtxKey{}, "a")
+
+	doc := &struct {
+		Source struct {
+			Name string `json:"name"`
+		} `json:"_source"`
+	}{}
+	if _, err := tenant.Get(ctx, doc, "shared", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Source.Name != "mine" {
+		t.Errorf("the name is %q, expected mine", doc.Source.Name)
+	}
+	if _, err := tenant.Get(ctx, doc, "shared", "2"); !IsNotFound(err) {
+		t.Errorf("the error of the foreign document is %v, expected not found", err)
+	}
+	source := &struct {
+		Name string `json:"name"`
+	}{}
+	if _, err := tenant.GetSource(ctx, source, "shared", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if source.Name != "mine" {
+		t.Errorf("the name is %q, expected mine", source.Name)
+	}
+	if _, err := tenant.GetSource(ctx, source, "shared", "2"); !IsNotFound(err) {
+		t.Errorf("the error of the foreign source is %v, expected not found", err)
+	}
+	// each document is read once
+	expected := []string{"GET /shared/_doc/1", "GET /shared/_doc/2", "GET /shared/_source/1", "GET /shared/_source/2"}
+	if sent := stub.sent(); strings.Join(sent, ",") != strings.Join(expected, ",") {
+		t.Errorf("the requests are %v, expected %v", sent, expected)
+	}
+}
+
+func TestTenancyGetKeepsTheOwnerField(t *testing.T) {
+	oper, stub := newRouteOper(t, map[string]stubResponse{
+		"GET /shared/_doc/1": {http.StatusOK, `{"_index":"shared","_id":"1","found":true,"_source":{"tenant":"b"}}`},
+	})
+	tenant := NewTenantESOper(oper, NewFieldTenancyResolver("tenant", tenantOf))
+	ctx := context.WithValue(context.Background(), tenantCtxKey{}, "a")
+	var model map[string]interface{}
+	_, err := tenant.Get(ctx, &model, "shared", "1", func(r *GetRequest) {
+		r.SourceIncludes = []string{"name"}
+		r.SourceExcludes = []string{"tenant"}
+	})
+	if !IsNotFound(err) {
+		t.Errorf("the error is %v, expected not found", err)
+	}
+	if len(stub.queries) != 1 {
+		t.Fatalf("the requests are %v, expected a single read", stub.sent())
+	}
+	query, _ := url.ParseQuery(stub.queries[0])
+	if includes := query.Get("_source_includes"); includes != "name,tenant" {
+		t.Errorf("the includes are %q, expected name,tenant", includes)
+	}
+	if excludes := query.Get("_source_excludes"); excludes != "" {
+		t.Errorf("the excludes are %q, expected none", excludes)
+	}
+}
+
+func TestTenancyBulkRejectsForeignDocuments(t *testing.T) {
+	oper, stub := newRouteOper(t, map[string]stubResponse{
+		"POST /_mget": {http.StatusOK, `{"docs":[{"_index":"shared","_id":"2","found":true,"_seq_no":1,"_primary_term":1,"_source":{"tenant":"b"}}]}`},
+	})
+	tenant := NewTenantESOper(oper, NewFieldTenancyResolver("tenant", tenantOf))
+	ctx := context.WithValue(context.Background(), tenantCtxKey{}, "a")
+	err := tenant.Bulk(ctx, "shared", func(ctx context.Context, buf *bytes.Buffer) error {
+		buf.WriteString(`{"delete":{"_id":"2"}}` + "\n")
+		return nil
+	})
+	if !IsNotFound(err) {
+		t.Errorf("the error is %v, expected not found", err)
+	}
+	for _, r := range stub.sent() {
+		if strings.HasSuffix(r, "/_bulk") {
+			t.Errorf("the bulk is sent: %v", stub.sent())
+		}
+	}
+}
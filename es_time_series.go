@@ -0,0 +1,179 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const timeSeriesAggName = "time_series"
+
+// TimeSeriesBucket - a point of the time series, Values are keyed by the names of the metrics
+// and the null values are absent, e.g. the avg of an empty bucket.
+type TimeSeriesBucket struct {
+	Timestamp time.Time
+	DocCount  int64
+	Values    map[string]float64
+}
+
+// TimeSeriesOption - the option of TimeSeries.
+type TimeSeriesOption func(*timeSeriesParams)
+
+type timeSeriesParams struct {
+	location *time.Location
+	from     time.Time
+	to       time.Time
+	opts     []func(*SearchRequest)
+}
+
+// WithTimeZone - the time zone in which the buckets are rounded, the timestamps are in the location as well.
+// The IANA name of the location is sent to the cluster, or its offset at the start of the series if it has none,
+// e.g. the time.FixedZone ones, whose buckets are not rounded by the daylight saving time.
+func WithTimeZone(loc *time.Location) TimeSeriesOption {
+	return func(p *timeSeriesParams) {
+		p.location = loc
+	}
+}
+
+// WithExtendedBounds - the range of the time series, the empty buckets are filled between from and to
+// so the series has no gap even when there is no document at its ends.
+func WithExtendedBounds(from time.Time, to time.Time) TimeSeriesOption {
+	return func(p *timeSeriesParams) {
+		p.from = from
+		p.to = to
+	}
+}
+
+// WithTimeSeriesSearchOptions - the options of the search request.
+func WithTimeSeriesSearchOptions(opts ...func(*SearchRequest)) TimeSeriesOption {
+	return func(p *timeSeriesParams) {
+		p.opts = append(p.opts, opts...)
+	}
+}
+
+// TimeSeries returns the date histogram of the documents matched by the query with the metrics of each bucket,
+// e.g. the avg cpu usage per minute. The metrics are the single value aggregations like AvgAgg, the empty buckets
+// are included so the series is continuous.
+func TimeSeries(ctx context.Context, oper ESOper, index string, dateField string, interval string, metrics map[string]Agg, query string, opts ...TimeSeriesOption) ([]*TimeSeriesBucket, error) {
+	p := &timeSeriesParams{}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	body := map[string]interface{}{}
+	if query != "" {
+		if err := json.Unmarshal([]byte(query), &body); err != nil {
+			return nil, fmt.Errorf("nes: the request body is not a JSON object: %w", err)
+		}
+	}
+	histogram := DateHistogramAgg(dateField, interval).With("min_doc_count", 0)
+	if p.location != nil {
+		at := p.from
+		if at.IsZero() {
+			at = time.Now()
+		}
+		histogram.With("time_zone", timeZoneID(p.location, at))
+	}
+	if !p.from.IsZero() || !p.to.IsZero() {
+		bounds := map[string]interface{}{}
+		if !p.from.IsZero() {
+			bounds["min"] = p.from.UnixMilli()
+		}
+		if !p.to.IsZero() {
+			bounds["max"] = p.to.UnixMilli()
+		}
+		histogram.With("extended_bounds", bounds)
+	}
+	if len(metrics) > 0 {
+		histogram.SubAggs(metrics)
+	}
+	body["size"] = 0
+	body["aggs"] = map[string]Agg{timeSeriesAggName: histogram}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody := &aggsResponseBody{}
+	if _, err := oper.Search(ctx, respBody, string(b), []string{index}, p.opts...); err != nil {
+		return nil, err
+	}
+	buckets, err := respBody.Aggregations.Buckets(timeSeriesAggName)
+	if err != nil {
+		return nil, err
+	}
+	series := make([]*TimeSeriesBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		key, ok := bucket.Key.(float64)
+		if !ok {
+			return nil, fmt.Errorf("nes: the key of the date histogram bucket is not a timestamp: %v", bucket.Key)
+		}
+		point := &TimeSeriesBucket{
+			Timestamp: time.UnixMilli(int64(key)),
+			DocCount:  bucket.DocCount,
+			Values:    make(map[string]float64, len(metrics)),
+		}
+		if p.location != nil {
+			point.Timestamp = point.Timestamp.In(p.location)
+		}
+		for name := range metrics {
+			v, err := bucket.Aggs.Value(name)
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				point.Values[name] = *v
+			}
+		}
+		series = append(series, point)
+	}
+	return series, nil
+}
+
+// timeZoneID returns the IANA name of the location, or its offset at the time like +08:00 if it has none, the cluster
+// rejects the other names like Local.
+func timeZoneID(loc *time.Location, at time.Time) string {
+	name := loc.String()
+	if loc == time.Local {
+		name = localZoneName()
+	}
+	if name != "" && name != "Local" {
+		if _, err := time.LoadLocation(name); err == nil {
+			return name
+		}
+	}
+	return at.In(loc).Format("-07:00")
+}
+
+// localZoneName returns the IANA name of time.Local by the TZ environment variable or the /etc/localtime link,
+// it's empty if it's unknown.
+func localZoneName() string {
+	if tz, ok := os.LookupEnv("TZ"); ok {
+		return strings.TrimPrefix(tz, ":")
+	}
+	target, err := os.Readlink("/etc/localtime")
+	if err != nil {
+		return ""
+	}
+	if i := strings.LastIndex(target, "zoneinfo/"); i >= 0 {
+		return target[i+len("zoneinfo/"):]
+	}
+	return ""
+}
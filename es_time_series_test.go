@@ -0,0 +1,50 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestTimeZoneIDIsAcceptedByTheCluster(t *testing.T) {
+	at := time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC)
+	if id := timeZoneID(time.UTC, at); id != "UTC" {
+		t.Errorf("the id of UTC is %s", id)
+	}
+	if id := timeZoneID(time.FixedZone("CST", 8*3600), at); id != "+08:00" {
+		t.Errorf("the id of the fixed zone is %s, expected +08:00", id)
+	}
+	if id := timeZoneID(time.FixedZone("", -(5*3600+1800)), at); id != "-05:30" {
+		t.Errorf("the id of the fixed zone is %s, expected -05:30", id)
+	}
+	if loc, err := time.LoadLocation("America/New_York"); err == nil {
+		if id := timeZoneID(loc, at); id != "America/New_York" {
+			t.Errorf("the id of America/New_York is %s", id)
+		}
+	}
+
+	t.Setenv("TZ", "Europe/Berlin")
+	if _, err := time.LoadLocation("Europe/Berlin"); err == nil {
+		if id := timeZoneID(time.Local, at); id != "Europe/Berlin" {
+			t.Errorf("the id of Local with TZ=Europe/Berlin is %s", id)
+		}
+	}
+	t.Setenv("TZ", "")
+	if id := timeZoneID(time.Local, at); !regexp.MustCompile(`^[+-]\d\d:\d\d$`).MatchString(id) {
+		t.Errorf("the id of Local with an empty TZ is %s, expected an offset", id)
+	}
+}
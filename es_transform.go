@@ -0,0 +1,176 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+// Transform - the definition of a transform, either Pivot or Latest is set.
+type Transform struct {
+	Description string                 `json:"description,omitempty"`
+	Source      *TransformSource       `json:"source"`
+	Dest        *TransformDest         `json:"dest"`
+	Pivot       *TransformPivot        `json:"pivot,omitempty"`
+	Latest      map[string]interface{} `json:"latest,omitempty"`
+	Frequency   string                 `json:"frequency,omitempty"`
+	// Sync makes the transform continuous.
+	Sync     *TransformSync         `json:"sync,omitempty"`
+	Settings map[string]interface{} `json:"settings,omitempty"`
+}
+
+// TransformSource - the source indices of the transform, the documents are filtered by the query if it's not nil.
+type TransformSource struct {
+	Index []string    `json:"index"`
+	Query interface{} `json:"query,omitempty"`
+}
+
+// TransformDest - the destination index of the transform.
+type TransformDest struct {
+	Index    string `json:"index"`
+	Pipeline string `json:"pipeline,omitempty"`
+}
+
+// TransformPivot - the entities grouped by the group_by sources, e.g. {"user": {"terms": {"field": "user_id"}}},
+// and summarized by the aggregations.
+type TransformPivot struct {
+	GroupBy      map[string]interface{} `json:"group_by"`
+	Aggregations map[string]Agg         `json:"aggregations"`
+}
+
+// TransformSync - the sync of the continuous transform.
+type TransformSync struct {
+	Time *TransformTimeSync `json:"time"`
+}
+
+// TransformTimeSync - the documents are synced by the time field with the delay of the ingestion.
+type TransformTimeSync struct {
+	Field string `json:"field"`
+	Delay string `json:"delay,omitempty"`
+}
+
+// TransformStats - the state and the stats of a transform.
+type TransformStats struct {
+	ID            string                 `json:"id"`
+	State         string                 `json:"state"`
+	Reason        string                 `json:"reason"`
+	Stats         map[string]interface{} `json:"stats"`
+	Checkpointing map[string]interface{} `json:"checkpointing"`
+	Health        map[string]interface{} `json:"health"`
+}
+
+type transformStatsResponseBody struct {
+	Transforms []*TransformStats `json:"transforms"`
+}
+
+// TransformPreview - the preview of the documents generated by a transform and the mappings of the dest index.
+type TransformPreview struct {
+	Preview            []map[string]interface{} `json:"preview"`
+	GeneratedDestIndex map[string]interface{}   `json:"generated_dest_index"`
+}
+
+func (e *esAdminOper) PutTransform(ctx context.Context, id string, transform *Transform, opts ...func(*TransformPutTransformRequest)) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(transform); err != nil {
+		return err
+	}
+	api := e.client
+	o := append([]func(*TransformPutTransformRequest){api.TransformPutTransform.WithContext(ctx)}, opts...)
+	resp, err := api.TransformPutTransform(body, id, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) StartTransform(ctx context.Context, id string, opts ...func(*TransformStartTransformRequest)) error {
+	api := e.client
+	o := append([]func(*TransformStartTransformRequest){api.TransformStartTransform.WithContext(ctx)}, opts...)
+	resp, err := api.TransformStartTransform(id, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) StopTransform(ctx context.Context, id string, opts ...func(*TransformStopTransformRequest)) error {
+	api := e.client
+	o := append([]func(*TransformStopTransformRequest){api.TransformStopTransform.WithContext(ctx)}, opts...)
+	resp, err := api.TransformStopTransform(id, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) DeleteTransform(ctx context.Context, id string, opts ...func(*TransformDeleteTransformRequest)) error {
+	api := e.client
+	o := append([]func(*TransformDeleteTransformRequest){api.TransformDeleteTransform.WithContext(ctx)}, opts...)
+	resp, err := api.TransformDeleteTransform(id, o...)
+	if err != nil {
+		return err
+	}
+	defer closeResponse(resp)
+	if resp.IsError() {
+		return newRespErr(resp)
+	}
+	return nil
+}
+
+func (e *esAdminOper) GetTransformStats(ctx context.Context, id string, opts ...func(*TransformGetTransformStatsRequest)) ([]*TransformStats, error) {
+	api := e.client
+	o := append([]func(*TransformGetTransformStatsRequest){api.TransformGetTransformStats.WithContext(ctx)}, opts...)
+	resp, err := api.TransformGetTransformStats(id, o...)
+	if err != nil {
+		return nil, err
+	}
+	respBody := &transformStatsResponseBody{}
+	if err := unmarshallResponse(resp, respBody); err != nil {
+		return nil, err
+	}
+	return respBody.Transforms, nil
+}
+
+func (e *esAdminOper) PreviewTransform(ctx context.Context, transform *Transform, opts ...func(*TransformPreviewTransformRequest)) (*TransformPreview, error) {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(transform); err != nil {
+		return nil, err
+	}
+	api := e.client
+	o := append([]func(*TransformPreviewTransformRequest){api.TransformPreviewTransform.WithContext(ctx), api.TransformPreviewTransform.WithBody(body)}, opts...)
+	resp, err := api.TransformPreviewTransform(o...)
+	if err != nil {
+		return nil, err
+	}
+	preview := &TransformPreview{}
+	if err := unmarshallResponse(resp, preview); err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
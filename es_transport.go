@@ -0,0 +1,220 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/nf-go/nfgo/ncontext"
+)
+
+// the headers set by the client
+const (
+	// HeaderOpaqueID - the header identifying the request in the slow logs and the tasks of the cluster.
+	HeaderOpaqueID = "X-Opaque-Id"
+	// HeaderRunAs - the header making the request on behalf of the user, the authenticated user must have the run_as privilege.
+	HeaderRunAs = "es-security-runas-user"
+)
+
+// ESClientOption - the option of the client.
+type ESClientOption func(*esTransport)
+
+// WithOpaqueIDExtractor - the function extracting the X-Opaque-Id header of each request from its context,
+// the trace id of the nfgo MDC is used by default. The header is not set if it returns an empty string.
+func WithOpaqueIDExtractor(fn func(ctx context.Context) string) ESClientOption {
+	return func(t *esTransport) {
+		t.opaqueID = fn
+	}
+}
+
+// WithDefaultHeader - the header set on all the requests of the client, the header of the request itself takes
+// precedence. Set the headers of a single operation by its context with ContextWithHeader instead.
+func WithDefaultHeader(key string, value string) ESClientOption {
+	return func(t *esTransport) {
+		t.header.Set(key, value)
+	}
+}
+
+// WithBaseTransport - the transport sending the requests, a clone of http.DefaultTransport by default,
+// e.g. the stub transport of the tests.
+func WithBaseTransport(base http.RoundTripper) ESClientOption {
+	return func(t *esTransport) {
+		if base != nil {
+			t.base = base
+		}
+	}
+}
+
+// WithRunAs - the user on behalf of which all the requests are made.
+func WithRunAs(username string) ESClientOption {
+	return WithDefaultHeader(HeaderRunAs, username)
+}
+
+// WithRunAsExtractor - the function extracting the user on behalf of which each request is made from its context,
+// e.g. the authenticated user of the application. The header is not set if it returns an empty string.
+func WithRunAsExtractor(fn func(ctx context.Context) string) ESClientOption {
+	return func(t *esTransport) {
+		t.runAs = fn
+	}
+}
+
+// WithRunAsSubject - the requests are made on behalf of the subject id of the nfgo MDC,
+// so the authorization of each user is enforced by the cluster.
+func WithRunAsSubject() ESClientOption {
+	return WithRunAsExtractor(mdcSubjectID)
+}
+
+// ContextWithRunAs returns a copy of the context making the requests on behalf of the user.
+func ContextWithRunAs(ctx context.Context, username string) context.Context {
+	return ContextWithHeader(ctx, HeaderRunAs, username)
+}
+
+type headerCtxKey struct{}
+
+// ContextWithHeader returns a copy of the context carrying the header, which is set on the requests made
+// with the context by the client created with NewESClient, e.g. the routing or compatibility headers per request.
+// It's the way to set the headers of a single operation, the header of the context takes precedence over the
+// ones of WithDefaultHeader.
+func ContextWithHeader(ctx context.Context, key string, value string) context.Context {
+	h := HeaderFromContext(ctx).Clone()
+	if h == nil {
+		h = http.Header{}
+	}
+	h.Set(key, value)
+	return context.WithValue(ctx, headerCtxKey{}, h)
+}
+
+// HeaderFromContext returns the header carried by the context, nil if there is no header.
+func HeaderFromContext(ctx context.Context) http.Header {
+	h, _ := ctx.Value(headerCtxKey{}).(http.Header)
+	return h
+}
+
+func mdcTraceID(ctx context.Context) string {
+	mdc, err := ncontext.CurrentMDC(ctx)
+	if err != nil {
+		return ""
+	}
+	return mdc.TraceID()
+}
+
+type esTransport struct {
+	base     http.RoundTripper
+	opaqueID func(ctx context.Context) string
+	runAs    func(ctx context.Context) string
+	header   http.Header
+
+	nodeHealth *NodeHealthTracker
+	recorder   *Recorder
+	compat7    *compat7
+	version    *clusterVersion
+}
+
+func newESTransport(opts ...ESClientOption) *esTransport {
+	t := &esTransport{
+		base:     http.DefaultTransport.(*http.Transport).Clone(),
+		opaqueID: mdcTraceID,
+		header:   http.Header{},
+		version:  &clusterVersion{},
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+func (t *esTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// the retries of the client rewind the bodies by the GetBody it sets
+	ctx := req.Context()
+	if t.compat7 != nil {
+		if err := t.compat7.check(req); err != nil {
+			return nil, err
+		}
+	}
+	if err := t.version.check(req); err != nil {
+		return nil, err
+	}
+	// the header of the context takes precedence over the one of the client
+	req = t.setHeader(req, HeaderFromContext(ctx))
+	if t.runAs != nil && req.Header.Get(HeaderRunAs) == "" {
+		if user := t.runAs(ctx); user != "" {
+			req = t.setHeader(req, http.Header{HeaderRunAs: []string{user}})
+		}
+	}
+	req = t.setHeader(req, t.header)
+	if t.opaqueID != nil && req.Header.Get(HeaderOpaqueID) == "" {
+		if id := t.opaqueID(ctx); id != "" {
+			req = req.Clone(ctx)
+			req.Header.Set(HeaderOpaqueID, id)
+		}
+	}
+	if t.nodeHealth == nil {
+		return t.roundTrip(req)
+	}
+	start := time.Now()
+	resp, err := t.roundTrip(req)
+	// the requests canceled by the callers say nothing about the node
+	if err != nil && ctx.Err() != nil {
+		return resp, err
+	}
+	t.nodeHealth.observe(req.URL.Host, time.Since(start), err != nil || resp.StatusCode >= http.StatusInternalServerError)
+	return resp, err
+}
+
+func (t *esTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	if t.compat7 == nil {
+		return t.send(req)
+	}
+	req = t.compat7.request(req)
+	resp, err := t.send(req)
+	if err != nil {
+		return resp, err
+	}
+	if err := t.compat7.response(req, resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (t *esTransport) send(req *http.Request) (resp *http.Response, err error) {
+	if t.recorder == nil {
+		resp, err = t.base.RoundTrip(req)
+	} else {
+		resp, err = t.recorder.roundTrip(t.base, req)
+	}
+	if resp != nil {
+		recordStatus(req.Context(), resp.StatusCode)
+	}
+	return resp, err
+}
+
+// setHeader sets the header absent in the request, the request is cloned before being changed.
+func (t *esTransport) setHeader(req *http.Request, h http.Header) *http.Request {
+	cloned := false
+	for k, v := range h {
+		if req.Header.Get(k) != "" {
+			continue
+		}
+		if !cloned {
+			req = req.Clone(req.Context())
+			cloned = true
+		}
+		req.Header[http.CanonicalHeaderKey(k)] = v
+	}
+	return req
+}
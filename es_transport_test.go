@@ -0,0 +1,63 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// headerTransport - records the header of the last request.
+type headerTransport struct {
+	header http.Header
+}
+
+func (s *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.header = req.Header.Clone()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}, "X-Elastic-Product": []string{"Elasticsearch"}},
+		Body:       io.NopCloser(strings.NewReader(`{}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestTheHeaderOfTheContextOverridesTheDefaultHeader(t *testing.T) {
+	stub := &headerTransport{}
+	client, err := NewESClient(&ESConfig{Addrs: []string{"http://nes.invalid:9200"}, Version: "8.13.1"}, WithBaseTransport(stub),
+		WithDefaultHeader("X-Tag", "client"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		ctx  context.Context
+		want string
+	}{
+		{context.Background(), "client"},
+		{ContextWithHeader(context.Background(), "X-Tag", "op"), "op"},
+	} {
+		resp, err := client.Ping(client.Ping.WithContext(c.ctx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		closeResponse(resp)
+		if got := stub.header.Get("X-Tag"); got != c.want {
+			t.Errorf("X-Tag %q, want %q", got, c.want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nf-go/nfgo/nlog"
+	"github.com/nf-go/nfgo/nutil/graceful"
+	"go.uber.org/multierr"
+)
+
+// DefaultTTLField - the field of the expiration time of the documents, it should be mapped as a date.
+const DefaultTTLField = "expires_at"
+
+// TTL - the expiration time of a document, embedded in the models to carry the DefaultTTLField.
+type TTL struct {
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// ExpireAfter sets the expiration time of the document to the ttl after now.
+func (t *TTL) ExpireAfter(ttl time.Duration) {
+	at := time.Now().Add(ttl).UTC()
+	t.ExpiresAt = &at
+}
+
+// Expired reports whether the document is expired, it may not be purged yet.
+func (t *TTL) Expired() bool {
+	return t.ExpiresAt != nil && !t.ExpiresAt.After(time.Now())
+}
+
+// ExpiredQuery - the query of the documents expired by the field.
+func ExpiredQuery(field string) *Query {
+	return RangeQuery(field).Lte("now")
+}
+
+// NotExpiredQuery - the filter of the documents not expired by the field, including the ones without expiration,
+// for the reads hiding the expired documents not purged yet.
+func NotExpiredQuery(field string) *Query {
+	return BoolQuery().MustNot(ExpiredQuery(field))
+}
+
+// TTLIndex - the TTL of the documents of an index.
+type TTLIndex struct {
+	// Index is the index, alias or pattern purged.
+	Index string
+	// Field is the date field of the expiration time, DefaultTTLField by default.
+	Field string
+	// Interval is the interval of the purges of the index, 1 hour by default.
+	Interval time.Duration
+	// RequestsPerSecond throttles the delete_by_query of the purges, it's not throttled if it's not positive.
+	RequestsPerSecond int
+	// BatchSize is the scroll size of the delete_by_query, 1000 by default.
+	BatchSize int
+	// MaxDocs caps the documents deleted in a purge, the rest are left to the next one if it's positive.
+	MaxDocs int
+}
+
+func (t *TTLIndex) field() string {
+	if t.Field == "" {
+		return DefaultTTLField
+	}
+	return t.Field
+}
+
+func (t *TTLIndex) interval() time.Duration {
+	if t.Interval <= 0 {
+		return time.Hour
+	}
+	return t.Interval
+}
+
+// TTLConfig -
+type TTLConfig struct {
+	Oper    ESOper
+	Indices []*TTLIndex
+}
+
+// TTLPurger - purges the expired documents of the indices by delete_by_query, emulating the TTL removed from
+// Elasticsearch. It's a graceful.ShutdownServer purging each index at its interval.
+type TTLPurger interface {
+	graceful.ShutdownServer
+
+	// PurgeOnce purges the expired documents of all the indices.
+	PurgeOnce(ctx context.Context) error
+}
+
+// NewTTLPurger -
+func NewTTLPurger(config *TTLConfig) TTLPurger {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ttlPurger{config: config, ctx: ctx, cancel: cancel, stopped: make(chan struct{})}
+}
+
+type ttlPurger struct {
+	config  *TTLConfig
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped chan struct{}
+
+	mu      sync.Mutex
+	serving bool
+}
+
+func (p *ttlPurger) PurgeOnce(ctx context.Context) error {
+	var errs error
+	for _, index := range p.config.Indices {
+		errs = multierr.Append(errs, p.purge(ctx, index))
+	}
+	return errs
+}
+
+func (p *ttlPurger) purge(ctx context.Context, index *TTLIndex) error {
+	query, err := NewSearchBody(ExpiredQuery(index.field())).JSON()
+	if err != nil {
+		return err
+	}
+	batchSize := index.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	err = p.config.Oper.DeleteByQuery(ctx, query, []string{index.Index}, func(r *DeleteByQueryRequest) {
+		// the documents updated meanwhile are purged by the next run
+		r.Conflicts = "proceed"
+		r.ScrollSize = &batchSize
+		if index.RequestsPerSecond > 0 {
+			rps := index.RequestsPerSecond
+			r.RequestsPerSecond = &rps
+		}
+		if index.MaxDocs > 0 {
+			maxDocs := index.MaxDocs
+			r.MaxDocs = &maxDocs
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("nes ttl purger: fail to purge %s: %w", index.Index, err)
+	}
+	return nil
+}
+
+// Serve purges the indices at their intervals until it's shutdown, the failures are logged and retried in the next run.
+func (p *ttlPurger) Serve() error {
+	p.mu.Lock()
+	p.serving = true
+	p.mu.Unlock()
+	defer close(p.stopped)
+	var wg sync.WaitGroup
+	for _, index := range p.config.Indices {
+		wg.Add(1)
+		go func(index *TTLIndex) {
+			defer wg.Done()
+			ticker := time.NewTicker(index.interval())
+			defer ticker.Stop()
+			for {
+				if err := p.purge(p.ctx, index); err != nil && p.ctx.Err() == nil {
+					nlog.Logger(p.ctx).Errorf("%s", err)
+				}
+				select {
+				case <-p.ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(index)
+	}
+	<-p.ctx.Done()
+	wg.Wait()
+	return nil
+}
+
+func (p *ttlPurger) MustServe() {
+	if err := p.Serve(); err != nil {
+		nlog.Fatal("fail to serve the nes ttl purger: ", err)
+	}
+}
+
+// Shutdown cancels the purges in flight, it returns at once if it's not served.
+func (p *ttlPurger) Shutdown(ctx context.Context) error {
+	p.cancel()
+	p.mu.Lock()
+	serving := p.serving
+	p.mu.Unlock()
+	if !serving {
+		return nil
+	}
+	select {
+	case <-p.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,418 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"time"
+)
+
+// The typed options are owned by nes unlike the func(*esapi.XxxRequest) options of the oper, so the callers of
+// TypedOper don't depend on the requests of esapi and the backend can be swapped, e.g. to OpenSearch or a mock.
+// They're translated into the esapi options by the TypedOper of NewTypedOper.
+
+// SearchOption - the typed option of the searches.
+type SearchOption func(*searchParams)
+
+type searchParams struct {
+	routing        []string
+	timeout        time.Duration
+	from           *int
+	size           *int
+	terminateAfter *int
+	trackTotalHits interface{}
+	// the options shared with the oper
+	opts []func(*SearchRequest)
+}
+
+// WithSearchRouting - the routings of the shards searched.
+func WithSearchRouting(routing ...string) SearchOption {
+	return func(p *searchParams) {
+		p.routing = append(p.routing, routing...)
+	}
+}
+
+// WithSearchPreference - the preference of the shard copies searched like WithPreference.
+func WithSearchPreference(preference string) SearchOption {
+	return func(p *searchParams) {
+		p.opts = append(p.opts, WithPreference(preference))
+	}
+}
+
+// WithSearchTimeout - the timeout of the search on the shards, the partial results are returned when it's exceeded.
+func WithSearchTimeout(timeout time.Duration) SearchOption {
+	return func(p *searchParams) {
+		p.timeout = timeout
+	}
+}
+
+// WithSearchPage - the from and the size of the hits, overriding the ones of the query.
+func WithSearchPage(from int, size int) SearchOption {
+	return func(p *searchParams) {
+		p.from, p.size = &from, &size
+	}
+}
+
+// WithSearchTerminateAfter - the max number of the documents collected per shard.
+func WithSearchTerminateAfter(n int) SearchOption {
+	return func(p *searchParams) {
+		p.terminateAfter = &n
+	}
+}
+
+// WithSearchTrackTotalHits - the total hits are counted accurately up to the limit, all of them if it's negative.
+func WithSearchTrackTotalHits(limit int) SearchOption {
+	return func(p *searchParams) {
+		if limit < 0 {
+			p.trackTotalHits = true
+		} else {
+			p.trackTotalHits = limit
+		}
+	}
+}
+
+// WithSearchRequestCache - whether the shard request cache is used like WithRequestCache.
+func WithSearchRequestCache(enabled bool) SearchOption {
+	return func(p *searchParams) {
+		p.opts = append(p.opts, WithRequestCache(enabled))
+	}
+}
+
+// WithSearchPartialResults - whether the partial results are returned like WithAllowPartialSearchResults.
+func WithSearchPartialResults(allowed bool) SearchOption {
+	return func(p *searchParams) {
+		p.opts = append(p.opts, WithAllowPartialSearchResults(allowed))
+	}
+}
+
+func searchOptions(opts []SearchOption) func(*SearchRequest) {
+	p := &searchParams{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return func(r *SearchRequest) {
+		if len(p.routing) > 0 {
+			r.Routing = p.routing
+		}
+		if p.timeout > 0 {
+			r.Timeout = p.timeout
+		}
+		if p.from != nil {
+			r.From, r.Size = p.from, p.size
+		}
+		if p.terminateAfter != nil {
+			r.TerminateAfter = p.terminateAfter
+		}
+		if p.trackTotalHits != nil {
+			r.TrackTotalHits = p.trackTotalHits
+		}
+		for _, opt := range p.opts {
+			opt(r)
+		}
+	}
+}
+
+// CountOption - the typed option of the counts.
+type CountOption func(*countParams)
+
+type countParams struct {
+	routing        []string
+	preference     string
+	terminateAfter *int
+}
+
+// WithCountRouting - the routings of the shards counted.
+func WithCountRouting(routing ...string) CountOption {
+	return func(p *countParams) {
+		p.routing = append(p.routing, routing...)
+	}
+}
+
+// WithCountPreference - the preference of the shard copies counted.
+func WithCountPreference(preference string) CountOption {
+	return func(p *countParams) {
+		p.preference = preference
+	}
+}
+
+// WithCountTerminateAfter - the max number of the documents counted per shard.
+func WithCountTerminateAfter(n int) CountOption {
+	return func(p *countParams) {
+		p.terminateAfter = &n
+	}
+}
+
+func countOptions(opts []CountOption) func(*CountRequest) {
+	p := &countParams{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return func(r *CountRequest) {
+		if len(p.routing) > 0 {
+			r.Routing = p.routing
+		}
+		if p.preference != "" {
+			r.Preference = p.preference
+		}
+		if p.terminateAfter != nil {
+			r.TerminateAfter = p.terminateAfter
+		}
+	}
+}
+
+// GetOption - the typed option of the document reads.
+type GetOption func(*getParams)
+
+type getParams struct {
+	routing        string
+	preference     string
+	realtime       *bool
+	sourceIncludes []string
+	sourceExcludes []string
+}
+
+// WithGetRouting - the routing of the document.
+func WithGetRouting(routing string) GetOption {
+	return func(p *getParams) {
+		p.routing = routing
+	}
+}
+
+// WithGetPreference - the preference of the shard copy read.
+func WithGetPreference(preference string) GetOption {
+	return func(p *getParams) {
+		p.preference = preference
+	}
+}
+
+// WithGetRealtime - reads the document in realtime or from the last refresh.
+func WithGetRealtime(realtime bool) GetOption {
+	return func(p *getParams) {
+		p.realtime = &realtime
+	}
+}
+
+// WithGetSource - the fields of the _source included and excluded.
+func WithGetSource(includes []string, excludes []string) GetOption {
+	return func(p *getParams) {
+		p.sourceIncludes, p.sourceExcludes = includes, excludes
+	}
+}
+
+func getOptions(opts []GetOption) func(*GetRequest) {
+	p := &getParams{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return func(r *GetRequest) {
+		if p.routing != "" {
+			r.Routing = p.routing
+		}
+		if p.preference != "" {
+			r.Preference = p.preference
+		}
+		if p.realtime != nil {
+			r.Realtime = p.realtime
+		}
+		if len(p.sourceIncludes) > 0 {
+			r.SourceIncludes = p.sourceIncludes
+		}
+		if len(p.sourceExcludes) > 0 {
+			r.SourceExcludes = p.sourceExcludes
+		}
+	}
+}
+
+// WriteOption - the typed option of the document writes, the ones not supported by a write are ignored,
+// e.g. the pipeline of the deletes.
+type WriteOption func(*writeParams)
+
+type writeParams struct {
+	refresh       string
+	routing       string
+	timeout       time.Duration
+	pipeline      string
+	ifSeqNo       *int
+	ifPrimaryTerm *int
+}
+
+// WithWriteRefresh - the refresh policy of the write, one of true, false and wait_for,
+// the default refresh of the oper by default.
+func WithWriteRefresh(refresh string) WriteOption {
+	return func(p *writeParams) {
+		p.refresh = refresh
+	}
+}
+
+// WithWriteRouting - the routing of the document.
+func WithWriteRouting(routing string) WriteOption {
+	return func(p *writeParams) {
+		p.routing = routing
+	}
+}
+
+// WithWriteTimeout - the timeout of waiting for the active shards.
+func WithWriteTimeout(timeout time.Duration) WriteOption {
+	return func(p *writeParams) {
+		p.timeout = timeout
+	}
+}
+
+// WithWritePipeline - the ingest pipeline of the indexed document.
+func WithWritePipeline(pipeline string) WriteOption {
+	return func(p *writeParams) {
+		p.pipeline = pipeline
+	}
+}
+
+// WithWriteIfSeqNo - the write succeeds only if the document is still at the seq_no and the primary_term,
+// the conflicts are reported by IsConflict.
+func WithWriteIfSeqNo(seqNo int, primaryTerm int) WriteOption {
+	return func(p *writeParams) {
+		p.ifSeqNo, p.ifPrimaryTerm = &seqNo, &primaryTerm
+	}
+}
+
+func newWriteParams(opts []WriteOption) *writeParams {
+	p := &writeParams{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+func indexOptions(opts []WriteOption) func(*IndexRequest) {
+	p := newWriteParams(opts)
+	return func(r *IndexRequest) {
+		if p.refresh != "" {
+			r.Refresh = p.refresh
+		}
+		if p.routing != "" {
+			r.Routing = p.routing
+		}
+		if p.timeout > 0 {
+			r.Timeout = p.timeout
+		}
+		if p.pipeline != "" {
+			r.Pipeline = p.pipeline
+		}
+		if p.ifSeqNo != nil {
+			r.IfSeqNo, r.IfPrimaryTerm = p.ifSeqNo, p.ifPrimaryTerm
+		}
+	}
+}
+
+func createOptions(opts []WriteOption) func(*CreateRequest) {
+	p := newWriteParams(opts)
+	return func(r *CreateRequest) {
+		if p.refresh != "" {
+			r.Refresh = p.refresh
+		}
+		if p.routing != "" {
+			r.Routing = p.routing
+		}
+		if p.timeout > 0 {
+			r.Timeout = p.timeout
+		}
+		if p.pipeline != "" {
+			r.Pipeline = p.pipeline
+		}
+	}
+}
+
+func updateOptions(opts []WriteOption) func(*UpdateRequest) {
+	p := newWriteParams(opts)
+	return func(r *UpdateRequest) {
+		if p.refresh != "" {
+			r.Refresh = p.refresh
+		}
+		if p.routing != "" {
+			r.Routing = p.routing
+		}
+		if p.timeout > 0 {
+			r.Timeout = p.timeout
+		}
+		if p.ifSeqNo != nil {
+			r.IfSeqNo, r.IfPrimaryTerm = p.ifSeqNo, p.ifPrimaryTerm
+		}
+	}
+}
+
+func deleteOptions(opts []WriteOption) func(*DeleteRequest) {
+	p := newWriteParams(opts)
+	return func(r *DeleteRequest) {
+		if p.refresh != "" {
+			r.Refresh = p.refresh
+		}
+		if p.routing != "" {
+			r.Routing = p.routing
+		}
+		if p.timeout > 0 {
+			r.Timeout = p.timeout
+		}
+		if p.ifSeqNo != nil {
+			r.IfSeqNo, r.IfPrimaryTerm = p.ifSeqNo, p.ifPrimaryTerm
+		}
+	}
+}
+
+// TypedOper - the document operations and the searches with the typed options, it can be implemented by the other
+// backends or the mocks without esapi.
+type TypedOper interface {
+	Get(ctx context.Context, model interface{}, index string, id string, opts ...GetOption) (interface{}, error)
+	Create(ctx context.Context, index string, id string, obj interface{}, opts ...WriteOption) error
+	Index(ctx context.Context, index string, id string, obj interface{}, opts ...WriteOption) error
+	Update(ctx context.Context, index string, id string, obj interface{}, opts ...WriteOption) error
+	Delete(ctx context.Context, index string, id string, opts ...WriteOption) error
+	Count(ctx context.Context, query string, indexes []string, opts ...CountOption) (int64, error)
+	Search(ctx context.Context, model interface{}, query string, indexes []string, opts ...SearchOption) (interface{}, error)
+}
+
+// NewTypedOper - the TypedOper of the oper, whose typed options are translated into the esapi options.
+func NewTypedOper(oper ESOper) TypedOper {
+	return &typedOper{oper: oper}
+}
+
+type typedOper struct {
+	oper ESOper
+}
+
+func (t *typedOper) Get(ctx context.Context, model interface{}, index string, id string, opts ...GetOption) (interface{}, error) {
+	return t.oper.Get(ctx, model, index, id, getOptions(opts))
+}
+
+func (t *typedOper) Create(ctx context.Context, index string, id string, obj interface{}, opts ...WriteOption) error {
+	return t.oper.Create(ctx, index, id, obj, createOptions(opts))
+}
+
+func (t *typedOper) Index(ctx context.Context, index string, id string, obj interface{}, opts ...WriteOption) error {
+	return t.oper.Index(ctx, index, id, obj, indexOptions(opts))
+}
+
+func (t *typedOper) Update(ctx context.Context, index string, id string, obj interface{}, opts ...WriteOption) error {
+	return t.oper.Update(ctx, index, id, obj, updateOptions(opts))
+}
+
+func (t *typedOper) Delete(ctx context.Context, index string, id string, opts ...WriteOption) error {
+	return t.oper.DeleteDoc(ctx, DocRef{Index: index, ID: id}, deleteOptions(opts))
+}
+
+func (t *typedOper) Count(ctx context.Context, query string, indexes []string, opts ...CountOption) (int64, error) {
+	return t.oper.Count(ctx, query, indexes, countOptions(opts))
+}
+
+func (t *typedOper) Search(ctx context.Context, model interface{}, query string, indexes []string, opts ...SearchOption) (interface{}, error) {
+	return t.oper.Search(ctx, model, query, indexes, searchOptions(opts))
+}
@@ -0,0 +1,202 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrUnsupportedByCluster - the feature is not supported by the version of the cluster.
+var ErrUnsupportedByCluster = errors.New("nes: the feature is unsupported by the cluster")
+
+// the products of the clusters
+const (
+	ProductElasticsearch = "Elasticsearch"
+	ProductOpenSearch    = "OpenSearch"
+)
+
+// the features only supported by the recent versions, the requests of the point in time and ES|QL are rejected by
+// the client before they're sent to the cluster detected not supporting them
+const (
+	FeaturePIT        = "point in time"
+	FeatureESQL       = "ES|QL"
+	FeatureDownsample = "downsampling"
+)
+
+// featureVersions - the minimum versions of the features on Elasticsearch, they are not supported by OpenSearch.
+var featureVersions = map[string]ClusterVersion{
+	FeaturePIT:        {Major: 7, Minor: 10},
+	FeatureESQL:       {Major: 8, Minor: 11},
+	FeatureDownsample: {Major: 8, Minor: 5},
+}
+
+// ClusterVersion - the product and the version of the cluster.
+type ClusterVersion struct {
+	Product string
+	Number  string
+	Major   int
+	Minor   int
+	Patch   int
+}
+
+// ParseClusterVersion parses the version number like 8.13.1 or 7.17.0-SNAPSHOT of the Elasticsearch cluster.
+func ParseClusterVersion(number string) (*ClusterVersion, error) {
+	v := &ClusterVersion{Product: ProductElasticsearch, Number: number}
+	parts := strings.SplitN(strings.SplitN(number, "-", 2)[0], ".", 3)
+	dest := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("nes: invalid version %s", number)
+		}
+		*dest[i] = n
+	}
+	return v, nil
+}
+
+// AtLeast reports whether the version is the major.minor or a later one.
+func (v *ClusterVersion) AtLeast(major int, minor int) bool {
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
+}
+
+// Supports reports whether the feature is supported by the cluster, the unknown features are supported.
+func (v *ClusterVersion) Supports(feature string) bool {
+	min, ok := featureVersions[feature]
+	if !ok {
+		return true
+	}
+	return v.Product == ProductElasticsearch && v.AtLeast(min.Major, min.Minor)
+}
+
+func (v *ClusterVersion) String() string {
+	return v.Product + " " + v.Number
+}
+
+// WithClusterVersion - the version number of the Elasticsearch cluster like 8.13.1, which skips the detection.
+func WithClusterVersion(number string) ESOperOption {
+	return func(e *esOper) {
+		v, err := ParseClusterVersion(number)
+		if err != nil {
+			e.logger(context.Background()).Warnf("ignore the cluster version: %s", err)
+			return
+		}
+		e.version = &clusterVersion{}
+		e.version.v.Store(v)
+	}
+}
+
+// versionDetectionTimeout - the timeout of the detection of the version by NewESClient.
+const versionDetectionTimeout = 5 * time.Second
+
+// clusterVersions are the versions of the clients created by NewESClient, which are shared by their opers.
+var clusterVersions sync.Map
+
+// versionOf returns the version of the client shared by its opers.
+func versionOf(client *Client) *clusterVersion {
+	if v, ok := clusterVersions.Load(client); ok {
+		return v.(*clusterVersion)
+	}
+	return &clusterVersion{}
+}
+
+// clusterVersion caches the version of the cluster, the detection is retried on the next call if it fails.
+type clusterVersion struct {
+	// mu serializes the detections
+	mu sync.Mutex
+	v  atomic.Pointer[ClusterVersion]
+}
+
+func (c *clusterVersion) get(ctx context.Context, api *Client) (*ClusterVersion, error) {
+	if v := c.v.Load(); v != nil {
+		return v, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v := c.v.Load(); v != nil {
+		return v, nil
+	}
+	resp, err := api.Info(api.Info.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	var info infoResponse
+	if err := unmarshallResponse(resp, &info); err != nil {
+		return nil, err
+	}
+	v, err := ParseClusterVersion(info.Version.Number)
+	if err != nil {
+		return nil, err
+	}
+	if info.Version.Distribution == "opensearch" {
+		v.Product = ProductOpenSearch
+	}
+	c.v.Store(v)
+	return v, nil
+}
+
+// check rejects the request of the feature unsupported by the version detected, the requests are sent if the
+// version is not detected yet.
+func (c *clusterVersion) check(req *http.Request) error {
+	v := c.v.Load()
+	if v == nil {
+		return nil
+	}
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	var feature string
+	switch {
+	case strings.HasSuffix(path, "/_pit"):
+		feature = FeaturePIT
+	case path == "/_query" || strings.HasPrefix(path, "/_query/"):
+		feature = FeatureESQL
+	default:
+		return nil
+	}
+	return requireFeature(v, feature)
+}
+
+func requireFeature(v *ClusterVersion, feature string) error {
+	if v.Supports(feature) {
+		return nil
+	}
+	min := featureVersions[feature]
+	return fmt.Errorf("%w: %s requires Elasticsearch %d.%d or later, the cluster is %s", ErrUnsupportedByCluster, feature, min.Major, min.Minor, v)
+}
+
+type infoResponse struct {
+	Version struct {
+		Number       string `json:"number"`
+		Distribution string `json:"distribution"`
+	} `json:"version"`
+}
+
+func (e *esAdminOper) ClusterVersion(ctx context.Context) (*ClusterVersion, error) {
+	return e.version.get(ctx, e.client)
+}
+
+func (e *esAdminOper) RequireFeature(ctx context.Context, feature string) error {
+	v, err := e.ClusterVersion(ctx)
+	if err != nil {
+		return err
+	}
+	return requireFeature(v, feature)
+}
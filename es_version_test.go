@@ -0,0 +1,91 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newVersionClient(t *testing.T, routes map[string]stubResponse) (*Client, *routeTransport) {
+	t.Helper()
+	stub := &routeTransport{routes: routes}
+	client, err := NewESClient(&ESConfig{Addrs: []string{"http://nes.invalid:9200"}}, WithBaseTransport(stub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, stub
+}
+
+func TestNewESClientDetectsTheVersion(t *testing.T) {
+	client, stub := newVersionClient(t, map[string]stubResponse{
+		"GET /": {http.StatusOK, `{"version":{"number":"7.9.3"}}`},
+	})
+	if sent := stub.sent(); len(sent) != 1 || sent[0] != "GET /" {
+		t.Fatalf("requests %v, want the detection only", sent)
+	}
+	v, err := NewESAdminOper(client).ClusterVersion(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Number != "7.9.3" || len(stub.sent()) != 1 {
+		t.Errorf("version %s detected again by the admin oper", v)
+	}
+	if err := NewESAdminOper(client).RequireFeature(context.Background(), FeatureDownsample); !errors.Is(err, ErrUnsupportedByCluster) {
+		t.Errorf("require %s of 7.9.3: %v", FeatureDownsample, err)
+	}
+}
+
+func TestTheUnsupportedFeaturesAreNotSent(t *testing.T) {
+	client, stub := newVersionClient(t, map[string]stubResponse{
+		"GET /": {http.StatusOK, `{"version":{"number":"7.9.3"}}`},
+	})
+	ctx := context.Background()
+	if _, err := client.OpenPointInTime([]string{"i"}, "1m", client.OpenPointInTime.WithContext(ctx)); !errors.Is(err, ErrUnsupportedByCluster) {
+		t.Errorf("open the point in time of 7.9.3: %v", err)
+	}
+	if _, err := client.EsqlQuery(strings.NewReader(`{"query":"FROM i"}`), client.EsqlQuery.WithContext(ctx)); !errors.Is(err, ErrUnsupportedByCluster) {
+		t.Errorf("query ES|QL of 7.9.3: %v", err)
+	}
+	if sent := stub.sent(); len(sent) != 1 {
+		t.Errorf("requests %v, want the detection only", sent)
+	}
+}
+
+func TestTheVersionIsDetectedLaterIfTheClusterIsUnavailable(t *testing.T) {
+	routes := map[string]stubResponse{}
+	client, stub := newVersionClient(t, routes)
+	// the version is unknown, so the request is sent
+	resp, err := client.OpenPointInTime([]string{"i"}, "1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closeResponse(resp)
+	if sent := stub.sent(); sent[len(sent)-1] != "POST /i/_pit" {
+		t.Errorf("requests %v, want the point in time opened", sent)
+	}
+
+	routes["GET /"] = stubResponse{http.StatusOK, `{"version":{"number":"8.13.1"}}`}
+	v, err := NewESAdminOper(client).ClusterVersion(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.Supports(FeatureESQL) {
+		t.Errorf("%s doesn't support %s", v, FeatureESQL)
+	}
+}
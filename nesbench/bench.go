@@ -0,0 +1,235 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nesbench drives the index and search workloads against a cluster through the nes API and reports
+// the throughput and the latency percentiles, to validate the sizing of the clusters and the overhead of nes.
+//
+//	gen := nesbench.NewDocGenerator(1)
+//	report, err := nesbench.Run(ctx, &nesbench.Workload{
+//		Name:        "bulk",
+//		Concurrency: 4,
+//		Duration:    time.Minute,
+//		Op:          nesbench.IndexOp(oper, "bench", gen, 500),
+//	})
+package nesbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nf-go/nes"
+)
+
+// Op - an operation of the workload, the seq numbers the operations of the run from 0 and the r is the random of
+// the worker. It returns the number of the documents processed, e.g. the size of a bulk.
+type Op func(ctx context.Context, r *rand.Rand, seq int) (int, error)
+
+// Workload - the operation performed by the concurrent workers until the Duration elapses or MaxOps are performed.
+type Workload struct {
+	Name string
+	// Concurrency is the number of the workers, 1 by default.
+	Concurrency int
+	Duration    time.Duration
+	MaxOps      int
+	Op          Op
+	// Seed seeds the randoms of the workers.
+	Seed int64
+}
+
+// Report - the result of a workload run.
+type Report struct {
+	Name       string
+	Ops        int
+	Docs       int
+	Errors     int
+	FirstError error
+	Elapsed    time.Duration
+	// OpsPerSec and DocsPerSec are the throughput of the successful operations.
+	OpsPerSec  float64
+	DocsPerSec float64
+	Mean       time.Duration
+	P50        time.Duration
+	P90        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+}
+
+func (r *Report) String() string {
+	return fmt.Sprintf("%s: %d ops (%d errors), %d docs in %s, %.1f ops/s, %.1f docs/s, latency mean %s p50 %s p90 %s p99 %s max %s",
+		r.Name, r.Ops, r.Errors, r.Docs, r.Elapsed.Round(time.Millisecond), r.OpsPerSec, r.DocsPerSec,
+		r.Mean, r.P50, r.P90, r.P99, r.Max)
+}
+
+// Run runs the workload and reports it, the latencies are the ones of the successful operations.
+func Run(ctx context.Context, w *Workload) (*Report, error) {
+	if w.Op == nil || (w.Duration <= 0 && w.MaxOps <= 0) {
+		return nil, errors.New("nesbench: the op and the duration or the max ops of the workload are required")
+	}
+	concurrency := w.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	if w.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.Duration)
+		defer cancel()
+	}
+
+	var (
+		seq       int64
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		latencies []time.Duration
+	)
+	report := &Report{Name: w.Name}
+	start := time.Now()
+	for worker := 0; worker < concurrency; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(w.Seed + int64(worker)))
+			var local []time.Duration
+			docs, errs := 0, 0
+			var firstErr error
+			for ctx.Err() == nil {
+				n := int(atomic.AddInt64(&seq, 1) - 1)
+				if w.MaxOps > 0 && n >= w.MaxOps {
+					break
+				}
+				opStart := time.Now()
+				processed, err := w.Op(ctx, r, n)
+				if err != nil {
+					// the ops canceled at the end of the duration are not counted
+					if ctx.Err() != nil {
+						break
+					}
+					errs++
+					if firstErr == nil {
+						firstErr = err
+					}
+					continue
+				}
+				local = append(local, time.Since(opStart))
+				docs += processed
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			latencies = append(latencies, local...)
+			report.Docs += docs
+			report.Errors += errs
+			if report.FirstError == nil {
+				report.FirstError = firstErr
+			}
+		}(worker)
+	}
+	wg.Wait()
+	report.Elapsed = time.Since(start)
+	report.Ops = len(latencies) + report.Errors
+	summarize(report, latencies)
+	return report, nil
+}
+
+func summarize(report *Report, latencies []time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+	seconds := report.Elapsed.Seconds()
+	report.OpsPerSec = float64(len(latencies)) / seconds
+	report.DocsPerSec = float64(report.Docs) / seconds
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+	}
+	report.Mean = total / time.Duration(len(latencies))
+	report.P50 = percentile(latencies, 0.50)
+	report.P90 = percentile(latencies, 0.90)
+	report.P99 = percentile(latencies, 0.99)
+	report.Max = latencies[len(latencies)-1]
+}
+
+// percentile returns the nearest-rank percentile of the sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(p*float64(len(sorted))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// IndexOp - the op indexing the generated documents by the bulks of the batch size, the op seq writes the documents
+// from seq*batchSize, so the documents of a run are distinct.
+func IndexOp(oper nes.ESOper, index string, gen *DocGenerator, batchSize int) Op {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	return func(ctx context.Context, r *rand.Rand, seq int) (int, error) {
+		resp, err := oper.BulkWithResponse(ctx, index, func(ctx context.Context, buf *bytes.Buffer) error {
+			enc := json.NewEncoder(buf)
+			for i := seq * batchSize; i < (seq+1)*batchSize; i++ {
+				action := map[string]interface{}{"index": map[string]interface{}{"_id": gen.ID(i)}}
+				if err := enc.Encode(action); err != nil {
+					return err
+				}
+				if err := enc.Encode(gen.Doc(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		if resp.HasErrors {
+			for _, item := range resp.Items {
+				for _, res := range item {
+					if res.Error.Type != "" {
+						return 0, fmt.Errorf("nesbench: fail to index %s: %s: %s", res.DocumentID, res.Error.Type, res.Error.Reason)
+					}
+				}
+			}
+		}
+		return batchSize, nil
+	}
+}
+
+// SearchOp - the op searching the query built by the query func, e.g. MatchQueryOf.
+func SearchOp(oper nes.Searcher, indexes []string, query func(r *rand.Rand) string) Op {
+	return func(ctx context.Context, r *rand.Rand, seq int) (int, error) {
+		var respBody json.RawMessage
+		if _, err := oper.Search(ctx, &respBody, query(r), indexes); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+}
+
+// MatchQueryOf - the query func of SearchOp matching a random word of the generated documents in the body.
+func MatchQueryOf(gen *DocGenerator, size int) func(r *rand.Rand) string {
+	return func(r *rand.Rand) string {
+		body, _ := nes.NewSearchBody(nes.MatchQuery("body", gen.Word(r))).WithPage(0, size).JSON()
+		return body
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nesbench
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var words = strings.Fields(`alpha bravo charlie delta echo foxtrot golf hotel india juliet kilo lima mike november
+oscar papa quebec romeo sierra tango uniform victor whiskey xray yankee zulu cluster shard replica index search query
+document field mapping node segment merge refresh flush snapshot alias template pipeline`)
+
+// DocGenerator - generates the synthetic documents, the document i is the same for the same seed, so the runs
+// against the clusters are comparable.
+type DocGenerator struct {
+	seed int64
+	// BodyWords is the number of the words of the body field, 50 by default.
+	BodyWords int
+	// Start is the earliest created_at of the documents, which spread over the 30 days after it.
+	Start time.Time
+}
+
+// NewDocGenerator -
+func NewDocGenerator(seed int64) *DocGenerator {
+	return &DocGenerator{seed: seed, BodyWords: 50, Start: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+// ID returns the id of the document i.
+func (g *DocGenerator) ID(i int) string {
+	return "doc-" + strconv.Itoa(i)
+}
+
+// Doc returns the document i with the fields of the Mappings.
+func (g *DocGenerator) Doc(i int) map[string]interface{} {
+	r := rand.New(rand.NewSource(g.seed + int64(i)))
+	tags := make([]string, 1+r.Intn(3))
+	for j := range tags {
+		tags[j] = words[r.Intn(len(words))]
+	}
+	return map[string]interface{}{
+		"id":         g.ID(i),
+		"title":      g.text(r, 3+r.Intn(5)),
+		"body":       g.text(r, g.BodyWords),
+		"tags":       tags,
+		"count":      r.Intn(10000),
+		"price":      float64(r.Intn(100000)) / 100,
+		"created_at": g.Start.Add(time.Duration(r.Int63n(int64(30 * 24 * time.Hour)))).Format(time.RFC3339),
+	}
+}
+
+func (g *DocGenerator) text(r *rand.Rand, n int) string {
+	var b strings.Builder
+	for j := 0; j < n; j++ {
+		if j > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(words[r.Intn(len(words))])
+	}
+	return b.String()
+}
+
+// Word returns a random word of the documents, e.g. for the match queries of the search workloads.
+func (g *DocGenerator) Word(r *rand.Rand) string {
+	return words[r.Intn(len(words))]
+}
+
+// Mappings - the mappings of the generated documents.
+func (g *DocGenerator) Mappings() map[string]interface{} {
+	return map[string]interface{}{
+		"properties": map[string]interface{}{
+			"id":         map[string]interface{}{"type": "keyword"},
+			"title":      map[string]interface{}{"type": "text"},
+			"body":       map[string]interface{}{"type": "text"},
+			"tags":       map[string]interface{}{"type": "keyword"},
+			"count":      map[string]interface{}{"type": "integer"},
+			"price":      map[string]interface{}{"type": "double"},
+			"created_at": map[string]interface{}{"type": "date"},
+		},
+	}
+}
@@ -0,0 +1,322 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nestest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/nf-go/nes"
+)
+
+// EnvUpdate - the environment variable updating the golden files of Golden against the real cluster if it's set.
+const EnvUpdate = "NES_TEST_UPDATE"
+
+// Fixture - a request and its response captured from a cluster, the response is replayed for the request with
+// the same method, path and query.
+type Fixture struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Query  string `json:"query,omitempty"`
+	// Request is the request body kept for the readers of the golden file, it's not matched.
+	Request string `json:"request,omitempty"`
+	Status  int    `json:"status"`
+	// Response is the JSON response body, or the JSON string of the text response body if Text is true.
+	Response json.RawMessage `json:"response,omitempty"`
+	Text     bool            `json:"text,omitempty"`
+}
+
+// DefaultScrubbedFields - the volatile fields of the responses replaced by Scrub with the stable values.
+var DefaultScrubbedFields = map[string]interface{}{
+	"took":         0,
+	"_node":        "node",
+	"node":         "node",
+	"node_id":      "node",
+	"_scroll_id":   "scroll",
+	"pit_id":       "pit",
+	"cluster_uuid": "cluster",
+}
+
+// Scrub replaces the scalar values of the fields at any depth of the JSON body except the _source of the documents,
+// the objects and the arrays of the fields like the node of the allocation explanations are scrubbed recursively
+// instead. The body is returned as it is if it's not JSON.
+func Scrub(body []byte, fields map[string]interface{}) []byte {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return body
+	}
+	scrub(v, fields)
+	b, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return b
+}
+
+func scrub(v interface{}, fields map[string]interface{}) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if k == "_source" {
+				continue
+			}
+			if stable, ok := fields[k]; ok && isScalar(item) {
+				val[k] = stable
+				continue
+			}
+			scrub(item, fields)
+		}
+	case []interface{}:
+		for _, item := range val {
+			scrub(item, fields)
+		}
+	}
+}
+
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return true
+}
+
+// Capturer - the transport capturing the requests sent by the base transport and their scrubbed responses.
+type Capturer struct {
+	base   http.RoundTripper
+	fields map[string]interface{}
+
+	mu       sync.Mutex
+	fixtures []*Fixture
+}
+
+// NewCapturer - the capturer of the requests sent by the base, http.DefaultTransport if it's nil, the fields
+// are scrubbed from the responses, DefaultScrubbedFields if it's nil.
+func NewCapturer(base http.RoundTripper, fields map[string]interface{}) *Capturer {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if fields == nil {
+		fields = DefaultScrubbedFields
+	}
+	return &Capturer{base: base, fields: fields}
+}
+
+// RoundTrip -
+func (c *Capturer) RoundTrip(req *http.Request) (*http.Response, error) {
+	f := &Fixture{Method: req.Method, Path: req.URL.Path, Query: req.URL.RawQuery}
+	if req.Body != nil && req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		f.Request = string(body)
+	}
+	resp, err := c.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	f.Status = resp.StatusCode
+	if len(body) > 0 {
+		if json.Valid(body) {
+			f.Response = Scrub(body, c.fields)
+		} else {
+			f.Response, _ = json.Marshal(string(body))
+			f.Text = true
+		}
+	}
+	c.mu.Lock()
+	c.fixtures = append(c.fixtures, f)
+	c.mu.Unlock()
+	return resp, nil
+}
+
+// Fixtures returns the fixtures captured in order.
+func (c *Capturer) Fixtures() []*Fixture {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*Fixture(nil), c.fixtures...)
+}
+
+// WriteFile writes the fixtures captured into the golden file, the directories are created if they are missing.
+func (c *Capturer) WriteFile(path string) error {
+	b, err := json.MarshalIndent(c.Fixtures(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// LoadFixtures reads the fixtures of the golden file written by the Capturer.
+func LoadFixtures(path string) ([]*Fixture, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixtures []*Fixture
+	if err := json.Unmarshal(b, &fixtures); err != nil {
+		return nil, fmt.Errorf("nestest: invalid golden file %s: %w", path, err)
+	}
+	return fixtures, nil
+}
+
+// StubTransport - the transport replaying the fixtures, each fixture is replayed once in order among the ones
+// matching the request, and the requests without a fixture fail.
+type StubTransport struct {
+	mu       sync.Mutex
+	fixtures []*Fixture
+	used     []bool
+}
+
+// NewStubTransport -
+func NewStubTransport(fixtures []*Fixture) *StubTransport {
+	return &StubTransport{fixtures: fixtures, used: make([]bool, len(fixtures))}
+}
+
+// RoundTrip -
+func (s *StubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_, _ = io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, f := range s.fixtures {
+		if s.used[i] || f.Method != req.Method || f.Path != req.URL.Path || f.Query != req.URL.RawQuery {
+			continue
+		}
+		s.used[i] = true
+		return f.response(req)
+	}
+	return nil, fmt.Errorf("nestest: no fixture of %s %s?%s", req.Method, req.URL.Path, req.URL.RawQuery)
+}
+
+// Unused returns the fixtures not replayed yet, e.g. to check all the requests expected are sent.
+func (s *StubTransport) Unused() []*Fixture {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var unused []*Fixture
+	for i, f := range s.fixtures {
+		if !s.used[i] {
+			unused = append(unused, f)
+		}
+	}
+	return unused
+}
+
+func (f *Fixture) response(req *http.Request) (*http.Response, error) {
+	body := []byte(f.Response)
+	header := http.Header{}
+	// the product header is checked by the client
+	header.Set("X-Elastic-Product", "Elasticsearch")
+	if f.Text {
+		var text string
+		if err := json.Unmarshal(f.Response, &text); err != nil {
+			return nil, err
+		}
+		body = []byte(text)
+		header.Set("Content-Type", "text/plain; charset=UTF-8")
+	} else {
+		header.Set("Content-Type", "application/json")
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Status, http.StatusText(f.Status)),
+		StatusCode:    f.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// NewStubClient - the client replaying the fixtures by the StubTransport.
+func NewStubClient(fixtures []*Fixture, opts ...nes.ESClientOption) (*nes.Client, *StubTransport, error) {
+	stub := NewStubTransport(fixtures)
+	opts = append(opts, nes.WithBaseTransport(stub))
+	client, err := nes.NewESClient(&nes.ESConfig{Addrs: []string{"http://nestest.invalid:9200"}}, opts...)
+	return client, stub, err
+}
+
+// Golden returns the client replaying the golden file, or the client of StartElasticsearch capturing the golden file
+// when the test ends if EnvUpdate is set. The test fails if a fixture of the golden file is not replayed.
+// The requests must be the same in both modes, so the indices of the test are named fixed unlike IndexName.
+//
+//	client := nestest.Golden(t, "testdata/search.golden.json")
+//	oper := nes.NewESOper(client)
+func Golden(t testing.TB, path string, opts ...Option) *nes.Client {
+	t.Helper()
+	if os.Getenv(EnvUpdate) == "" {
+		fixtures, err := LoadFixtures(path)
+		if err != nil {
+			t.Fatalf("nestest: fail to load the golden file, set %s to capture it: %s", EnvUpdate, err)
+		}
+		client, stub, err := NewStubClient(fixtures)
+		if err != nil {
+			t.Fatalf("nestest: fail to create the stub client: %s", err)
+		}
+		t.Cleanup(func() {
+			if unused := stub.Unused(); len(unused) > 0 && !t.Failed() {
+				t.Errorf("nestest: %d fixtures of %s are not replayed, the first is %s %s", len(unused), path, unused[0].Method, unused[0].Path)
+			}
+		})
+		return client
+	}
+
+	cluster := StartElasticsearch(t, opts...)
+	capturer := NewCapturer(nil, nil)
+	client, err := nes.NewESClient(&nes.ESConfig{
+		Addrs:    cluster.Addrs,
+		Username: os.Getenv(EnvUsername),
+		Password: os.Getenv(EnvPassword),
+	}, nes.WithBaseTransport(capturer))
+	if err != nil {
+		t.Fatalf("nestest: fail to create the client: %s", err)
+	}
+	t.Cleanup(func() {
+		if t.Failed() {
+			return
+		}
+		if err := capturer.WriteFile(path); err != nil {
+			t.Errorf("nestest: fail to write the golden file %s: %s", path, err)
+		}
+	})
+	return client
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nestest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/nf-go/nes"
+)
+
+type goldenDoc struct {
+	Title string `json:"title"`
+	Tag   string `json:"tag"`
+	Node  string `json:"node,omitempty"`
+}
+
+// TestGoldenReplay replays testdata/search.golden.json, set NES_TEST_UPDATE to capture it again.
+func TestGoldenReplay(t *testing.T) {
+	const index = "nestest-golden"
+	oper := nes.NewESOper(Golden(t, "testdata/search.golden.json"))
+	ctx := context.Background()
+	t.Cleanup(func() {
+		if _, err := oper.ESClient().Indices.Delete([]string{index}); err != nil {
+			t.Error(err)
+		}
+	})
+	for id, doc := range map[string]*goldenDoc{"1": {Title: "nes", Tag: "go", Node: "kept in the source"}, "2": {Title: "nfgo", Tag: "go"}} {
+		if err := oper.Index(ctx, index, id, doc, func(r *nes.IndexRequest) { r.Refresh = "wait_for" }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	query, err := nes.NewSearchBody(nes.TermQuery("tag", "go")).JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := oper.Count(ctx, query, []string{index})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("the count is %d, expected 2", count)
+	}
+	hits, err := nes.SearchRaw(ctx, oper, query, []string{index})
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles := map[string]bool{}
+	for _, h := range hits {
+		doc := &goldenDoc{}
+		if err := json.Unmarshal(h.Source, doc); err != nil {
+			t.Fatal(err)
+		}
+		titles[doc.Title] = true
+	}
+	if len(titles) != 2 || !titles["nes"] || !titles["nfgo"] {
+		t.Errorf("the titles are %v, expected nes and nfgo", titles)
+	}
+	if _, err := oper.Get(ctx, &goldenDoc{}, index, "3"); !nes.IsNotFound(err) {
+		t.Errorf("the error is %v, expected not found", err)
+	}
+}
+
+func TestScrubKeepsObjects(t *testing.T) {
+	body := []byte(`{"took":12,"node":"abc","current_node":{"id":"x","name":"n1"},"nodes":[{"node":"def"}],` +
+		`"allocate_explanation":{"node":{"node_id":"y"}},"hits":{"hits":[{"_source":{"took":"kept","node":"kept"}}]}}`)
+	expected := `{"took":0,"node":"node","current_node":{"id":"x","name":"n1"},"nodes":[{"node":"node"}],` +
+		`"allocate_explanation":{"node":{"node_id":"node"}},"hits":{"hits":[{"_source":{"took":"kept","node":"kept"}}]}}`
+	var got, want interface{}
+	if err := json.Unmarshal(Scrub(body, DefaultScrubbedFields), &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		t.Fatal(err)
+	}
+	gotJSON, _ := json.Marshal(got)
+	wantJSON, _ := json.Marshal(want)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("got %s, expected %s", gotJSON, wantJSON)
+	}
+}
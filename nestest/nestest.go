@@ -0,0 +1,246 @@
+// Copyright 2020 The nfgo Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nestest boots an Elasticsearch container for the integration tests of the apps using nes.
+//
+//	func TestSearch(t *testing.T) {
+//		cluster := nestest.StartElasticsearch(t)
+//		index := cluster.CreateIndex(t, mappings)
+//		...
+//	}
+//
+// The cluster of NES_TEST_ADDRS is used instead of a container if it's set, the tests are skipped if neither
+// the cluster nor the docker CLI is available. The container is started with the docker CLI, so nestest has no
+// dependency on the docker client libraries.
+package nestest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nf-go/nes"
+)
+
+const (
+	// EnvAddrs - the environment variable of the comma separated addresses of an existing cluster.
+	EnvAddrs = "NES_TEST_ADDRS"
+	// EnvUsername - the environment variable of the username of the existing cluster.
+	EnvUsername = "NES_TEST_USERNAME"
+	// EnvPassword - the environment variable of the password of the existing cluster.
+	EnvPassword = "NES_TEST_PASSWORD"
+	// EnvImage - the environment variable of the image of the container, DefaultImage by default.
+	EnvImage = "NES_TEST_IMAGE"
+
+	// DefaultImage - the image of the container.
+	DefaultImage = "docker.elastic.co/elasticsearch/elasticsearch:8.13.4"
+	// DefaultStartTimeout - the timeout of the cluster becoming yellow.
+	DefaultStartTimeout = 3 * time.Minute
+)
+
+// Cluster - the cluster of the tests.
+type Cluster struct {
+	Addrs  []string
+	Client *nes.Client
+	Oper   nes.ESOper
+	Admin  nes.ESAdminOper
+	// ContainerID is the id of the container started, empty if the cluster of EnvAddrs is used.
+	ContainerID string
+}
+
+// Option - the option of StartElasticsearch.
+type Option func(*options)
+
+type options struct {
+	image    string
+	timeout  time.Duration
+	env      map[string]string
+	operOpts []nes.ESOperOption
+}
+
+// WithImage - the image of the container, which takes precedence over EnvImage.
+func WithImage(image string) Option {
+	return func(o *options) {
+		o.image = image
+	}
+}
+
+// WithStartTimeout - the timeout of the cluster becoming yellow, DefaultStartTimeout by default.
+func WithStartTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithEnv - the environment variable of the container like the settings of Elasticsearch.
+func WithEnv(key string, value string) Option {
+	return func(o *options) {
+		o.env[key] = value
+	}
+}
+
+// WithOperOptions - the options of the Oper of the cluster.
+func WithOperOptions(opts ...nes.ESOperOption) Option {
+	return func(o *options) {
+		o.operOpts = append(o.operOpts, opts...)
+	}
+}
+
+// StartElasticsearch returns the cluster of EnvAddrs, or starts a single-node container without the security,
+// which is removed when the test ends. The cluster is yellow when it's returned.
+func StartElasticsearch(t testing.TB, opts ...Option) *Cluster {
+	t.Helper()
+	o := &options{
+		image:   os.Getenv(EnvImage),
+		timeout: DefaultStartTimeout,
+		env: map[string]string{
+			"discovery.type":         "single-node",
+			"xpack.security.enabled": "false",
+			"ES_JAVA_OPTS":           "-Xms512m -Xmx512m",
+		},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.image == "" {
+		o.image = DefaultImage
+	}
+
+	cluster := &Cluster{}
+	config := &nes.ESConfig{Username: os.Getenv(EnvUsername), Password: os.Getenv(EnvPassword)}
+	if addrs := os.Getenv(EnvAddrs); addrs != "" {
+		cluster.Addrs = strings.Split(addrs, ",")
+	} else {
+		cluster.ContainerID, cluster.Addrs = startContainer(t, o)
+	}
+	config.Addrs = cluster.Addrs
+	client, err := nes.NewESClient(config)
+	if err != nil {
+		t.Fatalf("nestest: fail to create the client: %s", err)
+	}
+	cluster.Client = client
+	cluster.Oper = nes.NewESOper(client, o.operOpts...)
+	cluster.Admin = nes.NewESAdminOper(client)
+	if err := waitForYellow(client, o.timeout); err != nil {
+		t.Fatalf("nestest: the cluster %s is not ready: %s", strings.Join(cluster.Addrs, ","), err)
+	}
+	return cluster
+}
+
+func startContainer(t testing.TB, o *options) (string, []string) {
+	t.Helper()
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skipf("nestest: neither %s nor the docker CLI is available", EnvAddrs)
+	}
+	args := []string{"run", "-d", "--rm", "-p", "127.0.0.1::9200"}
+	for k, v := range o.env {
+		args = append(args, "-e", k+"="+v)
+	}
+	args = append(args, o.image)
+	out, err := exec.Command("docker", args...).Output()
+	if err != nil {
+		t.Fatalf("nestest: fail to start the container of %s: %s", o.image, commandError(err))
+	}
+	id := strings.TrimSpace(string(out))
+	t.Cleanup(func() {
+		if err := exec.Command("docker", "rm", "-f", id).Run(); err != nil {
+			t.Logf("nestest: fail to remove the container %s: %s", id, commandError(err))
+		}
+	})
+	out, err = exec.Command("docker", "port", id, "9200/tcp").Output()
+	if err != nil {
+		t.Fatalf("nestest: fail to get the port of the container %s: %s", id, commandError(err))
+	}
+	// the first line is the address of the port like 127.0.0.1:49153
+	addr := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+	return id, []string{"http://" + addr}
+}
+
+func commandError(err error) string {
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return strings.TrimSpace(string(exitErr.Stderr))
+	}
+	return err.Error()
+}
+
+func waitForYellow(client *nes.Client, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var lastErr error
+	for {
+		resp, err := client.Cluster.Health(
+			client.Cluster.Health.WithContext(ctx),
+			client.Cluster.Health.WithWaitForStatus("yellow"),
+			client.Cluster.Health.WithTimeout(5*time.Second),
+		)
+		if err == nil {
+			resp.Body.Close()
+			if !resp.IsError() {
+				return nil
+			}
+			err = fmt.Errorf("the health is %s", resp.Status())
+		}
+		lastErr = err
+		select {
+		case <-ctx.Done():
+			return lastErr
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+var unsafeIndexChars = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// IndexName returns a unique name of the throwaway index of the test.
+func IndexName(t testing.TB) string {
+	name := unsafeIndexChars.ReplaceAllString(strings.ToLower(t.Name()), "-")
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	return fmt.Sprintf("nestest-%s-%d", strings.Trim(name, "-"), rand.Int63())
+}
+
+// CreateIndex creates a throwaway index with the mappings deleted when the test ends, and returns its name.
+func (c *Cluster) CreateIndex(t testing.TB, mappings interface{}) string {
+	t.Helper()
+	index := IndexName(t)
+	shards, replicas := 1, 0
+	body := &nes.CreateIndexBody{
+		Settings: &nes.IndexSettings{NumberOfShards: &shards, NumberOfReplicas: &replicas},
+		Mappings: mappings,
+	}
+	if err := c.Admin.CreateIndex(context.Background(), index, body); err != nil {
+		t.Fatalf("nestest: fail to create the index %s: %s", index, err)
+	}
+	t.Cleanup(func() {
+		if err := c.Admin.DeleteIndex(context.Background(), []string{index}); err != nil && !nes.IsNotFound(err) {
+			t.Logf("nestest: fail to delete the index %s: %s", index, err)
+		}
+	})
+	return index
+}
+
+// Refresh refreshes the indexes, so the documents written are searchable.
+func (c *Cluster) Refresh(t testing.TB, indexes ...string) {
+	t.Helper()
+	if err := c.Admin.Refresh(context.Background(), indexes); err != nil {
+		t.Fatalf("nestest: fail to refresh %s: %s", strings.Join(indexes, ","), err)
+	}
+}